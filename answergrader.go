@@ -0,0 +1,123 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// GradingConfig configures how players' typed answers to free-response questions are graded
+type GradingConfig struct {
+	// Similarity from 0 to 1 at which a short answer counts as a misspelling of
+	// an accepted one; 0 means DefaultFuzzyThreshold
+	FuzzyThreshold float64 `json:"fuzzy_threshold,omitempty"`
+	LLM            bool    `json:"llm"` // Ask the grader stage about answers the rules find borderline
+}
+
+// AnswerGrader grades typed answers by the rules, asking an LLM about borderline ones when enabled
+type AnswerGrader struct {
+	provider LLMProvider // Nil grades by the rules alone
+	config   StageConfig
+	grading  GradingConfig
+}
+
+// NewAnswerGrader creates an answer grader using the given provider, stage
+// config and grading rules; a nil provider grades by the rules alone
+func NewAnswerGrader(provider LLMProvider, config StageConfig, grading GradingConfig) *AnswerGrader {
+	if grading.FuzzyThreshold <= 0 {
+		grading.FuzzyThreshold = DefaultFuzzyThreshold
+	}
+	return &AnswerGrader{
+		provider: provider,
+		config:   config,
+		grading:  grading,
+	}
+}
+
+// Grade grades a typed answer to a free-response question. If the LLM grader
+// fails, the rules' grade is returned along with the error.
+func (ag *AnswerGrader) Grade(ctx context.Context, question *Question, response string, logger *LLMLogger) (Grade, error) {
+	grade := question.gradeResponse(response, ag.grading.FuzzyThreshold)
+	if !grade.Borderline || ag.provider == nil {
+		return grade, nil
+	}
+
+	prompt, err := RenderPrompt(ag.config.Prompt, PromptData{
+		Topic:    question.Topic,
+		Question: question,
+		Response: response,
+	})
+	if err != nil {
+		return grade, err
+	}
+
+	if logger != nil {
+		logger.LogLLMRequest("AnswerGrader", prompt)
+	}
+
+	resp, err := ag.provider.Chat(ctx, ChatRequest{
+		Stage:       "AnswerGrader",
+		Model:       ag.config.Model,
+		Temperature: ag.config.Temperature,
+		MaxTokens:   ag.config.MaxTokens,
+		Messages: []ChatMessage{
+			{
+				Role:    RoleSystem,
+				Content: ag.config.SystemPrompt,
+			},
+			{
+				Role:    RoleUser,
+				Content: prompt,
+			},
+		},
+		Tool: ToolDefinition{
+			Name:        "grade_answer",
+			Description: "Accept or reject a player's answer to a quiz question",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reason": map[string]interface{}{
+						"type":        "string",
+						"description": "Brief reason for the decision",
+					},
+					"correct": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether the answer should be accepted as correct",
+					},
+				},
+				"required": []string{"reason", "correct"},
+			},
+		},
+	})
+	if err != nil {
+		return grade, fmt.Errorf("failed to grade answer: %w", err)
+	}
+
+	if logger != nil {
+		responseText := ""
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
+		}
+		logger.LogLLMResponse("AnswerGrader", responseText)
+	}
+
+	if len(resp.ToolCalls) == 0 {
+		return grade, fmt.Errorf("no tool calls in response")
+	}
+
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "grade_answer" {
+		return grade, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
+	}
+
+	var toolArgs struct {
+		Reason  string `json:"reason"`
+		Correct bool   `json:"correct"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
+		return grade, fmt.Errorf("failed to parse tool arguments: %w", err)
+	}
+
+	VerboseLog("Question %s: grader judged %q (correct %t) - %s", question.ID, response, toolArgs.Correct, toolArgs.Reason)
+	return Grade{Correct: toolArgs.Correct, Reason: toolArgs.Reason}, nil
+}
@@ -0,0 +1,53 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAnswerGraderAsksAboutBorderlineAnswers(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Enqueue("grade_answer", `{"correct": true, "reason": "Everest is the mountain's common name"}`)
+	grader := NewAnswerGrader(env.provider, env.cfg.ResolveStage(env.cfg.Grader, DefaultFastModel), GradingConfig{LLM: true})
+	question := &Question{Type: QuestionShortAnswer, Text: "What is the highest mountain?", AcceptedAnswers: []string{"Mount Everest"}}
+
+	// Answers the rules are sure about never reach the LLM
+	for response, want := range map[string]bool{"mount everest": true, "K2": false} {
+		grade, err := grader.Grade(context.Background(), question, response, nil)
+		if err != nil || grade.Correct != want {
+			t.Errorf("Grade(%q) = %+v (%v), want correct %t", response, grade, err, want)
+		}
+	}
+	if calls := len(env.provider.Requests()); calls != 0 {
+		t.Fatalf("grader made %d LLM calls for clear-cut answers", calls)
+	}
+
+	grade, err := grader.Grade(context.Background(), question, "Everest", nil)
+	if err != nil || !grade.Correct || grade.Reason != "Everest is the mountain's common name" {
+		t.Errorf("Grade of borderline answer = %+v (%v), want the LLM's verdict", grade, err)
+	}
+	if calls := len(env.provider.Requests()); calls != 1 {
+		t.Errorf("grader made %d LLM calls for a borderline answer, want 1", calls)
+	}
+}
+
+func TestAnswerGraderFallsBackToRules(t *testing.T) {
+	question := &Question{Type: QuestionShortAnswer, AcceptedAnswers: []string{"Mount Everest"}}
+
+	// Without a provider borderline answers are graded by the rules alone
+	grade, err := NewAnswerGrader(nil, StageConfig{}, GradingConfig{LLM: true}).Grade(context.Background(), question, "Everest", nil)
+	if err != nil || grade.Correct || !grade.Borderline {
+		t.Errorf("Grade without a provider = %+v (%v), want the rules' borderline grade", grade, err)
+	}
+
+	env := newTestEnv(t)
+	env.provider.Handle("grade_answer", func(req ChatRequest) (string, error) {
+		return "", errors.New("grader unavailable")
+	})
+	grader := NewAnswerGrader(env.provider, env.cfg.ResolveStage(env.cfg.Grader, DefaultFastModel), GradingConfig{LLM: true})
+	grade, err = grader.Grade(context.Background(), question, "Everest", nil)
+	if err == nil || grade.Correct || !grade.Borderline {
+		t.Errorf("Grade with a failing provider = %+v (%v), want the rules' grade and the error", grade, err)
+	}
+}
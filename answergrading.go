@@ -0,0 +1,308 @@
+package quizgenerator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultFuzzyThreshold is the similarity at which a short answer counts as a misspelling of an accepted one
+const DefaultFuzzyThreshold = 0.8
+
+// borderlineMargin is how far below the fuzzy threshold a short answer is still worth a second opinion
+const borderlineMargin = 0.2
+
+// NumericAnswer is the answer key of a numeric question
+type NumericAnswer struct {
+	Value     float64 `json:"value"`
+	Tolerance float64 `json:"tolerance,omitempty"` // Largest accepted difference from Value, in Unit
+	Unit      string  `json:"unit,omitempty"`      // Empty for plain numbers such as years or counts
+}
+
+// String formats the answer with its unit, e.g. "8849 m"
+func (n *NumericAnswer) String() string {
+	value := strconv.FormatFloat(n.Value, 'f', -1, 64)
+	if n.Unit == "" {
+		return value
+	}
+	if n.Unit == "%" {
+		return value + "%"
+	}
+	return value + " " + n.Unit
+}
+
+// Range describes the accepted answers, e.g. "8849 m (8839 to 8859 accepted)"
+func (n *NumericAnswer) Range() string {
+	if n.Tolerance <= 0 {
+		return n.String()
+	}
+	return fmt.Sprintf("%s (%s to %s accepted)", n.String(),
+		strconv.FormatFloat(n.Value-n.Tolerance, 'f', -1, 64), strconv.FormatFloat(n.Value+n.Tolerance, 'f', -1, 64))
+}
+
+// Grade is the outcome of grading a typed answer to a free-response question
+type Grade struct {
+	Correct    bool
+	Borderline bool   // Close to an accepted answer without matching it, so worth asking the LLM grader
+	Reason     string // How the answer was judged
+}
+
+// Score returns 1 for a correct answer and 0 otherwise
+func (g Grade) Score() float64 {
+	if g.Correct {
+		return 1
+	}
+	return 0
+}
+
+// GradeResponse grades a typed answer to a short-answer or numeric question
+// by the rules alone, with the default fuzzy threshold
+func (q *Question) GradeResponse(response string) Grade {
+	return q.gradeResponse(response, DefaultFuzzyThreshold)
+}
+
+func (q *Question) gradeResponse(response string, threshold float64) Grade {
+	if strings.TrimSpace(response) == "" {
+		return Grade{Reason: "no answer"}
+	}
+	switch q.Kind() {
+	case QuestionShortAnswer:
+		return gradeShortAnswer(response, q.AcceptedAnswers, threshold)
+	case QuestionNumeric:
+		if q.Numeric == nil {
+			return Grade{Reason: "question has no numeric answer"}
+		}
+		return gradeNumeric(response, q.Numeric)
+	}
+	return Grade{Reason: fmt.Sprintf("%s questions are not answered by typing", q.Kind())}
+}
+
+// gradeShortAnswer accepts a response that matches an accepted answer once
+// case, punctuation and leading articles are ignored, or that is a close
+// enough misspelling of one. Responses a little further off, or that contain
+// an accepted answer among other words, are borderline.
+func gradeShortAnswer(response string, accepted []string, threshold float64) Grade {
+	given := normalizeAnswer(response)
+	if given == "" {
+		return Grade{Reason: "matches no accepted answer"}
+	}
+	best, closest := 0.0, ""
+	contains := ""
+	for _, answer := range accepted {
+		want := normalizeAnswer(answer)
+		if want == "" {
+			continue
+		}
+		if given == want {
+			return Grade{Correct: true, Reason: fmt.Sprintf("matches %q", answer)}
+		}
+		// Short answers like "Au" or "1066" have to be exact, as one letter changes their meaning
+		if len([]rune(want)) > 4 {
+			if similarity := stringSimilarity(given, want); similarity > best {
+				best, closest = similarity, answer
+			}
+		}
+		if contains == "" && (containsWord(given, want) || containsWord(want, given)) {
+			contains = answer
+		}
+	}
+
+	switch {
+	case best >= threshold:
+		return Grade{Correct: true, Reason: fmt.Sprintf("close spelling of %q", closest)}
+	case contains != "":
+		return Grade{Borderline: true, Reason: fmt.Sprintf("overlaps %q", contains)}
+	case best >= threshold-borderlineMargin:
+		return Grade{Borderline: true, Reason: fmt.Sprintf("resembles %q", closest)}
+	}
+	return Grade{Reason: "matches no accepted answer"}
+}
+
+var articleRegexp = regexp.MustCompile(`^(the|a|an) `)
+
+// normalizeAnswer lowercases a short answer, turns punctuation into spaces and drops a leading article
+func normalizeAnswer(answer string) string {
+	answer = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		if r == '\'' || r == '’' || r == '.' {
+			return -1 // "O'Brien" and "U.S.A." read the same without them
+		}
+		return ' '
+	}, answer)
+	answer = strings.Join(strings.Fields(answer), " ")
+	return articleRegexp.ReplaceAllString(answer, "")
+}
+
+// stringSimilarity returns one minus the edit distance between a and b relative to the longer one
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+var (
+	numberRegexp    = regexp.MustCompile(`^([-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?)\s*(.*)$`)
+	digitsSeparator = regexp.MustCompile(`(\d),(\d{3})`)
+	scaleWords      = map[string]float64{"thousand": 1e3, "million": 1e6, "billion": 1e9, "trillion": 1e12}
+)
+
+// gradeNumeric accepts a number within the tolerance of the answer. A unit may
+// follow the number; one of the same kind is converted to the answer's unit,
+// and one that can't be compared makes the response borderline. So do
+// responses that aren't a number at all, like "twelve".
+func gradeNumeric(response string, answer *NumericAnswer) Grade {
+	value, unit, ok := parseNumber(response)
+	if !ok {
+		return Grade{Borderline: true, Reason: "not a number"}
+	}
+
+	if unit != "" && answer.Unit != "" && !sameUnit(unit, answer.Unit) {
+		converted, ok := convertUnit(value, unit, answer.Unit)
+		if !ok {
+			return Grade{Borderline: true, Reason: fmt.Sprintf("unit %q can't be compared with %q", unit, answer.Unit)}
+		}
+		value = converted
+	}
+
+	// Allow for floating point error in answers like 0.1 + 0.2
+	diff := math.Abs(value - answer.Value)
+	if diff <= answer.Tolerance+1e-9*math.Max(1, math.Abs(answer.Value)) {
+		if answer.Tolerance == 0 {
+			return Grade{Correct: true, Reason: fmt.Sprintf("matches %s", answer)}
+		}
+		return Grade{Correct: true, Reason: fmt.Sprintf("within %g of %s", answer.Tolerance, answer)}
+	}
+	return Grade{Reason: fmt.Sprintf("off by %g from %s", diff, answer)}
+}
+
+// parseNumber reads a number such as "1,200", "3.5e8" or "2.1 million" and
+// whatever unit follows it
+func parseNumber(response string) (float64, string, bool) {
+	response = strings.TrimSpace(response)
+	response = digitsSeparator.ReplaceAllString(digitsSeparator.ReplaceAllString(response, "$1$2"), "$1$2")
+	match := numberRegexp.FindStringSubmatch(response)
+	if match == nil {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, "", false
+	}
+
+	unit := strings.TrimSpace(match[2])
+	word, rest, _ := strings.Cut(unit, " ")
+	if scale, ok := scaleWords[strings.ToLower(word)]; ok {
+		value *= scale
+		unit = strings.TrimSpace(rest)
+	}
+	return value, strings.TrimRight(unit, "."), true
+}
+
+// unitInfo places a unit in its dimension, scaled to the dimension's base unit
+type unitInfo struct {
+	dimension string
+	scale     float64
+}
+
+// units lists the units answers are converted between, under their common spellings
+var units = map[string]unitInfo{}
+
+func init() {
+	add := func(dimension string, scale float64, names ...string) {
+		for _, name := range names {
+			units[name] = unitInfo{dimension, scale}
+		}
+	}
+	add("length", 1e-3, "mm", "millimeter", "millimetre")
+	add("length", 1e-2, "cm", "centimeter", "centimetre")
+	add("length", 1, "m", "meter", "metre")
+	add("length", 1e3, "km", "kilometer", "kilometre")
+	add("length", 0.0254, "in", "inch", "inches")
+	add("length", 0.3048, "ft", "foot", "feet")
+	add("length", 0.9144, "yd", "yard")
+	add("length", 1609.344, "mi", "mile")
+	add("mass", 1e-3, "g", "gram", "gramme")
+	add("mass", 1, "kg", "kilogram", "kilogramme", "kilo")
+	add("mass", 1e3, "t", "tonne", "metric ton")
+	add("mass", 0.45359237, "lb", "pound")
+	add("mass", 0.028349523125, "oz", "ounce")
+	add("time", 1e-3, "ms", "millisecond")
+	add("time", 1, "s", "sec", "second")
+	add("time", 60, "min", "minute")
+	add("time", 3600, "h", "hr", "hour")
+	add("time", 86400, "day")
+	add("time", 604800, "week")
+	add("time", 31557600, "yr", "year")
+	add("area", 1, "m2", "m²", "square meter", "square metre")
+	add("area", 1e6, "km2", "km²", "square kilometer", "square kilometre")
+	add("area", 2589988.110336, "sq mi", "mi2", "mi²", "square mile")
+	add("speed", 1, "m/s")
+	add("speed", 1/3.6, "km/h", "kph", "kmh")
+	add("speed", 0.44704, "mph")
+	add("percent", 1, "%", "percent", "per cent")
+	// Temperatures only match their own scale, as converting them needs an offset
+	add("celsius", 1, "°c", "c", "celsius", "degree celsius", "degrees celsius")
+	add("fahrenheit", 1, "°f", "f", "fahrenheit", "degree fahrenheit", "degrees fahrenheit")
+}
+
+// lookupUnit finds a unit by name, ignoring case and plural endings
+func lookupUnit(name string) (unitInfo, bool) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if info, ok := units[name]; ok {
+		return info, true
+	}
+	if singular, ok := strings.CutSuffix(name, "s"); ok && len(singular) > 1 {
+		info, ok := units[singular]
+		return info, ok
+	}
+	return unitInfo{}, false
+}
+
+// sameUnit reports whether two unit names mean the same unit
+func sameUnit(a, b string) bool {
+	if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+		return true
+	}
+	ua, okA := lookupUnit(a)
+	ub, okB := lookupUnit(b)
+	return okA && okB && ua == ub
+}
+
+// convertUnit converts value from one unit to another of the same dimension
+func convertUnit(value float64, from, to string) (float64, bool) {
+	uf, okFrom := lookupUnit(from)
+	ut, okTo := lookupUnit(to)
+	if !okFrom || !okTo || uf.dimension != ut.dimension {
+		return 0, false
+	}
+	return value * uf.scale / ut.scale, true
+}
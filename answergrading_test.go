@@ -0,0 +1,94 @@
+package quizgenerator
+
+import "testing"
+
+func TestGradeShortAnswer(t *testing.T) {
+	accepted := []string{"Mount Everest", "Chomolungma"}
+	tests := []struct {
+		response   string
+		correct    bool
+		borderline bool
+	}{
+		{"Mount Everest", true, false},
+		{"mount everest!", true, false},
+		{"the Mount Everest", true, false}, // Leading articles are ignored
+		{"Mount Everst", true, false},      // Close misspelling
+		{"chomolungma", true, false},
+		{"Everest", false, true}, // Part of an accepted answer
+		{"I think it is Mount Everest", false, true},
+		{"Mont Blanc", false, false},
+		{"K2", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		grade := gradeShortAnswer(tt.response, accepted, DefaultFuzzyThreshold)
+		if grade.Correct != tt.correct || grade.Borderline != tt.borderline {
+			t.Errorf("gradeShortAnswer(%q) = %+v, want correct %t, borderline %t", tt.response, grade, tt.correct, tt.borderline)
+		}
+	}
+}
+
+func TestGradeShortAnswerShortAnswersMustBeExact(t *testing.T) {
+	accepted := []string{"Au"}
+	if grade := gradeShortAnswer("au", accepted, DefaultFuzzyThreshold); !grade.Correct {
+		t.Errorf("exact short answer graded %+v", grade)
+	}
+	if grade := gradeShortAnswer("Ag", accepted, DefaultFuzzyThreshold); grade.Correct || grade.Borderline {
+		t.Errorf("one letter off a short answer graded %+v, want wrong", grade)
+	}
+}
+
+func TestGradeNumeric(t *testing.T) {
+	answer := &NumericAnswer{Value: 1500, Tolerance: 10, Unit: "m"}
+	tests := []struct {
+		response   string
+		correct    bool
+		borderline bool
+	}{
+		{"1500", true, false},
+		{"1,505 m", true, false},
+		{"1.5 km", true, false}, // Converted to the answer's unit
+		{"1.5 thousand", true, false},
+		{"1.5e3 metres", true, false},
+		{"1520", false, false},
+		{"1.6 km", false, false},
+		{"1500 kg", false, true}, // Units that can't be compared
+		{"fifteen hundred", false, true},
+	}
+	for _, tt := range tests {
+		grade := gradeNumeric(tt.response, answer)
+		if grade.Correct != tt.correct || grade.Borderline != tt.borderline {
+			t.Errorf("gradeNumeric(%q) = %+v, want correct %t, borderline %t", tt.response, grade, tt.correct, tt.borderline)
+		}
+	}
+}
+
+func TestGradeNumericExact(t *testing.T) {
+	answer := &NumericAnswer{Value: 0.3}
+	if grade := gradeNumeric("0.30000000000000004", answer); !grade.Correct {
+		t.Errorf("floating point error graded %+v, want correct", grade)
+	}
+	if grade := gradeNumeric("0.31", answer); grade.Correct {
+		t.Errorf("0.31 graded correct with no tolerance")
+	}
+}
+
+func TestGradeResponse(t *testing.T) {
+	shortAnswer := &Question{Type: QuestionShortAnswer, AcceptedAnswers: []string{"Paris"}}
+	if grade := shortAnswer.GradeResponse("paris"); grade.Score() != 1 {
+		t.Errorf("short answer scored %v, want 1", grade.Score())
+	}
+	if grade := shortAnswer.GradeResponse("  "); grade.Score() != 0 || grade.Borderline {
+		t.Errorf("blank answer graded %+v", grade)
+	}
+
+	numeric := &Question{Type: QuestionNumeric, Numeric: &NumericAnswer{Value: 42}}
+	if grade := numeric.GradeResponse("42"); grade.Score() != 1 {
+		t.Errorf("numeric answer scored %v, want 1", grade.Score())
+	}
+
+	choice := &Question{Options: []string{"A", "B"}}
+	if grade := choice.GradeResponse("A"); grade.Correct {
+		t.Errorf("typed answer to a single-choice question graded correct")
+	}
+}
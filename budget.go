@@ -0,0 +1,146 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned once a quiz or the global daily budget has been spent
+var ErrBudgetExhausted = errors.New("LLM budget exhausted")
+
+// Budget limits the LLM usage of a single quiz; zero fields are unlimited
+type Budget struct {
+	MaxTokens  int     `json:"max_tokens,omitempty"`
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
+}
+
+// IsZero reports whether the budget has no limits
+func (b Budget) IsZero() bool {
+	return b.MaxTokens <= 0 && b.MaxCostUSD <= 0
+}
+
+// Exceeded reports whether the given usage has reached the budget
+func (b Budget) Exceeded(usage UsageSummary) bool {
+	if b.MaxTokens > 0 && usage.TotalTokens() >= b.MaxTokens {
+		return true
+	}
+	if b.MaxCostUSD > 0 && usage.CostUSD >= b.MaxCostUSD {
+		return true
+	}
+	return false
+}
+
+// DailyBudget caps the combined cost of all quizzes generated in a calendar day.
+// Quizzes in progress count through their attached usage trackers.
+type DailyBudget struct {
+	mu         sync.Mutex
+	maxCostUSD float64
+	day        string
+	committed  float64                   // Cost of finished quizzes today
+	live       map[*UsageTracker]float64 // Attached trackers and their cost at attach time
+}
+
+// NewDailyBudget creates a daily budget; spentToday is the cost already incurred today
+func NewDailyBudget(maxCostUSD, spentToday float64) *DailyBudget {
+	return &DailyBudget{
+		maxCostUSD: maxCostUSD,
+		day:        today(),
+		committed:  spentToday,
+		live:       make(map[*UsageTracker]float64),
+	}
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// Attach starts counting a tracker's new usage towards today's spend
+func (d *DailyBudget) Attach(tracker *UsageTracker) {
+	cost := tracker.Summary().CostUSD
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.live[tracker] = cost
+}
+
+// Detach commits a tracker's usage since Attach and stops following it
+func (d *DailyBudget) Detach(tracker *UsageTracker) {
+	cost := tracker.Summary().CostUSD
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if baseline, ok := d.live[tracker]; ok {
+		d.rollover()
+		d.committed += cost - baseline
+		delete(d.live, tracker)
+	}
+}
+
+// Spent returns today's spend including quizzes in progress
+func (d *DailyBudget) Spent() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rollover()
+
+	spent := d.committed
+	for tracker, baseline := range d.live {
+		spent += tracker.Summary().CostUSD - baseline
+	}
+	return spent
+}
+
+// Exceeded reports whether today's spend has reached the limit
+func (d *DailyBudget) Exceeded() bool {
+	return d.maxCostUSD > 0 && d.Spent() >= d.maxCostUSD
+}
+
+// rollover resets the spend when the day changes, so attached trackers only
+// count their usage from then on; callers hold d.mu
+func (d *DailyBudget) rollover() {
+	if day := today(); day != d.day {
+		d.day = day
+		d.committed = 0
+		for tracker := range d.live {
+			d.live[tracker] = tracker.Summary().CostUSD
+		}
+	}
+}
+
+// NewDailyBudgetProvider wraps inner so its calls are recorded in tracker,
+// which counts towards the daily budget for as long as the provider is used,
+// and refuses calls with ErrBudgetExhausted once the budget is spent. It is
+// for LLM calls made outside quiz generation, such as grading answers.
+func NewDailyBudgetProvider(inner LLMProvider, tracker *UsageTracker, daily *DailyBudget) LLMProvider {
+	daily.Attach(tracker)
+	return &budgetProvider{
+		inner: NewUsageProvider(inner, tracker),
+		check: func() error {
+			if daily.Exceeded() {
+				return ErrBudgetExhausted
+			}
+			return nil
+		},
+	}
+}
+
+// budgetProvider refuses to make calls once check reports the budget is spent
+type budgetProvider struct {
+	inner LLMProvider
+	check func() error
+}
+
+func (bp *budgetProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := bp.check(); err != nil {
+		return nil, err
+	}
+	return bp.inner.Chat(ctx, req)
+}
+
+func (bp *budgetProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	if err := bp.check(); err != nil {
+		return nil, err
+	}
+	return embed(ctx, bp.inner, req)
+}
@@ -0,0 +1,82 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBudgetExceeded(t *testing.T) {
+	usage := UsageSummary{PromptTokens: 800, CompletionTokens: 200, CostUSD: 0.05}
+	tests := []struct {
+		budget   Budget
+		exceeded bool
+	}{
+		{Budget{}, false},
+		{Budget{MaxTokens: 1001}, false},
+		{Budget{MaxTokens: 1000}, true},
+		{Budget{MaxCostUSD: 0.10}, false},
+		{Budget{MaxCostUSD: 0.05}, true},
+		{Budget{MaxTokens: 5000, MaxCostUSD: 0.01}, true},
+	}
+	for _, tt := range tests {
+		if exceeded := tt.budget.Exceeded(usage); exceeded != tt.exceeded {
+			t.Errorf("%+v.Exceeded() = %t, want %t", tt.budget, exceeded, tt.exceeded)
+		}
+	}
+}
+
+func TestDailyBudget(t *testing.T) {
+	prices := map[string]ModelPrice{"gpt": {PromptPerMillion: 1000000}} // $1 per prompt token
+	daily := NewDailyBudget(5, 1)
+
+	first := NewUsageTracker(prices)
+	first.Record("QuestionMaker", "gpt", Usage{PromptTokens: 1})
+	// Usage from before the tracker was attached isn't counted
+	daily.Attach(first)
+	first.Record("QuestionMaker", "gpt", Usage{PromptTokens: 2})
+	second := NewUsageTracker(prices)
+	daily.Attach(second)
+	second.Record("QuestionMaker", "gpt", Usage{PromptTokens: 1})
+
+	if spent := daily.Spent(); spent != 4 || daily.Exceeded() {
+		t.Fatalf("spent $%v with quizzes in progress, want $4 and not exceeded", spent)
+	}
+
+	daily.Detach(first)
+	first.Record("QuestionMaker", "gpt", Usage{PromptTokens: 10})
+	if spent := daily.Spent(); spent != 4 {
+		t.Errorf("spent $%v after detaching, want the detached tracker's later usage ignored", spent)
+	}
+	second.Record("QuestionMaker", "gpt", Usage{PromptTokens: 1})
+	if !daily.Exceeded() {
+		t.Errorf("spent $%v of $5 without exceeding the budget", daily.Spent())
+	}
+}
+
+func TestDailyBudgetProvider(t *testing.T) {
+	prices := map[string]ModelPrice{DefaultModel: {PromptPerMillion: 1000000}} // $1 per prompt token
+	daily := NewDailyBudget(5, 4)
+	tracker := NewUsageTracker(prices)
+	provider := NewDailyBudgetProvider(NewFakeProvider(), tracker, daily)
+	req := testRequest("grade_answer", "Is this answer right?")
+
+	if _, err := provider.Chat(context.Background(), req); err != nil {
+		t.Fatalf("Chat within the budget failed: %v", err)
+	}
+	if spent := daily.Spent(); spent <= 4 || spent != 4+tracker.Summary().CostUSD {
+		t.Fatalf("spent $%v, want the call's cost added to the $4 already spent", spent)
+	}
+	if _, err := provider.Chat(context.Background(), req); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Chat over the budget error = %v, want ErrBudgetExhausted", err)
+	}
+
+	// A new day starts the spend afresh, ignoring what the tracker used the day before
+	daily.day = "2000-01-01"
+	if spent := daily.Spent(); spent != 0 {
+		t.Errorf("spent $%v on a new day, want nothing", spent)
+	}
+	if _, err := provider.Chat(context.Background(), req); err != nil {
+		t.Errorf("Chat on a new day failed: %v", err)
+	}
+}
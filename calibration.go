@@ -0,0 +1,124 @@
+package quizgenerator
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MinCalibrationAnswers is how many recorded answers a question needs before
+// its measured difficulty is used to build quizzes
+const MinCalibrationAnswers = 5
+
+// Games answers are recorded from
+const (
+	GameSinglePlayer = "single"
+	GameMultiplayer  = "multiplayer"
+)
+
+// difficultyTargets maps the difficulty levels players choose to a target
+// empirical difficulty
+var difficultyTargets = map[string]float64{
+	"easy":   0.25,
+	"medium": 0.5,
+	"hard":   0.75,
+}
+
+// DifficultyTarget returns the empirical difficulty a quiz of the given level should aim for
+func DifficultyTarget(level string) (float64, error) {
+	target, ok := difficultyTargets[strings.ToLower(strings.TrimSpace(level))]
+	if !ok {
+		return 0, fmt.Errorf("unknown difficulty %q", level)
+	}
+	return target, nil
+}
+
+// AnswerOutcome is one player's graded answer to a stored question
+type AnswerOutcome struct {
+	QuestionID string    `json:"question_id"` // DBQuestion.StatsID of the question answered
+	QuizID     string    `json:"quiz_id"`     // Quiz the question was played in
+	Selected   []int     `json:"selected"`    // Options chosen; empty for typed and arranged answers
+	Score      float64   `json:"score"`       // Credit earned, from 0 to 1
+	Game       string    `json:"game"`        // GameSinglePlayer or GameMultiplayer
+	Time       time.Time `json:"time"`
+}
+
+// QuestionStats summarises the recorded answers to a question
+type QuestionStats struct {
+	QuestionID   string  `json:"question_id"`
+	Answers      int     `json:"answers"`
+	Correct      int     `json:"correct"`       // Answers earning full credit
+	TotalScore   float64 `json:"total_score"`   // Credit earned across every answer
+	OptionCounts []int   `json:"option_counts"` // Times each option was chosen, by option index
+}
+
+// add counts one answer
+func (stats *QuestionStats) add(selected []int, score float64) {
+	stats.Answers++
+	stats.TotalScore += score
+	if score >= 1 {
+		stats.Correct++
+	}
+	for _, option := range selected {
+		if option < 0 {
+			continue
+		}
+		for len(stats.OptionCounts) <= option {
+			stats.OptionCounts = append(stats.OptionCounts, 0)
+		}
+		stats.OptionCounts[option]++
+	}
+}
+
+// PercentCorrect returns the percentage of answers that earned full credit
+func (stats QuestionStats) PercentCorrect() float64 {
+	if stats.Answers == 0 {
+		return 0
+	}
+	return 100 * float64(stats.Correct) / float64(stats.Answers)
+}
+
+// SelectionRate returns the fraction of answers that chose the given option;
+// for a wrong option this is how well it works as a distractor
+func (stats QuestionStats) SelectionRate(option int) float64 {
+	if stats.Answers == 0 || option < 0 || option >= len(stats.OptionCounts) {
+		return 0
+	}
+	return float64(stats.OptionCounts[option]) / float64(stats.Answers)
+}
+
+// Difficulty returns the question's empirical difficulty, from 0 when every
+// player earns full credit to 1 when none earns any. It is smoothed towards
+// 0.5 so a question answered only a few times doesn't look trivial or impossible.
+func (stats QuestionStats) Difficulty() float64 {
+	return 1 - (stats.TotalScore+1)/float64(stats.Answers+2)
+}
+
+// Calibrated reports whether the question has been answered often enough for its difficulty to be trusted
+func (stats QuestionStats) Calibrated() bool {
+	return stats.Answers >= MinCalibrationAnswers
+}
+
+// CalibratedQuestion is a candidate for a quiz built to a target difficulty
+type CalibratedQuestion struct {
+	QuizID      string
+	QuestionNum int
+	Stats       QuestionStats
+}
+
+// SelectByDifficulty picks the n questions whose difficulty is closest to the
+// target and returns them easiest first, so the quiz builds up
+func SelectByDifficulty(candidates []CalibratedQuestion, n int, target float64) []CalibratedQuestion {
+	selected := slices.Clone(candidates)
+	slices.SortStableFunc(selected, func(a, b CalibratedQuestion) int {
+		return cmp.Compare(math.Abs(a.Stats.Difficulty()-target), math.Abs(b.Stats.Difficulty()-target))
+	})
+	selected = selected[:min(n, len(selected))]
+	slices.SortStableFunc(selected, func(a, b CalibratedQuestion) int {
+		return cmp.Compare(a.Stats.Difficulty(), b.Stats.Difficulty())
+	})
+	return selected
+}
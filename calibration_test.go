@@ -0,0 +1,73 @@
+package quizgenerator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuestionStats(t *testing.T) {
+	var stats QuestionStats
+	if stats.Difficulty() != 0.5 || stats.PercentCorrect() != 0 || stats.SelectionRate(0) != 0 {
+		t.Errorf("unanswered question has difficulty %v, %v%% correct and option 1 chosen %v", stats.Difficulty(), stats.PercentCorrect(), stats.SelectionRate(0))
+	}
+
+	stats.add([]int{0}, 1)
+	stats.add([]int{0}, 1)
+	stats.add([]int{2}, 0)
+	stats.add([]int{0, 2, -1}, 0.5)
+
+	if stats.Answers != 4 || stats.Correct != 2 || stats.PercentCorrect() != 50 {
+		t.Errorf("stats = %+v, want 2 of 4 answers correct", stats)
+	}
+	// Two full answers and a half out of four, smoothed towards 0.5
+	if want := 1 - 3.5/6; math.Abs(stats.Difficulty()-want) > 1e-9 {
+		t.Errorf("Difficulty = %v, want %v", stats.Difficulty(), want)
+	}
+	for option, want := range map[int]float64{0: 0.75, 1: 0, 2: 0.5, 5: 0, -1: 0} {
+		if got := stats.SelectionRate(option); got != want {
+			t.Errorf("SelectionRate(%d) = %v, want %v", option, got, want)
+		}
+	}
+	if stats.Calibrated() {
+		t.Errorf("question answered %d times is calibrated", stats.Answers)
+	}
+	stats.add(nil, 0)
+	if !stats.Calibrated() {
+		t.Errorf("question answered %d times isn't calibrated", stats.Answers)
+	}
+}
+
+func TestSelectByDifficulty(t *testing.T) {
+	// candidate returns a question answered ten times with the given number of full answers
+	candidate := func(num, correct int) CalibratedQuestion {
+		stats := QuestionStats{Answers: 10, Correct: correct, TotalScore: float64(correct)}
+		return CalibratedQuestion{QuizID: "quiz1", QuestionNum: num, Stats: stats}
+	}
+	candidates := []CalibratedQuestion{candidate(1, 10), candidate(2, 1), candidate(3, 6), candidate(4, 4), candidate(5, 8)}
+
+	selected := SelectByDifficulty(candidates, 3, 0.5)
+	var nums []int
+	for _, question := range selected {
+		nums = append(nums, question.QuestionNum)
+	}
+	// Questions 3, 4 and 5 are closest to 0.5, and come easiest first
+	if len(nums) != 3 || nums[0] != 5 || nums[1] != 3 || nums[2] != 4 {
+		t.Errorf("SelectByDifficulty picked questions %v, want [5 3 4]", nums)
+	}
+	if candidates[0].QuestionNum != 1 {
+		t.Errorf("SelectByDifficulty reordered the candidates")
+	}
+
+	if selected := SelectByDifficulty(candidates, 10, 0.5); len(selected) != len(candidates) {
+		t.Errorf("SelectByDifficulty of more questions than candidates picked %d", len(selected))
+	}
+}
+
+func TestDifficultyTarget(t *testing.T) {
+	if target, err := DifficultyTarget(" Hard "); err != nil || target != 0.75 {
+		t.Errorf("DifficultyTarget(hard) = %v (%v)", target, err)
+	}
+	if _, err := DifficultyTarget("impossible"); err == nil {
+		t.Errorf("DifficultyTarget accepted an unknown level")
+	}
+}
@@ -0,0 +1,204 @@
+package quizgenerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+	CassetteStrict = "strict" // Replay, failing on any request that wasn't recorded
+)
+
+// CassetteInteraction is one recorded request/response exchange
+type CassetteInteraction struct {
+	Key      string       `json:"key"`
+	Tool     string       `json:"tool"`
+	Request  ChatRequest  `json:"request"`
+	Response ChatResponse `json:"response"`
+}
+
+// CassetteProvider records real LLM exchanges to a JSON file, or replays them
+// from one without touching the network
+type CassetteProvider struct {
+	mu           sync.Mutex
+	path         string
+	mode         string
+	inner        LLMProvider        // Only used when recording
+	recording    *cassetteRecording // Only used when recording
+	interactions []CassetteInteraction
+	used         []bool
+}
+
+// cassetteRecording is the content of a cassette file being recorded, shared
+// by every recording provider writing to it so none overwrites another's exchanges
+type cassetteRecording struct {
+	mu           sync.Mutex
+	interactions []CassetteInteraction
+}
+
+var (
+	recordingsMu sync.Mutex
+	recordings   = make(map[string]*cassetteRecording)
+)
+
+// NewRecordingProvider wraps inner and adds every exchange to the cassette at
+// path, after any exchanges already recorded there
+func NewRecordingProvider(inner LLMProvider, path string) (*CassetteProvider, error) {
+	recordingsMu.Lock()
+	defer recordingsMu.Unlock()
+
+	recording, ok := recordings[path]
+	if !ok {
+		interactions, err := readCassette(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		recording = &cassetteRecording{interactions: interactions}
+		recordings[path] = recording
+	}
+
+	return &CassetteProvider{
+		path:      path,
+		mode:      CassetteRecord,
+		inner:     inner,
+		recording: recording,
+	}, nil
+}
+
+// NewReplayProvider loads a cassette from path and answers requests from it.
+// A strict provider fails any request that doesn't match a recorded one;
+// otherwise it falls back to the next unused exchange for the same tool.
+func NewReplayProvider(path string, strict bool) (*CassetteProvider, error) {
+	interactions, err := readCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	// Recompute keys so cassettes recorded before question IDs were normalised still match
+	for i := range interactions {
+		interactions[i].Key = cassetteKey(interactions[i].Request)
+	}
+
+	mode := CassetteReplay
+	if strict {
+		mode = CassetteStrict
+	}
+	return &CassetteProvider{
+		path:         path,
+		mode:         mode,
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}, nil
+}
+
+// readCassette reads the interactions recorded in a cassette file
+func readCassette(path string) ([]CassetteInteraction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var interactions []CassetteInteraction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	return interactions, nil
+}
+
+// Chat records or replays a single exchange
+func (cp *CassetteProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if cp.mode == CassetteRecord {
+		return cp.record(ctx, req)
+	}
+	return cp.replay(req)
+}
+
+func (cp *CassetteProvider) record(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := cp.inner.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	cp.recording.mu.Lock()
+	defer cp.recording.mu.Unlock()
+
+	cp.recording.interactions = append(cp.recording.interactions, CassetteInteraction{
+		Key:      cassetteKey(req),
+		Tool:     req.Tool.Name,
+		Request:  req,
+		Response: *resp,
+	})
+
+	// Rewrite the whole file so the cassette is usable even if the run is interrupted
+	data, err := json.MarshalIndent(cp.recording.interactions, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.WriteFile(cp.path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replay prefers an unused interaction with an identical request. Outside
+// strict mode it otherwise falls back to the next unused interaction for the
+// same tool, since prompts can differ from run to run, e.g. in the order the
+// solver is shown the options.
+func (cp *CassetteProvider) replay(req ChatRequest) (*ChatResponse, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	key := cassetteKey(req)
+	match := -1
+	for i, interaction := range cp.interactions {
+		if !cp.used[i] && interaction.Key == key {
+			match = i
+			break
+		}
+	}
+	if match < 0 && cp.mode == CassetteStrict {
+		return nil, &LLMError{Kind: ErrorPermanent, Err: fmt.Errorf("cassette %s has no recording of this %s request", cp.path, req.Tool.Name)}
+	}
+	if match < 0 {
+		for i, interaction := range cp.interactions {
+			if !cp.used[i] && interaction.Tool == req.Tool.Name {
+				match = i
+				break
+			}
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("cassette %s has no unused interaction for tool %s", cp.path, req.Tool.Name)
+	}
+
+	cp.used[match] = true
+	resp := cp.interactions[match].Response
+	return &resp, nil
+}
+
+// questionIDRegexp matches the randomly generated question IDs prompts contain
+var questionIDRegexp = regexp.MustCompile(`\bID: [a-z0-9]{8}\b`)
+
+// cassetteKey identifies a request by a hash of its contents, with question
+// IDs blanked out so the same request matches from run to run
+func cassetteKey(req ChatRequest) string {
+	normalised := req
+	normalised.Messages = make([]ChatMessage, len(req.Messages))
+	for i, message := range req.Messages {
+		message.Content = questionIDRegexp.ReplaceAllString(message.Content, "ID: -")
+		normalised.Messages[i] = message
+	}
+	data, _ := json.Marshal(normalised)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,105 @@
+package quizgenerator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// testRequest is a request for the named tool with a single user message
+func testRequest(tool, content string) ChatRequest {
+	return ChatRequest{
+		Stage:    "Test",
+		Model:    DefaultModel,
+		Tool:     ToolDefinition{Name: tool},
+		Messages: []ChatMessage{{Role: RoleUser, Content: content}},
+	}
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+
+	// Providers recording to the same cassette add to it rather than overwrite each other
+	fp := NewFakeProvider()
+	fp.Enqueue("evaluate_question", `{"action":"accept","reason":"first"}`)
+	fp.Enqueue("evaluate_question", `{"action":"reject","reason":"second"}`)
+	for _, content := range []string{"Check question one", "Check question two"} {
+		recorder, err := NewRecordingProvider(fp, path)
+		if err != nil {
+			t.Fatalf("NewRecordingProvider failed: %v", err)
+		}
+		if _, err := recorder.Chat(ctx, testRequest("evaluate_question", content)); err != nil {
+			t.Fatalf("recording failed: %v", err)
+		}
+	}
+
+	player, err := NewReplayProvider(path, true)
+	if err != nil {
+		t.Fatalf("NewReplayProvider failed: %v", err)
+	}
+	if len(player.interactions) != 2 {
+		t.Fatalf("cassette holds %d interactions, want 2", len(player.interactions))
+	}
+	// Identical requests are matched whatever order they come in
+	for _, tt := range []struct{ content, arguments string }{
+		{"Check question two", `{"action":"reject","reason":"second"}`},
+		{"Check question one", `{"action":"accept","reason":"first"}`},
+	} {
+		resp, err := player.Chat(ctx, testRequest("evaluate_question", tt.content))
+		if err != nil {
+			t.Fatalf("replay failed: %v", err)
+		}
+		if got := resp.ToolCalls[0].Arguments; got != tt.arguments {
+			t.Errorf("replayed %q for %q, want %q", got, tt.content, tt.arguments)
+		}
+	}
+	// Every interaction has been used
+	if _, err := player.Chat(ctx, testRequest("evaluate_question", "Check question one")); err == nil {
+		t.Errorf("replay answered the same interaction twice")
+	}
+}
+
+func TestCassetteStrictReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder, err := NewRecordingProvider(NewFakeProvider(), path)
+	if err != nil {
+		t.Fatalf("NewRecordingProvider failed: %v", err)
+	}
+	if _, err := recorder.Chat(context.Background(), testRequest("evaluate_question", "Check question one")); err != nil {
+		t.Fatalf("recording failed: %v", err)
+	}
+
+	strict, err := NewReplayProvider(path, true)
+	if err != nil {
+		t.Fatalf("NewReplayProvider failed: %v", err)
+	}
+	_, err = strict.Chat(context.Background(), testRequest("evaluate_question", "Check question two"))
+	if !IsPermanentLLMError(err) {
+		t.Errorf("strict replay of an unrecorded request error = %v, want a permanent error", err)
+	}
+
+	// Without strict mode the request falls back to the unused interaction for its tool
+	lenient, err := NewReplayProvider(path, false)
+	if err != nil {
+		t.Fatalf("NewReplayProvider failed: %v", err)
+	}
+	if _, err := lenient.Chat(context.Background(), testRequest("evaluate_question", "Check question two")); err != nil {
+		t.Errorf("lenient replay failed: %v", err)
+	}
+	if _, err := lenient.Chat(context.Background(), testRequest("check_duplicate", "Check question two")); err == nil {
+		t.Errorf("lenient replay answered a tool that was never recorded")
+	}
+}
+
+func TestCassetteKeyIgnoresQuestionIDs(t *testing.T) {
+	a := testRequest("check_duplicate", "ID: ab12cd34\nQuestion: Which volcano?")
+	b := testRequest("check_duplicate", "ID: zz99yy88\nQuestion: Which volcano?")
+	if cassetteKey(a) != cassetteKey(b) {
+		t.Errorf("requests differing only in question IDs have different keys")
+	}
+	c := testRequest("check_duplicate", "ID: ab12cd34\nQuestion: Which mountain?")
+	if cassetteKey(a) == cassetteKey(c) {
+		t.Errorf("different requests have the same key")
+	}
+}
@@ -7,12 +7,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
 	"quizgenerator"
-
-	openai "github.com/sashabaranov/go-openai"
 )
 
 // TopicSuggestion represents a suggested quiz topic
@@ -26,126 +23,90 @@ type TopicSuggestion struct {
 
 // TopicGenerator generates quiz topics using an LLM
 type TopicGenerator struct {
-	client *openai.Client
+	provider quizgenerator.LLMProvider
+	config   quizgenerator.StageConfig
 }
 
-// NewTopicGenerator creates a new topic generator
-func NewTopicGenerator(apiKey string) *TopicGenerator {
+// NewTopicGenerator creates a new topic generator using the given provider and stage config
+func NewTopicGenerator(provider quizgenerator.LLMProvider, config quizgenerator.StageConfig) *TopicGenerator {
 	return &TopicGenerator{
-		client: openai.NewClient(apiKey),
+		provider: provider,
+		config:   config,
 	}
 }
 
 // GenerateFreshTopic generates a single fresh quiz topic that doesn't exist in the database
 func (tg *TopicGenerator) GenerateFreshTopic(ctx context.Context, existingTopics []string, category string) (*TopicSuggestion, error) {
-	var prompt strings.Builder
-
-	prompt.WriteString("Generate ONE interesting quiz topic that would make for engaging multiple choice questions.\n\n")
-
-	if category != "" {
-		prompt.WriteString(fmt.Sprintf("Focus on the category: %s\n\n", category))
+	prompt, err := quizgenerator.RenderPrompt(tg.config.Prompt, quizgenerator.PromptData{
+		Category:       category,
+		ExistingTopics: existingTopics,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	prompt.WriteString("IMPORTANT: The topic must be completely different from these existing topics:\n")
-	for _, topic := range existingTopics {
-		prompt.WriteString(fmt.Sprintf("- %s\n", topic))
-	}
-	prompt.WriteString("\n")
-
-	prompt.WriteString("Requirements:\n")
-	prompt.WriteString("- Topic should be educational and engaging\n")
-	prompt.WriteString("- Topic should be broad enough to generate 10+ questions\n")
-	prompt.WriteString("- Avoid overly specific or niche topics\n")
-	prompt.WriteString("- Must be completely different from the existing topics listed above\n")
-	prompt.WriteString("- Include topics from various fields: science, history, literature, technology, arts, etc.\n\n")
-
-	prompt.WriteString("For the topic, provide:\n")
-	prompt.WriteString("- A clear, concise topic name\n")
-	prompt.WriteString("- A brief description of what the quiz would cover\n")
-	prompt.WriteString("- A category (e.g., Science, History, Technology, Arts, Literature, Geography, etc.)\n")
-	prompt.WriteString("- A suggested difficulty level (easy, medium, or hard)\n")
-	prompt.WriteString("- Source material: Write 3-4 detailed paragraphs about the topic that can be used to generate accurate questions. Include key facts, concepts, historical context, important figures, and interesting details that would make for good multiple choice questions.\n\n")
-
-	prompt.WriteString("Return the topic using the submit_topic tool.")
-
-	resp, err := tg.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4o,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are an expert at creating engaging quiz topics. Generate unique, educational topics that would make for interesting multiple choice quizzes. When writing source material, be comprehensive and include specific details that can be used to create accurate questions.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt.String(),
-				},
+	resp, err := tg.provider.Chat(ctx, quizgenerator.ChatRequest{
+		Stage:       "TopicGenerator",
+		Model:       tg.config.Model,
+		Temperature: tg.config.Temperature,
+		MaxTokens:   tg.config.MaxTokens,
+		Messages: []quizgenerator.ChatMessage{
+			{
+				Role:    quizgenerator.RoleSystem,
+				Content: tg.config.SystemPrompt,
 			},
-			Tools: []openai.Tool{
-				{
-					Type: openai.ToolTypeFunction,
-					Function: &openai.FunctionDefinition{
-						Name:        "submit_topic",
-						Description: "Submit the generated quiz topic",
-						Parameters: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"topic": map[string]interface{}{
-									"type":        "string",
-									"description": "The quiz topic name",
-								},
-								"description": map[string]interface{}{
-									"type":        "string",
-									"description": "Brief description of what the quiz covers",
-								},
-								"category": map[string]interface{}{
-									"type":        "string",
-									"description": "Category of the topic (e.g., Science, History, Technology)",
-								},
-								"difficulty": map[string]interface{}{
-									"type":        "string",
-									"description": "Suggested difficulty level (easy, medium, hard)",
-								},
-								"source_material": map[string]interface{}{
-									"type":        "string",
-									"description": "Detailed source material (3-4 paragraphs) about the topic for generating questions",
-								},
-							},
-							"required": []string{"topic", "description", "category", "difficulty", "source_material"},
-						},
-					},
-				},
+			{
+				Role:    quizgenerator.RoleUser,
+				Content: prompt,
 			},
-			ToolChoice: openai.ToolChoice{
-				Type: openai.ToolTypeFunction,
-				Function: openai.ToolFunction{
-					Name: "submit_topic",
+		},
+		Tool: quizgenerator.ToolDefinition{
+			Name:        "submit_topic",
+			Description: "Submit the generated quiz topic",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"topic": map[string]interface{}{
+						"type":        "string",
+						"description": "The quiz topic name",
+					},
+					"description": map[string]interface{}{
+						"type":        "string",
+						"description": "Brief description of what the quiz covers",
+					},
+					"category": map[string]interface{}{
+						"type":        "string",
+						"description": "Category of the topic (e.g., Science, History, Technology)",
+					},
+					"difficulty": map[string]interface{}{
+						"type":        "string",
+						"description": "Suggested difficulty level (easy, medium, hard)",
+					},
+					"source_material": map[string]interface{}{
+						"type":        "string",
+						"description": "Detailed source material (3-4 paragraphs) about the topic for generating questions",
+					},
 				},
+				"required": []string{"topic", "description", "category", "difficulty", "source_material"},
 			},
 		},
-	)
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate topic: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from LLM")
-	}
-
-	choice := resp.Choices[0]
-	if len(choice.Message.ToolCalls) == 0 {
+	if len(resp.ToolCalls) == 0 {
 		return nil, fmt.Errorf("no tool calls in response")
 	}
 
-	toolCall := choice.Message.ToolCalls[0]
-	if toolCall.Function.Name != "submit_topic" {
-		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Function.Name)
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "submit_topic" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
 	}
 
 	var topic TopicSuggestion
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &topic); err != nil {
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &topic); err != nil {
 		return nil, fmt.Errorf("failed to parse topic: %w", err)
 	}
 
@@ -158,7 +119,13 @@ func main() {
 		numQuestions = flag.Int("questions", 10, "Number of questions per quiz")
 		difficulty   = flag.String("difficulty", "medium", "Default difficulty level")
 		dbPath       = flag.String("db", "./quiz.db", "Database path")
+		configPath   = flag.String("config", "", "JSON config file with provider, per-stage model and prompt settings (or set QUIZ_CONFIG env var)")
 		apiKey       = flag.String("api-key", "", "OpenAI API key (or set OPENAI_API_KEY env var)")
+		baseURL      = flag.String("base-url", "", "OpenAI-compatible API base URL, e.g. for llama.cpp or Ollama (or set OPENAI_BASE_URL env var)")
+		model        = flag.String("model", "", "Model to use for every stage (or set LLM_MODEL env var)")
+		cassette     = flag.String("cassette", "", "Record LLM exchanges to, or replay them from, this file (or set LLM_CASSETTE env var)")
+		cassetteMode = flag.String("cassette-mode", "", "Cassette mode: record, replay, or strict to fail on requests that were not recorded (default replay)")
+		crossQuiz    = flag.String("cross-quiz-dedup", "", "Also reject questions that duplicate other quizzes: all, category or topic (default from config)")
 		verbose      = flag.Bool("verbose", false, "Enable verbose output")
 	)
 
@@ -166,13 +133,40 @@ func main() {
 
 	quizgenerator.SetVerbose(*verbose)
 
-	// Get API key from flag or environment
-	if *apiKey == "" {
-		*apiKey = os.Getenv("OPENAI_API_KEY")
-		if *apiKey == "" {
-			log.Fatal("OpenAI API key is required. Use -api-key flag or set OPENAI_API_KEY environment variable.")
+	// Load config, then let flags override the provider options from the file or environment
+	if *configPath == "" {
+		*configPath = os.Getenv("QUIZ_CONFIG")
+	}
+	cfg, err := quizgenerator.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	providerOpts := &cfg.Provider
+	if *apiKey != "" {
+		providerOpts.APIKey = *apiKey
+	}
+	if *baseURL != "" {
+		providerOpts.BaseURL = *baseURL
+	}
+	if *model != "" {
+		providerOpts.Model = *model
+	}
+	if *cassette != "" {
+		providerOpts.Cassette = *cassette
+	}
+	if *cassetteMode != "" {
+		providerOpts.CassetteMode = *cassetteMode
+	}
+	if *crossQuiz != "" {
+		cfg.CrossQuiz.Enabled = true
+		cfg.CrossQuiz.Scope = *crossQuiz
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("Invalid -cross-quiz-dedup: %v", err)
 		}
 	}
+	if providerOpts.APIKey == "" && providerOpts.NeedsAPIKey() {
+		log.Fatal("OpenAI API key is required. Use -api-key flag or set OPENAI_API_KEY environment variable.")
+	}
 
 	// Initialize database
 	db, err := quizgenerator.OpenDB(*dbPath)
@@ -180,6 +174,7 @@ func main() {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.CloseDB()
+	db.SetConfig(cfg)
 
 	// Create tables if they don't exist
 	if err := db.CreateTables(); err != nil {
@@ -207,7 +202,12 @@ func main() {
 	}
 
 	// Create topic generator
-	topicGen := NewTopicGenerator(*apiKey)
+	provider, err := quizgenerator.NewProvider(cfg.Provider)
+	if err != nil {
+		log.Fatalf("Failed to create LLM provider: %v", err)
+	}
+	provider = quizgenerator.NewRetryProvider(provider, cfg.Retry)
+	topicGen := NewTopicGenerator(provider, cfg.ResolveStage(cfg.Discoverer, quizgenerator.DefaultModel))
 
 	// Generate fresh topic
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
@@ -247,6 +247,7 @@ func main() {
 		Difficulty:     quizDifficulty,
 		CreatedAt:      time.Now(),
 		Status:         "generating",
+		Category:       topic.Category,
 	}
 
 	if err := db.CreateQuiz(quiz); err != nil {
@@ -255,7 +256,12 @@ func main() {
 
 	fmt.Printf("🚀 Quiz created with ID: %s\n", quizID)
 
-	db.GenerateQuiz(quizID, topic.Topic, *numQuestions, topic.SourceMaterial, quizDifficulty)
+	db.GenerateQuiz(quizID, quizgenerator.GenerationRequest{
+		Topic:          topic.Topic,
+		NumQuestions:   *numQuestions,
+		SourceMaterial: topic.SourceMaterial,
+		Difficulty:     quizDifficulty,
+	})
 
 	fmt.Printf("🎉 Successfully completed quiz generation!\n")
 }
@@ -4,13 +4,17 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"quizgenerator"
 )
@@ -20,12 +24,27 @@ func main() {
 		topic          = flag.String("topic", "", "Quiz topic (required)")
 		numQuestions   = flag.Int("questions", 10, "Number of questions to generate")
 		sourceMaterial = flag.String("source", "", "Source material to base questions on")
+		sourceFile     = flag.String("source-file", "", "File to extract source material from: .txt, .md, .html, .epub or .pdf")
 		difficulty     = flag.String("difficulty", "medium", "Difficulty level (easy, medium, hard)")
+		questionTypes  = flag.String("types", "", "Comma-separated question types to mix: single_choice, true_false, multi_select, short_answer, numeric, ordering, matching (default single_choice)")
+		numOptions     = flag.Int("options", 0, "Options per single-choice or multi-select question, 2 to 6 (default 4)")
 		outputFile     = flag.String("output", "", "Output file for quiz JSON (default: stdout)")
+		configPath     = flag.String("config", "", "JSON config file with provider, per-stage model and prompt settings (or set QUIZ_CONFIG env var)")
 		apiKey         = flag.String("api-key", "", "OpenAI API key (or set OPENAI_API_KEY env var)")
+		baseURL        = flag.String("base-url", "", "OpenAI-compatible API base URL, e.g. for llama.cpp or Ollama (or set OPENAI_BASE_URL env var)")
+		model          = flag.String("model", "", "Model to use for every stage (or set LLM_MODEL env var)")
+		cassette       = flag.String("cassette", "", "Record LLM exchanges to, or replay them from, this file (or set LLM_CASSETTE env var)")
+		cassetteMode   = flag.String("cassette-mode", "", "Cassette mode: record, replay, or strict to fail on requests that were not recorded (default replay)")
+		workers        = flag.Int("workers", 0, "Number of questions to check in parallel (default from config)")
+		maxTokens      = flag.Int("max-tokens", 0, "Stop generating once this many LLM tokens have been used (0 = no limit)")
+		maxCost        = flag.Float64("max-cost", 0, "Stop generating once this many US dollars have been spent (0 = no limit)")
+		plan           = flag.Bool("plan", false, "Outline subtopics first and spread the questions across them")
+		verify         = flag.String("verify", "", "Solve accepted questions blind and reject or flag those answered differently: reject or flag")
 		playMode       = flag.Bool("play", false, "Play the quiz interactively")
+		llmGrading     = flag.Bool("llm-grading", false, "In play mode, ask the LLM about typed answers that nearly match")
 		numPlayers     = flag.Int("players", 1, "Number of players for multiplayer mode")
 		verbose        = flag.Bool("verbose", false, "Enable verbose debugging output")
+		events         = flag.Bool("events", false, "Print generation events as JSON lines to stderr")
 	)
 
 	flag.Parse()
@@ -36,16 +55,103 @@ func main() {
 		log.Fatal("Topic is required. Use -topic flag.")
 	}
 
-	// Get API key from flag or environment
-	if *apiKey == "" {
-		*apiKey = os.Getenv("OPENAI_API_KEY")
-		if *apiKey == "" {
-			log.Fatal("OpenAI API key is required. Use -api-key flag or set OPENAI_API_KEY environment variable.")
+	types, err := quizgenerator.ParseQuestionTypes(*questionTypes)
+	if err != nil {
+		log.Fatalf("Invalid -types: %v", err)
+	}
+	if *numOptions != 0 && (*numOptions < quizgenerator.MinOptions || *numOptions > quizgenerator.MaxOptions) {
+		log.Fatalf("Invalid -options: must be between %d and %d", quizgenerator.MinOptions, quizgenerator.MaxOptions)
+	}
+
+	sourceLength := 0
+	if *sourceFile != "" {
+		if *sourceMaterial != "" {
+			log.Fatal("Use either -source or -source-file, not both.")
+		}
+		text, err := quizgenerator.ReadSourceFile(*sourceFile)
+		if err != nil {
+			log.Fatalf("Failed to load source file: %v", err)
+		}
+		*sourceMaterial = text
+		sourceLength = utf8.RuneCountInString(text)
+		if *verbose {
+			log.Printf("Extracted %d characters from %s", sourceLength, *sourceFile)
+		}
+	}
+
+	// Load config, then let flags override the provider options from the file or environment
+	if *configPath == "" {
+		*configPath = os.Getenv("QUIZ_CONFIG")
+	}
+	cfg, err := quizgenerator.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	providerOpts := &cfg.Provider
+	if *apiKey != "" {
+		providerOpts.APIKey = *apiKey
+	}
+	if *baseURL != "" {
+		providerOpts.BaseURL = *baseURL
+	}
+	if *model != "" {
+		providerOpts.Model = *model
+	}
+	if *cassette != "" {
+		providerOpts.Cassette = *cassette
+	}
+	if *cassetteMode != "" {
+		providerOpts.CassetteMode = *cassetteMode
+	}
+	if *workers > 0 {
+		cfg.Workers = *workers
+	}
+	if *plan {
+		cfg.Planning.Enabled = true
+	}
+	if *llmGrading {
+		cfg.Grading.LLM = true
+	}
+	if *verify != "" {
+		cfg.Verification.Enabled = true
+		cfg.Verification.OnMismatch = *verify
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("Invalid -verify: %v", err)
 		}
 	}
 
+	if providerOpts.APIKey == "" && providerOpts.NeedsAPIKey() {
+		log.Fatal("OpenAI API key is required. Use -api-key flag or set OPENAI_API_KEY environment variable.")
+	}
+
 	// Create quiz generator
-	generator := quizgenerator.NewQuizGenerator(*apiKey)
+	generator, err := quizgenerator.NewQuizGenerator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create quiz generator: %v", err)
+	}
+	if *events {
+		encoder := json.NewEncoder(os.Stderr)
+		generator.SetEventHandler(func(event quizgenerator.GenerationEvent) {
+			encoder.Encode(event)
+		})
+	}
+
+	// Images the source file shows are stored so questions can show them too
+	images := quizgenerator.NewImageStore(cfg.ImageDir)
+	var sourceImages []quizgenerator.QuestionImage
+	if *sourceFile != "" {
+		found, err := quizgenerator.ReadSourceImages(*sourceFile)
+		if err != nil {
+			log.Fatalf("Failed to load source file images: %v", err)
+		}
+		sourceImages, err = images.PutSourceImages(found)
+		if err != nil {
+			log.Fatalf("Failed to store source file images: %v", err)
+		}
+		if *verbose && len(sourceImages) > 0 {
+			log.Printf("Stored %d images from %s in %s", len(sourceImages), *sourceFile, cfg.ImageDir)
+		}
+	}
 
 	// Create generation request
 	req := quizgenerator.GenerationRequest{
@@ -53,10 +159,26 @@ func main() {
 		NumQuestions:   *numQuestions,
 		SourceMaterial: *sourceMaterial,
 		Difficulty:     *difficulty,
+		QuestionTypes:  types,
+		NumOptions:     *numOptions,
+		SourceImages:   sourceImages,
+		Budget: quizgenerator.Budget{
+			MaxTokens:  *maxTokens,
+			MaxCostUSD: *maxCost,
+		},
 	}
 
 	if *playMode {
-		playQuiz(generator, req, *numPlayers)
+		var graderProvider quizgenerator.LLMProvider
+		if cfg.Grading.LLM {
+			graderProvider, err = quizgenerator.NewProvider(cfg.Provider)
+			if err != nil {
+				log.Fatalf("Failed to create LLM provider for grading: %v", err)
+			}
+			graderProvider = quizgenerator.NewRetryProvider(graderProvider, cfg.Retry)
+		}
+		grader := quizgenerator.NewAnswerGrader(graderProvider, cfg.ResolveStage(cfg.Grader, quizgenerator.DefaultFastModel), cfg.Grading)
+		playQuiz(generator, grader, images, req, *numPlayers)
 		return
 	}
 
@@ -73,11 +195,21 @@ func main() {
 	defer cancel()
 
 	quiz, err := generator.GenerateQuiz(ctx, req)
-	if err != nil {
+	if errors.Is(err, quizgenerator.ErrInsufficientQuestions) && quiz != nil {
+		// Still write out the questions we did get
+		log.Printf("Warning: %v", err)
+	} else if err != nil {
 		log.Fatalf("Failed to generate quiz: %v", err)
 	}
+	if *sourceFile != "" {
+		quiz.SourceFilename = filepath.Base(*sourceFile)
+		quiz.SourceLength = sourceLength
+	}
 
-	// Output the quiz
+	// Output the quiz with its images embedded, so it doesn't depend on the image directory
+	if err := images.Embed(quiz.Questions); err != nil {
+		log.Fatalf("Failed to embed question images: %v", err)
+	}
 	output, err := json.MarshalIndent(quiz, "", "  ")
 	if err != nil {
 		log.Fatalf("Failed to marshal quiz: %v", err)
@@ -95,17 +227,95 @@ func main() {
 
 	if *verbose {
 		log.Printf("Quiz generation completed successfully!")
+		for _, coverage := range quiz.Coverage {
+			log.Printf("Subtopic %q: %d of %d questions", coverage.Subtopic, coverage.Accepted, coverage.Quota)
+		}
+		log.Printf("Token usage:\n%s", generator.Usage())
 	}
 }
 
 // Player represents a player in the multiplayer quiz
 type Player struct {
 	Name    string
-	Score   int
-	Answers []int // Track answers for each question (0-3 for A-D)
+	Score   float64  // Multi-select, ordering and matching questions earn partial credit
+	Answers []Answer // Track the answer given to each question
+}
+
+// Answer is a player's answer to one question
+type Answer struct {
+	Selected []int  // Options chosen (0 for A, 1 for B, ...), options in order, or the match for each option
+	Response string // Typed answer to a short-answer or numeric question
+}
+
+// printImage tells players where to find the picture shown with a question, if it has one
+func printImage(images *quizgenerator.ImageStore, question *quizgenerator.Question) {
+	if question.Image == nil {
+		return
+	}
+	path, err := images.Path(question.Image.Name)
+	if err != nil {
+		return
+	}
+	if question.Image.Alt != "" {
+		fmt.Printf("🖼️  Image: %s (%s)\n\n", question.Image.Alt, path)
+	} else {
+		fmt.Printf("🖼️  Image: %s\n\n", path)
+	}
 }
 
-func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.GenerationRequest, numPlayers int) {
+// optionLetter labels an option A, B, C...
+func optionLetter(index int) string {
+	return string(rune('A' + index))
+}
+
+// parseAnswer reads option letters such as "B", or "A C" and "A,C" for
+// multi-select questions, and returns their indexes. Ordering questions take
+// every option's letter once, in order, and matching questions the letter of
+// a match for each option in turn.
+func parseAnswer(input string, question *quizgenerator.Question) ([]int, bool) {
+	choices := len(question.Options)
+	if question.Kind() == quizgenerator.QuestionMatching {
+		choices = len(question.Matches)
+	}
+	var letters []int
+	for _, r := range strings.ToUpper(input) {
+		if r == ' ' || r == ',' {
+			continue
+		}
+		index := int(r - 'A')
+		if index < 0 || index >= choices {
+			return nil, false
+		}
+		letters = append(letters, index)
+	}
+
+	switch question.Kind() {
+	case quizgenerator.QuestionOrdering:
+		sorted := slices.Sorted(slices.Values(letters))
+		if len(letters) != len(question.Options) || len(slices.Compact(sorted)) != len(letters) {
+			return nil, false
+		}
+		return letters, true
+	case quizgenerator.QuestionMatching:
+		if len(letters) != len(question.Options) {
+			return nil, false
+		}
+		return letters, true
+	}
+
+	var selected []int
+	for _, index := range letters {
+		if !slices.Contains(selected, index) {
+			selected = append(selected, index)
+		}
+	}
+	if len(selected) == 0 || (len(selected) > 1 && question.Kind() != quizgenerator.QuestionMultiSelect) {
+		return nil, false
+	}
+	return selected, true
+}
+
+func playQuiz(generator *quizgenerator.QuizGenerator, grader *quizgenerator.AnswerGrader, images *quizgenerator.ImageStore, req quizgenerator.GenerationRequest, numPlayers int) {
 	fmt.Printf("🎯 Starting interactive quiz on: %s\n", req.Topic)
 	fmt.Printf("📝 Questions: %d, Difficulty: %s\n", req.NumQuestions, req.Difficulty)
 	fmt.Printf("👥 Players: %d\n", numPlayers)
@@ -129,7 +339,7 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		players[i] = &Player{
 			Name:    name,
 			Score:   0,
-			Answers: make([]int, 0, req.NumQuestions),
+			Answers: make([]Answer, 0, req.NumQuestions),
 		}
 	}
 	fmt.Println()
@@ -155,30 +365,75 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		questionNum++
 		fmt.Printf("Question %d/%d:\n", questionNum, req.NumQuestions)
 		fmt.Printf("%s\n\n", question.Text)
+		printImage(images, question)
 
-		// Display options
-		options := []string{"A", "B", "C", "D"}
-		for i, option := range question.Options {
-			fmt.Printf("%s) %s\n", options[i], option)
-		}
-		fmt.Println()
-
-		// Get answers from all players
-		for _, player := range players {
-			var userAnswer string
-			for {
-				fmt.Printf("%s's answer (A/B/C/D): ", player.Name)
-				scanner.Scan()
-				userAnswer = strings.ToUpper(strings.TrimSpace(scanner.Text()))
-
-				if userAnswer == "A" || userAnswer == "B" || userAnswer == "C" || userAnswer == "D" {
-					break
+		// Typed answers need no options, just any non-blank line
+		if question.FreeResponse() {
+			prompt := "answer"
+			if question.Numeric != nil && question.Numeric.Unit != "" {
+				prompt = fmt.Sprintf("answer (in %s)", question.Numeric.Unit)
+			}
+			for _, player := range players {
+				response := ""
+				for response == "" {
+					fmt.Printf("%s's %s: ", player.Name, prompt)
+					scanner.Scan()
+					response = strings.TrimSpace(scanner.Text())
 				}
-				fmt.Println("Please enter A, B, C, or D")
+				player.Answers = append(player.Answers, Answer{Response: response})
 			}
+		} else {
+			// Display options, or the terms to match numbered and their matches lettered
+			if question.Kind() == quizgenerator.QuestionMatching {
+				for i, option := range question.Options {
+					fmt.Printf("%d) %s\n", i+1, option)
+				}
+				fmt.Println()
+				for i, match := range question.Matches {
+					fmt.Printf("%s) %s\n", optionLetter(i), match)
+				}
+			} else {
+				for i, option := range question.Options {
+					fmt.Printf("%s) %s\n", optionLetter(i), option)
+				}
+			}
+			fmt.Println()
 
-			playerIndex := strings.Index("ABCD", userAnswer)
-			player.Answers = append(player.Answers, playerIndex)
+			// Get answers from all players
+			letters := optionLetter(0) + "-" + optionLetter(len(question.Options)-1)
+			prompt := fmt.Sprintf("answer (%s)", letters)
+			switch question.Kind() {
+			case quizgenerator.QuestionMultiSelect:
+				prompt = fmt.Sprintf("answers, all that apply (e.g. %s%s)", optionLetter(0), optionLetter(len(question.Options)-1))
+			case quizgenerator.QuestionOrdering:
+				prompt = fmt.Sprintf("order, first to last (each of %s)", letters)
+			case quizgenerator.QuestionMatching:
+				letters = optionLetter(0) + "-" + optionLetter(len(question.Matches)-1)
+				prompt = fmt.Sprintf("matches for 1-%d in turn (from %s)", len(question.Options), letters)
+			}
+			for _, player := range players {
+				var selected []int
+				for {
+					fmt.Printf("%s's %s: ", player.Name, prompt)
+					scanner.Scan()
+					var ok bool
+					if selected, ok = parseAnswer(strings.TrimSpace(scanner.Text()), question); ok {
+						break
+					}
+					switch question.Kind() {
+					case quizgenerator.QuestionMultiSelect:
+						fmt.Printf("Please enter one or more letters from %s\n", letters)
+					case quizgenerator.QuestionOrdering:
+						fmt.Printf("Please enter each letter from %s once, in order\n", letters)
+					case quizgenerator.QuestionMatching:
+						fmt.Printf("Please enter %d letters from %s, one for each item\n", len(question.Options), letters)
+					default:
+						fmt.Printf("Please enter one letter from %s\n", letters)
+					}
+				}
+
+				player.Answers = append(player.Answers, Answer{Selected: selected})
+			}
 		}
 
 		// Store the question for later review
@@ -195,6 +450,18 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		}
 	}
 
+	// Tell the players why the quiz ended early
+	if result := generator.Result(); len(questions) < req.NumQuestions {
+		if result.Err != nil {
+			fmt.Printf("⚠️  Only %d of %d questions could be generated: %v\n\n", len(questions), req.NumQuestions, result.Err)
+		} else {
+			fmt.Printf("⚠️  Only %d of %d questions could be generated\n\n", len(questions), req.NumQuestions)
+		}
+	}
+	if len(questions) == 0 {
+		return
+	}
+
 	// Now show the results review
 	fmt.Println("🎉 Quiz completed! Let's review the results...")
 	fmt.Println()
@@ -204,16 +471,35 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 	// Review each question
 	for i, question := range questions {
 		fmt.Printf("\n📋 Question %d/%d:\n", i+1, len(questions))
+		if question.Subtopic != "" {
+			fmt.Printf("🧭 Subtopic: %s\n", question.Subtopic)
+		}
 		fmt.Printf("%s\n\n", question.Text)
-
-		// Display options with correct answer highlighted
-		options := []string{"A", "B", "C", "D"}
-
-		for j, option := range question.Options {
-			if j == question.CorrectAnswer {
-				fmt.Printf("✅ %s) %s (CORRECT)\n", options[j], option)
-			} else {
-				fmt.Printf("   %s) %s\n", options[j], option)
+		printImage(images, question)
+
+		// Display options with correct answers highlighted, or the expected typed answer
+		switch question.Kind() {
+		case quizgenerator.QuestionShortAnswer:
+			fmt.Printf("✅ Answer: %s\n", question.CorrectText())
+			if len(question.AcceptedAnswers) > 1 {
+				fmt.Printf("   Also accepted: %s\n", strings.Join(question.AcceptedAnswers[1:], ", "))
+			}
+		case quizgenerator.QuestionNumeric:
+			if question.Numeric != nil {
+				fmt.Printf("✅ Answer: %s\n", question.Numeric.Range())
+			}
+		case quizgenerator.QuestionOrdering:
+			fmt.Printf("✅ Correct order: %s\n", question.CorrectText())
+		case quizgenerator.QuestionMatching:
+			fmt.Printf("✅ Correct pairs: %s\n", question.CorrectText())
+		}
+		if !question.Arranged() {
+			for j, option := range question.Options {
+				if question.IsCorrectOption(j) {
+					fmt.Printf("✅ %s) %s (CORRECT)\n", optionLetter(j), option)
+				} else {
+					fmt.Printf("   %s) %s\n", optionLetter(j), option)
+				}
 			}
 		}
 		fmt.Println()
@@ -221,17 +507,34 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		// Show each player's answer and result
 		fmt.Println("👥 Player Results:")
 		for _, player := range players {
-			playerAnswer := player.Answers[i]
-			isCorrect := playerAnswer == question.CorrectAnswer
-			playerOption := options[playerAnswer]
-
-			if isCorrect {
-				fmt.Printf("  ✅ %s: %s) %s - Correct!\n",
-					player.Name, playerOption, question.Options[playerAnswer])
-				player.Score++
-			} else {
-				fmt.Printf("  ❌ %s: %s) %s - Wrong\n",
-					player.Name, playerOption, question.Options[playerAnswer])
+			answer := player.Answers[i]
+			var chosen []string
+			score := question.Score(answer.Selected)
+			switch {
+			case question.Arranged():
+				chosen = []string{question.ArrangementText(answer.Selected)}
+			case question.FreeResponse():
+				// The review can outlast the generation timeout, so grade without it
+				grade, err := grader.Grade(context.Background(), question, answer.Response, nil)
+				if err != nil {
+					log.Printf("Failed to grade %s's answer with the LLM, using the rules: %v", player.Name, err)
+				}
+				chosen = []string{fmt.Sprintf("%q", answer.Response)}
+				score = grade.Score()
+			default:
+				for _, index := range answer.Selected {
+					chosen = append(chosen, fmt.Sprintf("%s) %s", optionLetter(index), question.Options[index]))
+				}
+			}
+			player.Score += score
+
+			switch {
+			case score == 1:
+				fmt.Printf("  ✅ %s: %s - Correct!\n", player.Name, strings.Join(chosen, ", "))
+			case score > 0:
+				fmt.Printf("  🟡 %s: %s - Partly right (%.2g points)\n", player.Name, strings.Join(chosen, ", "), score)
+			default:
+				fmt.Printf("  ❌ %s: %s - Wrong\n", player.Name, strings.Join(chosen, ", "))
 			}
 		}
 
@@ -239,12 +542,15 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		if question.Explanation != "" {
 			fmt.Printf("\n💡 Explanation: %s\n", question.Explanation)
 		}
+		if question.Source != nil && question.Source.Quote != "" {
+			fmt.Printf("📖 From the source: \"%s\"\n", question.Source.Quote)
+		}
 
 		// Show current scores after this question
 		fmt.Println("\n📊 Scores after this question:")
 		for _, player := range players {
-			percentage := float64(player.Score) / float64(i+1) * 100
-			fmt.Printf("  %s: %d/%d (%.1f%%)\n", player.Name, player.Score, i+1, percentage)
+			percentage := player.Score / float64(i+1) * 100
+			fmt.Printf("  %s: %g/%d (%.1f%%)\n", player.Name, player.Score, i+1, percentage)
 		}
 
 		fmt.Println()
@@ -257,7 +563,9 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 		}
 	}
 
-	// Final results
+	// Final results, scored out of the questions actually played, which may be
+	// fewer than requested
+	total := len(questions)
 	fmt.Println("\n🏆 Final Results:")
 
 	// Sort players by score (highest first)
@@ -266,27 +574,27 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 	})
 
 	for i, player := range players {
-		percentage := float64(player.Score) / float64(req.NumQuestions) * 100
+		percentage := player.Score / float64(total) * 100
 		rank := i + 1
 
 		if rank == 1 {
-			fmt.Printf("🥇 %s: %d/%d (%.1f%%)\n", player.Name, player.Score, req.NumQuestions, percentage)
+			fmt.Printf("🥇 %s: %g/%d (%.1f%%)\n", player.Name, player.Score, total, percentage)
 		} else if rank == 2 && numPlayers > 1 {
-			fmt.Printf("🥈 %s: %d/%d (%.1f%%)\n", player.Name, player.Score, req.NumQuestions, percentage)
+			fmt.Printf("🥈 %s: %g/%d (%.1f%%)\n", player.Name, player.Score, total, percentage)
 		} else if rank == 3 && numPlayers > 2 {
-			fmt.Printf("🥉 %s: %d/%d (%.1f%%)\n", player.Name, player.Score, req.NumQuestions, percentage)
+			fmt.Printf("🥉 %s: %g/%d (%.1f%%)\n", player.Name, player.Score, total, percentage)
 		} else {
-			fmt.Printf("   %s: %d/%d (%.1f%%)\n", player.Name, player.Score, req.NumQuestions, percentage)
+			fmt.Printf("   %s: %g/%d (%.1f%%)\n", player.Name, player.Score, total, percentage)
 		}
 	}
 
 	// Winner announcement
 	if numPlayers > 1 {
 		winner := players[0]
-		percentage := float64(winner.Score) / float64(req.NumQuestions) * 100
+		percentage := winner.Score / float64(total) * 100
 
-		fmt.Printf("\n🎊 Winner: %s with %d/%d correct answers (%.1f%%)\n",
-			winner.Name, winner.Score, req.NumQuestions, percentage)
+		fmt.Printf("\n🎊 Winner: %s with %g/%d correct answers (%.1f%%)\n",
+			winner.Name, winner.Score, total, percentage)
 
 		if percentage >= 0.8 {
 			fmt.Println("🌟 Outstanding performance!")
@@ -298,7 +606,7 @@ func playQuiz(generator *quizgenerator.QuizGenerator, req quizgenerator.Generati
 	} else {
 		// Single player mode - use original feedback
 		player := players[0]
-		percentage := float64(player.Score) / float64(req.NumQuestions) * 100
+		percentage := player.Score / float64(total) * 100
 
 		if percentage >= 0.8 {
 			fmt.Println("🌟 Excellent work!")
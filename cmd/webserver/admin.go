@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// requireAdmin lets a request through to next only if it carries the admin
+// token as its HTTP basic auth password. Admin pages are disabled when no
+// token is configured.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Quiz admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// csrfToken returns the token admin forms must post back. Browsers send basic
+// auth credentials with any request to the site, so a form on another site
+// could otherwise make changes as the admin; it can't know this token, which
+// is derived from the admin token.
+func (s *Server) csrfToken() string {
+	mac := hmac.New(sha256.New, []byte(s.adminToken))
+	mac.Write([]byte("csrf"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRF reports whether a parsed admin form carries the CSRF token
+func (s *Server) validCSRF(r *http.Request) bool {
+	return subtle.ConstantTimeCompare([]byte(r.FormValue("csrf_token")), []byte(s.csrfToken())) == 1
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"quizgenerator"
+)
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	_, ts := newTestServer(t, "")
+	client := newTestClient(t)
+
+	for _, page := range []string{"/admin", "/admin/quiz/quiz1"} {
+		if status, _, _ := get(t, client, ts.URL+page); status != http.StatusNotFound {
+			t.Errorf("%s status %d, want %d", page, status, http.StatusNotFound)
+		}
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	_, ts := newTestServer(t, "secret")
+
+	for _, password := range []string{"", "wrong"} {
+		req, err := http.NewRequest("GET", ts.URL+"/admin", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if password != "" {
+			req.SetBasicAuth("admin", password)
+		}
+		resp, err := newTestClient(t).Do(req)
+		if err != nil {
+			t.Fatalf("GET /admin failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("password %q: status %d, WWW-Authenticate %q", password, resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	if status, _, _ := get(t, newAdminClient(t, "secret"), ts.URL+"/admin"); status != http.StatusOK {
+		t.Errorf("admin page with the token status %d", status)
+	}
+}
+
+func TestAdminImageFormRequiresCSRFToken(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newAdminClient(t, "secret")
+	storeTestQuiz(t, server.db, "quiz1")
+	image, err := server.images.Put(testPNG)
+	if err != nil {
+		t.Fatalf("failed to store image: %v", err)
+	}
+	if err := server.db.UpdateQuestionImage("quiz1", 1, &quizgenerator.QuestionImage{Name: image}); err != nil {
+		t.Fatalf("UpdateQuestionImage failed: %v", err)
+	}
+
+	for _, token := range []string{"", "forged"} {
+		if status, _, _ := post(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"remove": {"1"}, "csrf_token": {token}}); status != http.StatusForbidden {
+			t.Errorf("form token %q: status %d, want %d", token, status, http.StatusForbidden)
+		}
+	}
+	if question, err := server.db.GetQuestion("quiz1", 1); err != nil || question.Image != image {
+		t.Fatalf("image was removed without a valid form token: %+v (%v)", question, err)
+	}
+
+	if status, _, _ := post(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"remove": {"1"}, "csrf_token": {server.csrfToken()}}); status != http.StatusSeeOther {
+		t.Fatalf("removing image status %d", status)
+	}
+	if question, err := server.db.GetQuestion("quiz1", 1); err != nil || question.Image != "" {
+		t.Errorf("image wasn't removed: %+v (%v)", question, err)
+	}
+	// No other question shows the image, so it is deleted from the store
+	if _, err := server.images.Get(image); err == nil {
+		t.Errorf("unused image %s is still in the store", image)
+	}
+}
+
+func TestAdminShowsUsage(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newAdminClient(t, "secret")
+	storeTestQuiz(t, server.db, "quiz1")
+	usage := quizgenerator.UsageSummary{
+		Stages:       map[string]quizgenerator.StageUsage{"QuestionMaker": {Calls: 2, PromptTokens: 1200, CompletionTokens: 300, CostUSD: 0.0123}},
+		PromptTokens: 1200, CompletionTokens: 300, CostUSD: 0.0123,
+	}
+	if err := server.db.UpdateQuizUsage("quiz1", usage); err != nil {
+		t.Fatalf("UpdateQuizUsage failed: %v", err)
+	}
+
+	status, _, body := get(t, client, ts.URL+"/admin")
+	if status != http.StatusOK {
+		t.Fatalf("admin page status %d", status)
+	}
+	for _, want := range []string{"1200 + 300", "$0.0123", "QuestionMaker: 2 calls"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("admin page doesn't show %q", want)
+		}
+	}
+}
+
+func TestAdminShowsContestedQuestions(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newAdminClient(t, "secret")
+	storeTestQuiz(t, server.db, "quiz1")
+	question := &quizgenerator.DBQuestion{
+		ID:           "quiz1-q2",
+		QuizID:       "quiz1",
+		QuestionNum:  2,
+		Text:         "Which volcano is the tallest on Earth?",
+		Options:      `["Mauna Kea","Everest","Etna","Fuji"]`,
+		Votes:        `[{"judge":"strict","action":"reject","reason":"Tallest is ambiguous"},{"judge":"lenient","action":"accept","reason":"Fine"}]`,
+		Disagreement: 0.5,
+	}
+	if err := server.db.CreateQuestion(question); err != nil {
+		t.Fatalf("CreateQuestion failed: %v", err)
+	}
+
+	status, _, body := get(t, client, ts.URL+"/admin")
+	if status != http.StatusOK {
+		t.Fatalf("admin page status %d", status)
+	}
+	for _, want := range []string{question.Text, "50% of judges", "Tallest is ambiguous"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("admin page doesn't show %q", want)
+		}
+	}
+	// Questions every judge agreed on aren't contested
+	if strings.Contains(body, "Which volcano buried Pompeii?") {
+		t.Errorf("admin page lists an uncontested question")
+	}
+}
+
+func TestAdminShowsSolverContestedQuestions(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newAdminClient(t, "secret")
+	storeTestQuiz(t, server.db, "quiz1")
+	question := &quizgenerator.DBQuestion{
+		ID:               "quiz1-q2",
+		QuizID:           "quiz1",
+		QuestionNum:      2,
+		Text:             "Which volcano is the tallest on Earth?",
+		Options:          `["Mauna Kea","Everest","Etna","Fuji"]`,
+		SolverAnswer:     "Everest",
+		SolverConfidence: 0.6,
+	}
+	if err := server.db.CreateQuestion(question); err != nil {
+		t.Fatalf("CreateQuestion failed: %v", err)
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	status, _, body := get(t, client, ts.URL+"/admin")
+	if status != http.StatusOK || !strings.Contains(body, `Blind solver answered "Everest" with 60% confidence`) {
+		t.Errorf("admin page status %d doesn't show the solver's answer", status)
+	}
+	// A question only the solver contested has no votes to parse
+	if strings.Contains(logged.String(), "votes") {
+		t.Errorf("admin page logged: %s", logged.String())
+	}
+}
+
+func TestAdminAttachesImage(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newAdminClient(t, "secret")
+	storeTestQuiz(t, server.db, "quiz1")
+	token := server.csrfToken()
+
+	if status, _, body := get(t, client, ts.URL+"/admin/quiz/quiz1"); status != http.StatusOK || !strings.Contains(body, "Which volcano buried Pompeii?") {
+		t.Fatalf("admin quiz page status %d doesn't list the questions", status)
+	}
+	if status, _, _ := postFile(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"alt": {"A red dot"}, "csrf_token": {token}}, "image", "notes.txt", []byte("not an image")); status != http.StatusBadRequest {
+		t.Errorf("uploading text status %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _, _ := postFile(t, client, ts.URL+"/admin/quiz/quiz1/9/image", url.Values{"csrf_token": {token}}, "image", "dot.png", testPNG); status != http.StatusNotFound {
+		t.Errorf("uploading for a missing question status %d, want %d", status, http.StatusNotFound)
+	}
+
+	if status, _, _ := postFile(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"alt": {" A red dot "}, "csrf_token": {token}}, "image", "dot.png", testPNG); status != http.StatusSeeOther {
+		t.Fatalf("uploading image status %d", status)
+	}
+	question, err := server.db.GetQuestion("quiz1", 1)
+	if err != nil || question.Image == "" || question.ImageAlt != "A red dot" {
+		t.Fatalf("question after upload = %+v (%v)", question, err)
+	}
+
+	// Players see the image, which is served with its type and cached for good
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}})
+	if _, _, body := get(t, client, ts.URL+"/quiz/quiz1/1"); !strings.Contains(body, `src="/images/`+question.Image+`" alt="A red dot"`) {
+		t.Errorf("question page doesn't show the image:\n%s", body)
+	}
+	req, err := http.NewRequest("GET", ts.URL+"/images/"+question.Image, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET image failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || !strings.Contains(resp.Header.Get("Cache-Control"), "immutable") {
+		t.Errorf("image status %d, Content-Type %q, Cache-Control %q", resp.StatusCode, resp.Header.Get("Content-Type"), resp.Header.Get("Cache-Control"))
+	}
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	if status, _, _ := fetch(t, client, req); status != http.StatusNotModified {
+		t.Errorf("revalidating image status %d, want %d", status, http.StatusNotModified)
+	}
+	for _, name := range []string{"quiz.db", "missing.png"} {
+		if status, _, _ := get(t, client, ts.URL+"/images/"+name); status != http.StatusNotFound {
+			t.Errorf("image %q status %d, want %d", name, status, http.StatusNotFound)
+		}
+	}
+
+	// The description can be changed without uploading the image again, and the image removed
+	if status, _, _ := post(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"alt": {"A dot"}, "csrf_token": {token}}); status != http.StatusSeeOther {
+		t.Fatalf("changing description status %d", status)
+	}
+	if updated, err := server.db.GetQuestion("quiz1", 1); err != nil || updated.Image != question.Image || updated.ImageAlt != "A dot" {
+		t.Errorf("question after changing the description = %+v (%v)", updated, err)
+	}
+	if status, _, _ := post(t, client, ts.URL+"/admin/quiz/quiz1/1/image", url.Values{"remove": {"1"}, "csrf_token": {token}}); status != http.StatusSeeOther {
+		t.Fatalf("removing image status %d", status)
+	}
+	if updated, err := server.db.GetQuestion("quiz1", 1); err != nil || updated.Image != "" {
+		t.Errorf("question after removing the image = %+v (%v)", updated, err)
+	}
+}
+
+// testPNG is a 1x1 PNG image
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\xff\xff?\x00\x05\xfe\x02\xfe\xa7\x35\x81\x84\x00\x00\x00\x00IEND\xaeB`\x82")
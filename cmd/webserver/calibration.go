@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"quizgenerator"
+)
+
+// recordAnswer stores a player's graded answer so the question's difficulty
+// can be measured; failing to is logged rather than interrupting the game
+func (s *Server) recordAnswer(quizID string, dbQuestion *quizgenerator.DBQuestion, question *quizgenerator.Question, answer Answer, game string) {
+	outcome := quizgenerator.AnswerOutcome{
+		QuestionID: dbQuestion.StatsID(),
+		QuizID:     quizID,
+		Score:      answer.Score,
+		Game:       game,
+		Time:       time.Now(),
+	}
+	// Arranged answers list every option, so they say nothing about which options mislead
+	if !question.Arranged() {
+		outcome.Selected = answer.Selected
+	}
+	if err := s.db.RecordAnswer(outcome); err != nil {
+		log.Printf("Failed to record answer to question %s: %v", dbQuestion.ID, err)
+	}
+}
+
+// handleCalibratedQuiz builds a quiz from already-played questions on related
+// topics, picked by how difficult players found them
+func (s *Server) handleCalibratedQuiz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	topic := strings.TrimSpace(r.FormValue("topic"))
+	if topic == "" {
+		http.Error(w, "Topic is required", http.StatusBadRequest)
+		return
+	}
+	difficulty := r.FormValue("difficulty")
+	target, err := quizgenerator.DifficultyTarget(difficulty)
+	if err != nil {
+		http.Error(w, "Invalid difficulty", http.StatusBadRequest)
+		return
+	}
+	numQuestions, err := strconv.Atoi(r.FormValue("num_questions"))
+	if err != nil || numQuestions <= 0 {
+		numQuestions = 10
+	}
+
+	// Only create the quiz once there are questions to fill it with
+	selected, err := s.db.FindCalibratedQuestions(topic, numQuestions, target)
+	if err != nil {
+		log.Printf("Failed to find calibrated questions on %q: %v", topic, err)
+		http.Error(w, "Failed to build quiz", http.StatusInternalServerError)
+		return
+	}
+	if len(selected) == 0 {
+		http.Error(w, "No questions on related topics have been played often enough yet; try a broader topic or generate a new quiz", http.StatusNotFound)
+		return
+	}
+
+	quizID := generateQuizID()
+	quiz := &quizgenerator.DBQuiz{
+		ID:           quizID,
+		Topic:        topic,
+		NumQuestions: len(selected),
+		Difficulty:   difficulty,
+		CreatedAt:    time.Now(),
+		Status:       "generating",
+	}
+	if err := s.db.CreateQuiz(quiz); err != nil {
+		http.Error(w, "Failed to create quiz", http.StatusInternalServerError)
+		return
+	}
+	if err := s.db.AssembleCalibratedQuiz(quizID, selected); err != nil {
+		log.Printf("Failed to assemble calibrated quiz %s: %v", quizID, err)
+		s.db.UpdateQuizStatus(quizID, "failed")
+		http.Error(w, "Failed to build quiz", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
+}
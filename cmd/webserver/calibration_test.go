@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"quizgenerator"
+)
+
+func TestAnswersAreRecorded(t *testing.T) {
+	server, ts := newTestServer(t, "secret")
+	client := newTestClient(t)
+	storeTestQuiz(t, server.db, "quiz1")
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"2"}})
+
+	// Only the first submission of a question counts
+	for range 2 {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/quiz1/1", url.Values{"player_0": {"1"}, "player_1": {"0"}}); status != http.StatusSeeOther {
+			t.Fatalf("answering status %d", status)
+		}
+	}
+	stats, err := server.db.GetQuestionStats("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuestionStats failed: %v", err)
+	}
+	if got := stats["quiz1-q1"]; got == nil || got.Answers != 2 || got.Correct != 1 {
+		t.Fatalf("question stats = %+v, want the two players' first answers", got)
+	}
+
+	status, _, body := get(t, newAdminClient(t, "secret"), ts.URL+"/admin/quiz/quiz1")
+	if status != http.StatusOK {
+		t.Fatalf("admin quiz page status %d", status)
+	}
+	for _, want := range []string{"2 answers, 50% correct", "(provisional)", "Etna: chosen by 50%"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("admin quiz page doesn't show %q", want)
+		}
+	}
+}
+
+func TestCalibratedQuiz(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	storeTestQuiz(t, server.db, "quiz1")
+	for range quizgenerator.MinCalibrationAnswers {
+		outcome := quizgenerator.AnswerOutcome{QuestionID: "quiz1-q1", QuizID: "quiz1", Selected: []int{1}, Score: 1, Game: quizgenerator.GameSinglePlayer}
+		if err := server.db.RecordAnswer(outcome); err != nil {
+			t.Fatalf("RecordAnswer failed: %v", err)
+		}
+	}
+
+	for _, form := range []url.Values{
+		{"difficulty": {"easy"}},
+		{"topic": {"Active volcanoes"}, "difficulty": {"impossible"}},
+	} {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/calibrated", form); status != http.StatusBadRequest {
+			t.Errorf("form %v status %d, want %d", form, status, http.StatusBadRequest)
+		}
+	}
+	if status, _, _ := post(t, client, ts.URL+"/quiz/calibrated", url.Values{"topic": {"Roman history"}, "difficulty": {"easy"}}); status != http.StatusNotFound {
+		t.Errorf("calibrated quiz on an unplayed topic status %d, want %d", status, http.StatusNotFound)
+	}
+	if quizzes, err := server.db.GetQuizzes(0); err != nil || len(quizzes) != 1 {
+		t.Errorf("calibrated quiz without questions was still created: %v (%v)", quizzes, err)
+	}
+
+	status, location, _ := post(t, client, ts.URL+"/quiz/calibrated", url.Values{"topic": {"Active volcanoes"}, "difficulty": {"easy"}, "num_questions": {"3"}})
+	if status != http.StatusSeeOther || !strings.HasPrefix(location, "/quiz/") {
+		t.Fatalf("calibrated quiz status %d, location %q", status, location)
+	}
+	quizID := strings.TrimPrefix(location, "/quiz/")
+	quiz, err := server.db.GetQuiz(quizID)
+	if err != nil || quiz.Status != "completed" || quiz.NumQuestions != 1 {
+		t.Fatalf("calibrated quiz = %+v (%v), want it completed with the one played question", quiz, err)
+	}
+	questions, err := server.db.GetQuestions(quizID)
+	if err != nil || len(questions) != 1 || questions[0].OriginID != "quiz1-q1" {
+		t.Fatalf("calibrated quiz questions = %+v (%v)", questions, err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"quizgenerator"
+)
+
+// handleImage serves a question image from the image store. Image names are
+// derived from their content, so browsers may cache them forever.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/images/")
+	data, err := s.images.Get(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", quizgenerator.ImageContentType(name))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+strings.TrimSuffix(name, filepath.Ext(name))+`"`)
+	// ServeContent answers If-None-Match with 304 Not Modified
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// handleAdminQuiz lets an editor see a quiz's questions, with how players have
+// answered them, and attach images to them: /admin/quiz/{id} lists the
+// questions and /admin/quiz/{id}/{num}/image takes uploads
+func (s *Server) handleAdminQuiz(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/quiz/"), "/")
+	quizID := parts[0]
+
+	if len(parts) == 3 && parts[2] == "image" {
+		questionNum, err := strconv.Atoi(parts[1])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.handleQuestionImage(w, r, quizID, questionNum)
+		return
+	}
+	if len(parts) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	quiz, err := s.db.GetQuiz(quizID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	dbQuestions, err := s.db.GetQuestions(quizID)
+	if err != nil {
+		http.Error(w, "Failed to get questions", http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := s.db.GetQuestionStats(quizID)
+	if err != nil {
+		log.Printf("Failed to get answer statistics for quiz %s: %v", quizID, err)
+	}
+
+	type adminQuestion struct {
+		questionResult
+		Stats *quizgenerator.QuestionStats // Nil until the question has been answered
+	}
+
+	var questions []adminQuestion
+	for _, q := range dbQuestions {
+		question, err := q.ToQuestion()
+		if err != nil {
+			log.Printf("Failed to parse question %s: %v", q.ID, err)
+			continue
+		}
+		questions = append(questions, adminQuestion{
+			questionResult: questionResult{Question: question, QuestionNum: q.QuestionNum},
+			Stats:          stats[q.StatsID()],
+		})
+	}
+
+	err = s.templates["admin_quiz"].ExecuteTemplate(w, "base.html", map[string]interface{}{
+		"Quiz":                  quiz,
+		"Questions":             questions,
+		"MaxImageBytes":         quizgenerator.MaxImageBytes,
+		"CSRFToken":             s.csrfToken(),
+		"MinCalibrationAnswers": quizgenerator.MinCalibrationAnswers,
+	})
+	if err != nil {
+		log.Printf("Template error in admin_quiz: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleQuestionImage stores an uploaded image and attaches it to a question,
+// updates the alt text of the image already attached, or removes it
+func (s *Server) handleQuestionImage(w http.ResponseWriter, r *http.Request, quizID string, questionNum int) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, quizgenerator.MaxImageBytes+1<<20)
+	if err := r.ParseMultipartForm(quizgenerator.MaxImageBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+	if !s.validCSRF(r) {
+		http.Error(w, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	question, err := s.db.GetQuestion(quizID, questionNum)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var image *quizgenerator.QuestionImage
+	if r.FormValue("remove") == "" {
+		image = &quizgenerator.QuestionImage{
+			Name: question.Image,
+			Alt:  strings.TrimSpace(r.FormValue("alt")),
+		}
+		if file, _, err := r.FormFile("image"); err == nil {
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				http.Error(w, "Failed to read image", http.StatusBadRequest)
+				return
+			}
+			image.Name, err = s.images.Put(data)
+			if err != nil {
+				http.Error(w, "Could not store the image: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "Failed to read image", http.StatusBadRequest)
+			return
+		}
+		if image.Name == "" {
+			http.Error(w, "Choose an image to upload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.db.UpdateQuestionImage(quizID, questionNum, image); err != nil {
+		log.Printf("Failed to update image of question %d of quiz %s: %v", questionNum, quizID, err)
+		http.Error(w, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+	if question.Image != "" && (image == nil || image.Name != question.Image) {
+		s.deleteUnusedImage(question.Image)
+	}
+	http.Redirect(w, r, "/admin/quiz/"+quizID, http.StatusSeeOther)
+}
+
+// deleteUnusedImage removes an image replaced or removed from a question, or
+// left over from a source file, unless a question still shows it
+func (s *Server) deleteUnusedImage(name string) {
+	uses, err := s.db.CountImageUses(name)
+	if err != nil {
+		log.Printf("Failed to count questions showing image %s: %v", name, err)
+		return
+	}
+	if uses > 0 {
+		return
+	}
+	if err := s.images.Delete(name); err != nil {
+		log.Printf("Failed to delete unused image %s: %v", name, err)
+	}
+}
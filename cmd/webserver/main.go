@@ -2,16 +2,21 @@ package main
 
 import (
 	"crypto/rand"
-	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"quizgenerator"
 
@@ -20,16 +25,16 @@ import (
 
 // MultiplayerSession represents a multiplayer quiz session
 type MultiplayerSession struct {
-	ID         string                 `json:"id"`
-	QuizID     string                 `json:"quiz_id"`
-	HostName   string                 `json:"host_name"`
-	Status     string                 `json:"status"` // "waiting", "playing", "completed"
-	CurrentQ   int                    `json:"current_q"`
-	CreatedAt  time.Time              `json:"created_at"`
-	StartedAt  *time.Time             `json:"started_at,omitempty"`
-	MaxPlayers int                    `json:"max_players"`
-	Players    []MultiplayerPlayer    `json:"players"`
-	Answers    map[int]map[string]int `json:"answers"` // questionNum -> playerID -> answer
+	ID         string                    `json:"id"`
+	QuizID     string                    `json:"quiz_id"`
+	HostName   string                    `json:"host_name"`
+	Status     string                    `json:"status"` // "waiting", "playing", "completed"
+	CurrentQ   int                       `json:"current_q"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	StartedAt  *time.Time                `json:"started_at,omitempty"`
+	MaxPlayers int                       `json:"max_players"`
+	Players    []MultiplayerPlayer       `json:"players"`
+	Answers    map[int]map[string]Answer `json:"answers"` // questionNum -> playerID -> answer
 	mu         sync.RWMutex
 }
 
@@ -39,18 +44,32 @@ type MultiplayerPlayer struct {
 	SessionID string    `json:"session_id"`
 	Name      string    `json:"name"`
 	JoinedAt  time.Time `json:"joined_at"`
-	Score     int       `json:"score"`
+	Score     float64   `json:"score"` // Multi-select questions earn partial credit
 	Ready     bool      `json:"ready"`
 }
 
+// maxSourceFileBytes caps the size of an uploaded source file
+const maxSourceFileBytes = 20 << 20
+
+// maxResponseChars caps the length of a typed answer
+const maxResponseChars = 200
+
 type Server struct {
 	db        *quizgenerator.DB
+	daily     *quizgenerator.DailyBudget // Nil when DAILY_BUDGET_USD is not set
 	store     *sessions.CookieStore
 	templates map[string]*template.Template
+	grader    *quizgenerator.AnswerGrader // Grades typed answers to short-answer and numeric questions
+	images    *quizgenerator.ImageStore   // Pictures shown with questions
+	// Password for the admin pages, from ADMIN_TOKEN; they are disabled without one
+	adminToken string
 	// Multiplayer in-memory storage
 	multiplayerSessions map[string]*MultiplayerSession
 	playerTokens        map[string]PlayerTokenInfo // playerToken -> session/player info
-	mu                  sync.RWMutex
+	// Single-player games, kept here rather than in the session cookie, which
+	// only carries the game ID, because typed answers soon outgrow a cookie
+	games map[string]GameSession
+	mu    sync.RWMutex
 }
 
 // PlayerTokenInfo stores the mapping from player token to session and player info
@@ -60,13 +79,36 @@ type PlayerTokenInfo struct {
 	PlayerName string
 }
 
+// gameIdleTimeout is how long a single-player game is kept after its last answer
+const gameIdleTimeout = 24 * time.Hour
+
 type GameSession struct {
-	QuizID    string   `json:"quiz_id"`
-	Players   []Player `json:"players"`
-	CurrentQ  int      `json:"current_q"`
-	Answers   [][]int  `json:"answers"` // [question][player] -> answer
-	Scores    []int    `json:"scores"`
-	Completed bool     `json:"completed"`
+	ID        string     `json:"id"`
+	QuizID    string     `json:"quiz_id"`
+	Players   []Player   `json:"players"`
+	CurrentQ  int        `json:"current_q"`
+	Answers   [][]Answer `json:"answers"` // [question][player] -> answer
+	Scores    []float64  `json:"scores"`  // Multi-select questions earn partial credit
+	Completed bool       `json:"completed"`
+	Updated   time.Time  `json:"updated"`
+}
+
+// clone copies the game so a handler can change it without racing another request
+func (game GameSession) clone() GameSession {
+	game.Players = slices.Clone(game.Players)
+	game.Scores = slices.Clone(game.Scores)
+	game.Answers = slices.Clone(game.Answers)
+	for i := range game.Answers {
+		game.Answers[i] = slices.Clone(game.Answers[i])
+	}
+	return game
+}
+
+// Answer is one player's graded answer to a question
+type Answer struct {
+	Selected []int   `json:"selected,omitempty"` // Chosen options
+	Response string  `json:"response,omitempty"` // Typed answer to a short-answer or numeric question
+	Score    float64 `json:"score"`              // Credit earned, from 0 to 1
 }
 
 type Player struct {
@@ -74,17 +116,17 @@ type Player struct {
 	Score int    `json:"score"`
 }
 
-func init() {
-	gob.Register(GameSession{})
-	gob.Register(Player{})
-}
-
 func main() {
 	quizgenerator.SetVerbose(true)
-	// Get API key from environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("OPENAI_API_KEY environment variable is required")
+	// Load config from QUIZ_CONFIG; provider options come from the environment unless set there
+	cfg, err := quizgenerator.LoadConfig(os.Getenv("QUIZ_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// A local OpenAI-compatible server needs no key
+	if cfg.Provider.APIKey == "" && cfg.Provider.NeedsAPIKey() {
+		log.Fatal("OPENAI_API_KEY (or OPENAI_BASE_URL) environment variable is required")
 	}
 
 	// Initialize database
@@ -93,16 +135,87 @@ func main() {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.CloseDB()
+	db.SetConfig(cfg)
 
 	// Create tables
 	if err := db.CreateTables(); err != nil {
 		log.Fatalf("Failed to create tables: %v", err)
 	}
 
+	// Optional cap on the combined LLM cost of all quizzes generated each day
+	var daily *quizgenerator.DailyBudget
+	if value := os.Getenv("DAILY_BUDGET_USD"); value != "" {
+		maxCost, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid DAILY_BUDGET_USD %q: %v", value, err)
+		}
+		now := time.Now()
+		spent, err := db.GetCostSince(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+		if err != nil {
+			log.Fatalf("Failed to get today's LLM cost: %v", err)
+		}
+		daily = quizgenerator.NewDailyBudget(maxCost, spent)
+		db.SetDailyBudget(daily)
+		log.Printf("Daily LLM budget: $%.2f ($%.4f spent today)", maxCost, spent)
+	}
+
+	// Typed answers are graded by the rules, and by the grader stage too when grading.llm is set
+	var graderProvider quizgenerator.LLMProvider
+	if cfg.Grading.LLM {
+		graderProvider, err = quizgenerator.NewProvider(cfg.Provider)
+		if err != nil {
+			log.Fatalf("Failed to create LLM provider for grading: %v", err)
+		}
+		// Grading counts towards the daily budget; once it is spent answers are graded by the rules alone
+		graderUsage := quizgenerator.NewUsageTracker(cfg.Prices)
+		if daily != nil {
+			graderProvider = quizgenerator.NewDailyBudgetProvider(graderProvider, graderUsage, daily)
+		} else {
+			graderProvider = quizgenerator.NewUsageProvider(graderProvider, graderUsage)
+		}
+		graderProvider = quizgenerator.NewRetryProvider(graderProvider, cfg.Retry)
+	}
+	grader := quizgenerator.NewAnswerGrader(graderProvider, cfg.ResolveStage(cfg.Grader, quizgenerator.DefaultFastModel), cfg.Grading)
+
 	// Initialize session store
 	store := sessions.NewCookieStore([]byte("your-secret-key-here"))
 
-	// Load templates with custom functions
+	templates, err := loadTemplates("templates")
+	if err != nil {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Printf("ADMIN_TOKEN is not set, so the admin pages are disabled")
+	}
+
+	server := &Server{
+		db:        db,
+		daily:     daily,
+		store:     store,
+		templates: templates,
+		grader:    grader,
+		images:    quizgenerator.NewImageStore(cfg.ImageDir),
+		// Admin pages sign in with any user name and the token as password
+		adminToken: adminToken,
+		// Initialize multiplayer sessions map
+		multiplayerSessions: make(map[string]*MultiplayerSession),
+		playerTokens:        make(map[string]PlayerTokenInfo),
+		games:               make(map[string]GameSession),
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8180"
+	}
+
+	log.Printf("Starting server on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, server.routes()))
+}
+
+// loadTemplates parses each page's template in dir together with base.html
+func loadTemplates(dir string) (map[string]*template.Template, error) {
 	funcMap := template.FuncMap{
 		"list": func(items ...interface{}) []interface{} {
 			return items
@@ -117,6 +230,10 @@ func main() {
 				if idx, ok := i.(int); ok {
 					return v[idx]
 				}
+			case []float64:
+				if idx, ok := i.(int); ok {
+					return v[idx]
+				}
 			case []string:
 				if idx, ok := i.(int); ok {
 					return v[idx]
@@ -146,9 +263,10 @@ func main() {
 		"mul": func(a, b float64) float64 {
 			return a * b
 		},
-		"div": func(a, b int) float64 {
-			return float64(a) / float64(b)
+		"div": func(a, b interface{}) float64 {
+			return toFloat(a) / toFloat(b)
 		},
+		"letter": optionLetter,
 		"gt": func(a, b int) bool {
 			return a > b
 		},
@@ -161,7 +279,6 @@ func main() {
 		},
 	}
 
-	// Create template map
 	templates := make(map[string]*template.Template)
 
 	// Load each template with base.html
@@ -169,54 +286,91 @@ func main() {
 		name string
 		file string
 	}{
-		{"home", "templates/home.html"},
-		{"new_quiz", "templates/new_quiz.html"},
-		{"quiz_setup", "templates/quiz_setup.html"},
-		{"question", "templates/question.html"},
-		{"generating", "templates/generating.html"},
-		{"results", "templates/results.html"},
+		{"home", "home.html"},
+		{"new_quiz", "new_quiz.html"},
+		{"quiz_setup", "quiz_setup.html"},
+		{"question", "question.html"},
+		{"generating", "generating.html"},
+		{"results", "results.html"},
+		{"admin", "admin.html"},
+		{"admin_quiz", "admin_quiz.html"},
 		// Multiplayer templates
-		{"new_multiplayer", "templates/new_multiplayer.html"},
-		{"join_session", "templates/join_session.html"},
-		{"multiplayer_lobby", "templates/multiplayer_lobby.html"},
-		{"multiplayer_question", "templates/multiplayer_question.html"},
-		{"multiplayer_waiting", "templates/multiplayer_waiting.html"},
-		{"multiplayer_results", "templates/multiplayer_results.html"},
+		{"new_multiplayer", "new_multiplayer.html"},
+		{"join_session", "join_session.html"},
+		{"multiplayer_lobby", "multiplayer_lobby.html"},
+		{"multiplayer_question", "multiplayer_question.html"},
+		{"multiplayer_waiting", "multiplayer_waiting.html"},
+		{"multiplayer_results", "multiplayer_results.html"},
 	}
 
 	for _, tmpl := range templateFiles {
-		templates[tmpl.name] = template.Must(template.New(tmpl.name).Funcs(funcMap).ParseFiles("templates/base.html", tmpl.file))
+		parsed, err := template.New(tmpl.name).Funcs(funcMap).ParseFiles(filepath.Join(dir, "base.html"), filepath.Join(dir, tmpl.file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", tmpl.name, err)
+		}
+		templates[tmpl.name] = parsed
 	}
+	return templates, nil
+}
 
-	server := &Server{
-		db:        db,
-		store:     store,
-		templates: templates,
-		// Initialize multiplayer sessions map
-		multiplayerSessions: make(map[string]*MultiplayerSession),
-		playerTokens:        make(map[string]PlayerTokenInfo),
+// routes returns the handler serving every page
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleHome)
+	mux.HandleFunc("/quiz/new", s.handleNewQuiz)
+	mux.HandleFunc("/quiz/calibrated", s.handleCalibratedQuiz)
+	mux.HandleFunc("/quiz/", s.handleQuiz)
+	mux.HandleFunc("/admin", s.requireAdmin(s.handleAdmin))
+	mux.HandleFunc("/admin/quiz/", s.requireAdmin(s.handleAdminQuiz))
+	mux.HandleFunc("/images/", s.handleImage)
+	// Add multiplayer routes
+	mux.HandleFunc("/multiplayer/", s.handleMultiplayer)
+	return mux
+}
+
+// Helper functions for multiplayer
+func generateSessionID() string {
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 8)
+	rand.Read(b)
+	for i := range b {
+		b[i] = charset[b[i]%byte(len(charset))]
 	}
+	return string(b)
+}
 
-	// Setup routes
-	http.HandleFunc("/", server.handleHome)
-	http.HandleFunc("/quiz/new", server.handleNewQuiz)
-	http.HandleFunc("/quiz/", server.handleQuiz)
-	// Add multiplayer routes
-	http.HandleFunc("/multiplayer/", server.handleMultiplayer)
+// loadGame returns a copy of the game the request's session cookie refers to,
+// if it is a game of the given quiz
+func (s *Server) loadGame(r *http.Request, quizID string) (GameSession, bool) {
+	session, _ := s.store.Get(r, "quiz-session")
+	gameID, _ := session.Values["game"].(string)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8180"
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	game, ok := s.games[gameID]
+	if !ok || game.QuizID != quizID {
+		return GameSession{}, false
 	}
+	return game.clone(), true
+}
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+// saveGame stores a game's progress and forgets games abandoned long ago
+func (s *Server) saveGame(game GameSession) {
+	game.Updated = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.games[game.ID] = game
+	for id, other := range s.games {
+		if time.Since(other.Updated) > gameIdleTimeout {
+			delete(s.games, id)
+		}
+	}
 }
 
-// Helper functions for multiplayer
-func generateSessionID() string {
+func generateGameID() string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, 8)
+	b := make([]byte, 16)
 	rand.Read(b)
 	for i := range b {
 		b[i] = charset[b[i]%byte(len(charset))]
@@ -251,7 +405,7 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 	// Filter to only show completed quizzes
 	var completedQuizzes []quizgenerator.DBQuiz
 	for _, quiz := range allQuizzes {
-		if quiz.Status == "completed" {
+		if quiz.Playable() {
 			completedQuizzes = append(completedQuizzes, quiz)
 		}
 	}
@@ -268,7 +422,14 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleNewQuiz(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		err := s.templates["new_quiz"].ExecuteTemplate(w, "base.html", nil)
+		data := map[string]interface{}{
+			"SourceFileAccept":      strings.Join(quizgenerator.SourceFileExtensions, ","),
+			"MinOptions":            quizgenerator.MinOptions,
+			"MaxOptions":            quizgenerator.MaxOptions,
+			"DefaultNumOptions":     quizgenerator.DefaultNumOptions,
+			"MinCalibrationAnswers": quizgenerator.MinCalibrationAnswers,
+		}
+		err := s.templates["new_quiz"].ExecuteTemplate(w, "base.html", data)
 		if err != nil {
 			log.Printf("Template error in new_quiz: %v", err)
 			http.Error(w, "Template error", http.StatusInternalServerError)
@@ -282,8 +443,9 @@ func (s *Server) handleNewQuiz(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse form
-	if err := r.ParseForm(); err != nil {
+	// Parse form, which may carry a source file upload
+	r.Body = http.MaxBytesReader(w, r.Body, maxSourceFileBytes+1<<20)
+	if err := r.ParseMultipartForm(maxSourceFileBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
@@ -298,11 +460,63 @@ func (s *Server) handleNewQuiz(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	questionTypes, err := quizgenerator.ParseQuestionTypes(strings.Join(r.Form["question_type"], ","))
+	if err != nil {
+		http.Error(w, "Invalid question type", http.StatusBadRequest)
+		return
+	}
+	numOptions := 0
+	if value := r.FormValue("num_options"); value != "" {
+		numOptions, err = strconv.Atoi(value)
+		if err != nil || numOptions < quizgenerator.MinOptions || numOptions > quizgenerator.MaxOptions {
+			http.Error(w, "Invalid number of options", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if s.daily != nil && s.daily.Exceeded() {
+		http.Error(w, "Daily quiz generation budget has been reached, please try again tomorrow", http.StatusServiceUnavailable)
+		return
+	}
+
 	numQuestions, err := strconv.Atoi(numQuestionsStr)
 	if err != nil || numQuestions <= 0 {
 		numQuestions = 10
 	}
 
+	sourceFilename, sourceLength := "", 0
+	var sourceImages []quizgenerator.QuestionImage
+	if file, header, err := r.FormFile("source_file"); err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Failed to read source file", http.StatusBadRequest)
+			return
+		}
+		text, err := quizgenerator.ExtractText(header.Filename, data)
+		if err != nil {
+			log.Printf("Failed to extract source file %s: %v", header.Filename, err)
+			http.Error(w, "Could not read text from the source file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sourceFilename = filepath.Base(header.Filename)
+		sourceLength = utf8.RuneCountInString(text)
+		// Only an EPUB carries its images with it; other documents' linked images weren't uploaded
+		sourceImages, err = s.images.PutSourceImages(quizgenerator.ExtractImages(header.Filename, data, nil))
+		if err != nil {
+			log.Printf("Failed to store images from source file %s: %v", header.Filename, err)
+			sourceImages = nil
+		}
+		if strings.TrimSpace(sourceMaterial) != "" {
+			sourceMaterial = strings.TrimSpace(sourceMaterial) + "\n\n" + text
+		} else {
+			sourceMaterial = text
+		}
+	} else if !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Failed to read source file", http.StatusBadRequest)
+		return
+	}
+
 	// Create quiz in database
 	quizID := generateQuizID()
 	quiz := &quizgenerator.DBQuiz{
@@ -313,6 +527,8 @@ func (s *Server) handleNewQuiz(w http.ResponseWriter, r *http.Request) {
 		Difficulty:     difficulty,
 		CreatedAt:      time.Now(),
 		Status:         "generating",
+		SourceFilename: sourceFilename,
+		SourceLength:   sourceLength,
 	}
 
 	if err := s.db.CreateQuiz(quiz); err != nil {
@@ -320,8 +536,21 @@ func (s *Server) handleNewQuiz(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start generating in background
-	go s.db.GenerateQuiz(quizID, topic, numQuestions, sourceMaterial, difficulty)
+	// Start generating in background, then delete the source images no question chose to show
+	go func() {
+		s.db.GenerateQuiz(quizID, quizgenerator.GenerationRequest{
+			Topic:          topic,
+			NumQuestions:   numQuestions,
+			SourceMaterial: sourceMaterial,
+			Difficulty:     difficulty,
+			QuestionTypes:  questionTypes,
+			NumOptions:     numOptions,
+			SourceImages:   sourceImages,
+		})
+		for _, image := range sourceImages {
+			s.deleteUnusedImage(image.Name)
+		}
+	}()
 
 	// Redirect to quiz page
 	http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
@@ -419,25 +648,28 @@ func (s *Server) handleQuizSetup(w http.ResponseWriter, r *http.Request, quizID
 	}
 
 	// Create game session
-	session, _ := s.store.Get(r, "quiz-session")
 	gameSession := GameSession{
+		ID:        generateGameID(),
 		QuizID:    quizID,
 		Players:   players,
 		CurrentQ:  1,
-		Answers:   make([][]int, quiz.NumQuestions),
-		Scores:    make([]int, len(players)),
+		Answers:   make([][]Answer, quiz.NumQuestions),
+		Scores:    make([]float64, len(players)),
 		Completed: false,
 	}
 
 	// Initialize answers array
 	for i := range gameSession.Answers {
-		gameSession.Answers[i] = make([]int, len(players))
+		gameSession.Answers[i] = make([]Answer, len(players))
 	}
 
-	session.Values["game"] = gameSession
-	err = session.Save(r, w)
-	if err != nil {
+	s.saveGame(gameSession)
+	session, _ := s.store.Get(r, "quiz-session")
+	session.Values["game"] = gameSession.ID
+	if err := session.Save(r, w); err != nil {
 		log.Printf("Session save error: %v", err)
+		http.Error(w, "Failed to start quiz", http.StatusInternalServerError)
+		return
 	}
 
 	// Redirect to first question
@@ -446,15 +678,8 @@ func (s *Server) handleQuizSetup(w http.ResponseWriter, r *http.Request, quizID
 
 func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID string, questionNum int) {
 	// Get game session
-	session, _ := s.store.Get(r, "quiz-session")
-	gameInterface := session.Values["game"]
-	if gameInterface == nil {
-		http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
-		return
-	}
-
-	gameSession := gameInterface.(GameSession)
-	if gameSession.QuizID != quizID {
+	gameSession, ok := s.loadGame(r, quizID)
+	if !ok {
 		http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
 		return
 	}
@@ -478,9 +703,15 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 
 		// If quiz is still generating, show generating page
 		if quiz.Status == "generating" || quiz.Status == "ready" {
-			err := s.templates["generating"].ExecuteTemplate(w, "base.html", map[string]interface{}{
+			// Show the latest generation progress while the user waits
+			events, err := s.db.GetGenerationEvents(quizID, 8)
+			if err != nil {
+				log.Printf("Failed to get generation events: %v", err)
+			}
+			err = s.templates["generating"].ExecuteTemplate(w, "base.html", map[string]interface{}{
 				"QuizID":      quizID,
 				"QuestionNum": questionNum,
+				"Events":      events,
 			})
 			if err != nil {
 				log.Printf("Template error in generating: %v", err)
@@ -494,8 +725,7 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 		// This handles the case where we truncated the quiz
 		log.Printf("Question %d for quiz %s doesn't exist, quiz is completed, redirecting to results", questionNum, quizID)
 		gameSession.Completed = true
-		session.Values["game"] = gameSession
-		session.Save(r, w)
+		s.saveGame(gameSession)
 		http.Redirect(w, r, fmt.Sprintf("/quiz/%s/results", quizID), http.StatusSeeOther)
 		return
 	}
@@ -515,8 +745,8 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 		totalQuestions = 10
 	}
 
-	// Parse options
-	options, err := quizgenerator.JSONToOptions(question.Options)
+	// Parse options and answers
+	parsed, err := question.ToQuestion()
 	if err != nil {
 		http.Error(w, "Failed to parse question", http.StatusInternalServerError)
 		return
@@ -528,7 +758,14 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 			"QuestionNum":    questionNum,
 			"TotalQuestions": totalQuestions,
 			"Question":       question.Text,
-			"Options":        options,
+			"Options":        parsed.Options,
+			"MultiSelect":    parsed.Kind() == quizgenerator.QuestionMultiSelect,
+			"Ordering":       parsed.Kind() == quizgenerator.QuestionOrdering,
+			"Matching":       parsed.Kind() == quizgenerator.QuestionMatching,
+			"Matches":        parsed.Matches,
+			"Image":          parsed.Image,
+			"FreeResponse":   parsed.FreeResponse(),
+			"Unit":           answerUnit(parsed),
 			"Players":        gameSession.Players,
 		})
 		if err != nil {
@@ -550,15 +787,18 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 		return
 	}
 
+	// Only the first submission of a question counts towards its difficulty
+	firstSubmission := slices.IndexFunc(gameSession.Answers[questionNum-1], Answer.given) < 0
+
 	// Get answers from all players
 	for i := range gameSession.Players {
-		answerStr := r.FormValue(fmt.Sprintf("player_%d", i))
-		if answerStr == "" {
+		values := r.Form[fmt.Sprintf("player_%d", i)]
+		if len(values) == 0 {
 			http.Error(w, "All players must answer", http.StatusBadRequest)
 			return
 		}
-		answer, err := strconv.Atoi(answerStr)
-		if err != nil || answer < 0 || answer > 3 {
+		answer, err := s.gradeAnswer(r, parsed, values)
+		if err != nil {
 			http.Error(w, "Invalid answer", http.StatusBadRequest)
 			return
 		}
@@ -567,8 +807,9 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 
 	// Update scores
 	for i, answer := range gameSession.Answers[questionNum-1] {
-		if answer == question.CorrectAnswer {
-			gameSession.Scores[i]++
+		gameSession.Scores[i] += answer.Score
+		if firstSubmission {
+			s.recordAnswer(quizID, question, parsed, answer, quizgenerator.GameSinglePlayer)
 		}
 	}
 
@@ -581,31 +822,22 @@ func (s *Server) handleQuestion(w http.ResponseWriter, r *http.Request, quizID s
 
 	if questionNum >= actualQuestions {
 		gameSession.Completed = true
-		session.Values["game"] = gameSession
-		session.Save(r, w)
+		s.saveGame(gameSession)
 		http.Redirect(w, r, fmt.Sprintf("/quiz/%s/results", quizID), http.StatusSeeOther)
 		return
 	}
 
 	// Move to next question
 	gameSession.CurrentQ = questionNum + 1
-	session.Values["game"] = gameSession
-	session.Save(r, w)
+	s.saveGame(gameSession)
 
 	http.Redirect(w, r, fmt.Sprintf("/quiz/%s/%d", quizID, questionNum+1), http.StatusSeeOther)
 }
 
 func (s *Server) handleResults(w http.ResponseWriter, r *http.Request, quizID string) {
 	// Get game session
-	session, _ := s.store.Get(r, "quiz-session")
-	gameInterface := session.Values["game"]
-	if gameInterface == nil {
-		http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
-		return
-	}
-
-	gameSession := gameInterface.(GameSession)
-	if gameSession.QuizID != quizID {
+	gameSession, ok := s.loadGame(r, quizID)
+	if !ok {
 		http.Redirect(w, r, "/quiz/"+quizID, http.StatusSeeOther)
 		return
 	}
@@ -624,34 +856,22 @@ func (s *Server) handleResults(w http.ResponseWriter, r *http.Request, quizID st
 		return
 	}
 
-	var questions []struct {
-		QuestionNum   int
-		Text          string
-		Options       []string
-		CorrectAnswer int
-		Explanation   string
-	}
-
-	for _, q := range dbQuestions {
-		// Parse options
-		options, err := quizgenerator.JSONToOptions(q.Options)
+	var questions []questionResult
+	for i, q := range dbQuestions {
+		question, err := q.ToQuestion()
 		if err != nil {
 			continue
 		}
 
-		questions = append(questions, struct {
-			QuestionNum   int
-			Text          string
-			Options       []string
-			CorrectAnswer int
-			Explanation   string
-		}{
-			QuestionNum:   q.QuestionNum,
-			Text:          q.Text,
-			Options:       options,
-			CorrectAnswer: q.CorrectAnswer,
-			Explanation:   q.Explanation,
-		})
+		result := questionResult{Question: question, QuestionNum: q.QuestionNum}
+		for p, player := range gameSession.Players {
+			var answer Answer
+			if i < len(gameSession.Answers) {
+				answer = gameSession.Answers[i][p]
+			}
+			result.Answers = append(result.Answers, newPlayerAnswer(player.Name, question, answer))
+		}
+		questions = append(questions, result)
 	}
 
 	err = s.templates["results"].ExecuteTemplate(w, "base.html", map[string]interface{}{
@@ -666,6 +886,220 @@ func (s *Server) handleResults(w http.ResponseWriter, r *http.Request, quizID st
 	}
 }
 
+// handleAdmin shows the LLM token usage and cost of every quiz, and the questions judges disagreed on
+func (s *Server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	allQuizzes, err := s.db.GetQuizzes(0)
+	if err != nil {
+		log.Printf("Failed to get quizzes: %v", err)
+		http.Error(w, "Failed to get quizzes", http.StatusInternalServerError)
+		return
+	}
+
+	type quizUsage struct {
+		Quiz   quizgenerator.DBQuiz
+		Stages map[string]quizgenerator.StageUsage
+	}
+
+	var quizzes []quizUsage
+	var totalPrompt, totalCompletion int
+	var totalCost float64
+	for _, quiz := range allQuizzes {
+		var stages map[string]quizgenerator.StageUsage
+		if err := json.Unmarshal([]byte(quiz.StageUsage), &stages); err != nil {
+			log.Printf("Failed to parse stage usage for quiz %s: %v", quiz.ID, err)
+		}
+		quizzes = append(quizzes, quizUsage{Quiz: quiz, Stages: stages})
+		totalPrompt += quiz.PromptTokens
+		totalCompletion += quiz.CompletionTokens
+		totalCost += quiz.CostUSD
+	}
+
+	type contestedQuestion struct {
+		Question quizgenerator.DBQuestion
+		Votes    []quizgenerator.JudgeVote
+	}
+
+	dbContested, err := s.db.GetContestedQuestions(20)
+	if err != nil {
+		log.Printf("Failed to get contested questions: %v", err)
+	}
+	var contested []contestedQuestion
+	for _, question := range dbContested {
+		// Questions contested only by the blind solver have no votes
+		var votes []quizgenerator.JudgeVote
+		if question.Votes != "" {
+			if err := json.Unmarshal([]byte(question.Votes), &votes); err != nil {
+				log.Printf("Failed to parse votes for question %s: %v", question.ID, err)
+			}
+		}
+		contested = append(contested, contestedQuestion{Question: question, Votes: votes})
+	}
+
+	err = s.templates["admin"].ExecuteTemplate(w, "base.html", map[string]interface{}{
+		"Quizzes":               quizzes,
+		"TotalPromptTokens":     totalPrompt,
+		"TotalCompletionTokens": totalCompletion,
+		"TotalCost":             totalCost,
+		"Contested":             contested,
+	})
+	if err != nil {
+		log.Printf("Template error in admin: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// questionResult is a question on a results page with every player's answer to it
+type questionResult struct {
+	*quizgenerator.Question
+	QuestionNum int
+	Answers     []playerAnswer
+}
+
+// given reports whether the player has answered
+func (answer Answer) given() bool {
+	return len(answer.Selected) > 0 || answer.Response != ""
+}
+
+// playerAnswer is one player's answer to a question on a results page
+type playerAnswer struct {
+	Player   string
+	Answered bool
+	Choice   string  // Letters and text of the chosen options, the typed answer, or the order or pairs given
+	Score    float64 // Credit earned, from 0 to 1
+}
+
+// Correct reports whether the answer earned full credit
+func (answer playerAnswer) Correct() bool {
+	return answer.Score >= 1
+}
+
+// Partial reports whether the answer earned some but not full credit
+func (answer playerAnswer) Partial() bool {
+	return answer.Score > 0 && answer.Score < 1
+}
+
+func newPlayerAnswer(player string, question *quizgenerator.Question, answer Answer) playerAnswer {
+	if question.FreeResponse() {
+		return playerAnswer{
+			Player:   player,
+			Answered: answer.Response != "",
+			Choice:   answer.Response,
+			Score:    answer.Score,
+		}
+	}
+	if question.Arranged() {
+		return playerAnswer{
+			Player:   player,
+			Answered: len(answer.Selected) > 0,
+			Choice:   question.ArrangementText(answer.Selected),
+			Score:    answer.Score,
+		}
+	}
+	var choices []string
+	for _, index := range answer.Selected {
+		if index >= 0 && index < len(question.Options) {
+			choices = append(choices, fmt.Sprintf("%s) %s", optionLetter(index), question.Options[index]))
+		}
+	}
+	return playerAnswer{
+		Player:   player,
+		Answered: len(answer.Selected) > 0,
+		Choice:   strings.Join(choices, ", "),
+		Score:    answer.Score,
+	}
+}
+
+// gradeAnswer grades a player's submitted form values: the chosen options, or
+// the typed answer to a free-response question
+func (s *Server) gradeAnswer(r *http.Request, question *quizgenerator.Question, values []string) (Answer, error) {
+	if !question.FreeResponse() {
+		selected, err := parseSelection(values, question)
+		if err != nil {
+			return Answer{}, err
+		}
+		return Answer{Selected: selected, Score: question.Score(selected)}, nil
+	}
+
+	if len(values) != 1 || strings.TrimSpace(values[0]) == "" {
+		return Answer{}, fmt.Errorf("missing answer")
+	}
+	response := strings.TrimSpace(values[0])
+	if utf8.RuneCountInString(response) > maxResponseChars {
+		return Answer{}, fmt.Errorf("answer longer than %d characters", maxResponseChars)
+	}
+	grade, err := s.grader.Grade(r.Context(), question, response, nil)
+	if err != nil && !errors.Is(err, quizgenerator.ErrBudgetExhausted) {
+		// The rules' grade still stands when the LLM grader fails or the budget is spent
+		log.Printf("Failed to grade answer to question %s with the LLM: %v", question.ID, err)
+	}
+	return Answer{Response: response, Score: grade.Score()}, nil
+}
+
+// answerUnit returns the unit a numeric question is answered in, if any
+func answerUnit(question *quizgenerator.Question) string {
+	if question.Numeric == nil {
+		return ""
+	}
+	return question.Numeric.Unit
+}
+
+// parseSelection converts the submitted option indexes of an answer, checking
+// they are options of the question and that only multi-select questions get
+// several. Ordering questions submit every option once in the order given,
+// and matching questions the index of a match for each option in turn.
+func parseSelection(values []string, question *quizgenerator.Question) ([]int, error) {
+	choices := len(question.Options)
+	switch question.Kind() {
+	case quizgenerator.QuestionMultiSelect:
+	case quizgenerator.QuestionOrdering, quizgenerator.QuestionMatching:
+		if len(values) != len(question.Options) {
+			return nil, fmt.Errorf("%d answers given for %d items", len(values), len(question.Options))
+		}
+		if question.Kind() == quizgenerator.QuestionMatching {
+			choices = len(question.Matches)
+		}
+	default:
+		if len(values) > 1 {
+			return nil, fmt.Errorf("only one answer allowed")
+		}
+	}
+
+	selected := make([]int, 0, len(values))
+	for _, value := range values {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= choices {
+			return nil, fmt.Errorf("invalid answer %q", value)
+		}
+		// Matching questions may give several options the same match; ordering
+		// ones must place each option once, and others ignore repeats
+		if question.Kind() != quizgenerator.QuestionMatching && slices.Contains(selected, index) {
+			if question.Kind() == quizgenerator.QuestionOrdering {
+				return nil, fmt.Errorf("option %d placed twice", index)
+			}
+			continue
+		}
+		selected = append(selected, index)
+	}
+	return selected, nil
+}
+
+// optionLetter labels an option A, B, C...
+func optionLetter(index int) string {
+	return string(rune('A' + index))
+}
+
+// toFloat converts a template number to float64
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
 func generateQuizID() string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	b := make([]byte, 12)
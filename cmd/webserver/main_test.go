@@ -0,0 +1,581 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quizgenerator"
+
+	"github.com/gorilla/sessions"
+)
+
+// newTestServer starts a server on an empty database in a temporary
+// directory, generating quizzes from the cassette recorded in testdata. The
+// admin pages are disabled unless adminToken is set.
+func newTestServer(t *testing.T, adminToken string) (*Server, *httptest.Server) {
+	t.Helper()
+	templates, err := loadTemplates(filepath.Join("..", "..", "templates"))
+	if err != nil {
+		t.Fatalf("loadTemplates failed: %v", err)
+	}
+	cassette, err := filepath.Abs(filepath.Join("..", "..", "testdata", "volcanoes.json"))
+	if err != nil {
+		t.Fatalf("failed to resolve cassette path: %v", err)
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	db, err := quizgenerator.OpenDB(filepath.Join(dir, "quiz.db"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables failed: %v", err)
+	}
+	cfg := quizgenerator.DefaultConfig()
+	cfg.Provider = quizgenerator.ProviderOptions{Cassette: cassette, CassetteMode: quizgenerator.CassetteReplay}
+	cfg.Retry.BaseDelayMs = 1
+	db.SetConfig(cfg)
+
+	server := &Server{
+		db:                  db,
+		store:               sessions.NewCookieStore([]byte("test-secret")),
+		templates:           templates,
+		grader:              quizgenerator.NewAnswerGrader(nil, cfg.ResolveStage(cfg.Grader, quizgenerator.DefaultFastModel), cfg.Grading),
+		images:              quizgenerator.NewImageStore(filepath.Join(dir, "images")),
+		adminToken:          adminToken,
+		multiplayerSessions: make(map[string]*MultiplayerSession),
+		playerTokens:        make(map[string]PlayerTokenInfo),
+		games:               make(map[string]GameSession),
+	}
+	ts := httptest.NewServer(server.routes())
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+// newTestClient returns a client that keeps cookies and doesn't follow redirects
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %v", err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newAdminClient returns a test client that signs in to the admin pages with token
+func newAdminClient(t *testing.T, token string) *http.Client {
+	t.Helper()
+	client := newTestClient(t)
+	client.Transport = adminTransport{token: token}
+	return client
+}
+
+// adminTransport adds the admin token as the basic auth password of every request
+type adminTransport struct {
+	token string
+}
+
+func (a adminTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth("admin", a.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fetch sends a request and returns the response status, redirect location and body
+func fetch(t *testing.T, client *http.Client, req *http.Request) (int, string, string) {
+	t.Helper()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response to %s %s: %v", req.Method, req.URL, err)
+	}
+	return resp.StatusCode, resp.Header.Get("Location"), string(body)
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	return fetch(t, client, req)
+}
+
+func post(t *testing.T, client *http.Client, url string, form url.Values) (int, string, string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetch(t, client, req)
+}
+
+// postFile posts a multipart form with the file uploaded as field
+func postFile(t *testing.T, client *http.Client, url string, form url.Values, field, filename string, data []byte) (int, string, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range form {
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				t.Fatalf("failed to write form field: %v", err)
+			}
+		}
+	}
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatalf("failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close form: %v", err)
+	}
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return fetch(t, client, req)
+}
+
+// waitForQuiz waits for a quiz to finish generating
+func waitForQuiz(t *testing.T, db *quizgenerator.DB, quizID string) *quizgenerator.DBQuiz {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		quiz, err := db.GetQuiz(quizID)
+		if err != nil {
+			t.Fatalf("GetQuiz failed: %v", err)
+		}
+		if quiz.Status != "generating" && quiz.Status != "ready" {
+			return quiz
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("quiz %s is still %s", quizID, quiz.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlayQuiz(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+
+	if status, _, _ := get(t, client, ts.URL+"/"); status != http.StatusOK {
+		t.Fatalf("home page status %d", status)
+	}
+
+	status, location, _ := post(t, client, ts.URL+"/quiz/new", url.Values{
+		"topic":         {"Volcanoes"},
+		"num_questions": {"4"},
+		"question_type": {"single_choice", "multi_select"},
+	})
+	if status != http.StatusSeeOther || !strings.HasPrefix(location, "/quiz/") {
+		t.Fatalf("new quiz status %d, location %q", status, location)
+	}
+	quizID := strings.TrimPrefix(location, "/quiz/")
+	if quiz := waitForQuiz(t, server.db, quizID); quiz.Status != "completed" || quiz.NumQuestions != 4 {
+		t.Fatalf("quiz status %q with %d questions, want completed with 4", quiz.Status, quiz.NumQuestions)
+	}
+
+	// Questions can't be played before the game is set up
+	if status, location, _ := get(t, client, ts.URL+location+"/1"); status != http.StatusSeeOther || location != "/quiz/"+quizID {
+		t.Errorf("question without a game status %d, location %q", status, location)
+	}
+
+	status, location, _ = post(t, client, ts.URL+"/quiz/"+quizID, url.Values{"num_players": {"1"}, "player_1": {"Ann"}})
+	if status != http.StatusSeeOther || location != "/quiz/"+quizID+"/1" {
+		t.Fatalf("quiz setup status %d, location %q", status, location)
+	}
+
+	questions, err := server.db.GetQuestions(quizID)
+	if err != nil {
+		t.Fatalf("GetQuestions failed: %v", err)
+	}
+	for _, dbQuestion := range questions {
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			t.Fatalf("ToQuestion failed: %v", err)
+		}
+		page := fmt.Sprintf("%s/quiz/%s/%d", ts.URL, quizID, dbQuestion.QuestionNum)
+		status, _, body := get(t, client, page)
+		if status != http.StatusOK || !strings.Contains(body, question.Text) {
+			t.Fatalf("question %d status %d, page doesn't show %q", dbQuestion.QuestionNum, status, question.Text)
+		}
+
+		var answer []string
+		for _, index := range question.CorrectOptions() {
+			answer = append(answer, strconv.Itoa(index))
+		}
+		if status, _, _ := post(t, client, page, url.Values{"player_0": answer}); status != http.StatusSeeOther {
+			t.Fatalf("answering question %d status %d", dbQuestion.QuestionNum, status)
+		}
+	}
+
+	status, _, body := get(t, client, ts.URL+"/quiz/"+quizID+"/results")
+	if status != http.StatusOK || !strings.Contains(body, "Ann: 4/4") {
+		t.Errorf("results status %d, page doesn't give Ann all 4 points:\n%s", status, body)
+	}
+}
+
+func TestAnswerValidation(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	storeTestQuiz(t, server.db, "quiz1")
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}})
+
+	for _, answer := range [][]string{nil, {"4"}, {"x"}, {"0", "1"}} {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/quiz1/1", url.Values{"player_0": answer}); status != http.StatusBadRequest {
+			t.Errorf("answer %v status %d, want %d", answer, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTypedAnswers(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	quiz := &quizgenerator.DBQuiz{ID: "quiz1", Topic: "Mountains", NumQuestions: 2, Status: "completed", CreatedAt: time.Now()}
+	if err := server.db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	for _, question := range []*quizgenerator.DBQuestion{
+		{ID: "quiz1-q1", Type: "short_answer", Text: "What is the highest mountain?", Options: "[]", AcceptedAnswers: `["Mount Everest"]`},
+		{ID: "quiz1-q2", Type: "numeric", Text: "How high is Everest?", Options: "[]", NumericAnswer: `{"value":8849,"tolerance":10,"unit":"m"}`},
+	} {
+		question.QuizID = "quiz1"
+		question.QuestionNum, _ = strconv.Atoi(strings.TrimPrefix(question.ID, "quiz1-q"))
+		if err := server.db.CreateQuestion(question); err != nil {
+			t.Fatalf("CreateQuestion failed: %v", err)
+		}
+	}
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}, "player_1": {"Ann"}})
+
+	// Blank and overlong answers are refused
+	for _, answer := range []string{" ", strings.Repeat("x", maxResponseChars+1)} {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/quiz1/1", url.Values{"player_0": {answer}}); status != http.StatusBadRequest {
+			t.Errorf("answer of %d characters status %d, want %d", len(answer), status, http.StatusBadRequest)
+		}
+	}
+
+	if status, _, body := get(t, client, ts.URL+"/quiz/quiz1/2"); status != http.StatusOK || !strings.Contains(body, "Everest") {
+		t.Errorf("numeric question page status %d", status)
+	}
+	for num, answer := range []string{"mount everst", "8.85 km"} {
+		if status, _, _ := post(t, client, fmt.Sprintf("%s/quiz/quiz1/%d", ts.URL, num+1), url.Values{"player_0": {answer}}); status != http.StatusSeeOther {
+			t.Fatalf("answering question %d status %d", num+1, status)
+		}
+	}
+	status, _, body := get(t, client, ts.URL+"/quiz/quiz1/results")
+	if status != http.StatusOK || !strings.Contains(body, "Ann: 2/2") || !strings.Contains(body, "8.85 km") {
+		t.Errorf("results status %d, page doesn't give Ann both points for the typed answers:\n%s", status, body)
+	}
+}
+
+func TestArrangedAnswers(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	quiz := &quizgenerator.DBQuiz{ID: "quiz1", Topic: "Elements", NumQuestions: 2, Status: "completed", CreatedAt: time.Now()}
+	if err := server.db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	for _, question := range []*quizgenerator.DBQuestion{
+		{ID: "quiz1-q1", Type: "ordering", Text: "Order by atomic number, lowest first.", Options: `["Iron","Hydrogen","Gold"]`, CorrectAnswers: "[1,0,2]"},
+		{ID: "quiz1-q2", Type: "matching", Text: "Match each symbol to its element.", Options: `["Fe","Au"]`, Matches: `["gold","iron"]`, CorrectAnswers: "[1,0]"},
+	} {
+		question.QuizID = "quiz1"
+		question.QuestionNum, _ = strconv.Atoi(strings.TrimPrefix(question.ID, "quiz1-q"))
+		if err := server.db.CreateQuestion(question); err != nil {
+			t.Fatalf("CreateQuestion failed: %v", err)
+		}
+	}
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}, "player_1": {"Ann"}})
+
+	// Every item must be placed exactly once, and matched to a match that exists
+	for _, answer := range [][]string{{"1", "0"}, {"1", "1", "2"}, {"1", "0", "3"}} {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/quiz1/1", url.Values{"player_0": answer}); status != http.StatusBadRequest {
+			t.Errorf("order %v status %d, want %d", answer, status, http.StatusBadRequest)
+		}
+	}
+	if status, _, _ := post(t, client, ts.URL+"/quiz/quiz1/2", url.Values{"player_0": {"0", "2"}}); status != http.StatusBadRequest {
+		t.Errorf("match out of range status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status, _, body := get(t, client, ts.URL+"/quiz/quiz1/2"); status != http.StatusOK || !strings.Contains(body, "gold") {
+		t.Errorf("matching question page status %d doesn't list the matches", status)
+	}
+	for num, answer := range [][]string{{"1", "0", "2"}, {"0", "0"}} {
+		if status, _, _ := post(t, client, fmt.Sprintf("%s/quiz/quiz1/%d", ts.URL, num+1), url.Values{"player_0": answer}); status != http.StatusSeeOther {
+			t.Fatalf("answering question %d status %d", num+1, status)
+		}
+	}
+	status, _, body := get(t, client, ts.URL+"/quiz/quiz1/results")
+	if status != http.StatusOK || !strings.Contains(body, "Ann: 1.5/2") || !strings.Contains(body, "Hydrogen → Iron → Gold") {
+		t.Errorf("results status %d, page doesn't give Ann one and a half points:\n%s", status, body)
+	}
+}
+
+func TestResultsWithMalformedAnswerKey(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	quiz := &quizgenerator.DBQuiz{ID: "quiz1", Topic: "Elements", NumQuestions: 2, Status: "completed", CreatedAt: time.Now()}
+	if err := server.db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	// Answer keys pointing past the options or matches, as an edited or older database might hold
+	for _, question := range []*quizgenerator.DBQuestion{
+		{ID: "quiz1-q1", Type: "ordering", Text: "Order by atomic number, lowest first.", Options: `["Iron","Hydrogen"]`, CorrectAnswers: "[1,0,5]"},
+		{ID: "quiz1-q2", Type: "matching", Text: "Match each symbol to its element.", Options: `["Fe","Au"]`, Matches: `["gold","iron"]`, CorrectAnswers: "[1]"},
+	} {
+		question.QuizID = "quiz1"
+		question.QuestionNum, _ = strconv.Atoi(strings.TrimPrefix(question.ID, "quiz1-q"))
+		if err := server.db.CreateQuestion(question); err != nil {
+			t.Fatalf("CreateQuestion failed: %v", err)
+		}
+	}
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}})
+	for num, answer := range [][]string{{"1", "0"}, {"1", "0"}} {
+		if status, _, _ := post(t, client, fmt.Sprintf("%s/quiz/quiz1/%d", ts.URL, num+1), url.Values{"player_0": answer}); status != http.StatusSeeOther {
+			t.Fatalf("answering question %d status %d", num+1, status)
+		}
+	}
+
+	status, _, body := get(t, client, ts.URL+"/quiz/quiz1/results")
+	if status != http.StatusOK || !strings.Contains(body, "2. Iron") || !strings.Contains(body, "Fe: iron") || !strings.Contains(body, "</html>") {
+		t.Errorf("results status %d, page doesn't show the valid parts of the answer keys:\n%s", status, body)
+	}
+}
+
+func TestGamesAreKeptServerSide(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	const numQuestions = 8
+	quiz := &quizgenerator.DBQuiz{ID: "quiz1", Topic: "Mountains", NumQuestions: numQuestions, Status: "completed", CreatedAt: time.Now()}
+	if err := server.db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	for num := 1; num <= numQuestions; num++ {
+		question := &quizgenerator.DBQuestion{
+			ID:              fmt.Sprintf("quiz1-q%d", num),
+			QuizID:          "quiz1",
+			QuestionNum:     num,
+			Type:            "short_answer",
+			Text:            fmt.Sprintf("Which is mountain %d?", num),
+			Options:         "[]",
+			AcceptedAnswers: fmt.Sprintf(`["Mountain %d"]`, num),
+		}
+		if err := server.db.CreateQuestion(question); err != nil {
+			t.Fatalf("CreateQuestion failed: %v", err)
+		}
+	}
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"2"}})
+
+	// Long typed answers from every player would overflow a session cookie
+	answer := strings.Repeat("x", maxResponseChars)
+	for num := 1; num <= numQuestions; num++ {
+		status, _, _ := post(t, client, fmt.Sprintf("%s/quiz/quiz1/%d", ts.URL, num), url.Values{"player_0": {answer}, "player_1": {answer}})
+		if status != http.StatusSeeOther {
+			t.Fatalf("answering question %d status %d", num, status)
+		}
+	}
+	if status, _, body := get(t, client, ts.URL+"/quiz/quiz1/results"); status != http.StatusOK || !strings.Contains(body, answer) {
+		t.Errorf("results status %d, page doesn't show the typed answers", status)
+	}
+
+	// Another browser has no game to continue
+	if status, location, _ := get(t, newTestClient(t), ts.URL+"/quiz/quiz1/results"); status != http.StatusSeeOther || location != "/quiz/quiz1" {
+		t.Errorf("results without a game status %d, location %q", status, location)
+	}
+
+	// Games idle for too long are forgotten when another is saved
+	server.mu.Lock()
+	for id, game := range server.games {
+		game.Updated = time.Now().Add(-gameIdleTimeout - time.Minute)
+		server.games[id] = game
+	}
+	server.mu.Unlock()
+	server.saveGame(GameSession{ID: "new", QuizID: "quiz1"})
+	if len(server.games) != 1 {
+		t.Errorf("%d games kept, want only the new one", len(server.games))
+	}
+}
+
+func TestNewQuizValidation(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+
+	for _, form := range []url.Values{
+		{"num_questions": {"4"}},
+		{"topic": {"Volcanoes"}, "question_type": {"essay"}},
+		{"topic": {"Volcanoes"}, "num_options": {"1"}},
+	} {
+		if status, _, _ := post(t, client, ts.URL+"/quiz/new", form); status != http.StatusBadRequest {
+			t.Errorf("new quiz %v status %d, want %d", form, status, http.StatusBadRequest)
+		}
+	}
+	if quizzes, err := server.db.GetQuizzes(0); err != nil || len(quizzes) != 0 {
+		t.Errorf("invalid requests created quizzes %v (%v)", quizzes, err)
+	}
+}
+
+func TestNewQuizFromSourceFile(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	form := url.Values{"topic": {"Volcanoes"}, "num_questions": {"2"}, "source_material": {"Notes pasted in the form."}}
+
+	status, location, _ := postFile(t, client, ts.URL+"/quiz/new", form, "source_file", "notes.md", []byte("# Volcanoes\n\nMagma **rises** through vents."))
+	if status != http.StatusSeeOther || !strings.HasPrefix(location, "/quiz/") {
+		t.Fatalf("new quiz status %d, location %q", status, location)
+	}
+	quiz := waitForQuiz(t, server.db, strings.TrimPrefix(location, "/quiz/"))
+	if quiz.SourceFilename != "notes.md" || quiz.SourceMaterial != "Notes pasted in the form.\n\nVolcanoes\n\nMagma rises through vents." {
+		t.Errorf("quiz source %q from %q, want the pasted notes followed by the file's text", quiz.SourceMaterial, quiz.SourceFilename)
+	}
+
+	if status, _, _ := postFile(t, client, ts.URL+"/quiz/new", form, "source_file", "slides.pptx", []byte("data")); status != http.StatusBadRequest {
+		t.Errorf("unsupported source file status %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestNewQuizDeletesUnusedSourceImages(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	// The first image is already shown by another quiz; the recorded questions show neither
+	shown, err := server.images.Put(testPNG)
+	if err != nil {
+		t.Fatalf("failed to store image: %v", err)
+	}
+	storeTestQuiz(t, server.db, "quiz1")
+	if err := server.db.UpdateQuestionImage("quiz1", 1, &quizgenerator.QuestionImage{Name: shown}); err != nil {
+		t.Fatalf("UpdateQuestionImage failed: %v", err)
+	}
+	other := append(bytes.Clone(testPNG), 0)
+	epub := testEPUB(t, `Calderas form when a volcano collapses. <img src="crater.png" alt="A caldera"/> <img src="vent.png" alt="A vent"/>`,
+		map[string][]byte{"OEBPS/crater.png": testPNG, "OEBPS/vent.png": other})
+
+	status, location, _ := postFile(t, client, ts.URL+"/quiz/new", url.Values{"topic": {"Volcanoes"}, "num_questions": {"2"}}, "source_file", "book.epub", epub)
+	if status != http.StatusSeeOther || !strings.HasPrefix(location, "/quiz/") {
+		t.Fatalf("new quiz status %d, location %q", status, location)
+	}
+	waitForQuiz(t, server.db, strings.TrimPrefix(location, "/quiz/"))
+
+	// Images are named by their content, so another store gives the same name
+	unused, err := quizgenerator.NewImageStore(t.TempDir()).Put(other)
+	if err != nil {
+		t.Fatalf("failed to name image: %v", err)
+	}
+	// Images are deleted once generation returns, just after the quiz is marked done
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := server.images.Get(unused); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("source image no question shows is still in the store")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := server.images.Get(shown); err != nil {
+		t.Errorf("image another quiz shows was deleted: %v", err)
+	}
+}
+
+func TestGeneratingPageShowsProgress(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	quiz := &quizgenerator.DBQuiz{ID: "quiz1", Topic: "Volcanoes", NumQuestions: 5, Status: "generating", CreatedAt: time.Now()}
+	if err := server.db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	event := quizgenerator.GenerationEvent{Type: quizgenerator.EventBatchRequested, BatchSize: 5, Time: time.Now()}
+	if err := server.db.CreateGenerationEvent("quiz1", event); err != nil {
+		t.Fatalf("CreateGenerationEvent failed: %v", err)
+	}
+	post(t, client, ts.URL+"/quiz/quiz1", url.Values{"num_players": {"1"}})
+
+	status, _, body := get(t, client, ts.URL+"/quiz/quiz1/1")
+	if status != http.StatusOK || !strings.Contains(body, "Asked for 5 new questions") {
+		t.Errorf("generating page status %d, doesn't show the batch request:\n%s", status, body)
+	}
+}
+
+func TestNewQuizOverDailyBudget(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	client := newTestClient(t)
+	server.daily = quizgenerator.NewDailyBudget(1, 1.5)
+
+	if status, _, _ := post(t, client, ts.URL+"/quiz/new", url.Values{"topic": {"Volcanoes"}}); status != http.StatusServiceUnavailable {
+		t.Errorf("new quiz over the daily budget status %d, want %d", status, http.StatusServiceUnavailable)
+	}
+}
+
+// storeTestQuiz stores a completed quiz with one question whose answer is option 1
+func storeTestQuiz(t *testing.T, db *quizgenerator.DB, quizID string) {
+	t.Helper()
+	quiz := &quizgenerator.DBQuiz{ID: quizID, Topic: "Volcanoes", NumQuestions: 1, Status: "completed", CreatedAt: time.Now()}
+	if err := db.CreateQuiz(quiz); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	question := &quizgenerator.DBQuestion{
+		ID:            quizID + "-q1",
+		QuizID:        quizID,
+		QuestionNum:   1,
+		Text:          "Which volcano buried Pompeii?",
+		Options:       `["Etna","Vesuvius","Hekla","Fuji"]`,
+		CorrectAnswer: 1,
+		SourceStart:   -1,
+		SourceEnd:     -1,
+	}
+	if err := db.CreateQuestion(question); err != nil {
+		t.Fatalf("CreateQuestion failed: %v", err)
+	}
+}
+
+// testEPUB builds an EPUB with one chapter, in OEBPS, and the given files
+func testEPUB(t *testing.T, chapter string, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files["mimetype"] = []byte("application/epub+zip")
+	files["META-INF/container.xml"] = []byte(`<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`)
+	files["OEBPS/content.opf"] = []byte(`<package><manifest><item id="a" href="a.xhtml"/></manifest><spine><itemref idref="a"/></spine></package>`)
+	files["OEBPS/a.xhtml"] = []byte("<html><body><p>" + chapter + "</p></body></html>")
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close EPUB: %v", err)
+	}
+	return buf.Bytes()
+}
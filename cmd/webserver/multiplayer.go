@@ -85,7 +85,7 @@ func (s *Server) handleNewMultiplayer(w http.ResponseWriter, r *http.Request) {
 		// Filter to only show completed quizzes
 		var completedQuizzes []quizgenerator.DBQuiz
 		for _, quiz := range allQuizzes {
-			if quiz.Status == "completed" {
+			if quiz.Playable() {
 				completedQuizzes = append(completedQuizzes, quiz)
 			}
 		}
@@ -131,7 +131,7 @@ func (s *Server) handleNewMultiplayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if quiz.Status != "completed" {
+	if !quiz.Playable() {
 		http.Error(w, "Quiz is not ready for multiplayer", http.StatusBadRequest)
 		return
 	}
@@ -147,7 +147,7 @@ func (s *Server) handleNewMultiplayer(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:  time.Now(),
 		MaxPlayers: 10,
 		Players:    []MultiplayerPlayer{},
-		Answers:    make(map[int]map[string]int),
+		Answers:    make(map[int]map[string]Answer),
 	}
 
 	// Add host as first player
@@ -368,10 +368,10 @@ func (s *Server) handleSubmitAnswer(w http.ResponseWriter, r *http.Request, play
 		return
 	}
 
-	answerStr := r.FormValue("answer")
+	answers := r.Form["answer"]
 	questionNumStr := r.FormValue("question_num")
 
-	if answerStr == "" || questionNumStr == "" {
+	if len(answers) == 0 || questionNumStr == "" {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
@@ -382,12 +382,6 @@ func (s *Server) handleSubmitAnswer(w http.ResponseWriter, r *http.Request, play
 		return
 	}
 
-	answer, err := strconv.Atoi(answerStr)
-	if err != nil || answer < 0 || answer > 3 {
-		http.Error(w, "Invalid answer", http.StatusBadRequest)
-		return
-	}
-
 	// Get session
 	s.mu.RLock()
 	session, exists := s.multiplayerSessions[playerInfo.SessionID]
@@ -398,14 +392,37 @@ func (s *Server) handleSubmitAnswer(w http.ResponseWriter, r *http.Request, play
 		return
 	}
 
+	// Check the answer fits the question
+	dbQuestion, err := s.db.GetQuestion(session.QuizID, questionNum)
+	if err != nil {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	question, err := dbQuestion.ToQuestion()
+	if err != nil {
+		http.Error(w, "Failed to parse question", http.StatusInternalServerError)
+		return
+	}
+	answer, err := s.gradeAnswer(r, question, answers)
+	if err != nil {
+		http.Error(w, "Invalid answer", http.StatusBadRequest)
+		return
+	}
+
 	// Record the answer
 	session.mu.Lock()
 	if session.Answers[questionNum] == nil {
-		session.Answers[questionNum] = make(map[string]int)
+		session.Answers[questionNum] = make(map[string]Answer)
 	}
+	_, resubmitted := session.Answers[questionNum][playerInfo.PlayerID]
 	session.Answers[questionNum][playerInfo.PlayerID] = answer
 	session.mu.Unlock()
 
+	// Only a player's first answer counts towards the question's difficulty
+	if !resubmitted {
+		s.recordAnswer(session.QuizID, dbQuestion, question, answer, quizgenerator.GameMultiplayer)
+	}
+
 	// Check if all players have answered
 	allAnswered := s.checkAllPlayersAnswered(playerInfo.SessionID, questionNum)
 	if allAnswered {
@@ -468,35 +485,29 @@ func (s *Server) handleMultiplayerResults(w http.ResponseWriter, _ *http.Request
 		return
 	}
 
+	session.mu.RLock()
+	players := make([]MultiplayerPlayer, len(session.Players))
+	copy(players, session.Players)
+
 	// Only show questions that were actually played (have answers)
-	var playedQuestions []map[string]interface{}
-	for i, question := range dbQuestions {
+	var playedQuestions []questionResult
+	for i, dbQuestion := range dbQuestions {
 		questionNum := i + 1 // Convert to 1-based indexing
-		if answers, exists := session.Answers[questionNum]; exists && len(answers) > 0 {
-			options, err := quizgenerator.JSONToOptions(question.Options)
-			if err != nil {
-				log.Printf("Failed to parse options for question %d: %v", questionNum, err)
-				options = []string{"Error parsing options"}
-			}
-
-			playedQuestions = append(playedQuestions, map[string]interface{}{
-				"Text":          question.Text,
-				"Options":       options,
-				"CorrectAnswer": question.CorrectAnswer,
-				"Explanation":   question.Explanation,
-			})
+		answers, exists := session.Answers[questionNum]
+		if !exists || len(answers) == 0 {
+			continue
+		}
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			log.Printf("Failed to parse question %d: %v", questionNum, err)
+			continue
 		}
-	}
 
-	session.mu.RLock()
-	players := make([]MultiplayerPlayer, len(session.Players))
-	copy(players, session.Players)
-	answers := make(map[int]map[string]int)
-	for q, a := range session.Answers {
-		answers[q] = make(map[string]int)
-		for playerID, answer := range a {
-			answers[q][playerID] = answer
+		result := questionResult{Question: question, QuestionNum: questionNum}
+		for _, player := range players {
+			result.Answers = append(result.Answers, newPlayerAnswer(player.Name, question, answers[player.ID]))
 		}
+		playedQuestions = append(playedQuestions, result)
 	}
 	session.mu.RUnlock()
 
@@ -505,7 +516,6 @@ func (s *Server) handleMultiplayerResults(w http.ResponseWriter, _ *http.Request
 		"Quiz":      quiz,
 		"Players":   players,
 		"Questions": playedQuestions,
-		"Answers":   answers,
 	})
 	if err != nil {
 		log.Printf("Template error in multiplayer_results: %v", err)
@@ -567,23 +577,17 @@ func (s *Server) moveToNextQuestion(sessionID string, currentQuestionNum int) {
 }
 
 func (s *Server) updateScores(session *MultiplayerSession, questionNum int) {
-	// Get the question to check correct answer
-	question, err := s.db.GetQuestion(session.QuizID, questionNum)
-	if err != nil {
-		log.Printf("Failed to get question for scoring: %v", err)
-		return
-	}
-
-	// Update scores for players who answered correctly
+	// Answers were graded when submitted, with partial credit for multi-select questions
 	if answers, exists := session.Answers[questionNum]; exists {
 		for playerID, answer := range answers {
-			if answer == question.CorrectAnswer {
-				// Find player and update score
-				for i := range session.Players {
-					if session.Players[i].ID == playerID {
-						session.Players[i].Score++
-						break
-					}
+			if answer.Score == 0 {
+				continue
+			}
+			// Find player and update score
+			for i := range session.Players {
+				if session.Players[i].ID == playerID {
+					session.Players[i].Score += answer.Score
+					break
 				}
 			}
 		}
@@ -677,7 +681,7 @@ func (s *Server) handleQuestionContent(w http.ResponseWriter, r *http.Request, s
 	}
 
 	// Parse options
-	options, err := quizgenerator.JSONToOptions(question.Options)
+	parsed, err := question.ToQuestion()
 	if err != nil {
 		http.Error(w, "Failed to parse question", http.StatusInternalServerError)
 		return
@@ -693,7 +697,14 @@ func (s *Server) handleQuestionContent(w http.ResponseWriter, r *http.Request, s
 		"QuestionNum":    currentQ,
 		"TotalQuestions": totalQuestions,
 		"Question":       question.Text,
-		"Options":        options,
+		"Options":        parsed.Options,
+		"MultiSelect":    parsed.Kind() == quizgenerator.QuestionMultiSelect,
+		"Ordering":       parsed.Kind() == quizgenerator.QuestionOrdering,
+		"Matching":       parsed.Kind() == quizgenerator.QuestionMatching,
+		"Matches":        parsed.Matches,
+		"Image":          parsed.Image,
+		"FreeResponse":   parsed.FreeResponse(),
+		"Unit":           answerUnit(parsed),
 		"Players":        players,
 		"PlayerID":       playerID,
 		"PlayerName":     playerName,
@@ -0,0 +1,447 @@
+package quizgenerator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Config holds the provider options and per-stage model and prompt settings
+type Config struct {
+	Provider     ProviderOptions       `json:"provider"`
+	Retry        RetryPolicy           `json:"retry"`
+	Prices       map[string]ModelPrice `json:"prices"`         // Dollars per million tokens, by model name
+	Budget       Budget                `json:"budget"`         // Default per-quiz budget
+	Workers      int                   `json:"workers"`        // Number of questions checked in parallel
+	LowWaterMark int                   `json:"low_water_mark"` // Prefetch the next batch once this few questions are left to check; 0 disables prefetch
+	ChunkChars   int                   `json:"chunk_chars"`    // Split longer source material into chunks of about this many bytes; 0 sends it whole
+	ImageDir     string                `json:"image_dir"`      // Where question images are stored
+	Planner      StageConfig           `json:"planner"`
+	Planning     PlanningConfig        `json:"planning"` // Subtopic outline the maker's batches are spread across
+	Maker        StageConfig           `json:"maker"`
+	MakerHistory HistoryConfig         `json:"maker_history"` // Bounds the conversation the maker keeps across batches
+	Checker      StageConfig           `json:"checker"`
+	Linter       LinterConfig          `json:"linter"`    // Rule-based checks run before the checker
+	Consensus    ConsensusConfig       `json:"consensus"` // Optional panel of judges replacing the single checker
+	Solver       StageConfig           `json:"solver"`
+	Verification VerificationConfig    `json:"verification"` // Blind solving of accepted questions by the solver stage
+	Grader       StageConfig           `json:"grader"`
+	Grading      GradingConfig         `json:"grading"` // How players' typed answers are graded
+	Dedup        StageConfig           `json:"dedup"`
+	DedupFilter  DedupFilterConfig     `json:"dedup_filter"`
+	CrossQuiz    CrossQuizDedupConfig  `json:"cross_quiz_dedup"` // Used by DB.GenerateQuiz
+	Discoverer   StageConfig           `json:"discoverer"`
+}
+
+// StageConfig configures the model and prompts of one pipeline stage.
+// Prompt templates use text/template syntax and receive a PromptData.
+type StageConfig struct {
+	Model          string  `json:"model,omitempty"`            // Falls back to the provider model, then the stage default
+	Temperature    float32 `json:"temperature,omitempty"`      // 0 means the provider default
+	MaxTokens      int     `json:"max_tokens,omitempty"`       // 0 means the provider default
+	SystemPrompt   string  `json:"system_prompt,omitempty"`    // Sent as the system message
+	Prompt         string  `json:"prompt,omitempty"`           // Template for the main user message
+	FollowUpPrompt string  `json:"follow_up_prompt,omitempty"` // Template for later batches (maker only)
+}
+
+// PromptData is passed to every prompt template; fields a stage doesn't use are left empty
+type PromptData struct {
+	Topic             string
+	Difficulty        string
+	SourceMaterial    string
+	SourceExcerpt     bool            // Maker: SourceMaterial is one chunk of a longer document
+	BatchSize         int             // Maker: number of questions requested; planner: number of questions in the quiz
+	Subtopic          *Subtopic       // Maker: subtopic the batch should cover, when planning is enabled
+	MaxSubtopics      int             // Planner: largest number of subtopics to outline
+	PreviousQuestions []string        // Maker: questions from earlier conversations, listed when a new one starts
+	QuestionTypes     []QuestionType  // Maker: types to mix across the batch; empty means single choice only
+	NumOptions        int             // Maker: options per single-choice or multi-select question
+	SourceImages      []QuestionImage // Maker: images from the source material that questions may show
+	Question          *Question       // Checker, dedup and grader: the question being evaluated
+	Response          string          // Grader: the player's typed answer
+	Existing          []*Question     // Dedup: previously accepted questions
+	Category          string          // Discoverer: requested category
+	ExistingTopics    []string        // Discoverer: topics already in the database
+}
+
+// promptFuncs are available to every prompt template
+var promptFuncs = template.FuncMap{
+	"add": func(a, b int) int {
+		return a + b
+	},
+	// options renders a question's options as a numbered list with the correct
+	// ones marked by *, followed by a matching question's numbered matches;
+	// free-response questions render nothing
+	"options": func(q *Question) string {
+		var sb strings.Builder
+		for i, option := range q.Options {
+			marker := " "
+			if q.IsCorrectOption(i) {
+				marker = "*"
+			}
+			sb.WriteString(fmt.Sprintf("%s%d. %s\n", marker, i+1, option))
+		}
+		if len(q.Matches) > 0 {
+			sb.WriteString("Matches:\n")
+			for i, match := range q.Matches {
+				sb.WriteString(fmt.Sprintf(" %d. %s\n", i+1, match))
+			}
+		}
+		return sb.String()
+	},
+	// answers lists the numbers of a question's correct options, e.g. "2" or "1, 3",
+	// the accepted answers of a short-answer question, the range of a numeric
+	// one, or the correct order or pairs of an arranged one
+	"answers": func(q *Question) string {
+		switch q.Kind() {
+		case QuestionOrdering, QuestionMatching:
+			return q.CorrectText()
+		case QuestionShortAnswer:
+			quoted := make([]string, len(q.AcceptedAnswers))
+			for i, answer := range q.AcceptedAnswers {
+				quoted[i] = strconv.Quote(answer)
+			}
+			return strings.Join(quoted, ", ")
+		case QuestionNumeric:
+			if q.Numeric == nil {
+				return ""
+			}
+			return q.Numeric.Range()
+		}
+		var numbers []string
+		for _, index := range q.CorrectOptions() {
+			numbers = append(numbers, strconv.Itoa(index+1))
+		}
+		return strings.Join(numbers, ", ")
+	},
+}
+
+// DefaultConfig returns the built-in configuration, with provider options read from the environment
+func DefaultConfig() *Config {
+	return &Config{
+		Provider:     ProviderOptionsFromEnv(),
+		Retry:        DefaultRetryPolicy(),
+		Prices:       DefaultPrices(),
+		Workers:      4,
+		LowWaterMark: 4,
+		ChunkChars:   8000,
+		ImageDir:     "images",
+		Planner: StageConfig{
+			SystemPrompt: "You are an expert curriculum designer. Break quiz topics down into distinct subtopics that together cover what someone should know about the topic.",
+			Prompt:       defaultPlannerPrompt,
+		},
+		Maker: StageConfig{
+			SystemPrompt:   "You are an expert quiz question generator. Generate high-quality quiz questions in exactly the format requested.",
+			Prompt:         defaultMakerPrompt,
+			FollowUpPrompt: defaultMakerFollowUpPrompt,
+		},
+		MakerHistory: HistoryConfig{MaxTokens: DefaultHistoryTokens, StemTokens: DefaultStemTokens},
+		Checker: StageConfig{
+			SystemPrompt: "You are an expert quiz question validator. Evaluate questions for quality, clarity, and fairness.",
+			Prompt:       defaultCheckerPrompt,
+		},
+		Dedup: StageConfig{
+			SystemPrompt: "You are an expert at detecting duplicate quiz questions. Compare the new question against existing questions and determine if it's a duplicate.",
+			Prompt:       defaultDedupPrompt,
+		},
+		Solver: StageConfig{
+			SystemPrompt: "You are an expert quiz taker. Answer each question as accurately as you can and be honest about how sure you are.",
+			Prompt:       defaultSolverPrompt,
+		},
+		Grader: StageConfig{
+			SystemPrompt: "You are a fair but careful quizmaster. Decide whether players' typed answers should be accepted.",
+			Prompt:       defaultGraderPrompt,
+		},
+		DedupFilter: DefaultDedupFilterConfig(),
+		Discoverer: StageConfig{
+			SystemPrompt: "You are an expert at creating engaging quiz topics. Generate unique, educational topics that would make for interesting multiple choice quizzes. When writing source material, be comprehensive and include specific details that can be used to create accurate questions.",
+			Prompt:       defaultDiscovererPrompt,
+		},
+	}
+}
+
+// LoadConfig reads a JSON config file on top of the defaults; an empty path returns the defaults
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that every prompt template parses and every enumerated setting has a known value
+func (cfg *Config) Validate() error {
+	switch cfg.CrossQuiz.Scope {
+	case "", ScopeAll, ScopeCategory, ScopeTopic:
+	default:
+		return fmt.Errorf("invalid cross-quiz dedup scope %q", cfg.CrossQuiz.Scope)
+	}
+	switch cfg.Consensus.Voting {
+	case "", VoteUnanimous, VoteMajority, VoteAnyReject:
+	default:
+		return fmt.Errorf("invalid consensus voting rule %q", cfg.Consensus.Voting)
+	}
+	switch cfg.Verification.OnMismatch {
+	case "", MismatchReject, MismatchFlag:
+	default:
+		return fmt.Errorf("invalid verification mismatch action %q", cfg.Verification.OnMismatch)
+	}
+
+	stages := map[string]StageConfig{
+		"planner":    cfg.Planner,
+		"maker":      cfg.Maker,
+		"checker":    cfg.Checker,
+		"dedup":      cfg.Dedup,
+		"solver":     cfg.Solver,
+		"grader":     cfg.Grader,
+		"discoverer": cfg.Discoverer,
+	}
+	for name, stage := range stages {
+		for _, tmpl := range []string{stage.Prompt, stage.FollowUpPrompt} {
+			if _, err := template.New(name).Funcs(promptFuncs).Parse(tmpl); err != nil {
+				return fmt.Errorf("invalid %s prompt template: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveStage returns the stage config with its model filled in from the
+// provider model or the given default
+func (cfg *Config) ResolveStage(stage StageConfig, defaultModel string) StageConfig {
+	if stage.Model == "" {
+		stage.Model = cfg.Provider.ModelOr(defaultModel)
+	}
+	return stage
+}
+
+// RenderPrompt executes a prompt template with the given data
+func RenderPrompt(tmpl string, data PromptData) (string, error) {
+	t, err := template.New("prompt").Funcs(promptFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+const defaultMakerPrompt = `Generate {{.BatchSize}} quiz questions about: {{.Topic}}
+
+{{if .SourceMaterial}}Use the following {{if .SourceExcerpt}}excerpt from a longer document{{else}}source material{{end}} as reference:
+{{.SourceMaterial}}
+
+{{end}}{{if .SourceImages}}The source material shows these images, which you can't see but which are described here:
+{{range $i, $image := .SourceImages}}{{add $i 1}}. {{if $image.Alt}}{{$image.Alt}}{{else}}(no description){{end}}
+{{end}}
+{{end}}{{if .Subtopic}}Every question in this batch must be about the subtopic "{{.Subtopic.Name}}": {{.Subtopic.Objective}}
+
+{{end}}{{if .PreviousQuestions}}These questions have already been written, so don't repeat them or ask about the same facts:
+{{range .PreviousQuestions}}- {{.}}
+{{end}}
+{{end}}{{if .Difficulty}}Difficulty level: {{.Difficulty}}
+
+{{end}}Requirements:
+{{if .QuestionTypes}}- Mix these question types across the batch and set each question's type: {{range $i, $type := .QuestionTypes}}{{if $i}}, {{end}}{{$type}}{{end}}
+{{range .QuestionTypes}}{{if eq . "single_choice"}}- single_choice questions have exactly {{$.NumOptions}} options and one correct answer
+{{else if eq . "true_false"}}- true_false questions make a statement that is clearly either true or false; their options are exactly "True" and "False", in that order
+{{else if eq . "multi_select"}}- multi_select questions have exactly {{$.NumOptions}} options, at least one and usually two or more of them correct but never all; list every correct one in correct_answers and say in the question to select all that apply
+{{else if eq . "short_answer"}}- short_answer questions have no options and are answered by typing a word, name or short phrase; ask for something with one clear answer, and put that answer first in accepted_answers followed by other spellings, abbreviations and names that should also count
+{{else if eq . "numeric"}}- numeric questions have no options and are answered by typing a number; give the answer as numeric_answer with its unit, say in the question which unit to answer in, and set tolerance to how far off an answer may be and still count (0 for exact answers such as years or counts)
+{{else if eq . "ordering"}}- ordering questions ask for {{if lt $.NumOptions 3}}3{{else}}{{$.NumOptions}}{{end}} items, such as events, steps or sizes, to be put in order; say in the question which way to order them (e.g. earliest first) and list the items in options in the correct order, as they are shuffled before being shown. Pick items far enough apart that the order is certain
+{{else if eq . "matching"}}- matching questions pair {{$.NumOptions}} terms with their definitions or counterparts; list the terms in options and, in matches, the item each term pairs with in the same order, as the matches are shuffled before being shown. No match may fit more than one term
+{{end}}{{end}}{{else}}- Each question must have exactly {{.NumOptions}} options and one correct answer
+{{end}}- The correct answer should be non-obvious but clearly correct
+- Incorrect options should be plausible but clearly wrong
+- Questions should test understanding, not just memorization
+- Avoid questions where the answer is given away in the question text
+- Provide a brief explanation for why the correct answer is right
+{{if .SourceImages}}- A question may show one of the images by setting image to its number, when the image helps; the question must still make sense to a player looking at it, and must not depend on details the description doesn't mention
+{{end}}{{if .SourceMaterial}}- Every question must be answerable from the source material; copy the sentence or sentences that support the correct answer, word for word, into source_quote
+{{end}}- Use the submit_questions tool to return your questions
+`
+
+const defaultMakerFollowUpPrompt = `Thanks! Can I have {{.BatchSize}} more unique questions please?{{if .Subtopic}} This time every question must be about the subtopic "{{.Subtopic.Name}}": {{.Subtopic.Objective}}{{end}} Make sure they are different from the ones you've already generated.`
+
+const defaultPlannerPrompt = `Outline the subtopics a {{.BatchSize}}-question quiz about "{{.Topic}}" should cover.
+
+{{if .SourceMaterial}}The quiz is based on the following source material, so only include subtopics it covers:
+{{.SourceMaterial}}
+
+{{end}}{{if .Difficulty}}Difficulty level: {{.Difficulty}}
+
+{{end}}Requirements:
+- List between 2 and {{.MaxSubtopics}} subtopics, most important first
+- Subtopics must not overlap, so questions written for one don't also fit another
+- Together the subtopics should cover the topic broadly rather than dwelling on one corner of it
+- Give each subtopic a short name and a one-sentence learning objective saying what a player who answers its questions correctly knows
+- Use the submit_outline tool to return the outline
+`
+
+const defaultCheckerPrompt = `Evaluate the following quiz question:
+
+Quiz Topic: {{.Question.Topic}}
+
+Question: {{.Question.Text}}
+
+{{if .Question.Type}}Question Type: {{.Question.Type}}
+
+{{end}}{{with .Question.Image}}Image shown with the question: {{if .Alt}}{{.Alt}}{{else}}(no description){{end}}
+
+{{end}}{{if .Question.Options}}Options:
+{{options .Question}}
+{{end}}{{if eq .Question.Type "short_answer"}}Accepted Answers{{else if eq .Question.Type "ordering"}}Correct Order{{else if eq .Question.Type "matching"}}Correct Pairs{{else}}Correct Answer{{if eq .Question.Type "multi_select"}}s{{end}}{{end}}: {{answers .Question}}
+Explanation: {{.Question.Explanation}}
+{{if .Question.Source}}Supporting excerpt from the source material: "{{.Question.Source.Quote}}"
+{{end}}
+CRITICAL EVALUATION CRITERIA:
+{{if .Question.Source}}🚨 AUTOMATIC REJECTION: If the supporting excerpt does not actually support the marked correct answer, REJECT immediately.
+{{end}}🚨 AUTOMATIC REJECTION: If the correct answer appears in the question text, REJECT immediately or REVISE to improve it.
+🚨 AUTOMATIC REJECTION: If the question text contains obvious clues that give away the answer, REJECT immediately or REVISE to improve it.
+🚨 AUTOMATIC REJECTION: If the question is not relevant to the quiz topic, REJECT immediately.
+Additional evaluation criteria:
+1. Is the question relevant to the quiz topic?
+2. Is the question clear and unambiguous?
+3. Is the correct answer actually correct?
+4. Are all incorrect options plausible but clearly wrong?
+5. Does the question test understanding rather than just memorization?
+6. Does the explanation provide meaningful context or reasoning for WHY the answer is correct?
+{{if eq .Question.Type "multi_select"}}7. Is every marked option correct, and every unmarked option wrong? Does the question say to select all that apply?
+{{else if eq .Question.Type "true_false"}}7. Is the statement unambiguously true or false, with no trick wording?
+{{else if eq .Question.Type "short_answer"}}7. Does the question have one clear answer, and do the accepted answers cover its common spellings and names without accepting anything wrong?
+{{else if eq .Question.Type "numeric"}}7. Is the number correct in the unit the question asks for, and is the tolerance fair: loose enough for sensible rounding but tight enough that guesses don't count?
+{{else if eq .Question.Type "ordering"}}7. Is there exactly one correct order, with the items far enough apart that it is certain, and does the question say which way to order them?
+{{else if eq .Question.Type "matching"}}7. Does every term have exactly one match, with no match that could fairly pair with a different term?
+{{end}}
+Topic relevance check:
+- The question must be directly related to the quiz topic
+- If the question is about a different subject or person, it should be rejected
+- The question should test knowledge about the specific topic, not general knowledge
+
+Explanation quality check:
+- The explanation should explain WHY the answer is correct, not just restate what the answer is
+- For acronyms, the explanation should break down what each letter stands for
+- For concepts, the explanation should provide context or reasoning
+- Avoid explanations that just repeat the answer in different words
+
+Decision guidelines:
+- REJECT: The question has fundamental problems (especially if answer is in question text or not relevant to topic or obvious given the topic)
+- REVISE: If the question has potential but needs improvements
+- ACCEPT: The question is good as-is (only if it passes all criteria)
+
+IMPORTANT: Only revise explanations if they are spectacularly bad (e.g., missing acronym definitions, completely wrong information, or no explanation at all).
+For mediocre or basic explanations, ACCEPT the question rather than rejecting it. A good question with a basic explanation is better than no question at all.
+Only reject questions if they have fundamental structural problems (answer in question text, obvious clues, or not relevant to topic or obvious given the topic).
+If you choose to revise, provide a complete revised version of the question.`
+
+const defaultSolverPrompt = `Answer the following quiz question.
+
+Quiz Topic: {{.Question.Topic}}
+
+Question: {{.Question.Text}}
+
+{{with .Question.Image}}Image shown with the question: {{if .Alt}}{{.Alt}}{{else}}(no description){{end}}
+
+{{end}}{{if .Question.Options}}Options:
+{{options .Question}}
+{{end}}{{if eq .Question.Type "multi_select"}}Choose every correct option, as answers{{else if eq .Question.Type "ordering"}}Give the numbers of all the options in the order the question asks for, as answers{{else if eq .Question.Type "matching"}}Give the number of the match for each option in turn, as answers{{else if eq .Question.Type "short_answer"}}Type your answer in a few words, as response{{else if eq .Question.Type "numeric"}}Type your answer as a number{{with .Question.Numeric}}{{if .Unit}} in {{.Unit}}{{end}}{{end}}, as response{{else}}Choose the single best option{{end}}, then rate your confidence from 0 (a pure guess) to 1 (certain).
+Use the answer_question tool to submit your answer.`
+
+const defaultGraderPrompt = `Decide whether a player's typed answer to a quiz question should be accepted.
+
+Question: {{.Question.Text}}
+
+{{if eq .Question.Type "short_answer"}}Accepted Answers{{else}}Correct Answer{{end}}: {{answers .Question}}
+{{if .Question.Explanation}}Explanation: {{.Question.Explanation}}
+{{end}}
+Player's answer: {{.Response}}
+
+The answer doesn't exactly match, so judge it as a fair pub-quiz quizmaster would:
+- Accept misspellings, other names for the same thing, and extra words that don't change the meaning
+- Accept numbers written in words or in other units if they fall in the accepted range once converted
+- Reject answers that are vaguer than the question asks for, name something else, or hedge between several answers
+
+Use the grade_answer tool to submit your decision.`
+
+const defaultDedupPrompt = `Existing accepted questions:
+
+{{range .Existing}}ID: {{.ID}}
+Question: {{.Text}}
+{{if .Options}}Options:
+{{options .}}{{end}}{{if eq .Type "short_answer"}}Accepted Answers{{else}}Correct Answer{{end}}: {{answers .}}
+Explanation: {{.Explanation}}
+
+{{end}}New question to check:
+
+ID: {{.Question.ID}}
+Question: {{.Question.Text}}
+{{if .Question.Options}}Options:
+{{options .Question}}{{end}}{{if eq .Question.Type "short_answer"}}Accepted Answers{{else}}Correct Answer{{end}}: {{answers .Question}}
+Explanation: {{.Question.Explanation}}
+
+Evaluation criteria for duplicates:
+
+1. EXACT DUPLICATES: Same question text, same options, same correct answer
+2. NEAR-DUPLICATES:
+   - Same concept tested but different wording
+   - Same question with minor rephrasing
+   - Same topic with very similar answer choices
+   - Questions that test the same knowledge point
+3. ANSWER SPOILERS:
+   - If an earlier question's text or explanation reveals the answer to the new question
+   - If an earlier question's correct answer choice is mentioned in the new question's text
+   - If the new question becomes trivial because an earlier question already established the answer
+   - In these cases mark the question as a duplicate of the earlier question
+4. NOT DUPLICATES:
+   - Different aspects of the same topic
+   - Different difficulty levels
+   - Different approaches to testing knowledge
+   - Questions that test related but distinct concepts
+
+Consider both the question text and the answer choices when determining duplicates.
+Pay special attention to whether earlier questions spoil the answers to later questions.
+If the new question is a duplicate, provide the ID of the existing question it duplicates.
+
+Decide whether the new question is a duplicate of any existing question.`
+
+const defaultDiscovererPrompt = `Generate ONE interesting quiz topic that would make for engaging multiple choice questions.
+
+{{if .Category}}Focus on the category: {{.Category}}
+
+{{end}}IMPORTANT: The topic must be completely different from these existing topics:
+{{range .ExistingTopics}}- {{.}}
+{{end}}
+Requirements:
+- Topic should be educational and engaging
+- Topic should be broad enough to generate 10+ questions
+- Avoid overly specific or niche topics
+- Must be completely different from the existing topics listed above
+- Include topics from various fields: science, history, literature, technology, arts, etc.
+
+For the topic, provide:
+- A clear, concise topic name
+- A brief description of what the quiz would cover
+- A category (e.g., Science, History, Technology, Arts, Literature, Geography, etc.)
+- A suggested difficulty level (easy, medium, or hard)
+- Source material: Write 3-4 detailed paragraphs about the topic that can be used to generate accurate questions. Include key facts, concepts, historical context, important figures, and interesting details that would make for good multiple choice questions.
+
+Return the topic using the submit_topic tool.`
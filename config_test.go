@@ -0,0 +1,102 @@
+package quizgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"maker": {"model": "llama3", "temperature": 0.2, "prompt": "Write {{.BatchSize}} questions on {{.Topic}}"}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Maker.Model != "llama3" || cfg.Maker.Temperature != 0.2 {
+		t.Errorf("maker config = %+v", cfg.Maker)
+	}
+	// Settings the file leaves out keep their defaults
+	defaults := DefaultConfig()
+	if cfg.Maker.SystemPrompt != defaults.Maker.SystemPrompt || cfg.Checker.Prompt != defaults.Checker.Prompt {
+		t.Errorf("settings missing from the file lost their defaults")
+	}
+
+	prompt, err := RenderPrompt(cfg.Maker.Prompt, PromptData{Topic: "Volcanoes", BatchSize: 3})
+	if err != nil || prompt != "Write 3 questions on Volcanoes" {
+		t.Errorf("RenderPrompt = %q (%v)", prompt, err)
+	}
+}
+
+func TestLoadConfigRejectsInvalidTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"checker": {"prompt": "{{.Question"}}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "checker") {
+		t.Errorf("LoadConfig error = %v, want an invalid checker template", err)
+	}
+}
+
+func TestResolveStage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Provider.Model = ""
+	if stage := cfg.ResolveStage(cfg.Dedup, DefaultFastModel); stage.Model != DefaultFastModel {
+		t.Errorf("stage model %q, want the stage default", stage.Model)
+	}
+	cfg.Provider.Model = "llama3"
+	if stage := cfg.ResolveStage(cfg.Dedup, DefaultFastModel); stage.Model != "llama3" {
+		t.Errorf("stage model %q, want the provider model", stage.Model)
+	}
+	cfg.Dedup.Model = "mistral"
+	if stage := cfg.ResolveStage(cfg.Dedup, DefaultFastModel); stage.Model != "mistral" {
+		t.Errorf("stage model %q, want the stage's own model", stage.Model)
+	}
+}
+
+func TestDefaultPrompts(t *testing.T) {
+	question := &Question{ID: "q1", Topic: "Volcanoes", Text: "Which volcano buried Pompeii?", Options: []string{"Etna", "Vesuvius", "Hekla", "Fuji"}, CorrectAnswer: 1}
+	cfg := DefaultConfig()
+	prompt, err := RenderPrompt(cfg.Checker.Prompt, PromptData{Question: question})
+	if err != nil {
+		t.Fatalf("RenderPrompt failed: %v", err)
+	}
+	if !strings.Contains(prompt, " 1. Etna\n*2. Vesuvius\n") || !strings.Contains(prompt, "Correct Answer: 2") {
+		t.Errorf("checker prompt doesn't mark the correct option:\n%s", prompt)
+	}
+
+	// Typed answers have no options to list
+	typed := &Question{ID: "q2", Type: QuestionShortAnswer, Text: "Which volcano buried Pompeii?", AcceptedAnswers: []string{"Vesuvius", "Mount Vesuvius"}}
+	prompt, err = RenderPrompt(cfg.Dedup.Prompt, PromptData{Question: typed, Existing: []*Question{question}})
+	if err != nil {
+		t.Fatalf("RenderPrompt failed: %v", err)
+	}
+	if strings.Count(prompt, "Options:") != 1 || !strings.Contains(prompt, `Accepted Answers: "Vesuvius", "Mount Vesuvius"`) {
+		t.Errorf("dedup prompt doesn't show the typed answers:\n%s", prompt)
+	}
+	for name, stage := range map[string]StageConfig{"maker": cfg.Maker, "dedup": cfg.Dedup, "discoverer": cfg.Discoverer} {
+		if _, err := RenderPrompt(stage.Prompt, PromptData{Topic: "Volcanoes", BatchSize: 3, Question: question, Existing: []*Question{question}}); err != nil {
+			t.Errorf("default %s prompt failed to render: %v", name, err)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownSettings(t *testing.T) {
+	tests := map[string]func(cfg *Config){
+		"scope":    func(cfg *Config) { cfg.CrossQuiz.Scope = "planet" },
+		"voting":   func(cfg *Config) { cfg.Consensus.Voting = "loudest" },
+		"mismatch": func(cfg *Config) { cfg.Verification.OnMismatch = "ignore" },
+	}
+	for name, configure := range tests {
+		cfg := DefaultConfig()
+		configure(cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Validate error = %v, want an invalid %s", err, name)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package quizgenerator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Checker decides whether a question is accepted, rejected or revised
+type Checker interface {
+	CheckQuestion(ctx context.Context, question *Question, logger *LLMLogger) (*ValidationResult, error)
+}
+
+// Voting rules for combining the verdicts of several judges
+const (
+	VoteUnanimous = "unanimous"  // Accept only if every judge accepts
+	VoteMajority  = "majority"   // Accept if more than half the judges accept
+	VoteAnyReject = "any_reject" // Reject if any judge rejects, otherwise go with the majority
+)
+
+// ConsensusConfig configures checking each question with several independent judges
+type ConsensusConfig struct {
+	Judges []JudgeConfig `json:"judges,omitempty"` // Empty disables consensus and uses the checker stage alone
+	Voting string        `json:"voting,omitempty"` // One of the Vote constants; empty means majority
+}
+
+// Enabled reports whether questions are checked by several judges
+func (cc ConsensusConfig) Enabled() bool {
+	return len(cc.Judges) > 0
+}
+
+// JudgeConfig describes one judge; unset fields fall back to the checker stage and main provider
+type JudgeConfig struct {
+	Name        string           `json:"name,omitempty"` // Shown in votes and usage; defaults to the model
+	Model       string           `json:"model,omitempty"`
+	Temperature float32          `json:"temperature,omitempty"`
+	Provider    *ProviderOptions `json:"provider,omitempty"` // A separate backend for this judge
+}
+
+// JudgeVote is one judge's verdict on a question
+type JudgeVote struct {
+	Judge  string           `json:"judge"`
+	Action ValidationAction `json:"action"`
+	Reason string           `json:"reason"`
+}
+
+// consensusJudge is a named checker taking part in a vote
+type consensusJudge struct {
+	name    string
+	checker *QuestionChecker
+}
+
+// ConsensusChecker asks every judge about a question in parallel and combines their votes
+type ConsensusChecker struct {
+	judges []consensusJudge
+	voting string
+}
+
+// NewConsensusChecker creates a checker that votes with the given judges
+func NewConsensusChecker(voting string) *ConsensusChecker {
+	if voting == "" {
+		voting = VoteMajority
+	}
+	return &ConsensusChecker{voting: voting}
+}
+
+// AddJudge adds a judge to the panel
+func (cc *ConsensusChecker) AddJudge(name string, checker *QuestionChecker) {
+	checker.stage = "QuestionChecker:" + name
+	cc.judges = append(cc.judges, consensusJudge{name: name, checker: checker})
+}
+
+// CheckQuestion collects every judge's verdict and records the votes and
+// disagreement on the question. Any judge failing fails the whole check so
+// the question is retried with the full panel.
+func (cc *ConsensusChecker) CheckQuestion(ctx context.Context, question *Question, logger *LLMLogger) (*ValidationResult, error) {
+	results := make([]*ValidationResult, len(cc.judges))
+	errs := make([]error, len(cc.judges))
+	var wg sync.WaitGroup
+	for i, judge := range cc.judges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = judge.checker.CheckQuestion(ctx, question, logger)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("judge %s failed: %w", cc.judges[i].name, err)
+		}
+	}
+
+	votes := make([]JudgeVote, len(results))
+	for i, result := range results {
+		votes[i] = JudgeVote{Judge: cc.judges[i].name, Action: result.Action, Reason: result.Reason}
+	}
+	action := cc.decide(results)
+
+	result := &ValidationResult{QuestionID: question.ID, Action: action}
+	dissent := 0
+	var reasons []string
+	for i, vote := range votes {
+		if vote.Action != action {
+			dissent++
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", vote.Judge, vote.Reason))
+		if action == ActionRevise && result.RevisedQuestion == nil {
+			result.RevisedQuestion = results[i].RevisedQuestion
+		}
+	}
+	result.Reason = fmt.Sprintf("Consensus %s (%s, %d of %d judges agree) - %s",
+		action, cc.voting, len(votes)-dissent, len(votes), strings.Join(reasons, "; "))
+
+	question.Votes = votes
+	question.Disagreement = float64(dissent) / float64(len(votes))
+
+	if logger != nil {
+		logger.LogQuestionResult(question.ID, string(result.Action), result.Reason)
+	}
+	VerboseLog("Question %s: %s - %s", question.ID, result.Action, result.Reason)
+	return result, nil
+}
+
+// decide applies the voting rule. A revision can only win if some judge supplied one.
+func (cc *ConsensusChecker) decide(results []*ValidationResult) ValidationAction {
+	var accepts, rejects, revises int
+	for _, result := range results {
+		switch {
+		case result.Action == ActionAccept:
+			accepts++
+		case result.Action == ActionRevise && result.RevisedQuestion != nil:
+			revises++
+		default:
+			rejects++
+		}
+	}
+
+	// Among judges that didn't accept, prefer fixing the question over dropping it
+	notAccepted := func() ValidationAction {
+		if revises > 0 && revises >= rejects {
+			return ActionRevise
+		}
+		return ActionReject
+	}
+
+	switch cc.voting {
+	case VoteUnanimous:
+		if accepts == len(results) {
+			return ActionAccept
+		}
+		return notAccepted()
+	case VoteAnyReject:
+		if rejects > 0 {
+			return ActionReject
+		}
+		if accepts*2 > len(results) {
+			return ActionAccept
+		}
+		return ActionRevise
+	default:
+		if accepts*2 > len(results) {
+			return ActionAccept
+		}
+		return notAccepted()
+	}
+}
@@ -0,0 +1,100 @@
+package quizgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestConsensusVoting(t *testing.T) {
+	revision := &Question{Text: "Which volcano buried Pompeii in 79 AD?", Options: []string{"Vesuvius", "Etna", "Hekla", "Fuji"}, Explanation: "Vesuvius erupted in 79 AD."}
+	tests := []struct {
+		voting string
+		votes  []ValidationAction
+		want   ValidationAction
+	}{
+		{VoteMajority, []ValidationAction{ActionAccept, ActionAccept, ActionReject}, ActionAccept},
+		{VoteMajority, []ValidationAction{ActionAccept, ActionReject, ActionRevise}, ActionRevise},
+		{VoteMajority, []ValidationAction{ActionAccept, ActionReject, ActionReject}, ActionReject},
+		{VoteUnanimous, []ValidationAction{ActionAccept, ActionAccept, ActionReject}, ActionReject},
+		{VoteUnanimous, []ValidationAction{ActionAccept, ActionAccept, ActionAccept}, ActionAccept},
+		{VoteAnyReject, []ValidationAction{ActionAccept, ActionAccept, ActionReject}, ActionReject},
+		{VoteAnyReject, []ValidationAction{ActionAccept, ActionRevise, ActionRevise}, ActionRevise},
+	}
+	for _, tt := range tests {
+		consensus := NewConsensusChecker(tt.voting)
+		for i, action := range tt.votes {
+			fp := NewFakeProvider()
+			var revised *Question
+			if action == ActionRevise {
+				revised = revision
+			}
+			fp.EnqueueEvaluation(action, "Judged "+string(action), revised)
+			consensus.AddJudge(string(rune('a'+i)), NewQuestionChecker(fp, StageConfig{Model: DefaultModel}))
+		}
+
+		question := &Question{ID: "q1", Text: "Which volcano buried Pompeii?", Options: []string{"Vesuvius", "Etna", "Hekla", "Fuji"}}
+		result, err := consensus.CheckQuestion(context.Background(), question, nil)
+		if err != nil {
+			t.Fatalf("CheckQuestion failed: %v", err)
+		}
+		if result.Action != tt.want {
+			t.Errorf("%s of %v = %s, want %s", tt.voting, tt.votes, result.Action, tt.want)
+		}
+		if tt.want == ActionRevise && (result.RevisedQuestion == nil || result.RevisedQuestion.Text != revision.Text) {
+			t.Errorf("%s of %v revised to %+v", tt.voting, tt.votes, result.RevisedQuestion)
+		}
+		if len(question.Votes) != len(tt.votes) {
+			t.Errorf("question records %d votes, want %d", len(question.Votes), len(tt.votes))
+		}
+		dissent := 0
+		for _, vote := range question.Votes {
+			if vote.Action != tt.want {
+				dissent++
+			}
+		}
+		if want := float64(dissent) / float64(len(tt.votes)); question.Disagreement != want {
+			t.Errorf("%s of %v: disagreement %.2f, want %.2f", tt.voting, tt.votes, question.Disagreement, want)
+		}
+	}
+}
+
+func TestDBGenerateQuizWithConsensus(t *testing.T) {
+	env := newTestEnv(t,
+		withConfig(func(cfg *Config) {
+			cfg.Consensus = ConsensusConfig{Judges: []JudgeConfig{{Name: "lenient"}, {Model: "strict"}, {Name: "careful", Temperature: 0.2}}}
+		}),
+		withQuiz("quiz1", "Volcanoes", 3),
+	)
+	env.provider.Handle("evaluate_question", func(req ChatRequest) (string, error) {
+		if req.Model == "strict" {
+			return `{"action":"reject","reason":"Too easy"}`, nil
+		}
+		return `{"action":"accept","reason":"Fine"}`, nil
+	})
+
+	env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+
+	quiz, err := env.db.GetQuiz("quiz1")
+	if err != nil || quiz.Status != "completed" || quiz.NumQuestions != 3 {
+		t.Fatalf("quiz = %+v (%v), want completed with 3 questions", quiz, err)
+	}
+	for _, stage := range []string{"QuestionChecker:lenient", "QuestionChecker:2-strict", "QuestionChecker:careful"} {
+		if !strings.Contains(quiz.StageUsage, stage) {
+			t.Errorf("stage usage %s doesn't include %s", quiz.StageUsage, stage)
+		}
+	}
+
+	contested, err := env.db.GetContestedQuestions(0)
+	if err != nil {
+		t.Fatalf("GetContestedQuestions failed: %v", err)
+	}
+	if len(contested) != 3 {
+		t.Fatalf("%d contested questions, want all 3", len(contested))
+	}
+	for _, question := range contested {
+		if question.Disagreement < 0.33 || question.Disagreement > 0.34 || !strings.Contains(question.Votes, "Too easy") {
+			t.Errorf("question %d has disagreement %.2f and votes %s", question.QuestionNum, question.Disagreement, question.Votes)
+		}
+	}
+}
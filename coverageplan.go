@@ -0,0 +1,118 @@
+package quizgenerator
+
+// coveragePlan spreads a quiz's questions evenly across the parts of a quiz,
+// such as source chunks or subtopics, and chooses which part each maker batch
+// draws from
+type coveragePlan struct {
+	quota     []int // Questions wanted from each part
+	accepted  []int // Questions accepted from each part
+	pending   []int // Questions from each part generated but not yet through checking
+	requested []int // Questions requested from each part
+}
+
+// newCoveragePlan assigns numQuestions to parts. With fewer questions than
+// parts the questions come from evenly spaced parts rather than the first ones.
+func newCoveragePlan(parts, numQuestions int) *coveragePlan {
+	cp := &coveragePlan{
+		quota:     make([]int, parts),
+		accepted:  make([]int, parts),
+		pending:   make([]int, parts),
+		requested: make([]int, parts),
+	}
+	for i := 0; i < numQuestions; i++ {
+		cp.quota[(2*i+1)*parts/(2*numQuestions)]++
+	}
+	return cp
+}
+
+// deficit returns how many more questions part needs beyond those accepted or still being checked
+func (cp *coveragePlan) deficit(part int) int {
+	return cp.quota[part] - cp.accepted[part] - cp.pending[part]
+}
+
+// open reports whether part may still be asked for questions: it has had
+// less than three times its quota requested
+func (cp *coveragePlan) open(part int) bool {
+	return cp.requested[part] < 3*cp.quota[part]
+}
+
+// next returns the part the next batch should come from and how many of the
+// wanted size questions to ask it for, and records the request. It prefers the
+// open part furthest below its quota counting questions still being checked,
+// asking only for what it still needs. When pending questions cover every
+// quota it hedges on the open part with the most questions not yet accepted,
+// and once there is none it falls back to the least used part.
+func (cp *coveragePlan) next(size int) (int, int) {
+	best := -1
+	for i := range cp.quota {
+		if cp.deficit(i) <= 0 || !cp.open(i) {
+			continue
+		}
+		if best < 0 || cp.deficit(i) > cp.deficit(best) ||
+			(cp.deficit(i) == cp.deficit(best) && cp.requested[i] < cp.requested[best]) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		size = min(size, cp.deficit(best))
+		cp.requested[best] += size
+		return best, size
+	}
+
+	for i := range cp.quota {
+		unmet := cp.quota[i] - cp.accepted[i]
+		if unmet <= 0 || !cp.open(i) {
+			continue
+		}
+		if best < 0 || unmet > cp.quota[best]-cp.accepted[best] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		size = min(size, cp.quota[best]-cp.accepted[best])
+	} else {
+		best = 0
+		for i := range cp.quota {
+			if cp.requested[i] < cp.requested[best] {
+				best = i
+			}
+		}
+	}
+	cp.requested[best] += size
+	return best, size
+}
+
+// full reports whether part has met its quota while some other part is still
+// short and can get more questions, so further questions from part should wait
+func (cp *coveragePlan) full(part int) bool {
+	if part < 0 || part >= len(cp.quota) || cp.accepted[part] < cp.quota[part] {
+		return false
+	}
+	for i := range cp.quota {
+		if cp.accepted[i] < cp.quota[i] && (cp.pending[i] > 0 || cp.open(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// generated records that a question from part entered the pipeline
+func (cp *coveragePlan) generated(part int) {
+	if part >= 0 && part < len(cp.pending) {
+		cp.pending[part]++
+	}
+}
+
+// settle records that a question from part finished checking, whatever the outcome
+func (cp *coveragePlan) settle(part int) {
+	if part >= 0 && part < len(cp.pending) {
+		cp.pending[part]--
+	}
+}
+
+// accept records that a question from part was accepted
+func (cp *coveragePlan) accept(part int) {
+	if part >= 0 && part < len(cp.accepted) {
+		cp.accepted[part]++
+	}
+}
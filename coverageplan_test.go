@@ -0,0 +1,81 @@
+package quizgenerator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewCoveragePlanQuotas(t *testing.T) {
+	tests := []struct {
+		parts, questions int
+		want             []int
+	}{
+		{parts: 3, questions: 6, want: []int{2, 2, 2}},
+		{parts: 3, questions: 7, want: []int{2, 3, 2}},
+		{parts: 4, questions: 2, want: []int{0, 1, 0, 1}}, // Evenly spaced rather than the first parts
+		{parts: 5, questions: 1, want: []int{0, 0, 1, 0, 0}},
+	}
+	for _, tt := range tests {
+		plan := newCoveragePlan(tt.parts, tt.questions)
+		if !slices.Equal(plan.quota, tt.want) {
+			t.Errorf("newCoveragePlan(%d, %d) quotas = %v, want %v", tt.parts, tt.questions, plan.quota, tt.want)
+		}
+	}
+}
+
+func TestCoveragePlanNext(t *testing.T) {
+	plan := newCoveragePlan(2, 4)
+
+	// Each part is asked only for what it still needs
+	part, size := plan.next(5)
+	if part != 0 || size != 2 {
+		t.Fatalf("first batch = part %d size %d, want part 0 size 2", part, size)
+	}
+	plan.generated(0)
+	plan.generated(0)
+	part, size = plan.next(5)
+	if part != 1 || size != 2 {
+		t.Fatalf("second batch = part %d size %d, want part 1 size 2", part, size)
+	}
+
+	// Part 0's questions pass; part 1's are rejected, so it is asked again
+	for range 2 {
+		plan.settle(0)
+		plan.accept(0)
+		plan.generated(1)
+		plan.settle(1)
+	}
+	if !plan.full(0) {
+		t.Errorf("part 0 isn't full while part 1 is short")
+	}
+	part, size = plan.next(5)
+	if part != 1 || size != 2 {
+		t.Errorf("third batch = part %d size %d, want part 1 size 2", part, size)
+	}
+
+	plan.accept(1)
+	plan.accept(1)
+	if plan.full(0) {
+		t.Errorf("part 0 is still full once every part met its quota")
+	}
+}
+
+func TestCoveragePlanClosesParts(t *testing.T) {
+	plan := newCoveragePlan(2, 2)
+
+	// A part that never yields questions stops being asked after three times its quota
+	for range 3 {
+		if part, _ := plan.next(1); part != 0 && part != 1 {
+			t.Fatalf("next returned part %d", part)
+		}
+	}
+	for range 6 {
+		plan.next(1)
+	}
+	if plan.open(0) || plan.open(1) {
+		t.Errorf("parts still open after being asked %v times for quotas %v", plan.requested, plan.quota)
+	}
+	if plan.full(0) {
+		t.Errorf("part 0 is full though it has no accepted questions")
+	}
+}
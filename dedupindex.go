@@ -0,0 +1,240 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DedupFilterConfig configures the similarity pre-filter that runs before the LLM dedup judge
+type DedupFilterConfig struct {
+	EmbeddingModel string `json:"embedding_model,omitempty"` // Empty disables embeddings and uses TF-IDF only
+	// Candidates at least this similar to an accepted question are duplicates without asking the LLM; 0 disables
+	DuplicateThreshold float64 `json:"duplicate_threshold"`
+	// Candidates less similar than this to every accepted question are unique without asking the LLM; 0 disables
+	UniqueThreshold float64 `json:"unique_threshold"`
+	// Number of most similar accepted questions sent to the LLM judge; 0 sends all of them
+	TopK int `json:"top_k"`
+}
+
+// DefaultDedupFilterConfig returns the built-in pre-filter settings
+func DefaultDedupFilterConfig() DedupFilterConfig {
+	return DedupFilterConfig{
+		EmbeddingModel:     DefaultEmbeddingModel,
+		DuplicateThreshold: 0.95,
+		TopK:               5,
+	}
+}
+
+// SimilarQuestion is an indexed question and its similarity to a candidate
+type SimilarQuestion struct {
+	Question   *Question
+	Similarity float64 // Cosine similarity, 1 for identical text
+}
+
+// DedupIndex finds the accepted questions most similar to a candidate. It uses
+// provider embeddings when available and falls back to TF-IDF vectors for good
+// once an embedding call fails. It is not safe for concurrent use.
+type DedupIndex struct {
+	provider      LLMProvider
+	model         string
+	useEmbeddings bool
+	entries       []*indexEntry
+	docFreq       map[string]int // Number of entries containing each term
+	candidate     *indexEntry    // Last entry built by Nearest, reused by Add
+}
+
+type indexEntry struct {
+	question *Question
+	terms    map[string]int // Term counts of the question text
+	vector   []float32      // Embedding, when embeddings are in use
+}
+
+// NewDedupIndex creates an empty index; an empty model disables embeddings
+func NewDedupIndex(provider LLMProvider, model string) *DedupIndex {
+	return &DedupIndex{
+		provider:      provider,
+		model:         model,
+		useEmbeddings: model != "",
+		docFreq:       make(map[string]int),
+	}
+}
+
+// Len returns the number of indexed questions
+func (di *DedupIndex) Len() int {
+	return len(di.entries)
+}
+
+// Nearest returns up to k indexed questions most similar to the candidate, most similar first; k <= 0 returns all
+func (di *DedupIndex) Nearest(ctx context.Context, question *Question, k int) ([]SimilarQuestion, error) {
+	candidate, err := di.entry(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]SimilarQuestion, 0, len(di.entries))
+	for _, entry := range di.entries {
+		var similarity float64
+		if di.useEmbeddings {
+			similarity = cosineSimilarity(candidate.vector, entry.vector)
+		} else {
+			similarity = di.tfidfSimilarity(candidate.terms, entry.terms)
+		}
+		matches = append(matches, SimilarQuestion{Question: entry.question, Similarity: similarity})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// Add indexes an accepted question
+func (di *DedupIndex) Add(ctx context.Context, question *Question) error {
+	entry, err := di.entry(ctx, question)
+	if err != nil {
+		return err
+	}
+	di.candidate = nil
+	di.insert(entry)
+	return nil
+}
+
+// AddAll indexes many questions, embedding them in batches
+func (di *DedupIndex) AddAll(ctx context.Context, questions []*Question) error {
+	entries := make([]*indexEntry, len(questions))
+	for i, question := range questions {
+		entries[i] = &indexEntry{
+			question: question,
+			terms:    termCounts(dedupText(question)),
+		}
+	}
+
+	for start := 0; start < len(entries) && di.useEmbeddings; start += embeddingBatchSize {
+		batch := entries[start:min(start+embeddingBatchSize, len(entries))]
+		if err := di.embedEntries(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		di.insert(entry)
+	}
+	return nil
+}
+
+// embeddingBatchSize is the most texts sent in one embedding request
+const embeddingBatchSize = 256
+
+// insert adds a built entry to the index
+func (di *DedupIndex) insert(entry *indexEntry) {
+	di.entries = append(di.entries, entry)
+	for term := range entry.terms {
+		di.docFreq[term]++
+	}
+}
+
+// entry builds the index entry for a question, embedding it if embeddings are in use
+func (di *DedupIndex) entry(ctx context.Context, question *Question) (*indexEntry, error) {
+	if di.candidate != nil && di.candidate.question == question {
+		return di.candidate, nil
+	}
+
+	entry := &indexEntry{
+		question: question,
+		terms:    termCounts(dedupText(question)),
+	}
+	if di.useEmbeddings {
+		if err := di.embedEntries(ctx, []*indexEntry{entry}); err != nil {
+			return nil, err
+		}
+	}
+	di.candidate = entry
+	return entry, nil
+}
+
+// embedEntries sets the vectors of the given entries. Any failure other than
+// running out of budget or being cancelled switches the index to TF-IDF.
+func (di *DedupIndex) embedEntries(ctx context.Context, entries []*indexEntry) error {
+	input := make([]string, len(entries))
+	for i, entry := range entries {
+		input[i] = dedupText(entry.question)
+	}
+
+	resp, err := embed(ctx, di.provider, EmbeddingRequest{
+		Stage: "DedupEmbeddings",
+		Model: di.model,
+		Input: input,
+	})
+	switch {
+	case err == nil:
+		for i, entry := range entries {
+			entry.vector = resp.Vectors[i]
+		}
+	case errors.Is(err, ErrBudgetExhausted) || ctx.Err() != nil:
+		return fmt.Errorf("failed to embed questions: %w", err)
+	default:
+		// Every entry must be compared the same way, so drop embeddings for the whole index
+		VerboseLog("Embeddings unavailable, using TF-IDF similarity for dedup: %v", err)
+		di.useEmbeddings = false
+	}
+	return nil
+}
+
+// tfidfSimilarity is the cosine similarity of two term-count maps weighted by inverse document frequency
+func (di *DedupIndex) tfidfSimilarity(a, b map[string]int) float64 {
+	// Smoothed IDF so terms in every document still count a little
+	n := float64(len(di.entries) + 1)
+	idf := func(term string) float64 {
+		return math.Log((n+1)/(float64(di.docFreq[term])+1)) + 1
+	}
+
+	var dot, normA, normB float64
+	for term, count := range a {
+		weight := float64(count) * idf(term)
+		normA += weight * weight
+		if other, ok := b[term]; ok {
+			dot += weight * float64(other) * idf(term)
+		}
+	}
+	for term, count := range b {
+		weight := float64(count) * idf(term)
+		normB += weight * weight
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// dedupText is the text compared for duplicates: the question and its correct answers
+func dedupText(question *Question) string {
+	text := question.Text
+	if answer := question.CorrectText(); answer != "" {
+		text += " " + answer
+	}
+	return text
+}
+
+// tokenize splits text into lowercase words and numbers
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// termCounts counts the occurrences of each token in text
+func termCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, term := range tokenize(text) {
+		counts[term]++
+	}
+	return counts
+}
@@ -0,0 +1,79 @@
+package quizgenerator
+
+import (
+	"context"
+	"testing"
+)
+
+// chatOnlyProvider hides the fake provider's Embed method
+type chatOnlyProvider struct {
+	LLMProvider
+}
+
+func TestDedupIndexNearest(t *testing.T) {
+	questions := []*Question{
+		{ID: "q1", Text: "Which volcano buried Pompeii in 79 AD?", Options: []string{"Vesuvius", "Etna"}},
+		{ID: "q2", Text: "What is the deepest lake in the world?", Options: []string{"Baikal", "Tanganyika"}},
+		{ID: "q3", Text: "Which gas makes up most of the air?", Options: []string{"Nitrogen", "Oxygen"}},
+	}
+	candidate := &Question{ID: "c1", Text: "In 79 AD, which volcano buried the city of Pompeii?", Options: []string{"Vesuvius", "Stromboli"}}
+
+	for name, index := range map[string]*DedupIndex{
+		"embeddings":             NewDedupIndex(NewFakeProvider(), DefaultEmbeddingModel),
+		"tf-idf":                 NewDedupIndex(NewFakeProvider(), ""),
+		"embeddings unsupported": NewDedupIndex(chatOnlyProvider{NewFakeProvider()}, DefaultEmbeddingModel),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, question := range questions {
+				if err := index.Add(ctx, question); err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+			}
+			if index.Len() != len(questions) {
+				t.Errorf("index has %d questions, want %d", index.Len(), len(questions))
+			}
+
+			matches, err := index.Nearest(ctx, candidate, 2)
+			if err != nil {
+				t.Fatalf("Nearest failed: %v", err)
+			}
+			if len(matches) != 2 || matches[0].Question.ID != "q1" || matches[0].Similarity <= matches[1].Similarity {
+				t.Errorf("Nearest = %+v, want q1 first of 2", matches)
+			}
+
+			all, err := index.Nearest(ctx, questions[1], 0)
+			if err != nil {
+				t.Fatalf("Nearest failed: %v", err)
+			}
+			if len(all) != len(questions) || all[0].Question.ID != "q2" || all[0].Similarity < 0.99 {
+				t.Errorf("Nearest of an indexed question = %+v, want itself first with similarity 1", all)
+			}
+		})
+	}
+}
+
+func TestQuestionDedupThresholds(t *testing.T) {
+	fp := NewFakeProvider()
+	dedup := NewQuestionDedup(fp, StageConfig{Model: DefaultFastModel}, DedupFilterConfig{DuplicateThreshold: 0.95, UniqueThreshold: 0.2})
+	ctx := context.Background()
+	check := func(question *Question) *DedupResult {
+		t.Helper()
+		result, err := dedup.CheckDuplicate(ctx, question, nil)
+		if err != nil {
+			t.Fatalf("CheckDuplicate failed: %v", err)
+		}
+		return result
+	}
+
+	check(&Question{ID: "q1", Text: "Which volcano buried Pompeii in 79 AD?", Options: []string{"Vesuvius", "Etna"}})
+	if result := check(&Question{ID: "q2", Text: "Which volcano buried Pompeii in 79 AD?", Options: []string{"Vesuvius", "Hekla"}}); !result.IsDuplicate || result.DuplicateID != "q1" {
+		t.Errorf("identical question: %+v, want a duplicate of q1", result)
+	}
+	if result := check(&Question{ID: "q3", Text: "How many legs does a spider have?", Options: []string{"Eight", "Six"}}); result.IsDuplicate {
+		t.Errorf("unrelated question: %+v, want unique", result)
+	}
+	if calls := len(fp.Requests()); calls != 0 {
+		t.Errorf("dedup judge was called %d times, want the thresholds to settle both questions", calls)
+	}
+}
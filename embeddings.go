@@ -0,0 +1,57 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// DefaultEmbeddingModel is used for the dedup pre-filter when no model is configured
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+// ErrEmbeddingsUnsupported is returned by providers that cannot compute embeddings
+var ErrEmbeddingsUnsupported = errors.New("provider does not support embeddings")
+
+// Embedder is implemented by providers that can compute text embeddings
+type Embedder interface {
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// EmbeddingRequest asks for one embedding vector per input text
+type EmbeddingRequest struct {
+	Stage string // Pipeline stage making the call, used for usage accounting
+	Model string
+	Input []string
+}
+
+// EmbeddingResponse holds the vectors in the same order as the request's input
+type EmbeddingResponse struct {
+	Vectors [][]float32
+	Usage   Usage
+}
+
+// embed calls provider's Embed method, or returns ErrEmbeddingsUnsupported if it has none
+func embed(ctx context.Context, provider LLMProvider, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	embedder, ok := provider.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	return embedder.Embed(ctx, req)
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors, or 0 if either is empty
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,54 @@
+package quizgenerator
+
+import "time"
+
+// EventType identifies what happened during quiz generation
+type EventType string
+
+const (
+	EventPlanCreated      EventType = "plan_created"      // The planner outlined the Subtopics questions are spread across; Reason lists their names
+	EventBatchRequested   EventType = "batch_requested"   // The maker was asked for BatchSize more questions
+	EventQuestionAccepted EventType = "question_accepted" // A question passed every check and was streamed
+	EventQuestionRejected EventType = "question_rejected" // The checker rejected a question
+	EventQuestionRevised  EventType = "question_revised"  // The checker revised a question and put it back in the pool
+	EventDuplicateFound   EventType = "duplicate_found"   // Dedup matched a question against DuplicateID
+	EventQuestionFlagged  EventType = "question_flagged"  // An accepted question's blind solver disagreed with its answer
+	EventCheckFailed      EventType = "check_failed"      // Checking a question failed; it is retried or dropped
+	EventBudgetExhausted  EventType = "budget_exhausted"  // The quiz or daily budget ran out
+	EventFinished         EventType = "finished"          // Generation ended; Stats and Error describe the outcome
+)
+
+// GenerationEvent is one step of quiz generation; fields that don't apply to Type are left empty
+type GenerationEvent struct {
+	Type                 EventType        `json:"type"`
+	Time                 time.Time        `json:"time"`
+	QuestionID           string           `json:"question_id,omitempty"`
+	BatchSize            int              `json:"batch_size,omitempty"`
+	Subtopic             string           `json:"subtopic,omitempty"` // Subtopic a batch was requested for
+	Subtopics            []Subtopic       `json:"subtopics,omitempty"`
+	Reason               string           `json:"reason,omitempty"`
+	DuplicateID          string           `json:"duplicate_id,omitempty"`
+	DuplicateQuizID      string           `json:"duplicate_quiz_id,omitempty"`      // Set when the duplicate is from another quiz
+	DuplicateQuestionNum int              `json:"duplicate_question_num,omitempty"` // Question number within DuplicateQuizID
+	Error                string           `json:"error,omitempty"`
+	Stats                *GenerationStats `json:"stats,omitempty"`
+}
+
+// GenerationStats counts the outcomes of a GenerateQuizStream run
+type GenerationStats struct {
+	Requested  int                `json:"requested"`          // Questions asked of the maker
+	Generated  int                `json:"generated"`          // Questions the maker returned
+	Accepted   int                `json:"accepted"`           // Questions streamed to the caller
+	Rejected   int                `json:"rejected"`           // Questions rejected by the checker
+	Revised    int                `json:"revised"`            // Revisions put back in the pool
+	Duplicates int                `json:"duplicates"`         // Questions rejected by dedup
+	Dropped    int                `json:"dropped"`            // Questions given up on after repeated check failures
+	Flagged    int                `json:"flagged"`            // Accepted questions whose blind solver disagreed
+	Coverage   []SubtopicCoverage `json:"coverage,omitempty"` // Per-subtopic quotas and acceptances, when planning is enabled
+	Usage      UsageSummary       `json:"usage"`
+	Duration   time.Duration      `json:"duration"`
+}
+
+// EventHandler receives generation events. It is called from the generation
+// goroutine, so it should return quickly.
+type EventHandler func(event GenerationEvent)
@@ -0,0 +1,354 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is a deterministic LLMProvider for offline runs and tests.
+// Scripted tool-call arguments are returned in order per tool name; once a
+// tool's queue is empty a handler or built-in default answer is used.
+type FakeProvider struct {
+	mu        sync.Mutex
+	responses map[string][]string // Queued tool-call arguments by tool name
+	handlers  map[string]func(req ChatRequest) (string, error)
+	requests  []ChatRequest
+	calls     int
+	batches   int // submit_questions calls, used to number placeholder questions
+}
+
+// NewFakeProvider creates a fake provider with no scripted responses
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		responses: make(map[string][]string),
+		handlers:  make(map[string]func(req ChatRequest) (string, error)),
+	}
+}
+
+// Enqueue queues raw tool-call arguments to be returned for the named tool
+func (fp *FakeProvider) Enqueue(toolName, arguments string) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.responses[toolName] = append(fp.responses[toolName], arguments)
+}
+
+// Handle sets a function that answers calls to the named tool once its queue is empty
+func (fp *FakeProvider) Handle(toolName string, handler func(req ChatRequest) (string, error)) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fp.handlers[toolName] = handler
+}
+
+// EnqueueQuestions queues a submit_questions response containing the given questions
+func (fp *FakeProvider) EnqueueQuestions(questions ...Question) {
+	fp.Enqueue("submit_questions", mustMarshal(map[string]interface{}{
+		"questions": toolQuestions(questions),
+	}))
+}
+
+// EnqueueEvaluation queues an evaluate_question response; revised may be nil
+func (fp *FakeProvider) EnqueueEvaluation(action ValidationAction, reason string, revised *Question) {
+	args := map[string]interface{}{
+		"action": string(action),
+		"reason": reason,
+	}
+	if revised != nil {
+		args["revised_question"] = toolQuestions([]Question{*revised})[0]
+	}
+	fp.Enqueue("evaluate_question", mustMarshal(args))
+}
+
+// EnqueueDedup queues a check_duplicate response
+func (fp *FakeProvider) EnqueueDedup(isDuplicate bool, duplicateID, reason string) {
+	fp.Enqueue("check_duplicate", mustMarshal(map[string]interface{}{
+		"is_duplicate": isDuplicate,
+		"duplicate_id": duplicateID,
+		"reason":       reason,
+	}))
+}
+
+// Requests returns a copy of every request the provider has received
+func (fp *FakeProvider) Requests() []ChatRequest {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	requests := make([]ChatRequest, len(fp.requests))
+	copy(requests, fp.requests)
+	return requests
+}
+
+// Chat answers with the next scripted response for the requested tool
+func (fp *FakeProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fp.mu.Lock()
+	fp.requests = append(fp.requests, req)
+	fp.calls++
+	callID := fmt.Sprintf("call_fake_%d", fp.calls)
+	if req.Tool.Name == "submit_questions" {
+		fp.batches++
+	}
+	batch := fp.batches
+
+	var arguments string
+	queue := fp.responses[req.Tool.Name]
+	handler := fp.handlers[req.Tool.Name]
+	if len(queue) > 0 {
+		arguments = queue[0]
+		fp.responses[req.Tool.Name] = queue[1:]
+	}
+	fp.mu.Unlock()
+
+	if arguments == "" {
+		var err error
+		if handler != nil {
+			arguments, err = handler(req)
+		} else {
+			arguments, err = fakeDefaultArguments(req, batch)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Estimate usage at roughly four characters per token
+	promptChars := 0
+	for _, msg := range req.Messages {
+		promptChars += len(msg.Content)
+	}
+
+	return &ChatResponse{
+		ToolCalls: []ToolCall{
+			{
+				ID:        callID,
+				Name:      req.Tool.Name,
+				Arguments: arguments,
+			},
+		},
+		Usage: Usage{
+			PromptTokens:     promptChars / 4,
+			CompletionTokens: len(arguments) / 4,
+		},
+	}, nil
+}
+
+// Embed returns hashed bag-of-words vectors, so texts sharing words are similar
+func (fp *FakeProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resp := &EmbeddingResponse{Vectors: make([][]float32, len(req.Input))}
+	for i, text := range req.Input {
+		vector := make([]float32, 64)
+		for _, term := range tokenize(text) {
+			hash := fnv.New32a()
+			hash.Write([]byte(term))
+			vector[hash.Sum32()%uint32(len(vector))]++
+		}
+		resp.Vectors[i] = vector
+		resp.Usage.PromptTokens += len(text) / 4
+	}
+	return resp, nil
+}
+
+var (
+	fakeBatchSizeRegexp = regexp.MustCompile(`\d+`)
+	fakeAnswerRegexp    = regexp.MustCompile(`(?m)^\s*(\d+)\. Answer \d+( also)?$`) // Correct options of a placeholder question
+	fakeSourceRegexp    = regexp.MustCompile(`(?:source material|longer document) as reference:\n([^\n]+)`)
+	fakeTypesRegexp     = regexp.MustCompile(`set each question's type: ([a-z_, ]+)`)
+	fakeNumberRegexp    = regexp.MustCompile(`Fake (?:question|statement) (\d+)`)  // Number of a placeholder question
+	fakeStepRegexp      = regexp.MustCompile(`(?m)^\s*(\d+)\. Step (\d+) of \d+$`) // Items of a placeholder ordering question
+	fakeMeaningRegexp   = regexp.MustCompile(`(?m)^\s*(\d+)\. Meaning (\d+)$`)     // Matches of a placeholder matching question
+)
+
+// fakeDefaultArguments produces a plausible answer for the pipeline's own tools:
+// an outline of four placeholder subtopics, numbered placeholder questions of
+// the requested types quoting the first line of any source material, an accept
+// verdict, a unique dedup verdict, a solver answer that picks the
+// placeholder's correct options, order or pairs or types its answer, and a grader that
+// accepts every borderline answer
+func fakeDefaultArguments(req ChatRequest, batch int) (string, error) {
+	switch req.Tool.Name {
+	case "submit_outline":
+		subtopics := make([]Subtopic, 4)
+		for i := range subtopics {
+			subtopics[i] = Subtopic{
+				Name:      fmt.Sprintf("Fake subtopic %d", i+1),
+				Objective: fmt.Sprintf("Knows fake fact %d", i+1),
+			}
+		}
+		return mustMarshal(map[string]interface{}{"subtopics": subtopics}), nil
+	case "submit_questions":
+		batchSize := 3
+		if len(req.Messages) > 0 {
+			prompt := req.Messages[len(req.Messages)-1].Content
+			if match := fakeBatchSizeRegexp.FindString(prompt); match != "" {
+				batchSize, _ = strconv.Atoi(match)
+			}
+		}
+		var source *SourceSpan
+		for _, message := range req.Messages {
+			if match := fakeSourceRegexp.FindStringSubmatch(message.Content); match != nil {
+				source = &SourceSpan{Quote: match[1]}
+				break
+			}
+		}
+		var types []QuestionType
+		if len(req.Messages) > 0 {
+			if match := fakeTypesRegexp.FindStringSubmatch(req.Messages[len(req.Messages)-1].Content); match != nil {
+				types, _ = ParseQuestionTypes(match[1])
+			}
+		}
+		questions := make([]Question, batchSize)
+		offset := batch + 1
+		for i := range questions {
+			n := offset*100 + i + 1
+			questions[i] = Question{
+				Text:          fmt.Sprintf("Fake question %d?", n),
+				Options:       []string{fmt.Sprintf("Answer %d", n), "Wrong A", "Wrong B", "Wrong C"},
+				CorrectAnswer: 0,
+				Explanation:   fmt.Sprintf("Answer %d is correct because this is fake question %d.", n, n),
+				Source:        source,
+			}
+			if len(types) > 0 {
+				questions[i].Type = types[i%len(types)]
+			}
+			switch questions[i].Type {
+			case QuestionTrueFalse:
+				questions[i].Text = fmt.Sprintf("Fake statement %d is correct.", n)
+				questions[i].Options = []string{"True", "False"}
+			case QuestionMultiSelect:
+				questions[i].Text = fmt.Sprintf("Fake question %d? Select all that apply.", n)
+				questions[i].Options[1] = fmt.Sprintf("Answer %d also", n)
+				questions[i].CorrectAnswers = []int{0, 1}
+			case QuestionShortAnswer:
+				questions[i].Text = fmt.Sprintf("Fake question %d? Answer in a few words.", n)
+				questions[i].Options = nil
+				questions[i].AcceptedAnswers = []string{fmt.Sprintf("Answer %d", n), fmt.Sprintf("Answer number %d", n)}
+			case QuestionNumeric:
+				questions[i].Text = fmt.Sprintf("Fake question %d? Answer in meters.", n)
+				questions[i].Options = nil
+				questions[i].Numeric = &NumericAnswer{Value: float64(n), Tolerance: 1, Unit: "m"}
+			case QuestionOrdering:
+				questions[i].Text = fmt.Sprintf("Fake question %d? Put the steps in order, first step first.", n)
+				questions[i].Options = []string{"Step 1 of 4", "Step 2 of 4", "Step 3 of 4", "Step 4 of 4"}
+			case QuestionMatching:
+				questions[i].Text = fmt.Sprintf("Fake question %d? Match each term to its meaning.", n)
+				questions[i].Options = []string{"Term 1", "Term 2", "Term 3", "Term 4"}
+				questions[i].Matches = []string{"Meaning 1", "Meaning 2", "Meaning 3", "Meaning 4"}
+			}
+		}
+		args := toolQuestions(questions)
+		// The first question of each batch shows the first source image, when there are any
+		if fakeOffersImages(req) && len(args) > 0 {
+			args[0]["image"] = 1
+		}
+		return mustMarshal(map[string]interface{}{"questions": args}), nil
+	case "evaluate_question":
+		return mustMarshal(map[string]interface{}{"action": "accept", "reason": "Accepted by fake provider"}), nil
+	case "check_duplicate":
+		return mustMarshal(map[string]interface{}{"is_duplicate": false, "reason": "Unique according to fake provider"}), nil
+	case "answer_question":
+		var answers []int
+		if len(req.Messages) > 0 {
+			for _, match := range fakeAnswerRegexp.FindAllStringSubmatch(req.Messages[len(req.Messages)-1].Content, -1) {
+				answer, _ := strconv.Atoi(match[1])
+				answers = append(answers, answer)
+			}
+		}
+		if len(req.Messages) > 0 {
+			prompt := req.Messages[len(req.Messages)-1].Content
+			// Step k and Meaning k belong in place k, wherever they were shuffled to
+			for _, re := range []*regexp.Regexp{fakeStepRegexp, fakeMeaningRegexp} {
+				if matches := re.FindAllStringSubmatch(prompt, -1); matches != nil {
+					answers = make([]int, len(matches))
+					for _, match := range matches {
+						number, _ := strconv.Atoi(match[1])
+						place, _ := strconv.Atoi(match[2])
+						if place >= 1 && place <= len(answers) {
+							answers[place-1] = number
+						}
+					}
+				}
+			}
+		}
+		if len(answers) == 0 {
+			answers = []int{1}
+		}
+		response := ""
+		if len(req.Messages) > 0 {
+			prompt := req.Messages[len(req.Messages)-1].Content
+			if match := fakeNumberRegexp.FindStringSubmatch(prompt); match != nil {
+				response = "Answer " + match[1]
+				if strings.Contains(prompt, "as a number") {
+					response = match[1] + " m"
+				}
+			}
+		}
+		return mustMarshal(map[string]interface{}{"answer": answers[0], "answers": answers, "response": response, "confidence": 0.9, "reasoning": "Solved by fake provider"}), nil
+	case "grade_answer":
+		return mustMarshal(map[string]interface{}{"correct": true, "reason": "Accepted by fake provider"}), nil
+	}
+	return "", fmt.Errorf("fake provider has no response for tool %s", req.Tool.Name)
+}
+
+// fakeOffersImages reports whether the maker's tool lets questions pick a source image
+func fakeOffersImages(req ChatRequest) bool {
+	tool, _ := req.Tool.Parameters["properties"].(map[string]interface{})
+	questions, _ := tool["questions"].(map[string]interface{})
+	items, _ := questions["items"].(map[string]interface{})
+	properties, _ := items["properties"].(map[string]interface{})
+	_, ok := properties["image"]
+	return ok
+}
+
+// toolQuestions converts questions to the argument shape used by the question tools
+func toolQuestions(questions []Question) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(questions))
+	for _, q := range questions {
+		args := map[string]interface{}{
+			"text":           q.Text,
+			"options":        q.Options,
+			"correct_answer": q.CorrectAnswer,
+			"explanation":    q.Explanation,
+		}
+		if q.Type != "" {
+			args["type"] = q.Type
+		}
+		if len(q.CorrectAnswers) > 0 {
+			args["correct_answers"] = q.CorrectAnswers
+		}
+		if len(q.Matches) > 0 {
+			args["matches"] = q.Matches
+		}
+		if len(q.AcceptedAnswers) > 0 {
+			args["accepted_answers"] = q.AcceptedAnswers
+		}
+		if q.Numeric != nil {
+			args["numeric_answer"] = q.Numeric.Value
+			args["tolerance"] = q.Numeric.Tolerance
+			args["unit"] = q.Numeric.Unit
+		}
+		if q.Source != nil {
+			args["source_quote"] = q.Source.Quote
+		}
+		result = append(result, args)
+	}
+	return result
+}
+
+func mustMarshal(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal fake response: %v", err))
+	}
+	return string(data)
+}
@@ -0,0 +1,167 @@
+package quizgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxImageBytes is the largest image accepted into an image store
+const MaxImageBytes = 5 << 20
+
+// imageExtensions maps the image formats questions may show to the extension they are stored under
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageNameRegexp matches the names ImageStore.Put gives images: a SHA-256 and an extension
+var imageNameRegexp = regexp.MustCompile(`^[0-9a-f]{64}\.(png|jpg|gif|webp)$`)
+
+// QuestionImage is a picture shown with a question, kept in an ImageStore
+type QuestionImage struct {
+	Name string `json:"name"`           // File name in the image store, derived from the image's content
+	Alt  string `json:"alt,omitempty"`  // Description for screen readers and for the LLM stages, which can't see the image
+	Data []byte `json:"data,omitempty"` // The image itself, only filled in by ImageStore.Embed for exports
+}
+
+// ImageStore keeps images on disk under content-addressed names, so the same
+// image is stored once however many questions show it, and a name's content
+// never changes
+type ImageStore struct {
+	dir string
+}
+
+// NewImageStore creates an image store in the given directory, which is created when the first image is stored
+func NewImageStore(dir string) *ImageStore {
+	return &ImageStore{dir: dir}
+}
+
+// Put stores a PNG, JPEG, GIF or WebP image and returns its name
+func (is *ImageStore) Put(data []byte) (string, error) {
+	if len(data) > MaxImageBytes {
+		return "", fmt.Errorf("image is %d bytes, more than the %d allowed", len(data), MaxImageBytes)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	filename := is.filename(name)
+	if _, err := os.Stat(filename); err == nil {
+		return name, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	// Write to a temporary file first so a half-written image is never served under its name
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return "", fmt.Errorf("failed to store image: %w", err)
+	}
+	return name, nil
+}
+
+// PutSourceImages stores a source file's images and returns them ready to be
+// offered to the maker in GenerationRequest.SourceImages
+func (is *ImageStore) PutSourceImages(images []SourceImage) ([]QuestionImage, error) {
+	stored := make([]QuestionImage, 0, len(images))
+	for _, image := range images {
+		name, err := is.Put(image.Data)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, QuestionImage{Name: name, Alt: image.Alt})
+	}
+	return stored, nil
+}
+
+// Path returns where the named image is stored, rejecting names Put could not have given
+func (is *ImageStore) Path(name string) (string, error) {
+	if !imageNameRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid image name %q", name)
+	}
+	return is.filename(name), nil
+}
+
+// Get returns the named image
+func (is *ImageStore) Get(name string) ([]byte, error) {
+	filename, err := is.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Delete removes the named image; deleting an image that isn't stored is not an error
+func (is *ImageStore) Delete(name string) error {
+	filename, err := is.Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete image %s: %w", name, err)
+	}
+	return nil
+}
+
+// Embed fills in the data of every question's image, so an exported quiz
+// carries its images with it
+func (is *ImageStore) Embed(questions []Question) error {
+	for i := range questions {
+		image := questions[i].Image
+		if image == nil {
+			continue
+		}
+		data, err := is.Get(image.Name)
+		if err != nil {
+			return err
+		}
+		// Copy the image so questions sharing it elsewhere aren't given the data too
+		questions[i].Image = &QuestionImage{Name: image.Name, Alt: image.Alt, Data: data}
+	}
+	return nil
+}
+
+// filename spreads images across subdirectories named by their first two hex digits
+func (is *ImageStore) filename(name string) string {
+	return filepath.Join(is.dir, name[:2], name)
+}
+
+// ImageContentType returns the MIME type of a stored image from its name
+func ImageContentType(name string) string {
+	ext := filepath.Ext(name)
+	for contentType, e := range imageExtensions {
+		if strings.EqualFold(e, ext) {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
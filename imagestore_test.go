@@ -0,0 +1,96 @@
+package quizgenerator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPNG is a 1x1 PNG image
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\xff\xff?\x00\x05\xfe\x02\xfe\xa7\x35\x81\x84\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func TestImageStorePut(t *testing.T) {
+	dir := t.TempDir()
+	store := NewImageStore(filepath.Join(dir, "images"))
+
+	name, err := store.Put(testPNG)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !strings.HasSuffix(name, ".png") || ImageContentType(name) != "image/png" {
+		t.Errorf("image stored as %q with content type %q", name, ImageContentType(name))
+	}
+	data, err := store.Get(name)
+	if err != nil || !bytes.Equal(data, testPNG) {
+		t.Fatalf("Get(%q) = %d bytes (%v), want the image back", name, len(data), err)
+	}
+
+	// The same image is stored once under the same name
+	again, err := store.Put(bytes.Clone(testPNG))
+	if err != nil || again != name {
+		t.Errorf("storing the image again gave %q (%v), want %q", again, err, name)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "images", name[:2]))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("image directory holds %d files (%v), want just the image", len(entries), err)
+	}
+
+	if err := store.Delete(name); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(name); err == nil {
+		t.Errorf("Get found a deleted image")
+	}
+	if err := store.Delete(name); err != nil {
+		t.Errorf("deleting a missing image failed: %v", err)
+	}
+
+	if _, err := store.Put([]byte("not an image")); err == nil {
+		t.Errorf("Put accepted text")
+	}
+	if _, err := store.Put(append(bytes.Clone(testPNG), make([]byte, MaxImageBytes)...)); err == nil {
+		t.Errorf("Put accepted an image over MaxImageBytes")
+	}
+}
+
+func TestImageStorePathRejectsOtherNames(t *testing.T) {
+	store := NewImageStore(t.TempDir())
+	for _, name := range []string{"", "../quiz.db", strings.Repeat("a", 64) + ".svg", strings.Repeat("A", 64) + ".png", "ab/" + strings.Repeat("a", 64) + ".png"} {
+		if _, err := store.Path(name); err == nil {
+			t.Errorf("Path accepted %q", name)
+		}
+	}
+	if _, err := store.Get(strings.Repeat("a", 64) + ".png"); err == nil {
+		t.Errorf("Get found an image that was never stored")
+	}
+}
+
+func TestImageStoreEmbed(t *testing.T) {
+	store := NewImageStore(t.TempDir())
+	name, err := store.Put(testPNG)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	shared := &QuestionImage{Name: name, Alt: "A red dot"}
+	questions := []Question{{Text: "With image", Image: shared}, {Text: "Without image"}}
+
+	if err := store.Embed(questions); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if image := questions[0].Image; image.Name != name || image.Alt != "A red dot" || !bytes.Equal(image.Data, testPNG) {
+		t.Errorf("embedded image = %+v", image)
+	}
+	if questions[1].Image != nil {
+		t.Errorf("question without an image was given %+v", questions[1].Image)
+	}
+	if shared.Data != nil {
+		t.Errorf("Embed filled in the data of an image other questions share")
+	}
+
+	questions[1].Image = &QuestionImage{Name: strings.Repeat("b", 64) + ".png"}
+	if err := store.Embed(questions); err == nil {
+		t.Errorf("Embed succeeded with a missing image")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +14,7 @@ type LLMLogger struct {
 	file   *os.File
 	mu     sync.Mutex
 	quizID string
+	usage  *UsageTracker // Summarised in the footer when the log is closed
 }
 
 // NewLLMLogger creates a new LLM logger for a specific quiz
@@ -40,6 +42,9 @@ func NewLLMLogger(quizID string, req GenerationRequest) (*LLMLogger, error) {
 	logger.Logf("Topic: %s\n", req.Topic)
 	logger.Logf("Number of Questions: %d\n", req.NumQuestions)
 	logger.Logf("Difficulty: %s\n", req.Difficulty)
+	if !req.Budget.IsZero() {
+		logger.Logf("Budget: %d tokens, $%.2f\n", req.Budget.MaxTokens, req.Budget.MaxCostUSD)
+	}
 	if req.SourceMaterial != "" {
 		logger.Logf("Source Material Length: %d characters\n", len(req.SourceMaterial))
 	}
@@ -92,6 +97,13 @@ func (ll *LLMLogger) LogDedupResult(questionID string, isDuplicate bool, reason,
 	}
 }
 
+// SetUsageTracker sets the tracker whose totals are written when the log is closed
+func (ll *LLMLogger) SetUsageTracker(usage *UsageTracker) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+	ll.usage = usage
+}
+
 // Close closes the log file
 func (ll *LLMLogger) Close() error {
 	ll.mu.Lock()
@@ -102,6 +114,12 @@ func (ll *LLMLogger) Close() error {
 		timestamp := time.Now().Format("15:04:05.000")
 		fmt.Fprintf(ll.file, "[%s] === Quiz Generation Complete ===\n", timestamp)
 		fmt.Fprintf(ll.file, "[%s] Completed: %s\n", timestamp, time.Now().Format(time.RFC3339))
+		if ll.usage != nil {
+			fmt.Fprintf(ll.file, "[%s] Token usage:\n", timestamp)
+			for _, line := range strings.Split(ll.usage.Summary().String(), "\n") {
+				fmt.Fprintf(ll.file, "[%s]   %s\n", timestamp, line)
+			}
+		}
 		fmt.Fprintf(ll.file, "[%s] ============================\n", timestamp)
 		ll.file.Sync()
 		return ll.file.Close()
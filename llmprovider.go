@@ -0,0 +1,272 @@
+package quizgenerator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Chat message roles understood by every provider
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Default models used by the pipeline stages
+const (
+	DefaultModel     = openai.GPT4o
+	DefaultFastModel = openai.GPT4oMini
+)
+
+// LLMProvider sends a conversation to a language model and forces it to answer with a tool call
+type LLMProvider interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+// ChatMessage is a single message in a conversation with the model
+type ChatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a function call made by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolDefinition describes the function the model is forced to call
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ChatRequest is a chat completion request with a single forced tool
+type ChatRequest struct {
+	Stage       string         `json:"stage,omitempty"` // Pipeline stage making the call, for usage accounting
+	Model       string         `json:"model"`
+	Temperature float32        `json:"temperature,omitempty"` // 0 means the provider default
+	MaxTokens   int            `json:"max_tokens,omitempty"`  // 0 means the provider default
+	Messages    []ChatMessage  `json:"messages"`
+	Tool        ToolDefinition `json:"tool"`
+}
+
+// ChatResponse holds the tool calls returned by the model
+type ChatResponse struct {
+	ToolCalls []ToolCall `json:"tool_calls"`
+	Usage     Usage      `json:"usage"`
+}
+
+// ProviderOptions configures which LLM backend the generator talks to
+type ProviderOptions struct {
+	APIKey       string      `json:"api_key,omitempty"`
+	BaseURL      string      `json:"base_url,omitempty"`      // OpenAI-compatible endpoint, e.g. http://localhost:11434/v1
+	Model        string      `json:"model,omitempty"`         // Overrides the default model of every stage if set
+	Cassette     string      `json:"cassette,omitempty"`      // Cassette file to record to or replay from
+	CassetteMode string      `json:"cassette_mode,omitempty"` // "record", "replay" or "strict"
+	Provider     LLMProvider `json:"-"`                       // Use this provider instead of building one, e.g. a FakeProvider
+}
+
+// ProviderOptionsFromEnv reads provider options from OPENAI_API_KEY, OPENAI_BASE_URL,
+// LLM_MODEL, LLM_CASSETTE and LLM_CASSETTE_MODE
+func ProviderOptionsFromEnv() ProviderOptions {
+	return ProviderOptions{
+		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		BaseURL:      os.Getenv("OPENAI_BASE_URL"),
+		Model:        os.Getenv("LLM_MODEL"),
+		Cassette:     os.Getenv("LLM_CASSETTE"),
+		CassetteMode: os.Getenv("LLM_CASSETTE_MODE"),
+	}
+}
+
+// NeedsAPIKey reports whether these options will talk to the OpenAI API and so require a key
+func (opts ProviderOptions) NeedsAPIKey() bool {
+	if opts.Provider != nil || opts.BaseURL != "" {
+		return false
+	}
+	return opts.Cassette == "" || opts.CassetteMode == CassetteRecord
+}
+
+// NewProvider creates the provider described by the options
+func NewProvider(opts ProviderOptions) (LLMProvider, error) {
+	if opts.Provider != nil {
+		return opts.Provider, nil
+	}
+
+	if opts.Cassette != "" && opts.CassetteMode != CassetteRecord {
+		switch opts.CassetteMode {
+		case "", CassetteReplay, CassetteStrict:
+		default:
+			return nil, fmt.Errorf("unknown cassette mode: %s", opts.CassetteMode)
+		}
+		return NewReplayProvider(opts.Cassette, opts.CassetteMode == CassetteStrict)
+	}
+
+	var provider LLMProvider
+	if opts.BaseURL != "" {
+		provider = NewOpenAICompatibleProvider(opts.BaseURL, opts.APIKey)
+	} else {
+		provider = NewOpenAIProvider(opts.APIKey)
+	}
+
+	if opts.Cassette != "" {
+		return NewRecordingProvider(provider, opts.Cassette)
+	}
+	return provider, nil
+}
+
+// ModelOr returns the configured model override, or the stage default
+func (opts ProviderOptions) ModelOr(defaultModel string) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return defaultModel
+}
+
+// OpenAIProvider talks to the OpenAI chat completions API, or any server that implements it
+type OpenAIProvider struct {
+	client *openai.Client
+}
+
+// NewOpenAIProvider creates a provider for the official OpenAI API
+func NewOpenAIProvider(apiKey string) *OpenAIProvider {
+	return newOpenAIProvider(openai.DefaultConfig(apiKey))
+}
+
+// NewOpenAICompatibleProvider creates a provider for an OpenAI-compatible server such as llama.cpp or Ollama
+func NewOpenAICompatibleProvider(baseURL, apiKey string) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	config.BaseURL = baseURL
+	return newOpenAIProvider(config)
+}
+
+func newOpenAIProvider(config openai.ClientConfig) *OpenAIProvider {
+	// Capture Retry-After headers so throttled calls can be retried politely
+	config.HTTPClient = &http.Client{
+		Transport: &retryAfterTransport{base: http.DefaultTransport},
+	}
+	return &OpenAIProvider{
+		client: openai.NewClientWithConfig(config),
+	}
+}
+
+// Chat sends the conversation and returns the tool calls from the first choice
+func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		message := openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, toolCall := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+				ID:   toolCall.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      toolCall.Name,
+					Arguments: toolCall.Arguments,
+				},
+			})
+		}
+		messages = append(messages, message)
+	}
+
+	var retryAfter time.Duration
+	resp, err := p.client.CreateChatCompletion(
+		context.WithValue(ctx, retryAfterKey{}, &retryAfter),
+		openai.ChatCompletionRequest{
+			Model:       req.Model,
+			Temperature: req.Temperature,
+			MaxTokens:   req.MaxTokens,
+			Messages:    messages,
+			Tools: []openai.Tool{
+				{
+					Type: openai.ToolTypeFunction,
+					Function: &openai.FunctionDefinition{
+						Name:        req.Tool.Name,
+						Description: req.Tool.Description,
+						Parameters:  req.Tool.Parameters,
+					},
+				},
+			},
+			ToolChoice: openai.ToolChoice{
+				Type: openai.ToolTypeFunction,
+				Function: openai.ToolFunction{
+					Name: req.Tool.Name,
+				},
+			},
+		},
+	)
+	if err != nil {
+		llmErr := ClassifyError(err)
+		llmErr.Tool = req.Tool.Name
+		llmErr.RetryAfter = retryAfter
+		return nil, llmErr
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from %s", req.Model)
+	}
+
+	result := &ChatResponse{
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		},
+	}
+	for _, toolCall := range resp.Choices[0].Message.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: toolCall.Function.Arguments,
+		})
+	}
+	return result, nil
+}
+
+// Embed returns an embedding vector for each input text
+func (p *OpenAIProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	var retryAfter time.Duration
+	resp, err := p.client.CreateEmbeddings(
+		context.WithValue(ctx, retryAfterKey{}, &retryAfter),
+		openai.EmbeddingRequestStrings{
+			Input: req.Input,
+			Model: openai.EmbeddingModel(req.Model),
+		},
+	)
+	if err != nil {
+		llmErr := ClassifyError(err)
+		llmErr.Tool = "embeddings"
+		llmErr.RetryAfter = retryAfter
+		return nil, llmErr
+	}
+
+	if len(resp.Data) != len(req.Input) {
+		return nil, fmt.Errorf("expected %d embeddings from %s, got %d", len(req.Input), req.Model, len(resp.Data))
+	}
+
+	result := &EmbeddingResponse{
+		Vectors: make([][]float32, len(resp.Data)),
+		Usage: Usage{
+			PromptTokens: resp.Usage.PromptTokens,
+		},
+	}
+	for _, embedding := range resp.Data {
+		if embedding.Index < 0 || embedding.Index >= len(result.Vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", embedding.Index)
+		}
+		result.Vectors[embedding.Index] = embedding.Embedding
+	}
+	return result, nil
+}
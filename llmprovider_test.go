@@ -0,0 +1,65 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var got struct {
+		Model      string `json:"model"`
+		Messages   []struct{ Role, Content string }
+		ToolChoice struct {
+			Function struct{ Name string } `json:"function"`
+		} `json:"tool_choice"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request sent to %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization header %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"submit_questions","arguments":"{\"questions\":[]}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderOptions{BaseURL: server.URL + "/v1", APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:    "llama3",
+		Messages: []ChatMessage{{Role: RoleSystem, Content: "Write questions"}, {Role: RoleUser, Content: "Volcanoes"}},
+		Tool:     ToolDefinition{Name: "submit_questions", Parameters: map[string]interface{}{"type": "object"}},
+	})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	if got.Model != "llama3" || len(got.Messages) != 2 || got.Messages[1].Content != "Volcanoes" {
+		t.Errorf("server received model %q and messages %+v", got.Model, got.Messages)
+	}
+	if got.ToolChoice.Function.Name != "submit_questions" {
+		t.Errorf("tool choice %q, want the request's tool to be forced", got.ToolChoice.Function.Name)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "submit_questions" || resp.ToolCalls[0].Arguments != `{"questions":[]}` {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+}
+
+func TestProviderOptionsModelOr(t *testing.T) {
+	if model := (ProviderOptions{}).ModelOr(DefaultFastModel); model != DefaultFastModel {
+		t.Errorf("ModelOr without an override = %q", model)
+	}
+	if model := (ProviderOptions{Model: "llama3"}).ModelOr(DefaultFastModel); model != "llama3" {
+		t.Errorf("ModelOr with an override = %q", model)
+	}
+}
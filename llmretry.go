@@ -0,0 +1,301 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind classifies why an LLM call failed
+type ErrorKind string
+
+const (
+	ErrorRateLimit ErrorKind = "rate_limit" // 429 - retry after a delay
+	ErrorServer    ErrorKind = "server"     // 5xx or connection failure - retry
+	ErrorTimeout   ErrorKind = "timeout"    // Request timed out - retry
+	ErrorMalformed ErrorKind = "malformed"  // Missing, wrong or unparseable tool call or response - retry
+	ErrorUnknown   ErrorKind = "unknown"    // Any other failure, e.g. a truncated response - retry
+	ErrorPermanent ErrorKind = "permanent"  // Auth, quota or bad request - don't retry
+)
+
+// LLMError is the typed error returned by LLM calls
+type LLMError struct {
+	Kind       ErrorKind
+	Tool       string        // Tool the call was forced to use
+	Attempts   int           // Number of attempts made before giving up
+	RetryAfter time.Duration // Delay requested by the server, if any
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s error calling %s after %d attempts: %v", e.Kind, e.Tool, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("%s error calling %s: %v", e.Kind, e.Tool, e.Err)
+}
+
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the call may succeed if tried again
+func (e *LLMError) Retryable() bool {
+	return e.Kind != ErrorPermanent
+}
+
+// IsPermanentLLMError reports whether err is an LLM error that retrying won't fix
+func IsPermanentLLMError(err error) bool {
+	var llmErr *LLMError
+	return errors.As(err, &llmErr) && !llmErr.Retryable()
+}
+
+// IsContextLengthError reports whether err says the prompt didn't fit in the model's context window
+func IsContextLengthError(err error) bool {
+	var apiErr *openai.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "context_length_exceeded"
+}
+
+// RetryPolicy controls how failed LLM calls and questions are retried
+type RetryPolicy struct {
+	MaxAttempts        int `json:"max_attempts"`         // Attempts per LLM call, including the first
+	BaseDelayMs        int `json:"base_delay_ms"`        // Initial backoff delay
+	MaxDelayMs         int `json:"max_delay_ms"`         // Upper bound on a single backoff delay
+	MaxQuestionRetries int `json:"max_question_retries"` // Times a question is re-queued after a failed check before it is dropped
+}
+
+// DefaultRetryPolicy returns the built-in retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:        4,
+		BaseDelayMs:        1000,
+		MaxDelayMs:         30000,
+		MaxQuestionRetries: 2,
+	}
+}
+
+// backoff returns a jittered exponential delay for the given attempt (1-based),
+// never shorter than the server's Retry-After
+func (rp RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	ceiling := time.Duration(rp.BaseDelayMs) * time.Millisecond << (attempt - 1)
+	maxDelay := time.Duration(rp.MaxDelayMs) * time.Millisecond
+	if ceiling > maxDelay || ceiling <= 0 {
+		ceiling = maxDelay
+	}
+
+	// Full jitter spreads out retries from concurrent callers
+	delay := ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)+1))
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// RetryProvider wraps a provider, validating the forced tool call and retrying
+// transient failures with jittered exponential backoff
+type RetryProvider struct {
+	inner  LLMProvider
+	policy RetryPolicy
+}
+
+// NewRetryProvider wraps inner with the given retry policy
+func NewRetryProvider(inner LLMProvider, policy RetryPolicy) *RetryProvider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &RetryProvider{
+		inner:  inner,
+		policy: policy,
+	}
+}
+
+// Chat calls the inner provider until it returns a well-formed tool call, a
+// permanent error occurs, or the attempts are used up
+func (rp *RetryProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var resp *ChatResponse
+	err := rp.do(ctx, req.Tool.Name, func() error {
+		var err error
+		resp, err = rp.inner.Chat(ctx, req)
+		if err != nil {
+			return err
+		}
+		return validateToolCall(req, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Embed calls the inner provider's Embed with the same retry policy as Chat
+func (rp *RetryProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	var resp *EmbeddingResponse
+	err := rp.do(ctx, "embeddings", func() error {
+		var err error
+		resp, err = embed(ctx, rp.inner, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// do runs call until it succeeds, a permanent error occurs, or the attempts are used up
+func (rp *RetryProvider) do(ctx context.Context, name string, call func() error) error {
+	var lastErr *LLMError
+	for attempt := 1; attempt <= rp.policy.MaxAttempts; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		// Give up straight away if the caller has cancelled
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		lastErr = ClassifyError(err)
+		lastErr.Tool = name
+		lastErr.Attempts = attempt
+		if !lastErr.Retryable() || attempt == rp.policy.MaxAttempts {
+			break
+		}
+
+		delay := rp.policy.backoff(attempt, lastErr.RetryAfter)
+		VerboseLog("LLM call to %s failed (%s, attempt %d/%d), retrying in %v: %v",
+			name, lastErr.Kind, attempt, rp.policy.MaxAttempts, delay, lastErr.Err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return lastErr
+}
+
+// validateToolCall checks that the response contains a parseable call to the forced tool
+func validateToolCall(req ChatRequest, resp *ChatResponse) error {
+	if len(resp.ToolCalls) == 0 {
+		return &LLMError{Kind: ErrorMalformed, Err: fmt.Errorf("no tool calls in response")}
+	}
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != req.Tool.Name {
+		return &LLMError{Kind: ErrorMalformed, Err: fmt.Errorf("unexpected tool call: %s", toolCall.Name)}
+	}
+	if !json.Valid([]byte(toolCall.Arguments)) {
+		return &LLMError{Kind: ErrorMalformed, Err: fmt.Errorf("tool call arguments are not valid JSON")}
+	}
+	return nil
+}
+
+// ClassifyError converts any error from a provider into an *LLMError
+func ClassifyError(err error) *LLMError {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		classified := *llmErr
+		return &classified
+	}
+
+	// The budget won't have grown by the next attempt
+	if errors.Is(err, ErrBudgetExhausted) {
+		return &LLMError{Kind: ErrorPermanent, Err: err}
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Type == "insufficient_quota" || apiErr.Code == "insufficient_quota" {
+			return &LLMError{Kind: ErrorPermanent, Err: err}
+		}
+		return &LLMError{Kind: kindForStatus(apiErr.HTTPStatusCode), Err: err}
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &LLMError{Kind: kindForStatus(reqErr.HTTPStatusCode), Err: err}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &LLMError{Kind: ErrorTimeout, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return &LLMError{Kind: ErrorTimeout, Err: err}
+		}
+		return &LLMError{Kind: ErrorServer, Err: err}
+	}
+
+	// A response cut off or garbled in transit may well come through intact next time
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return &LLMError{Kind: ErrorServer, Err: err}
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return &LLMError{Kind: ErrorMalformed, Err: err}
+	}
+
+	// Only errors known to be the caller's fault are permanent
+	return &LLMError{Kind: ErrorUnknown, Err: err}
+}
+
+func kindForStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrorTimeout
+	case status >= 500:
+		return ErrorServer
+	case status >= 400:
+		return ErrorPermanent
+	default:
+		return ErrorUnknown
+	}
+}
+
+// retryAfterKey is the context key under which a *time.Duration receives the
+// Retry-After header of the response
+type retryAfterKey struct{}
+
+// retryAfterTransport records the Retry-After header of throttled responses
+// into the holder stored in the request context
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if holder, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		*holder = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return resp, nil
+}
+
+// parseRetryAfter accepts either delay-seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, ErrorRateLimit},
+		{&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Code: "insufficient_quota"}, ErrorPermanent},
+		{&openai.APIError{HTTPStatusCode: http.StatusBadGateway}, ErrorServer},
+		{&openai.APIError{HTTPStatusCode: http.StatusGatewayTimeout}, ErrorTimeout},
+		{&openai.APIError{HTTPStatusCode: http.StatusUnauthorized}, ErrorPermanent},
+		{&openai.RequestError{HTTPStatusCode: http.StatusServiceUnavailable}, ErrorServer},
+		{fmt.Errorf("calling model: %w", context.DeadlineExceeded), ErrorTimeout},
+		{&LLMError{Kind: ErrorMalformed, Err: errors.New("no tool calls")}, ErrorMalformed},
+		{fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), ErrorServer},
+		{json.Unmarshal([]byte(`{"choices":`), &struct{}{}), ErrorMalformed},
+		{&openai.APIError{HTTPStatusCode: http.StatusFound}, ErrorUnknown},
+		{errors.New("something went wrong"), ErrorUnknown},
+		{fmt.Errorf("calling model: %w", ErrBudgetExhausted), ErrorPermanent},
+	}
+	for _, tt := range tests {
+		if kind := ClassifyError(tt.err).Kind; kind != tt.kind {
+			t.Errorf("ClassifyError(%v) = %s, want %s", tt.err, kind, tt.kind)
+		}
+	}
+}
+
+func TestRetryProvider(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 5}
+	tests := []struct {
+		name     string
+		failures []error
+		calls    int
+		kind     ErrorKind // Empty if the call succeeds
+	}{
+		{"transient errors", []error{&openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable}, &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}}, 3, ""},
+		{"permanent error", []error{&openai.APIError{HTTPStatusCode: http.StatusUnauthorized}}, 1, ErrorPermanent},
+		{"unknown error", []error{errors.New("something went wrong")}, 2, ""},
+		{"attempts used up", []error{&openai.APIError{HTTPStatusCode: 500}, &openai.APIError{HTTPStatusCode: 500}, &openai.APIError{HTTPStatusCode: 500}}, 3, ErrorServer},
+	}
+	for _, tt := range tests {
+		fp := NewFakeProvider()
+		calls := 0
+		fp.Handle("evaluate_question", func(req ChatRequest) (string, error) {
+			calls++
+			if calls <= len(tt.failures) {
+				return "", tt.failures[calls-1]
+			}
+			return `{"action":"accept"}`, nil
+		})
+
+		_, err := NewRetryProvider(fp, policy).Chat(context.Background(), ChatRequest{Tool: ToolDefinition{Name: "evaluate_question"}})
+		if calls != tt.calls {
+			t.Errorf("%s: %d calls, want %d", tt.name, calls, tt.calls)
+		}
+		var llmErr *LLMError
+		switch {
+		case tt.kind == "" && err != nil:
+			t.Errorf("%s: Chat failed: %v", tt.name, err)
+		case tt.kind != "" && (!errors.As(err, &llmErr) || llmErr.Kind != tt.kind || llmErr.Attempts != tt.calls):
+			t.Errorf("%s: Chat error = %v, want a %s error after %d attempts", tt.name, err, tt.kind, tt.calls)
+		}
+	}
+}
+
+func TestRetryProviderRetriesMalformedToolCalls(t *testing.T) {
+	fp := NewFakeProvider()
+	fp.Enqueue("evaluate_question", "not JSON")
+	fp.Enqueue("evaluate_question", `{"action":"accept"}`)
+
+	resp, err := NewRetryProvider(fp, RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1, MaxDelayMs: 1}).Chat(context.Background(), ChatRequest{Tool: ToolDefinition{Name: "evaluate_question"}})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.ToolCalls[0].Arguments != `{"action":"accept"}` || len(fp.Requests()) != 2 {
+		t.Errorf("got %q after %d calls, want the second response", resp.ToolCalls[0].Arguments, len(fp.Requests()))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelayMs: 100, MaxDelayMs: 1000}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for range 20 {
+			if delay := policy.backoff(attempt, 0); delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, delay, ceiling/2, ceiling)
+			}
+		}
+	}
+	if delay := policy.backoff(1, 5*time.Second); delay != 5*time.Second {
+		t.Errorf("backoff with Retry-After 5s = %v", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay := parseRetryAfter("7"); delay != 7*time.Second {
+		t.Errorf("parseRetryAfter(7) = %v", delay)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := parseRetryAfter(date); delay < 55*time.Second || delay > time.Minute {
+		t.Errorf("parseRetryAfter(%s) = %v", date, delay)
+	}
+	if delay := parseRetryAfter("soon"); delay != 0 {
+		t.Errorf("parseRetryAfter(soon) = %v", delay)
+	}
+}
+
+func TestIsContextLengthError(t *testing.T) {
+	if !IsContextLengthError(fmt.Errorf("chat failed: %w", &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "context_length_exceeded"})) {
+		t.Errorf("wrapped context_length_exceeded error not recognised")
+	}
+	if IsContextLengthError(&openai.APIError{HTTPStatusCode: http.StatusBadRequest}) || IsContextLengthError(errors.New("context_length_exceeded")) {
+		t.Errorf("other errors taken for context length errors")
+	}
+}
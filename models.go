@@ -2,17 +2,30 @@ package quizgenerator
 
 import "time"
 
-// Question represents a single quiz question with multiple choice answers
+// Question represents a single quiz question, answered by choosing options or by typing
 type Question struct {
-	ID            string         `json:"id"`
-	Text          string         `json:"text"`
-	Options       []string       `json:"options"`
-	CorrectAnswer int            `json:"correct_answer"` // 0-based index
-	Explanation   string         `json:"explanation"`
-	Topic         string         `json:"topic"`
-	CreatedAt     time.Time      `json:"created_at"`
-	Status        QuestionStatus `json:"status"`
-	RevisionCount int            `json:"revision_count"` // Number of times this question has been revised
+	ID              string         `json:"id"`
+	Type            QuestionType   `json:"type,omitempty"` // Empty means QuestionSingleChoice
+	Text            string         `json:"text"`
+	Options         []string       `json:"options"`
+	CorrectAnswer   int            `json:"correct_answer"`             // 0-based index; for multi-select the first correct option
+	CorrectAnswers  []int          `json:"correct_answers,omitempty"`  // Multi-select: every correct option; ordering: the options in correct order; matching: each option's index in Matches
+	Matches         []string       `json:"matches,omitempty"`          // Matching: the items the options are paired with
+	AcceptedAnswers []string       `json:"accepted_answers,omitempty"` // Short answer: the answer followed by other spellings and names that count
+	Numeric         *NumericAnswer `json:"numeric,omitempty"`          // Numeric: the answer, its tolerance and unit
+	Explanation     string         `json:"explanation"`
+	Topic           string         `json:"topic"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Status          QuestionStatus `json:"status"`
+	RevisionCount   int            `json:"revision_count"`         // Number of times this question has been revised
+	Votes           []JudgeVote    `json:"votes,omitempty"`        // Consensus mode: each judge's verdict
+	Disagreement    float64        `json:"disagreement,omitempty"` // Consensus mode: fraction of judges that voted against the outcome
+	Verification    *SolverResult  `json:"verification,omitempty"` // Blind solver's answer, when verification is enabled
+	Source          *SourceSpan    `json:"source,omitempty"`       // Excerpt supporting the answer, when generated from source material
+	Subtopic        string         `json:"subtopic,omitempty"`     // Planned subtopic the question was written for, when planning is enabled
+	Image           *QuestionImage `json:"image,omitempty"`        // Picture shown with the question, from the source material or an editor
+
+	chunk int // Source chunk the question was generated from, for the coverage plan
 }
 
 // QuestionStatus represents the state of a question in the pipeline
@@ -27,11 +40,14 @@ const (
 
 // Quiz represents a complete quiz with metadata
 type Quiz struct {
-	ID             string     `json:"id"`
-	Topic          string     `json:"topic"`
-	Questions      []Question `json:"questions"`
-	CreatedAt      time.Time  `json:"created_at"`
-	TotalQuestions int        `json:"total_questions"`
+	ID             string             `json:"id"`
+	Topic          string             `json:"topic"`
+	Questions      []Question         `json:"questions"`
+	CreatedAt      time.Time          `json:"created_at"`
+	TotalQuestions int                `json:"total_questions"`
+	SourceFilename string             `json:"source_filename,omitempty"` // File the source material was extracted from
+	SourceLength   int                `json:"source_length,omitempty"`   // Characters of text extracted from SourceFilename
+	Coverage       []SubtopicCoverage `json:"coverage,omitempty"`        // Questions planned and accepted per subtopic, when planning is enabled
 }
 
 // ValidationResult represents the result of checking a question
@@ -57,4 +73,10 @@ type GenerationRequest struct {
 	NumQuestions   int    `json:"num_questions"`
 	SourceMaterial string `json:"source_material,omitempty"`
 	Difficulty     string `json:"difficulty,omitempty"`
+	Budget         Budget `json:"budget,omitempty"` // Falls back to the configured default budget if empty
+	// Types of question the maker may write, mixed across each batch; empty means single choice only
+	QuestionTypes []QuestionType `json:"question_types,omitempty"`
+	NumOptions    int            `json:"num_options,omitempty"` // Options per single-choice, multi-select, ordering or matching question; 0 means DefaultNumOptions
+	// Images from the source material, already in the image store, that the maker may show with questions
+	SourceImages []QuestionImage `json:"source_images,omitempty"`
 }
@@ -0,0 +1,435 @@
+package quizgenerator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	pdfStreamRegexp = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfLengthRegexp = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+)
+
+// pdfText extracts the text shown by a PDF's content streams, in file order.
+// It handles uncompressed and Flate-compressed streams and fonts with
+// single-byte encodings; text in fonts that need a ToUnicode map, such as
+// Identity-H CID fonts, comes out garbled or missing.
+func pdfText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	limit := newExtractLimit()
+	var sb strings.Builder
+	for _, match := range pdfStreamRegexp.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[match[2]:match[3]])
+		start := match[1]
+
+		// Use a direct /Length when there is one, otherwise look for the endstream keyword
+		end := -1
+		if m := pdfLengthRegexp.FindStringSubmatch(dict); m != nil && m[2] == "" {
+			if length, err := strconv.Atoi(m[1]); err == nil && start+length <= len(data) {
+				end = start + length
+			}
+		}
+		if end < 0 {
+			i := bytes.Index(data[start:], []byte("endstream"))
+			if i < 0 {
+				continue
+			}
+			end = start + i
+		}
+
+		// Skip images, fonts and other streams that can't hold page text
+		if strings.Contains(dict, "/Subtype") && !strings.Contains(dict, "/Form") {
+			continue
+		}
+		if strings.Contains(dict, "/Type /XRef") || strings.Contains(dict, "/Type/XRef") ||
+			strings.Contains(dict, "/Type /ObjStm") || strings.Contains(dict, "/Type/ObjStm") {
+			continue
+		}
+
+		content := data[start:end]
+		if strings.Contains(dict, "/Filter") {
+			if !strings.Contains(dict, "/FlateDecode") || strings.Count(dict, "Decode") > 1 {
+				continue
+			}
+			inflated, err := pdfInflate(content, limit)
+			if errors.Is(err, ErrSourceTooLarge) {
+				return "", err
+			}
+			if err != nil {
+				VerboseLog("Skipping unreadable PDF stream: %v", err)
+				continue
+			}
+			content = inflated
+		}
+
+		if text := pdfContentText(content); strings.TrimSpace(text) != "" {
+			sb.WriteString(text)
+			sb.WriteString("\n\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// pdfInflate decompresses a FlateDecode stream, counting it against limit and
+// keeping whatever was decompressed before any corruption at the end
+func pdfInflate(data []byte, limit *extractLimit) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	out, err := limit.readAll(reader)
+	if errors.Is(err, ErrSourceTooLarge) || (err != nil && len(out) == 0) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pdfContentText interprets the text operators of a content stream
+func pdfContentText(content []byte) string {
+	var sb strings.Builder
+	var operands []pdfToken
+	inText := false
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if s := sb.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			sb.WriteByte(' ')
+		}
+	}
+
+	lexer := &pdfLexer{data: content}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		if token.kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch token.text {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			newline()
+		case "Tj":
+			if inText && len(operands) > 0 {
+				sb.WriteString(operands[len(operands)-1].text)
+			}
+		case "'", "\"":
+			if inText && len(operands) > 0 {
+				newline()
+				sb.WriteString(operands[len(operands)-1].text)
+			}
+		case "TJ":
+			if inText && len(operands) > 0 {
+				for _, item := range operands[len(operands)-1].items {
+					switch item.kind {
+					case pdfString:
+						sb.WriteString(item.text)
+					case pdfNumber:
+						// Large negative adjustments move the pen right far enough to be a word gap
+						if n, err := strconv.ParseFloat(item.text, 64); err == nil && n < -200 {
+							space()
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if inText && len(operands) >= 2 {
+				if y, err := strconv.ParseFloat(operands[len(operands)-1].text, 64); err == nil && y != 0 {
+					newline()
+				} else {
+					space()
+				}
+			}
+		case "T*", "Tm":
+			if inText {
+				newline()
+			}
+		}
+		operands = operands[:0]
+	}
+	return sb.String()
+}
+
+type pdfTokenKind int
+
+const (
+	pdfNumber pdfTokenKind = iota
+	pdfString
+	pdfName
+	pdfArray
+	pdfOperator
+	pdfOther
+)
+
+// pdfToken is one lexical token of a content stream; arrays hold their items
+type pdfToken struct {
+	kind  pdfTokenKind
+	text  string
+	items []pdfToken
+}
+
+// pdfLexer splits a content stream into tokens
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (pl *pdfLexer) next() (pdfToken, bool) {
+	pl.skipSpace()
+	if pl.pos >= len(pl.data) {
+		return pdfToken{}, false
+	}
+
+	c := pl.data[pl.pos]
+	switch {
+	case c == '(':
+		return pdfToken{kind: pdfString, text: pl.literalString()}, true
+	case c == '<' && pl.pos+1 < len(pl.data) && pl.data[pl.pos+1] == '<':
+		pl.skipDict()
+		return pdfToken{kind: pdfOther}, true
+	case c == '<':
+		return pdfToken{kind: pdfString, text: pl.hexString()}, true
+	case c == '[':
+		pl.pos++
+		array := pdfToken{kind: pdfArray}
+		for {
+			pl.skipSpace()
+			if pl.pos >= len(pl.data) {
+				return array, true
+			}
+			if pl.data[pl.pos] == ']' {
+				pl.pos++
+				return array, true
+			}
+			item, ok := pl.next()
+			if !ok {
+				return array, true
+			}
+			array.items = append(array.items, item)
+		}
+	case c == '/':
+		start := pl.pos
+		pl.pos++
+		pl.skipRegular()
+		return pdfToken{kind: pdfName, text: string(pl.data[start:pl.pos])}, true
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		pl.pos++
+		return pdfToken{kind: pdfOther}, true
+	}
+
+	start := pl.pos
+	pl.skipRegular()
+	if pl.pos == start {
+		pl.pos++
+	}
+	word := string(pl.data[start:pl.pos])
+	if _, err := strconv.ParseFloat(word, 64); err == nil {
+		return pdfToken{kind: pdfNumber, text: word}, true
+	}
+	if word == "BI" {
+		pl.skipInlineImage()
+	}
+	return pdfToken{kind: pdfOperator, text: word}, true
+}
+
+// skipSpace skips whitespace and comments
+func (pl *pdfLexer) skipSpace() {
+	for pl.pos < len(pl.data) {
+		c := pl.data[pl.pos]
+		if c == '%' {
+			for pl.pos < len(pl.data) && pl.data[pl.pos] != '\n' && pl.data[pl.pos] != '\r' {
+				pl.pos++
+			}
+			continue
+		}
+		if !pdfIsSpace(c) {
+			return
+		}
+		pl.pos++
+	}
+}
+
+// skipRegular advances past a run of regular (non-space, non-delimiter) characters
+func (pl *pdfLexer) skipRegular() {
+	for pl.pos < len(pl.data) {
+		c := pl.data[pl.pos]
+		if pdfIsSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0 {
+			return
+		}
+		pl.pos++
+	}
+}
+
+// skipDict skips a << >> dictionary, including nested ones
+func (pl *pdfLexer) skipDict() {
+	depth := 0
+	for pl.pos+1 < len(pl.data) {
+		switch {
+		case pl.data[pl.pos] == '<' && pl.data[pl.pos+1] == '<':
+			depth++
+			pl.pos += 2
+		case pl.data[pl.pos] == '>' && pl.data[pl.pos+1] == '>':
+			depth--
+			pl.pos += 2
+			if depth == 0 {
+				return
+			}
+		case pl.data[pl.pos] == '(':
+			pl.literalString()
+		default:
+			pl.pos++
+		}
+	}
+	pl.pos = len(pl.data)
+}
+
+// skipInlineImage skips the binary data of an inline image up to its EI operator
+func (pl *pdfLexer) skipInlineImage() {
+	i := bytes.Index(pl.data[pl.pos:], []byte("EI"))
+	for i >= 0 {
+		end := pl.pos + i
+		before := end == 0 || pdfIsSpace(pl.data[end-1])
+		after := end+2 >= len(pl.data) || pdfIsSpace(pl.data[end+2])
+		if before && after {
+			pl.pos = end + 2
+			return
+		}
+		next := bytes.Index(pl.data[end+2:], []byte("EI"))
+		if next < 0 {
+			break
+		}
+		i += 2 + next
+	}
+	pl.pos = len(pl.data)
+}
+
+// literalString reads a (...) string with its escapes, starting at the opening parenthesis
+func (pl *pdfLexer) literalString() string {
+	pl.pos++ // Opening parenthesis
+	var sb strings.Builder
+	depth := 1
+	for pl.pos < len(pl.data) {
+		c := pl.data[pl.pos]
+		pl.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfDecodeBytes(sb.String())
+			}
+		case '\\':
+			if pl.pos >= len(pl.data) {
+				continue
+			}
+			e := pl.data[pl.pos]
+			pl.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '\r':
+				if pl.pos < len(pl.data) && pl.data[pl.pos] == '\n' {
+					pl.pos++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for k := 0; k < 2 && pl.pos < len(pl.data) && pl.data[pl.pos] >= '0' && pl.data[pl.pos] <= '7'; k++ {
+						n = n*8 + int(pl.data[pl.pos]-'0')
+						pl.pos++
+					}
+					sb.WriteByte(byte(n))
+				} else {
+					sb.WriteByte(e)
+				}
+			}
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return pdfDecodeBytes(sb.String())
+}
+
+// hexString reads a <...> string, starting at the opening angle bracket
+func (pl *pdfLexer) hexString() string {
+	pl.pos++ // Opening angle bracket
+	var digits []byte
+	for pl.pos < len(pl.data) && pl.data[pl.pos] != '>' {
+		c := pl.data[pl.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		pl.pos++
+	}
+	pl.pos++ // Closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	raw := make([]byte, len(digits)/2)
+	for i := range raw {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		raw[i] = byte(n)
+	}
+	return pdfDecodeBytes(string(raw))
+}
+
+// pdfDecodeBytes converts PDF string bytes to UTF-8: UTF-16BE when marked by a
+// byte order mark, otherwise each byte as a Latin-1 character
+func pdfDecodeBytes(raw string) string {
+	if strings.HasPrefix(raw, "\xfe\xff") {
+		var sb strings.Builder
+		for i := 2; i+1 < len(raw); i += 2 {
+			r := rune(raw[i])<<8 | rune(raw[i+1])
+			if r >= 0xD800 && r < 0xDC00 && i+3 < len(raw) {
+				low := rune(raw[i+2])<<8 | rune(raw[i+3])
+				r = (r-0xD800)<<10 + (low - 0xDC00) + 0x10000
+				i += 2
+			}
+			sb.WriteRune(r)
+		}
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if c := raw[i]; c >= 0x20 || c == '\n' || c == '\t' {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// pdfIsSpace reports whether c is PDF whitespace
+func pdfIsSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
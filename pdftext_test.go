@@ -0,0 +1,96 @@
+package quizgenerator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF assembles a minimal PDF holding the given streams, each with its dictionary entries
+func testPDF(streams ...[2]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, stream := range streams {
+		fmt.Fprintf(&buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n%s\nendstream\nendobj\n", i+1, stream[0], len(stream[1]), stream[1])
+	}
+	buf.WriteString("%%EOF\n")
+	return buf.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	return buf.String()
+}
+
+func TestPDFText(t *testing.T) {
+	data := testPDF(
+		[2]string{"", "BT /F1 12 Tf 72 720 Td (Photosynthesis makes sugar.) Tj 0 -14 Td (It needs light.) Tj ET"},
+		[2]string{"/Filter /FlateDecode", deflate(t, []byte("BT [(Chloro) -20 (phyll) -300 (is) -300 (green.)] TJ ET"))},
+		[2]string{"/Subtype /Image /Width 1 /Height 1", "BT (Not text) Tj ET"},
+		[2]string{"/Filter /DCTDecode", "BT (Not text either) Tj ET"},
+	)
+
+	text, err := pdfText(data)
+	if err != nil {
+		t.Fatalf("pdfText failed: %v", err)
+	}
+	for _, want := range []string{"Photosynthesis makes sugar.\nIt needs light.", "Chlorophyll is green."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q doesn't contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, "Not text") {
+		t.Errorf("text %q includes an image or undecodable stream", text)
+	}
+}
+
+func TestPDFTextEscapes(t *testing.T) {
+	data := testPDF([2]string{"", `BT (A \(bracketed\) word) Tj T* <48656C6C6F> Tj ET`})
+	text, err := pdfText(data)
+	if err != nil {
+		t.Fatalf("pdfText failed: %v", err)
+	}
+	if !strings.Contains(text, "A (bracketed) word\nHello") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestPDFTextNotPDF(t *testing.T) {
+	if _, err := pdfText([]byte("<html></html>")); err == nil {
+		t.Errorf("pdfText accepted a file that isn't a PDF")
+	}
+}
+
+func TestPDFTextDecompressionLimit(t *testing.T) {
+	// A small stream that inflates to more than the limit
+	bomb := deflate(t, make([]byte, MaxExtractedBytes+1))
+	data := testPDF([2]string{"/Filter /FlateDecode", bomb})
+
+	if _, err := pdfText(data); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("pdfText error = %v, want ErrSourceTooLarge", err)
+	}
+}
+
+func TestPDFInflateKeepsTextBeforeCorruption(t *testing.T) {
+	compressed := deflate(t, []byte(strings.Repeat("BT (Readable) Tj ET\n", 100)))
+	truncated := []byte(compressed[:len(compressed)/2])
+
+	out, err := pdfInflate(truncated, newExtractLimit())
+	if err != nil {
+		t.Fatalf("pdfInflate failed: %v", err)
+	}
+	if !strings.Contains(string(out), "BT (Readable) Tj ET") {
+		t.Errorf("nothing recovered from the truncated stream")
+	}
+}
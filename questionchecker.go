@@ -4,20 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
-
-	openai "github.com/sashabaranov/go-openai"
 )
 
-// QuestionChecker validates and potentially revises questions using GPT-4o
+// QuestionChecker validates and potentially revises questions using an LLM
 type QuestionChecker struct {
-	client *openai.Client
+	provider LLMProvider
+	config   StageConfig
+	stage    string // Name used for usage accounting and logs
 }
 
-// NewQuestionChecker creates a new question checker with OpenAI client
-func NewQuestionChecker(apiKey string) *QuestionChecker {
+// NewQuestionChecker creates a new question checker using the given provider and stage config
+func NewQuestionChecker(provider LLMProvider, config StageConfig) *QuestionChecker {
 	return &QuestionChecker{
-		client: openai.NewClient(apiKey),
+		provider: provider,
+		config:   config,
+		stage:    "QuestionChecker",
 	}
 }
 
@@ -41,84 +42,112 @@ func (qc *QuestionChecker) CheckQuestion(ctx context.Context, question *Question
 		return result, nil
 	}
 
-	prompt := qc.buildPrompt(question)
+	prompt, err := RenderPrompt(qc.config.Prompt, PromptData{
+		Topic:    question.Topic,
+		Question: question,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Log the request
 	if logger != nil {
-		logger.LogLLMRequest("QuestionChecker", prompt)
+		logger.LogLLMRequest(qc.stage, prompt)
 	}
 
-	resp, err := qc.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4o,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are an expert quiz question validator. Evaluate questions for quality, clarity, and fairness.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+	resp, err := qc.provider.Chat(ctx, ChatRequest{
+		Stage:       qc.stage,
+		Model:       qc.config.Model,
+		Temperature: qc.config.Temperature,
+		MaxTokens:   qc.config.MaxTokens,
+		Messages: []ChatMessage{
+			{
+				Role:    RoleSystem,
+				Content: qc.config.SystemPrompt,
 			},
-			Tools: []openai.Tool{
-				{
-					Type: openai.ToolTypeFunction,
-					Function: &openai.FunctionDefinition{
-						Name:        "evaluate_question",
-						Description: "Evaluate a quiz question and decide whether to accept, reject, or revise it",
-						Parameters: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"reason": map[string]interface{}{
-									"type":        "string",
-									"description": "Explanation for the decision",
+			{
+				Role:    RoleUser,
+				Content: prompt,
+			},
+		},
+		Tool: ToolDefinition{
+			Name:        "evaluate_question",
+			Description: "Evaluate a quiz question and decide whether to accept, reject, or revise it",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reason": map[string]interface{}{
+						"type":        "string",
+						"description": "Explanation for the decision",
+					},
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"accept", "reject", "revise"},
+						"description": "What to do with this question",
+					},
+					"revised_question": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"text": map[string]interface{}{
+								"type":        "string",
+								"description": "The revised question text",
+							},
+							"options": map[string]interface{}{
+								"type": "array",
+								"items": map[string]interface{}{
+									"type": "string",
+								},
+								"description": "Array of answer options; [\"True\", \"False\"] for true/false questions, the items in the correct order for ordering questions, the terms to match for matching questions, and empty for short-answer and numeric questions",
+							},
+							"correct_answer": map[string]interface{}{
+								"type":        "integer",
+								"description": "0-based index of the correct answer; omit for short-answer and numeric questions",
+							},
+							"correct_answers": map[string]interface{}{
+								"type": "array",
+								"items": map[string]interface{}{
+									"type": "integer",
 								},
-								"action": map[string]interface{}{
-									"type":        "string",
-									"enum":        []string{"accept", "reject", "revise"},
-									"description": "What to do with this question",
+								"description": "Multi-select questions only: 0-based indexes of every correct option",
+							},
+							"matches": map[string]interface{}{
+								"type": "array",
+								"items": map[string]interface{}{
+									"type": "string",
 								},
-								"revised_question": map[string]interface{}{
-									"type": "object",
-									"properties": map[string]interface{}{
-										"text": map[string]interface{}{
-											"type":        "string",
-											"description": "The revised question text",
-										},
-										"options": map[string]interface{}{
-											"type": "array",
-											"items": map[string]interface{}{
-												"type": "string",
-											},
-											"description": "Array of 4 multiple choice options",
-										},
-										"correct_answer": map[string]interface{}{
-											"type":        "integer",
-											"description": "0-based index of the correct answer",
-										},
-										"explanation": map[string]interface{}{
-											"type":        "string",
-											"description": "Brief explanation of why the answer is correct",
-										},
-									},
-									"description": "Revised question (only if action is 'revise')",
+								"description": "Matching questions only: the item each option pairs with, in the same order as the options",
+							},
+							"accepted_answers": map[string]interface{}{
+								"type": "array",
+								"items": map[string]interface{}{
+									"type": "string",
 								},
+								"description": "Short-answer questions only: the answer, followed by other spellings and names that should also count",
+							},
+							"numeric_answer": map[string]interface{}{
+								"type":        "number",
+								"description": "Numeric questions only: the exact answer",
+							},
+							"tolerance": map[string]interface{}{
+								"type":        "number",
+								"description": "Numeric questions only: largest difference from numeric_answer, in its unit, that still counts as correct; 0 for exact answers",
+							},
+							"unit": map[string]interface{}{
+								"type":        "string",
+								"description": "Numeric questions only: unit of the answer; empty for counts and years",
+							},
+							"explanation": map[string]interface{}{
+								"type":        "string",
+								"description": "Brief explanation of why the answer is correct",
 							},
-							"required": []string{"reason", "action"},
 						},
+						"description": "Revised question of the same type (only if action is 'revise')",
 					},
 				},
-			},
-			ToolChoice: openai.ToolChoice{
-				Type: openai.ToolTypeFunction,
-				Function: openai.ToolFunction{
-					Name: "evaluate_question",
-				},
+				"required": []string{"reason", "action"},
 			},
 		},
-	)
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to check question: %w", err)
@@ -127,38 +156,39 @@ func (qc *QuestionChecker) CheckQuestion(ctx context.Context, question *Question
 	// Log the response
 	if logger != nil {
 		responseText := ""
-		if len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) > 0 {
-			responseText = resp.Choices[0].Message.ToolCalls[0].Function.Arguments
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
 		}
-		logger.LogLLMResponse("QuestionChecker", responseText)
+		logger.LogLLMResponse(qc.stage, responseText)
 	}
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from GPT-4o")
-	}
-
-	choice := resp.Choices[0]
-	if len(choice.Message.ToolCalls) == 0 {
+	if len(resp.ToolCalls) == 0 {
 		return nil, fmt.Errorf("no tool calls in response")
 	}
 
-	toolCall := choice.Message.ToolCalls[0]
-	if toolCall.Function.Name != "evaluate_question" {
-		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Function.Name)
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "evaluate_question" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
 	}
 
 	var toolArgs struct {
 		Reason          string `json:"reason"`
 		Action          string `json:"action"`
 		RevisedQuestion *struct {
-			Text          string   `json:"text"`
-			Options       []string `json:"options"`
-			CorrectAnswer int      `json:"correct_answer"`
-			Explanation   string   `json:"explanation"`
+			Text            string   `json:"text"`
+			Options         []string `json:"options"`
+			CorrectAnswer   int      `json:"correct_answer"`
+			CorrectAnswers  []int    `json:"correct_answers"`
+			Matches         []string `json:"matches"`
+			AcceptedAnswers []string `json:"accepted_answers"`
+			NumericAnswer   *float64 `json:"numeric_answer"`
+			Tolerance       float64  `json:"tolerance"`
+			Unit            string   `json:"unit"`
+			Explanation     string   `json:"explanation"`
 		} `json:"revised_question,omitempty"`
 	}
 
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &toolArgs); err != nil {
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
 		return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
 	}
 
@@ -170,15 +200,38 @@ func (qc *QuestionChecker) CheckQuestion(ctx context.Context, question *Question
 
 	if toolArgs.Action == "revise" && toolArgs.RevisedQuestion != nil {
 		revised := &Question{
-			ID:            question.ID, // Keep same ID
-			Text:          toolArgs.RevisedQuestion.Text,
-			Options:       toolArgs.RevisedQuestion.Options,
-			CorrectAnswer: toolArgs.RevisedQuestion.CorrectAnswer,
-			Explanation:   toolArgs.RevisedQuestion.Explanation,
-			Topic:         question.Topic,
-			Status:        StatusRevised,
-			RevisionCount: question.RevisionCount + 1, // Increment revision counter
+			ID:              question.ID, // Keep same ID
+			Type:            question.Type,
+			Text:            toolArgs.RevisedQuestion.Text,
+			Options:         toolArgs.RevisedQuestion.Options,
+			CorrectAnswer:   toolArgs.RevisedQuestion.CorrectAnswer,
+			CorrectAnswers:  toolArgs.RevisedQuestion.CorrectAnswers,
+			AcceptedAnswers: toolArgs.RevisedQuestion.AcceptedAnswers,
+			Explanation:     toolArgs.RevisedQuestion.Explanation,
+			Topic:           question.Topic,
+			Status:          StatusRevised,
+			RevisionCount:   question.RevisionCount + 1, // Increment revision counter
+			Source:          question.Source,            // Still has to be supported by the same excerpt
+			Subtopic:        question.Subtopic,
+			Image:           question.Image,
+			chunk:           question.chunk,
+		}
+		if revised.Kind() == QuestionMultiSelect && len(revised.CorrectAnswers) == 0 {
+			revised.CorrectAnswers = []int{revised.CorrectAnswer}
+		}
+		// Arranged questions are revised like they are made, in the correct order or pairing
+		if revised.Arranged() {
+			revised.Matches = toolArgs.RevisedQuestion.Matches
+			revised.CorrectAnswers = identityArrangement(len(revised.Options))
 		}
+		// A revision that leaves out the number keeps the original one
+		if revised.Kind() == QuestionNumeric {
+			revised.Numeric = question.Numeric
+			if rq := toolArgs.RevisedQuestion; rq.NumericAnswer != nil {
+				revised.Numeric = &NumericAnswer{Value: *rq.NumericAnswer, Tolerance: rq.Tolerance, Unit: rq.Unit}
+			}
+		}
+		revised.normalizeAnswers()
 		result.RevisedQuestion = revised
 	}
 
@@ -190,60 +243,3 @@ func (qc *QuestionChecker) CheckQuestion(ctx context.Context, question *Question
 	VerboseLog("Question %s: %s - %s", question.ID, result.Action, result.Reason)
 	return result, nil
 }
-
-func (qc *QuestionChecker) buildPrompt(question *Question) string {
-	var sb strings.Builder
-
-	sb.WriteString("Evaluate the following quiz question:\n\n")
-	sb.WriteString(fmt.Sprintf("Quiz Topic: %s\n\n", question.Topic))
-	sb.WriteString(fmt.Sprintf("Question: %s\n\n", question.Text))
-
-	sb.WriteString("Options:\n")
-	for i, option := range question.Options {
-		marker := " "
-		if i == question.CorrectAnswer {
-			marker = "*"
-		}
-		sb.WriteString(fmt.Sprintf("%s%d. %s\n", marker, i+1, option))
-	}
-
-	sb.WriteString(fmt.Sprintf("\nCorrect Answer: %d\n", question.CorrectAnswer+1))
-	sb.WriteString(fmt.Sprintf("Explanation: %s\n\n", question.Explanation))
-
-	sb.WriteString("CRITICAL EVALUATION CRITERIA:\n")
-	sb.WriteString("🚨 AUTOMATIC REJECTION: If the correct answer appears in the question text, REJECT immediately or REVISE to improve it.\n")
-	sb.WriteString("🚨 AUTOMATIC REJECTION: If the question text contains obvious clues that give away the answer, REJECT immediately or REVISE to improve it.\n")
-	sb.WriteString("🚨 AUTOMATIC REJECTION: If the question is not relevant to the quiz topic, REJECT immediately.\n")
-
-	sb.WriteString("Additional evaluation criteria:\n")
-	sb.WriteString("1. Is the question relevant to the quiz topic?\n")
-	sb.WriteString("2. Is the question clear and unambiguous?\n")
-	sb.WriteString("3. Is the correct answer actually correct?\n")
-	sb.WriteString("4. Are all incorrect options plausible but clearly wrong?\n")
-	sb.WriteString("5. Does the question test understanding rather than just memorization?\n")
-	sb.WriteString("6. Does the explanation provide meaningful context or reasoning for WHY the answer is correct?\n\n")
-
-	sb.WriteString("Topic relevance check:\n")
-	sb.WriteString("- The question must be directly related to the quiz topic\n")
-	sb.WriteString("- If the question is about a different subject or person, it should be rejected\n")
-	sb.WriteString("- The question should test knowledge about the specific topic, not general knowledge\n\n")
-
-	sb.WriteString("Explanation quality check:\n")
-	sb.WriteString("- The explanation should explain WHY the answer is correct, not just restate what the answer is\n")
-	sb.WriteString("- For acronyms, the explanation should break down what each letter stands for\n")
-	sb.WriteString("- For concepts, the explanation should provide context or reasoning\n")
-	sb.WriteString("- Avoid explanations that just repeat the answer in different words\n\n")
-
-	sb.WriteString("Decision guidelines:\n")
-	sb.WriteString("- REJECT: The question has fundamental problems (especially if answer is in question text or not relevant to topic or obvious given the topic)\n")
-	sb.WriteString("- REVISE: If the question has potential but needs improvements\n")
-	sb.WriteString("- ACCEPT: The question is good as-is (only if it passes all criteria)\n\n")
-
-	sb.WriteString("IMPORTANT: Only revise explanations if they are spectacularly bad (e.g., missing acronym definitions, completely wrong information, or no explanation at all).\n")
-	sb.WriteString("For mediocre or basic explanations, ACCEPT the question rather than rejecting it. A good question with a basic explanation is better than no question at all.\n")
-	sb.WriteString("Only reject questions if they have fundamental structural problems (answer in question text, obvious clues, or not relevant to topic or obvious given the topic).\n")
-
-	sb.WriteString("If you choose to revise, provide a complete revised version of the question.")
-
-	return sb.String()
-}
@@ -4,133 +4,154 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
-
-	openai "github.com/sashabaranov/go-openai"
 )
 
-// QuestionDedup checks for duplicate questions using GPT-4o
+// QuestionDedup checks for duplicate questions using a similarity pre-filter and an LLM judge
 type QuestionDedup struct {
-	client *openai.Client
-	cache  map[string]*Question // Cache of accepted questions by ID
+	provider LLMProvider
+	config   StageConfig
+	filter   DedupFilterConfig
+	index    *DedupIndex             // Accepted questions and the question bank
+	bank     map[string]BankQuestion // Questions from other quizzes by ID
+}
+
+// BankQuestion is a question stored with an earlier quiz
+type BankQuestion struct {
+	Question    *Question
+	QuizID      string
+	QuestionNum int
 }
 
-// NewQuestionDedup creates a new question deduplicator
-func NewQuestionDedup(apiKey string) *QuestionDedup {
+// NewQuestionDedup creates a new question deduplicator using the given provider, stage config and pre-filter settings
+func NewQuestionDedup(provider LLMProvider, config StageConfig, filter DedupFilterConfig) *QuestionDedup {
 	return &QuestionDedup{
-		client: openai.NewClient(apiKey),
-		cache:  make(map[string]*Question),
+		provider: provider,
+		config:   config,
+		filter:   filter,
+		index:    NewDedupIndex(provider, filter.EmbeddingModel),
+		bank:     make(map[string]BankQuestion),
+	}
+}
+
+// AddBank makes candidates also count as duplicates of questions from other quizzes
+func (qd *QuestionDedup) AddBank(ctx context.Context, questions []BankQuestion) error {
+	indexed := make([]*Question, 0, len(questions))
+	for _, bq := range questions {
+		if _, ok := qd.bank[bq.Question.ID]; ok {
+			continue
+		}
+		qd.bank[bq.Question.ID] = bq
+		indexed = append(indexed, bq.Question)
+	}
+	if err := qd.index.AddAll(ctx, indexed); err != nil {
+		return fmt.Errorf("failed to index question bank: %w", err)
 	}
+	VerboseLog("Added %d questions from other quizzes to dedup", len(indexed))
+	return nil
 }
 
 // DedupResult represents the result of deduplication
 type DedupResult struct {
-	IsDuplicate bool   `json:"is_duplicate"`
-	Reason      string `json:"reason"`
-	DuplicateID string `json:"duplicate_id,omitempty"` // ID of the duplicate question if found
+	IsDuplicate          bool   `json:"is_duplicate"`
+	Reason               string `json:"reason"`
+	DuplicateID          string `json:"duplicate_id,omitempty"`           // ID of the duplicate question if found
+	DuplicateQuizID      string `json:"duplicate_quiz_id,omitempty"`      // Set when the duplicate is from another quiz
+	DuplicateQuestionNum int    `json:"duplicate_question_num,omitempty"` // Question number within DuplicateQuizID
 }
 
-// CheckDuplicate checks if a question is a duplicate of any previously accepted question
+// CheckDuplicate checks if a question is a duplicate of any previously accepted question or the question bank
 func (qd *QuestionDedup) CheckDuplicate(ctx context.Context, question *Question, logger *LLMLogger) (*DedupResult, error) {
-	if len(qd.cache) == 0 {
+	if qd.index.Len() == 0 {
 		// First question, always accept
-		qd.cache[question.ID] = question
+		if err := qd.index.Add(ctx, question); err != nil {
+			return nil, err
+		}
 		return &DedupResult{IsDuplicate: false, Reason: "First question"}, nil
 	}
 
 	VerboseLog("Checking for duplicates: %s", question.ID)
 
-	// Build context of existing questions
-	var existingQuestions strings.Builder
-	existingQuestions.WriteString("Existing accepted questions:\n\n")
-
-	for id, existing := range qd.cache {
-		existingQuestions.WriteString(fmt.Sprintf("ID: %s\n", id))
-		existingQuestions.WriteString(fmt.Sprintf("Question: %s\n", existing.Text))
-		existingQuestions.WriteString("Options:\n")
-		for i, option := range existing.Options {
-			marker := " "
-			if i == existing.CorrectAnswer {
-				marker = "*"
-			}
-			existingQuestions.WriteString(fmt.Sprintf("%s%d. %s\n", marker, i+1, option))
+	// Only the most similar accepted questions go to the LLM judge
+	similar, err := qd.index.Nearest(ctx, question, qd.filter.TopK)
+	if err != nil {
+		return nil, err
+	}
+	closest := similar[0]
+
+	// Settle obvious cases by similarity alone
+	if qd.filter.DuplicateThreshold > 0 && closest.Similarity >= qd.filter.DuplicateThreshold {
+		result := &DedupResult{
+			IsDuplicate: true,
+			Reason:      fmt.Sprintf("Similarity %.2f to an accepted question is above the duplicate threshold", closest.Similarity),
+			DuplicateID: closest.Question.ID,
 		}
-		existingQuestions.WriteString(fmt.Sprintf("Correct Answer: %d\n", existing.CorrectAnswer+1))
-		existingQuestions.WriteString(fmt.Sprintf("Explanation: %s\n\n", existing.Explanation))
-	}
-
-	// Build prompt for new question
-	var newQuestion strings.Builder
-	newQuestion.WriteString("New question to check:\n\n")
-	newQuestion.WriteString(fmt.Sprintf("ID: %s\n", question.ID))
-	newQuestion.WriteString(fmt.Sprintf("Question: %s\n", question.Text))
-	newQuestion.WriteString("Options:\n")
-	for i, option := range question.Options {
-		marker := " "
-		if i == question.CorrectAnswer {
-			marker = "*"
+		return qd.finish(ctx, question, result, logger)
+	}
+	if qd.filter.UniqueThreshold > 0 && closest.Similarity < qd.filter.UniqueThreshold {
+		result := &DedupResult{
+			IsDuplicate: false,
+			Reason:      fmt.Sprintf("Highest similarity %.2f to an accepted question is below the unique threshold", closest.Similarity),
 		}
-		newQuestion.WriteString(fmt.Sprintf("%s%d. %s\n", marker, i+1, option))
+		return qd.finish(ctx, question, result, logger)
 	}
-	newQuestion.WriteString(fmt.Sprintf("Correct Answer: %d\n", question.CorrectAnswer+1))
-	newQuestion.WriteString(fmt.Sprintf("Explanation: %s\n\n", question.Explanation))
 
-	prompt := existingQuestions.String() + newQuestion.String() + qd.buildEvaluationCriteria()
+	existing := make([]*Question, len(similar))
+	for i, match := range similar {
+		existing[i] = match.Question
+	}
+
+	prompt, err := RenderPrompt(qd.config.Prompt, PromptData{
+		Topic:    question.Topic,
+		Question: question,
+		Existing: existing,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Log the request
 	if logger != nil {
 		logger.LogLLMRequest("QuestionDedup", prompt)
 	}
 
-	resp, err := qd.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are an expert at detecting duplicate quiz questions. Compare the new question against existing questions and determine if it's a duplicate.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+	resp, err := qd.provider.Chat(ctx, ChatRequest{
+		Stage:       "QuestionDedup",
+		Model:       qd.config.Model,
+		Temperature: qd.config.Temperature,
+		MaxTokens:   qd.config.MaxTokens,
+		Messages: []ChatMessage{
+			{
+				Role:    RoleSystem,
+				Content: qd.config.SystemPrompt,
 			},
-			Tools: []openai.Tool{
-				{
-					Type: openai.ToolTypeFunction,
-					Function: &openai.FunctionDefinition{
-						Name:        "check_duplicate",
-						Description: "Check if the new question is a duplicate of any existing question",
-						Parameters: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"reason": map[string]interface{}{
-									"type":        "string",
-									"description": "Explanation for the decision",
-								},
-								"is_duplicate": map[string]interface{}{
-									"type":        "boolean",
-									"description": "Whether the new question is a duplicate",
-								},
-								"duplicate_id": map[string]interface{}{
-									"type":        "string",
-									"description": "ID of the duplicate question if found (empty if not a duplicate)",
-								},
-							},
-							"required": []string{"reason", "is_duplicate"},
-						},
-					},
-				},
+			{
+				Role:    RoleUser,
+				Content: prompt,
 			},
-			ToolChoice: openai.ToolChoice{
-				Type: openai.ToolTypeFunction,
-				Function: openai.ToolFunction{
-					Name: "check_duplicate",
+		},
+		Tool: ToolDefinition{
+			Name:        "check_duplicate",
+			Description: "Check if the new question is a duplicate of any existing question",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reason": map[string]interface{}{
+						"type":        "string",
+						"description": "Explanation for the decision",
+					},
+					"is_duplicate": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Whether the new question is a duplicate",
+					},
+					"duplicate_id": map[string]interface{}{
+						"type":        "string",
+						"description": "ID of the duplicate question if found (empty if not a duplicate)",
+					},
 				},
+				"required": []string{"reason", "is_duplicate"},
 			},
 		},
-	)
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to check duplicate: %w", err)
@@ -139,24 +160,19 @@ func (qd *QuestionDedup) CheckDuplicate(ctx context.Context, question *Question,
 	// Log the response
 	if logger != nil {
 		responseText := ""
-		if len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) > 0 {
-			responseText = resp.Choices[0].Message.ToolCalls[0].Function.Arguments
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
 		}
 		logger.LogLLMResponse("QuestionDedup", responseText)
 	}
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from GPT-4o")
-	}
-
-	choice := resp.Choices[0]
-	if len(choice.Message.ToolCalls) == 0 {
+	if len(resp.ToolCalls) == 0 {
 		return nil, fmt.Errorf("no tool calls in response")
 	}
 
-	toolCall := choice.Message.ToolCalls[0]
-	if toolCall.Function.Name != "check_duplicate" {
-		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Function.Name)
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "check_duplicate" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
 	}
 
 	var toolArgs struct {
@@ -165,7 +181,7 @@ func (qd *QuestionDedup) CheckDuplicate(ctx context.Context, question *Question,
 		DuplicateID string `json:"duplicate_id"`
 	}
 
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &toolArgs); err != nil {
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
 		return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
 	}
 
@@ -175,9 +191,18 @@ func (qd *QuestionDedup) CheckDuplicate(ctx context.Context, question *Question,
 		DuplicateID: toolArgs.DuplicateID,
 	}
 
-	// If not a duplicate, add to cache
+	return qd.finish(ctx, question, result, logger)
+}
+
+// finish indexes the question unless it is a duplicate and logs the result
+func (qd *QuestionDedup) finish(ctx context.Context, question *Question, result *DedupResult, logger *LLMLogger) (*DedupResult, error) {
 	if !result.IsDuplicate {
-		qd.cache[question.ID] = question
+		if err := qd.index.Add(ctx, question); err != nil {
+			return nil, err
+		}
+	} else if bq, ok := qd.bank[result.DuplicateID]; ok {
+		result.DuplicateQuizID = bq.QuizID
+		result.DuplicateQuestionNum = bq.QuestionNum
 	}
 
 	// Log the result
@@ -188,30 +213,3 @@ func (qd *QuestionDedup) CheckDuplicate(ctx context.Context, question *Question,
 	VerboseLog("Question %s: duplicate=%v, reason=%s", question.ID, result.IsDuplicate, result.Reason)
 	return result, nil
 }
-
-func (qd *QuestionDedup) buildEvaluationCriteria() string {
-	return `Evaluation criteria for duplicates:
-
-1. EXACT DUPLICATES: Same question text, same options, same correct answer
-2. NEAR-DUPLICATES: 
-   - Same concept tested but different wording
-   - Same question with minor rephrasing
-   - Same topic with very similar answer choices
-   - Questions that test the same knowledge point
-3. ANSWER SPOILERS:
-   - If an earlier question's text or explanation reveals the answer to the new question
-   - If an earlier question's correct answer choice is mentioned in the new question's text
-   - If the new question becomes trivial because an earlier question already established the answer
-   - In these cases mark the question as a duplicate of the earlier question
-4. NOT DUPLICATES:
-   - Different aspects of the same topic
-   - Different difficulty levels
-   - Different approaches to testing knowledge
-   - Questions that test related but distinct concepts
-
-Consider both the question text and the answer choices when determining duplicates.
-Pay special attention to whether earlier questions spoil the answers to later questions.
-If the new question is a duplicate, provide the ID of the existing question it duplicates.
-
-Decide whether the new question is a duplicate of any existing question.`
-}
@@ -0,0 +1,311 @@
+package quizgenerator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Lint rules; fixes rewrite the question, checks reject it
+const (
+	LintTrimWhitespace     = "trim_whitespace"      // Fix: trim whitespace around text, options and explanation
+	LintStripOptionLabels  = "strip_option_labels"  // Fix: remove "A) ", "B. " style prefixes from options
+	LintTrueFalseOptions   = "true_false_options"   // Fix: put a true/false question's options in the order "True", "False"
+	LintQuestionType       = "question_type"        // Check: the type is known and a true/false question's options are "True" and "False"
+	LintAnswerKey          = "answer_key"           // Check: a short-answer question has an accepted answer, a numeric one a number, and an arranged one a full order or pairing
+	LintOptionCount        = "option_count"         // Check: MinOptions to MaxOptions options; free-response questions skip the option checks
+	LintCorrectAnswerRange = "correct_answer_range" // Check: every correct answer indexes an option, and not all options are correct
+	LintDuplicateOptions   = "duplicate_options"    // Check: no two options are the same
+	LintEmptyOption        = "empty_option"         // Check: no option is blank
+	LintAnswerInQuestion   = "answer_in_question"   // Check: the correct option or accepted answer doesn't appear in the question text
+	LintEmptyExplanation   = "empty_explanation"    // Check: the explanation isn't blank
+	LintAllOfTheAbove      = "all_of_the_above"     // Check: no "all/none of the above" options, which break when shuffled
+	LintSourceQuote        = "source_quote"         // Check: the supporting quote was found in the source material
+)
+
+// LinterConfig toggles individual lint rules; rules not listed are enabled
+type LinterConfig struct {
+	Rules map[string]bool `json:"rules,omitempty"`
+}
+
+// Enabled reports whether the named rule should run
+func (lc LinterConfig) Enabled(rule string) bool {
+	enabled, ok := lc.Rules[rule]
+	return !ok || enabled
+}
+
+// QuestionLinter applies cheap rule-based fixes and checks before the LLM checker
+type QuestionLinter struct {
+	config LinterConfig
+}
+
+// NewQuestionLinter creates a linter with the given rule toggles
+func NewQuestionLinter(config LinterConfig) *QuestionLinter {
+	return &QuestionLinter{config: config}
+}
+
+var (
+	optionLabelRegexp = regexp.MustCompile(`^\(?([A-Fa-f]|[1-6])[).:]\s+`)
+	aboveRegexp       = regexp.MustCompile(`(?i)\b(all|none|both|neither) of the (above|previous)\b`)
+)
+
+// Lint fixes what it can in place and returns an accept result, or a reject
+// result naming the first failed rule
+func (ql *QuestionLinter) Lint(question *Question, logger *LLMLogger) *ValidationResult {
+	for _, fix := range ql.fix(question) {
+		if logger != nil {
+			logger.Logf("Question %s: LINT FIX - %s\n", question.ID, fix)
+		}
+		VerboseLog("Question %s: lint fix - %s", question.ID, fix)
+	}
+
+	result := &ValidationResult{
+		QuestionID: question.ID,
+		Action:     ActionAccept,
+		Reason:     "Passed lint checks",
+	}
+	if rule, reason := ql.check(question); rule != "" {
+		result.Action = ActionReject
+		result.Reason = fmt.Sprintf("Lint %s: %s", rule, reason)
+	}
+
+	if logger != nil && result.Action != ActionAccept {
+		logger.LogQuestionResult(question.ID, string(result.Action), result.Reason)
+	}
+	return result
+}
+
+// fix rewrites the question and describes each change made
+func (ql *QuestionLinter) fix(question *Question) []string {
+	var fixes []string
+
+	if ql.config.Enabled(LintTrimWhitespace) {
+		changed := false
+		trim := func(s *string) {
+			if trimmed := strings.TrimSpace(*s); trimmed != *s {
+				*s = trimmed
+				changed = true
+			}
+		}
+		trim(&question.Text)
+		trim(&question.Explanation)
+		for i := range question.Options {
+			trim(&question.Options[i])
+		}
+		for i := range question.AcceptedAnswers {
+			trim(&question.AcceptedAnswers[i])
+		}
+		for i := range question.Matches {
+			trim(&question.Matches[i])
+		}
+		if changed {
+			fixes = append(fixes, "trimmed whitespace")
+		}
+	}
+
+	// Only strip labels when every option has one, so an option like "1. FC Köln" survives
+	if ql.config.Enabled(LintStripOptionLabels) && len(question.Options) > 0 {
+		labelled := true
+		for _, option := range question.Options {
+			if !optionLabelRegexp.MatchString(option) {
+				labelled = false
+				break
+			}
+		}
+		if labelled {
+			for i, option := range question.Options {
+				question.Options[i] = optionLabelRegexp.ReplaceAllString(option, "")
+			}
+			fixes = append(fixes, "stripped option labels")
+		}
+	}
+
+	if ql.config.Enabled(LintTrueFalseOptions) && question.Kind() == QuestionTrueFalse && len(question.Options) == 2 &&
+		strings.EqualFold(question.Options[0], "false") && strings.EqualFold(question.Options[1], "true") {
+		question.Options[0], question.Options[1] = question.Options[1], question.Options[0]
+		question.CorrectAnswer = 1 - question.CorrectAnswer
+		fixes = append(fixes, "put true/false options in order")
+	}
+
+	return fixes
+}
+
+// check returns the first failed rule and why, or an empty rule if the question passes
+func (ql *QuestionLinter) check(question *Question) (string, string) {
+	if ql.config.Enabled(LintQuestionType) {
+		switch question.Kind() {
+		case QuestionSingleChoice, QuestionMultiSelect, QuestionShortAnswer, QuestionNumeric, QuestionOrdering, QuestionMatching:
+		case QuestionTrueFalse:
+			if len(question.Options) != 2 || !strings.EqualFold(question.Options[0], "true") || !strings.EqualFold(question.Options[1], "false") {
+				return LintQuestionType, fmt.Sprintf("true/false question has options %q", question.Options)
+			}
+		default:
+			return LintQuestionType, fmt.Sprintf("unknown question type %q", question.Type)
+		}
+	}
+
+	if ql.config.Enabled(LintAnswerKey) {
+		switch question.Kind() {
+		case QuestionShortAnswer:
+			if len(question.AcceptedAnswers) == 0 || question.AcceptedAnswers[0] == "" {
+				return LintAnswerKey, "short-answer question has no accepted answer"
+			}
+		case QuestionNumeric:
+			if question.Numeric == nil {
+				return LintAnswerKey, "numeric question has no answer"
+			}
+			if math.IsNaN(question.Numeric.Value) || math.IsInf(question.Numeric.Value, 0) || question.Numeric.Tolerance < 0 {
+				return LintAnswerKey, fmt.Sprintf("numeric answer %s with tolerance %g is not usable", question.Numeric, question.Numeric.Tolerance)
+			}
+		case QuestionOrdering:
+			if len(question.Options) < MinOrderItems {
+				return LintAnswerKey, fmt.Sprintf("ordering question has %d items instead of at least %d", len(question.Options), MinOrderItems)
+			}
+			if !isArrangement(question.CorrectAnswers, len(question.Options)) {
+				return LintAnswerKey, fmt.Sprintf("correct order %v doesn't place each of the %d items once", question.CorrectAnswers, len(question.Options))
+			}
+		case QuestionMatching:
+			if len(question.Matches) != len(question.Options) {
+				return LintAnswerKey, fmt.Sprintf("matching question has %d matches for %d options", len(question.Matches), len(question.Options))
+			}
+			if !isArrangement(question.CorrectAnswers, len(question.Matches)) {
+				return LintAnswerKey, fmt.Sprintf("correct pairs %v don't use each of the %d matches once", question.CorrectAnswers, len(question.Matches))
+			}
+		}
+	}
+
+	// The remaining option checks only apply to questions answered by choosing
+	options := !question.FreeResponse()
+
+	if options && ql.config.Enabled(LintOptionCount) && (len(question.Options) < MinOptions || len(question.Options) > MaxOptions) {
+		return LintOptionCount, fmt.Sprintf("question has %d options instead of %d to %d", len(question.Options), MinOptions, MaxOptions)
+	}
+
+	// Arranged questions have their correct answers checked as a whole by LintAnswerKey
+	if options && !question.Arranged() && ql.config.Enabled(LintCorrectAnswerRange) {
+		correct := question.CorrectOptions()
+		if len(correct) == 0 {
+			return LintCorrectAnswerRange, "no option is marked correct"
+		}
+		for _, answer := range correct {
+			if answer < 0 || answer >= len(question.Options) {
+				return LintCorrectAnswerRange, fmt.Sprintf("correct answer %d is not one of the %d options", answer, len(question.Options))
+			}
+		}
+		if question.Kind() == QuestionMultiSelect && len(correct) == len(question.Options) {
+			return LintCorrectAnswerRange, "every option is marked correct"
+		}
+	}
+
+	if ql.config.Enabled(LintEmptyOption) {
+		for i, option := range question.Options {
+			if strings.TrimSpace(option) == "" {
+				return LintEmptyOption, fmt.Sprintf("option %d is empty", i+1)
+			}
+		}
+		for i, match := range question.Matches {
+			if strings.TrimSpace(match) == "" {
+				return LintEmptyOption, fmt.Sprintf("match %d is empty", i+1)
+			}
+		}
+	}
+
+	if ql.config.Enabled(LintDuplicateOptions) {
+		seen := make(map[string]int)
+		for i, option := range question.Options {
+			key := strings.ToLower(strings.TrimSpace(option))
+			if j, ok := seen[key]; ok {
+				return LintDuplicateOptions, fmt.Sprintf("options %d and %d are both %q", j+1, i+1, option)
+			}
+			seen[key] = i
+		}
+		seen = make(map[string]int)
+		for i, match := range question.Matches {
+			key := strings.ToLower(strings.TrimSpace(match))
+			if j, ok := seen[key]; ok {
+				return LintDuplicateOptions, fmt.Sprintf("matches %d and %d are both %q", j+1, i+1, match)
+			}
+			seen[key] = i
+		}
+	}
+
+	if ql.config.Enabled(LintAllOfTheAbove) {
+		for i, option := range question.Options {
+			if aboveRegexp.MatchString(option) {
+				return LintAllOfTheAbove, fmt.Sprintf("option %d %q depends on option order", i+1, option)
+			}
+		}
+	}
+
+	// A true/false statement may well contain the word "true", and a numeric
+	// question the numbers it is worked out from
+	if ql.config.Enabled(LintAnswerInQuestion) && question.Kind() != QuestionTrueFalse && question.Kind() != QuestionNumeric {
+		answers := append([]string(nil), question.AcceptedAnswers...)
+		for _, index := range question.CorrectOptions() {
+			if index >= 0 && index < len(question.Options) {
+				answers = append(answers, question.Options[index])
+			}
+		}
+		for _, answer := range answers {
+			// Short answers like "1" or "Au" appear in unrelated words too often to judge
+			answer = strings.TrimSpace(answer)
+			if len(answer) >= 4 && containsWord(question.Text, answer) {
+				return LintAnswerInQuestion, fmt.Sprintf("the correct answer %q appears in the question text", answer)
+			}
+		}
+	}
+
+	if ql.config.Enabled(LintEmptyExplanation) && strings.TrimSpace(question.Explanation) == "" {
+		return LintEmptyExplanation, "explanation is empty"
+	}
+
+	if ql.config.Enabled(LintSourceQuote) && question.Source != nil && !question.Source.Found() {
+		if question.Source.Quote == "" {
+			return LintSourceQuote, "no supporting quote from the source material"
+		}
+		return LintSourceQuote, fmt.Sprintf("supporting quote %q is not in the source material", question.Source.Quote)
+	}
+
+	return "", ""
+}
+
+// isArrangement reports whether arrangement uses each index from 0 to n-1 exactly once
+func isArrangement(arrangement []int, n int) bool {
+	if len(arrangement) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, index := range arrangement {
+		if index < 0 || index >= n || seen[index] {
+			return false
+		}
+		seen[index] = true
+	}
+	return true
+}
+
+// containsWord reports whether phrase appears in text as whole words, ignoring
+// case. It runs for every answer of every question, so it searches with strings
+// rather than compiling a pattern for each phrase.
+func containsWord(text, phrase string) bool {
+	text, phrase = strings.ToLower(text), strings.ToLower(phrase)
+	for start := 0; start <= len(text); {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		if (i == 0 || !isWordByte(text[i-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		start = i + 1
+	}
+	return false
+}
+
+// isWordByte reports whether b is an ASCII letter, digit or underscore
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
@@ -0,0 +1,255 @@
+package quizgenerator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuestionLinterFixes(t *testing.T) {
+	question := &Question{
+		ID:            "q1",
+		Text:          "  Which volcano buried Pompeii? ",
+		Options:       []string{"A) Vesuvius", "B) Etna ", "C) Hekla", "D) Fuji"},
+		CorrectAnswer: 0,
+		Explanation:   "Vesuvius erupted in 79 AD.\n",
+	}
+	result := NewQuestionLinter(LinterConfig{}).Lint(question, nil)
+	if result.Action != ActionAccept {
+		t.Fatalf("Lint = %+v, want the fixed question accepted", result)
+	}
+	if question.Text != "Which volcano buried Pompeii?" || question.Explanation != "Vesuvius erupted in 79 AD." {
+		t.Errorf("whitespace wasn't trimmed: %q, %q", question.Text, question.Explanation)
+	}
+	if want := []string{"Vesuvius", "Etna", "Hekla", "Fuji"}; !reflect.DeepEqual(question.Options, want) {
+		t.Errorf("options = %q, want %q", question.Options, want)
+	}
+
+	// Labels are only stripped when every option has one
+	question.Options = []string{"1. FC Köln", "Bayern", "Schalke", "Dortmund"}
+	NewQuestionLinter(LinterConfig{}).Lint(question, nil)
+	if question.Options[0] != "1. FC Köln" {
+		t.Errorf("option %q lost its number", question.Options[0])
+	}
+}
+
+func TestQuestionLinterChecks(t *testing.T) {
+	valid := func() *Question {
+		return &Question{
+			ID:            "q1",
+			Text:          "Which volcano buried Pompeii?",
+			Options:       []string{"Vesuvius", "Etna", "Hekla", "Fuji"},
+			CorrectAnswer: 0,
+			Explanation:   "Vesuvius erupted in 79 AD.",
+		}
+	}
+	tests := map[string]func(q *Question){
+		"":                     func(q *Question) {},
+		LintQuestionType:       func(q *Question) { q.Type = "essay" },
+		LintOptionCount:        func(q *Question) { q.Options = q.Options[:1] },
+		LintCorrectAnswerRange: func(q *Question) { q.CorrectAnswer = 4 },
+		LintEmptyOption:        func(q *Question) { q.Options[2] = " " },
+		LintDuplicateOptions:   func(q *Question) { q.Options[3] = "etna" },
+		LintAllOfTheAbove:      func(q *Question) { q.Options[3] = "None of the above" },
+		LintAnswerInQuestion:   func(q *Question) { q.Text = "Did Vesuvius bury Pompeii?" },
+		LintEmptyExplanation:   func(q *Question) { q.Explanation = "" },
+		LintSourceQuote:        func(q *Question) { q.Source = &SourceSpan{Quote: "Vesuvius is in Italy", Start: -1, End: -1} },
+	}
+	for rule, modify := range tests {
+		question := valid()
+		modify(question)
+		result := NewQuestionLinter(LinterConfig{}).Lint(question, nil)
+		if rule == "" {
+			if result.Action != ActionAccept {
+				t.Errorf("valid question: %+v", result)
+			}
+			continue
+		}
+		if result.Action != ActionReject || !strings.Contains(result.Reason, rule) {
+			t.Errorf("%s: Lint = %+v, want rejected by the rule", rule, result)
+		}
+
+		// Disabling the rule lets the question through
+		question = valid()
+		modify(question)
+		if result := NewQuestionLinter(LinterConfig{Rules: map[string]bool{rule: false}}).Lint(question, nil); strings.Contains(result.Reason, rule) {
+			t.Errorf("%s: disabled rule still ran: %+v", rule, result)
+		}
+	}
+}
+
+func TestQuestionLinterTypes(t *testing.T) {
+	trueFalse := &Question{
+		ID:            "q1",
+		Type:          QuestionTrueFalse,
+		Text:          "Vesuvius buried Pompeii. True or false?",
+		Options:       []string{"False", "True"},
+		CorrectAnswer: 1,
+		Explanation:   "Vesuvius erupted in 79 AD.",
+	}
+	if result := NewQuestionLinter(LinterConfig{}).Lint(trueFalse, nil); result.Action != ActionAccept {
+		t.Fatalf("Lint of reversed true/false options = %+v, want them fixed", result)
+	}
+	if trueFalse.Options[0] != "True" || trueFalse.CorrectAnswer != 0 {
+		t.Errorf("true/false question fixed to options %q with answer %d, want True first and still correct", trueFalse.Options, trueFalse.CorrectAnswer)
+	}
+
+	trueFalse.Options = []string{"Yes", "No"}
+	if result := NewQuestionLinter(LinterConfig{}).Lint(trueFalse, nil); result.Action != ActionReject || !strings.Contains(result.Reason, LintQuestionType) {
+		t.Errorf("Lint of true/false question with options %q = %+v", trueFalse.Options, result)
+	}
+
+	multiSelect := &Question{
+		ID:             "q2",
+		Type:           QuestionMultiSelect,
+		Text:           "Which of these volcanoes are in Italy? Select all that apply.",
+		Options:        []string{"Vesuvius", "Etna", "Hekla", "Fuji"},
+		CorrectAnswers: []int{0, 1},
+		Explanation:    "Vesuvius and Etna are both Italian.",
+	}
+	if result := NewQuestionLinter(LinterConfig{}).Lint(multiSelect, nil); result.Action != ActionAccept {
+		t.Errorf("Lint of valid multi-select question = %+v", result)
+	}
+	for _, answers := range [][]int{nil, {0, 4}, {0, 1, 2, 3}} {
+		multiSelect.CorrectAnswers = answers
+		if result := NewQuestionLinter(LinterConfig{}).Lint(multiSelect, nil); result.Action != ActionReject || !strings.Contains(result.Reason, LintCorrectAnswerRange) {
+			t.Errorf("Lint of multi-select question with correct answers %v = %+v", answers, result)
+		}
+	}
+
+	// Every correct option of a multi-select question is kept out of the question text
+	multiSelect.CorrectAnswers = []int{0, 1}
+	multiSelect.Text = "Is Etna one of these Italian volcanoes? Select all that apply."
+	if result := NewQuestionLinter(LinterConfig{}).Lint(multiSelect, nil); result.Action != ActionReject || !strings.Contains(result.Reason, LintAnswerInQuestion) {
+		t.Errorf("Lint of multi-select question naming a correct option = %+v", result)
+	}
+}
+
+func TestQuestionLinterFreeResponse(t *testing.T) {
+	shortAnswer := func() *Question {
+		return &Question{
+			ID:              "q1",
+			Type:            QuestionShortAnswer,
+			Text:            "Which volcano buried Pompeii?",
+			AcceptedAnswers: []string{" Vesuvius ", "Mount Vesuvius"},
+			Explanation:     "Vesuvius erupted in 79 AD.",
+		}
+	}
+	numeric := func() *Question {
+		return &Question{
+			ID:          "q2",
+			Type:        QuestionNumeric,
+			Text:        "In what year AD did Vesuvius bury Pompeii, 79 or later?",
+			Numeric:     &NumericAnswer{Value: 79},
+			Explanation: "Vesuvius erupted in 79 AD.",
+		}
+	}
+
+	// Free-response questions have no options to check, and a numeric question may mention its answer
+	for _, question := range []*Question{shortAnswer(), numeric()} {
+		if result := NewQuestionLinter(LinterConfig{}).Lint(question, nil); result.Action != ActionAccept {
+			t.Errorf("Lint of valid %s question = %+v", question.Kind(), result)
+		}
+	}
+	question := shortAnswer()
+	NewQuestionLinter(LinterConfig{}).Lint(question, nil)
+	if question.AcceptedAnswers[0] != "Vesuvius" {
+		t.Errorf("accepted answer %q wasn't trimmed", question.AcceptedAnswers[0])
+	}
+
+	tests := map[string]struct {
+		question *Question
+		rule     string
+	}{
+		"no accepted answer": {&Question{Type: QuestionShortAnswer, Text: "Which volcano?", Explanation: "None."}, LintAnswerKey},
+		"no numeric answer":  {&Question{Type: QuestionNumeric, Text: "How high?", Explanation: "None."}, LintAnswerKey},
+		"negative tolerance": {&Question{Type: QuestionNumeric, Text: "How high?", Numeric: &NumericAnswer{Value: 1, Tolerance: -1}, Explanation: "None."}, LintAnswerKey},
+		"answer in the question": {
+			&Question{Type: QuestionShortAnswer, Text: "Which volcano, Vesuvius or Etna, buried Pompeii?", AcceptedAnswers: []string{"Vesuvius"}, Explanation: "It erupted in 79 AD."},
+			LintAnswerInQuestion,
+		},
+	}
+	for name, tt := range tests {
+		if result := NewQuestionLinter(LinterConfig{}).Lint(tt.question, nil); result.Action != ActionReject || !strings.Contains(result.Reason, tt.rule) {
+			t.Errorf("%s: Lint = %+v, want rejected by %s", name, result, tt.rule)
+		}
+	}
+}
+
+func TestQuestionLinterArranged(t *testing.T) {
+	ordering := func() *Question {
+		return &Question{
+			ID:             "q1",
+			Type:           QuestionOrdering,
+			Text:           "Put these eruptions in order, earliest first.",
+			Options:        []string{"Krakatoa", "Vesuvius", "Pinatubo", "Tambora"},
+			CorrectAnswers: []int{1, 3, 0, 2},
+			Explanation:    "Vesuvius erupted in 79, Tambora in 1815, Krakatoa in 1883 and Pinatubo in 1991.",
+		}
+	}
+	matching := func() *Question {
+		return &Question{
+			ID:             "q2",
+			Type:           QuestionMatching,
+			Text:           "Match each volcano to its country.",
+			Options:        []string{"Vesuvius", "Fuji", "Hekla"},
+			Matches:        []string{" Iceland ", "Italy", "Japan"},
+			CorrectAnswers: []int{1, 2, 0},
+			Explanation:    "Vesuvius is in Italy, Fuji in Japan and Hekla in Iceland.",
+		}
+	}
+
+	for _, question := range []*Question{ordering(), matching()} {
+		if result := NewQuestionLinter(LinterConfig{}).Lint(question, nil); result.Action != ActionAccept {
+			t.Errorf("Lint of valid %s question = %+v", question.Kind(), result)
+		}
+	}
+	question := matching()
+	NewQuestionLinter(LinterConfig{}).Lint(question, nil)
+	if question.Matches[0] != "Iceland" {
+		t.Errorf("match %q wasn't trimmed", question.Matches[0])
+	}
+
+	tests := map[string]struct {
+		mutate func(q *Question)
+		rule   string
+	}{
+		"too few items":      {func(q *Question) { q.Options, q.CorrectAnswers = q.Options[:2], []int{1, 0} }, LintAnswerKey},
+		"item placed twice":  {func(q *Question) { q.CorrectAnswers = []int{1, 1, 0, 2} }, LintAnswerKey},
+		"item left out":      {func(q *Question) { q.CorrectAnswers = []int{1, 3, 0} }, LintAnswerKey},
+		"missing match":      {func(q *Question) { q.Type, q.Matches = QuestionMatching, []string{"a", "b", "c"} }, LintAnswerKey},
+		"match used twice":   {func(q *Question) { *q = *matching(); q.CorrectAnswers = []int{1, 1, 0} }, LintAnswerKey},
+		"empty match":        {func(q *Question) { *q = *matching(); q.Matches[2] = " " }, LintEmptyOption},
+		"duplicate matches":  {func(q *Question) { *q = *matching(); q.Matches[2] = "italy" }, LintDuplicateOptions},
+		"out of range match": {func(q *Question) { *q = *matching(); q.CorrectAnswers = []int{1, 2, 3} }, LintAnswerKey},
+	}
+	for name, tt := range tests {
+		question := ordering()
+		tt.mutate(question)
+		if result := NewQuestionLinter(LinterConfig{}).Lint(question, nil); result.Action != ActionReject || !strings.Contains(result.Reason, tt.rule) {
+			t.Errorf("%s: Lint = %+v, want rejected by %s", name, result, tt.rule)
+		}
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		text, phrase string
+		want         bool
+	}{
+		{"Did Vesuvius bury Pompeii?", "vesuvius", true},
+		{"Which gas is 78% of air, nitrogen?", "Nitrogen", true},
+		{"Which element is in the Goldfields?", "Gold", false},
+		{"What does C++ compile to?", "C++", true},
+		{"Which mountain range?", "Mount Etna", false},
+		{"Is gold or goldleaf denser? Gold.", "gold", true},
+		{"Golden goldfish", "gold", false},
+		{"What is H2O_2?", "H2O", false},
+		{"Café Terrace at Night", "CAFÉ", true},
+	}
+	for _, tt := range tests {
+		if got := containsWord(tt.text, tt.phrase); got != tt.want {
+			t.Errorf("containsWord(%q, %q) = %v, want %v", tt.text, tt.phrase, got, tt.want)
+		}
+	}
+}
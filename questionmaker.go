@@ -5,42 +5,165 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"slices"
 	"strings"
+	"sync"
 	"time"
-
-	openai "github.com/sashabaranov/go-openai"
 )
 
-// QuestionMaker generates questions using GPT-4o
+// DefaultHistoryTokens is the estimated size of the follow-up batches at which
+// the maker starts a new conversation
+const DefaultHistoryTokens = 6000
+
+// DefaultStemTokens is the estimated size of the list of earlier questions a new conversation starts with
+const DefaultStemTokens = 1500
+
+// HistoryConfig bounds the conversation the question maker keeps across batches
+type HistoryConfig struct {
+	// Estimated tokens of follow-up batches above which they are dropped and
+	// summarised as a list of question stems; 0 means DefaultHistoryTokens. The
+	// opening prompt isn't counted, so long source material doesn't force a new
+	// conversation for every batch.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Estimated tokens of question stems listed when a conversation starts
+	// over; 0 means DefaultStemTokens
+	StemTokens   int  `json:"stem_tokens,omitempty"`
+	AcceptedOnly bool `json:"accepted_only,omitempty"`  // List only accepted questions rather than every one generated
+	FreshOnRetry bool `json:"fresh_on_retry,omitempty"` // Retry a failed batch once in a new conversation
+}
+
+// QuestionMaker generates questions using an LLM
 type QuestionMaker struct {
-	client *openai.Client
+	provider LLMProvider
+	config   StageConfig
+	history  HistoryConfig
 	// Maintain conversation context to avoid duplicates
-	messages []openai.ChatCompletionMessage
+	messages []ChatMessage
+	chunk    int // Index of the source chunk the conversation is about, -1 before the first chunk
+
+	mu    sync.Mutex
+	stems []string // Texts of earlier questions, listed when a conversation starts over
 }
 
-// NewQuestionMaker creates a new question maker with OpenAI client
-func NewQuestionMaker(apiKey string) *QuestionMaker {
+// NewQuestionMaker creates a new question maker using the given provider, stage config and history bounds
+func NewQuestionMaker(provider LLMProvider, config StageConfig, history HistoryConfig) *QuestionMaker {
+	if history.MaxTokens <= 0 {
+		history.MaxTokens = DefaultHistoryTokens
+	}
+	if history.StemTokens <= 0 {
+		history.StemTokens = DefaultStemTokens
+	}
 	return &QuestionMaker{
-		client: openai.NewClient(apiKey),
-		messages: []openai.ChatCompletionMessage{
+		provider: provider,
+		config:   config,
+		history:  history,
+		messages: []ChatMessage{
 			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an expert quiz question generator. Generate high-quality multiple choice questions with exactly 4 options each.",
+				Role:    RoleSystem,
+				Content: config.SystemPrompt,
 			},
 		},
+		chunk: -1,
 	}
 }
 
-// GenerateQuestions generates a batch of questions for the given topic
-func (qm *QuestionMaker) GenerateQuestions(ctx context.Context, req GenerationRequest, batchSize int, logger *LLMLogger) ([]*Question, error) {
+// GenerateQuestions generates a batch of questions for the given topic. When
+// chunk is set, questions are drawn from that part of the source material only;
+// when subtopic is set, they are all about that subtopic and tagged with it.
+func (qm *QuestionMaker) GenerateQuestions(ctx context.Context, req GenerationRequest, batchSize int, chunk *SourceChunk, subtopic *Subtopic, logger *LLMLogger) ([]*Question, error) {
 	VerboseLog("Generating %d questions for topic: %s", batchSize, req.Topic)
 
+	// Start a new conversation for each chunk so earlier chunks don't fill the context
+	if chunk != nil {
+		if chunk.Index != qm.chunk {
+			VerboseLog("Switching to source chunk %d", chunk.Index+1)
+			qm.messages = qm.messages[:1]
+			qm.chunk = chunk.Index
+		}
+		req.SourceMaterial = chunk.Text
+	}
+
+	questions, err := qm.generate(ctx, req, batchSize, chunk, subtopic, logger)
+	// A long conversation may be what made the call fail, so try once more without it
+	if err != nil && qm.history.FreshOnRetry && len(qm.messages) > 1 && ctx.Err() == nil &&
+		(!IsPermanentLLMError(err) || IsContextLengthError(err)) {
+		VerboseLog("Retrying batch in a new conversation after: %v", err)
+		qm.messages = qm.messages[:1]
+		questions, err = qm.generate(ctx, req, batchSize, chunk, subtopic, logger)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !qm.history.AcceptedOnly {
+		for _, question := range questions {
+			qm.addStem(question.Text)
+		}
+	}
+	VerboseLog("Generated %d questions", len(questions))
+	return questions, nil
+}
+
+// RecordAccepted notes an accepted question so it is listed when a conversation
+// starts over; only needed with AcceptedOnly, as otherwise every generated question is
+func (qm *QuestionMaker) RecordAccepted(question *Question) {
+	if qm.history.AcceptedOnly {
+		qm.addStem(question.Text)
+	}
+}
+
+func (qm *QuestionMaker) addStem(text string) {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+	qm.stems = append(qm.stems, text)
+}
+
+// recentStems returns the newest question stems that fit in about the given number of tokens, oldest first
+func (qm *QuestionMaker) recentStems(tokens int) []string {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+	start := len(qm.stems)
+	for start > 0 {
+		tokens -= len(qm.stems[start-1])/4 + 2 // The stem plus its list marker
+		if tokens < 0 {
+			break
+		}
+		start--
+	}
+	return append([]string(nil), qm.stems[start:]...)
+}
+
+// generate makes one call to the maker, continuing the conversation unless it
+// has grown past the history limit. On failure the conversation is left as it was.
+func (qm *QuestionMaker) generate(ctx context.Context, req GenerationRequest, batchSize int, chunk *SourceChunk, subtopic *Subtopic, logger *LLMLogger) (questions []*Question, err error) {
 	// Build the prompt for this request
-	prompt := qm.buildPrompt(req, batchSize)
+	prompt, err := qm.buildPrompt(req, batchSize, chunk != nil, subtopic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace a conversation whose follow-ups have grown too long with a list of the questions it produced
+	if len(qm.messages) > 1 {
+		if size := estimateTokens(qm.messages[2:]) + len(prompt)/4; size > qm.history.MaxTokens {
+			VerboseLog("Maker conversation grew by about %d tokens, starting a new one", size)
+			qm.messages = qm.messages[:1]
+			prompt, err = qm.buildPrompt(req, batchSize, chunk != nil, subtopic)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	history := len(qm.messages)
+	defer func() {
+		if err != nil {
+			qm.messages = qm.messages[:history]
+		}
+	}()
 
 	// Add the user message to the conversation
-	userMessage := openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
+	userMessage := ChatMessage{
+		Role:    RoleUser,
 		Content: prompt,
 	}
 	qm.messages = append(qm.messages, userMessage)
@@ -50,62 +173,126 @@ func (qm *QuestionMaker) GenerateQuestions(ctx context.Context, req GenerationRe
 		logger.LogLLMRequest("QuestionMaker", prompt)
 	}
 
-	resp, err := qm.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT4o,
-			Messages: qm.messages,
-			Tools: []openai.Tool{
-				{
-					Type: openai.ToolTypeFunction,
-					Function: &openai.FunctionDefinition{
-						Name:        "submit_questions",
-						Description: "Submit generated quiz questions",
-						Parameters: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"questions": map[string]interface{}{
-									"type": "array",
-									"items": map[string]interface{}{
-										"type": "object",
-										"properties": map[string]interface{}{
-											"text": map[string]interface{}{
-												"type":        "string",
-												"description": "The question text",
-											},
-											"options": map[string]interface{}{
-												"type": "array",
-												"items": map[string]interface{}{
-													"type": "string",
-												},
-												"description": "Array of 4 multiple choice options",
-											},
-											"correct_answer": map[string]interface{}{
-												"type":        "integer",
-												"description": "0-based index of the correct answer",
-											},
-											"explanation": map[string]interface{}{
-												"type":        "string",
-												"description": "Brief explanation of why the answer is correct",
-											},
-										},
-										"required": []string{"text", "options", "correct_answer", "explanation"},
-									},
-								},
-							},
-							"required": []string{"questions"},
+	// With source material every question must cite the passage supporting its answer
+	required := []string{"text", "options", "correct_answer", "explanation"}
+	if req.SourceMaterial != "" {
+		required = append(required, "source_quote")
+	}
+	properties := map[string]interface{}{
+		"text": map[string]interface{}{
+			"type":        "string",
+			"description": "The question text",
+		},
+		"options": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+			"description": optionsDescription(req),
+		},
+		"correct_answer": map[string]interface{}{
+			"type":        "integer",
+			"description": "0-based index of the correct answer",
+		},
+		"explanation": map[string]interface{}{
+			"type":        "string",
+			"description": "Brief explanation of why the answer is correct",
+		},
+		"source_quote": map[string]interface{}{
+			"type":        "string",
+			"description": "Exact sentence or sentences copied from the source material that support the correct answer",
+		},
+	}
+	// Like other question types, images are only described when there are some to pick from
+	if len(req.SourceImages) > 0 {
+		properties["image"] = map[string]interface{}{
+			"type":        "integer",
+			"description": fmt.Sprintf("Number of the source image to show with the question, from 1 to %d; omit or 0 for none", len(req.SourceImages)),
+		}
+	}
+	// Other question types are only described when asked for, so the default schema stays the same
+	if len(req.QuestionTypes) > 0 {
+		required = append(required, "type")
+		properties["type"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        req.QuestionTypes,
+			"description": "Type of the question",
+		}
+		properties["correct_answer"].(map[string]interface{})["description"] = correctAnswerDescription(req)
+		if slices.Contains(req.QuestionTypes, QuestionMultiSelect) {
+			properties["correct_answers"] = map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "integer",
+				},
+				"description": "multi_select only: 0-based indexes of every correct option",
+			}
+		}
+		// Typed answers have no options, so a batch that may contain them can't require options
+		if slices.Contains(req.QuestionTypes, QuestionShortAnswer) || slices.Contains(req.QuestionTypes, QuestionNumeric) {
+			required = slices.DeleteFunc(required, func(name string) bool {
+				return name == "options" || name == "correct_answer"
+			})
+		}
+		if slices.Contains(req.QuestionTypes, QuestionMatching) {
+			properties["matches"] = map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+				"description": "matching only: the item each option pairs with, in the same order as the options",
+			}
+		}
+		if slices.Contains(req.QuestionTypes, QuestionShortAnswer) {
+			properties["accepted_answers"] = map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+				"description": "short_answer only: the answer, followed by other spellings, abbreviations and names that should also count",
+			}
+		}
+		if slices.Contains(req.QuestionTypes, QuestionNumeric) {
+			properties["numeric_answer"] = map[string]interface{}{
+				"type":        "number",
+				"description": "numeric only: the exact answer",
+			}
+			properties["tolerance"] = map[string]interface{}{
+				"type":        "number",
+				"description": "numeric only: largest difference from numeric_answer, in its unit, that still counts as correct; 0 for exact answers",
+			}
+			properties["unit"] = map[string]interface{}{
+				"type":        "string",
+				"description": "numeric only: unit of the answer, such as \"km\" or \"%\"; empty for counts and years",
+			}
+		}
+	}
+
+	resp, err := qm.provider.Chat(ctx, ChatRequest{
+		Stage:       "QuestionMaker",
+		Model:       qm.config.Model,
+		Temperature: qm.config.Temperature,
+		MaxTokens:   qm.config.MaxTokens,
+		Messages:    qm.messages,
+		Tool: ToolDefinition{
+			Name:        "submit_questions",
+			Description: "Submit generated quiz questions",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"questions": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type":       "object",
+							"properties": properties,
+							"required":   required,
 						},
 					},
 				},
-			},
-			ToolChoice: openai.ToolChoice{
-				Type: openai.ToolTypeFunction,
-				Function: openai.ToolFunction{
-					Name: "submit_questions",
-				},
+				"required": []string{"questions"},
 			},
 		},
-	)
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate questions: %w", err)
@@ -114,59 +301,63 @@ func (qm *QuestionMaker) GenerateQuestions(ctx context.Context, req GenerationRe
 	// Log the response
 	if logger != nil {
 		responseText := ""
-		if len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) > 0 {
-			responseText = resp.Choices[0].Message.ToolCalls[0].Function.Arguments
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
 		}
 		logger.LogLLMResponse("QuestionMaker", responseText)
 	}
 
-	VerboseLog("Received response from GPT-4o with %d choices", len(resp.Choices))
+	VerboseLog("Received response from %s with %d tool calls", qm.config.Model, len(resp.ToolCalls))
 
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from GPT-4o")
-	}
-
-	choice := resp.Choices[0]
-	if len(choice.Message.ToolCalls) == 0 {
+	if len(resp.ToolCalls) == 0 {
 		return nil, fmt.Errorf("no tool calls in response")
 	}
 
-	toolCall := choice.Message.ToolCalls[0]
-	if toolCall.Function.Name != "submit_questions" {
-		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Function.Name)
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "submit_questions" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
 	}
 
 	// Add the assistant's response to the conversation context
-	assistantMessage := openai.ChatCompletionMessage{
-		Role:      openai.ChatMessageRoleAssistant,
-		ToolCalls: choice.Message.ToolCalls,
+	assistantMessage := ChatMessage{
+		Role:      RoleAssistant,
+		ToolCalls: resp.ToolCalls,
 	}
 	qm.messages = append(qm.messages, assistantMessage)
 
 	// Add tool response messages for each tool call
-	for _, toolCall := range choice.Message.ToolCalls {
-		toolMessage := openai.ChatCompletionMessage{
-			Role:       openai.ChatMessageRoleTool,
+	for _, toolCall := range resp.ToolCalls {
+		toolMessage := ChatMessage{
+			Role:       RoleTool,
 			ToolCallID: toolCall.ID,
-			Content:    toolCall.Function.Arguments,
+			Content:    toolCall.Arguments,
 		}
 		qm.messages = append(qm.messages, toolMessage)
 	}
 
 	var toolArgs struct {
 		Questions []struct {
-			Text          string   `json:"text"`
-			Options       []string `json:"options"`
-			CorrectAnswer int      `json:"correct_answer"`
-			Explanation   string   `json:"explanation"`
+			Type            string   `json:"type"`
+			Text            string   `json:"text"`
+			Options         []string `json:"options"`
+			CorrectAnswer   int      `json:"correct_answer"`
+			CorrectAnswers  []int    `json:"correct_answers"`
+			Matches         []string `json:"matches"`
+			AcceptedAnswers []string `json:"accepted_answers"`
+			NumericAnswer   *float64 `json:"numeric_answer"`
+			Tolerance       float64  `json:"tolerance"`
+			Unit            string   `json:"unit"`
+			Explanation     string   `json:"explanation"`
+			SourceQuote     string   `json:"source_quote"`
+			Image           int      `json:"image"`
 		} `json:"questions"`
 	}
 
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &toolArgs); err != nil {
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
 		return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
 	}
 
-	questions := make([]*Question, 0, len(toolArgs.Questions))
+	questions = make([]*Question, 0, len(toolArgs.Questions))
 	for _, q := range toolArgs.Questions {
 		question := &Question{
 			ID:            generateQuestionID(),
@@ -178,44 +369,144 @@ func (qm *QuestionMaker) GenerateQuestions(ctx context.Context, req GenerationRe
 			Status:        StatusTentative,
 			RevisionCount: 0,
 		}
+		if len(req.QuestionTypes) > 0 {
+			question.Type = QuestionType(strings.ToLower(strings.TrimSpace(q.Type)))
+			if question.Type == "" && len(req.QuestionTypes) == 1 {
+				question.Type = req.QuestionTypes[0]
+			}
+			question.CorrectAnswers = q.CorrectAnswers
+			if question.Type == QuestionMultiSelect && len(question.CorrectAnswers) == 0 {
+				question.CorrectAnswers = []int{q.CorrectAnswer}
+			}
+			if question.Arranged() {
+				question.Matches = q.Matches
+				question.CorrectAnswers = identityArrangement(len(q.Options))
+			}
+			question.AcceptedAnswers = q.AcceptedAnswers
+			if question.Type == QuestionNumeric && q.NumericAnswer != nil {
+				question.Numeric = &NumericAnswer{Value: *q.NumericAnswer, Tolerance: q.Tolerance, Unit: q.Unit}
+			}
+			question.normalizeAnswers()
+		}
+		if req.SourceMaterial != "" {
+			question.Source = newSourceSpan(req.SourceMaterial, q.SourceQuote)
+			if chunk != nil && question.Source.Found() {
+				question.Source.Start += chunk.Start
+				question.Source.End += chunk.Start
+			}
+		}
+		if q.Image >= 1 && q.Image <= len(req.SourceImages) {
+			image := req.SourceImages[q.Image-1]
+			question.Image = &QuestionImage{Name: image.Name, Alt: image.Alt}
+		}
+		if chunk != nil {
+			question.chunk = chunk.Index
+		}
+		if subtopic != nil {
+			question.Subtopic = subtopic.Name
+		}
 		questions = append(questions, question)
 	}
-
-	VerboseLog("Generated %d questions", len(questions))
 	return questions, nil
 }
 
-func (qm *QuestionMaker) buildPrompt(req GenerationRequest, batchSize int) string {
-	var sb strings.Builder
+func (qm *QuestionMaker) buildPrompt(req GenerationRequest, batchSize int, excerpt bool, subtopic *Subtopic) (string, error) {
+	data := PromptData{
+		Topic:          req.Topic,
+		Difficulty:     req.Difficulty,
+		SourceMaterial: req.SourceMaterial,
+		SourceExcerpt:  excerpt,
+		BatchSize:      batchSize,
+		Subtopic:       subtopic,
+		QuestionTypes:  req.QuestionTypes,
+		NumOptions:     numOptions(req),
+		SourceImages:   req.SourceImages,
+	}
 
-	// If this is the first request, provide the full context
-	if len(qm.messages) == 1 { // Only system message
-		sb.WriteString(fmt.Sprintf("Generate %d multiple choice questions about: %s\n\n", batchSize, req.Topic))
+	// For subsequent requests, just ask for more unique questions
+	if len(qm.messages) > 1 {
+		return RenderPrompt(qm.config.FollowUpPrompt, data)
+	}
 
-		if req.SourceMaterial != "" {
-			sb.WriteString("Use the following source material as reference:\n")
-			sb.WriteString(req.SourceMaterial)
-			sb.WriteString("\n\n")
+	// If this is the first request, provide the full context, plus as many of
+	// the questions from earlier conversations as fit in the stem limit
+	data.PreviousQuestions = qm.recentStems(qm.history.StemTokens)
+	return RenderPrompt(qm.config.Prompt, data)
+}
+
+// identityArrangement returns the arrangement 0, 1, ..., n-1, which is the
+// answer to an arranged question whose options are listed in the correct
+// order or alongside their matches
+func identityArrangement(n int) []int {
+	arrangement := make([]int, n)
+	for i := range arrangement {
+		arrangement[i] = i
+	}
+	return arrangement
+}
+
+// optionsDescription describes what goes in the options of each question type
+// the request asks for, so the schema doesn't assume every question is single choice
+func optionsDescription(req GenerationRequest) string {
+	if len(req.QuestionTypes) == 0 {
+		return fmt.Sprintf("Array of %d answer options", numOptions(req))
+	}
+	var parts []string
+	for _, questionType := range req.QuestionTypes {
+		switch questionType {
+		case QuestionSingleChoice, QuestionMultiSelect:
+			parts = append(parts, fmt.Sprintf("%s: %d answer options", questionType, numOptions(req)))
+		case QuestionTrueFalse:
+			parts = append(parts, `true_false: ["True", "False"]`)
+		// Typed answers go in their own properties
+		case QuestionShortAnswer:
+			parts = append(parts, "short_answer: empty, with the answers in accepted_answers")
+		case QuestionNumeric:
+			parts = append(parts, "numeric: empty, with the answer in numeric_answer and how far off it may be in tolerance")
+		// Arranged questions list their options in the correct order or pairing, to be shuffled later
+		case QuestionOrdering:
+			parts = append(parts, "ordering: the items in the correct order")
+		case QuestionMatching:
+			parts = append(parts, "matching: the terms to match")
 		}
+	}
+	return "Array of options, by question type; " + strings.Join(parts, "; ")
+}
 
-		if req.Difficulty != "" {
-			sb.WriteString(fmt.Sprintf("Difficulty level: %s\n\n", req.Difficulty))
+// correctAnswerDescription describes correct_answer for the question types the request asks for
+func correctAnswerDescription(req GenerationRequest) string {
+	description := "0-based index of the correct answer"
+	for _, questionType := range req.QuestionTypes {
+		switch questionType {
+		case QuestionMultiSelect:
+			description += "; for multi_select the first correct option"
+		case QuestionShortAnswer:
+			description += "; omit for short_answer, whose answers go in accepted_answers"
+		case QuestionNumeric:
+			description += "; omit for numeric, whose answer goes in numeric_answer"
 		}
+	}
+	return description
+}
 
-		sb.WriteString("Requirements:\n")
-		sb.WriteString("- Each question must have exactly 4 multiple choice options\n")
-		sb.WriteString("- The correct answer should be non-obvious but clearly correct\n")
-		sb.WriteString("- Incorrect options should be plausible but clearly wrong\n")
-		sb.WriteString("- Questions should test understanding, not just memorization\n")
-		sb.WriteString("- Avoid questions where the answer is given away in the question text\n")
-		sb.WriteString("- Provide a brief explanation for why the correct answer is right\n")
-		sb.WriteString("- Use the submit_questions tool to return your questions\n")
-	} else {
-		// For subsequent requests, just ask for more unique questions
-		sb.WriteString(fmt.Sprintf("Thanks! Can I have %d more unique questions please? Make sure they are different from the ones you've already generated.", batchSize))
+// numOptions returns how many options the request's questions should have
+func numOptions(req GenerationRequest) int {
+	if req.NumOptions == 0 {
+		return DefaultNumOptions
 	}
+	return min(max(req.NumOptions, MinOptions), MaxOptions)
+}
 
-	return sb.String()
+// estimateTokens roughly sizes a conversation at four characters per token
+func estimateTokens(messages []ChatMessage) int {
+	chars := 0
+	for _, msg := range messages {
+		chars += len(msg.Content)
+		for _, toolCall := range msg.ToolCalls {
+			chars += len(toolCall.Arguments)
+		}
+	}
+	return chars / 4
 }
 
 func generateQuestionID() string {
@@ -0,0 +1,230 @@
+package quizgenerator
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// makerCall is what the maker sent in one call, copied as it was sent since
+// the maker reuses its conversation's backing array
+type makerCall struct {
+	messages int
+	prompt   string
+}
+
+// recordMakerCalls answers the maker with placeholder questions, first
+// passing each call to fail, and returns the calls made so far
+func recordMakerCalls(env *testEnv, fail func(req ChatRequest) error) func() []makerCall {
+	var calls []makerCall
+	env.provider.Handle("submit_questions", func(req ChatRequest) (string, error) {
+		calls = append(calls, makerCall{messages: len(req.Messages), prompt: req.Messages[len(req.Messages)-1].Content})
+		if fail != nil {
+			if err := fail(req); err != nil {
+				return "", err
+			}
+		}
+		return fakeDefaultArguments(req, len(calls))
+	})
+	return func() []makerCall { return calls }
+}
+
+func TestQuestionMakerStartsOverPastHistoryLimit(t *testing.T) {
+	env := newTestEnv(t)
+	calls := recordMakerCalls(env, nil)
+	maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{MaxTokens: 600})
+
+	var last *Question
+	for range 6 {
+		questions, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("GenerateQuestions failed: %v", err)
+		}
+		last = questions[len(questions)-1]
+	}
+
+	restarted := false
+	for i, call := range calls()[1:] {
+		if call.messages > 2 {
+			continue
+		}
+		restarted = true
+		// The new conversation lists questions from the old one
+		if !strings.Contains(call.prompt, "already been written") || !strings.Contains(call.prompt, "- Fake question") {
+			t.Errorf("call %d starts a new conversation without listing earlier questions:\n%s", i+2, call.prompt)
+		}
+	}
+	if !restarted {
+		t.Fatalf("maker kept one conversation of %d messages", calls()[len(calls())-1].messages)
+	}
+
+	// Starting over again lists the newest questions
+	maker.messages = maker.messages[:1]
+	if _, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil); err != nil {
+		t.Fatalf("GenerateQuestions failed: %v", err)
+	}
+	if prompt := calls()[len(calls())-1].prompt; !strings.Contains(prompt, "- "+last.Text) {
+		t.Errorf("new conversation doesn't list the latest question %q:\n%s", last.Text, prompt)
+	}
+}
+
+func TestQuestionMakerListsAcceptedOnly(t *testing.T) {
+	env := newTestEnv(t)
+	calls := recordMakerCalls(env, nil)
+	maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{AcceptedOnly: true})
+
+	questions, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateQuestions failed: %v", err)
+	}
+	maker.RecordAccepted(questions[1])
+
+	maker.messages = maker.messages[:1]
+	if _, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil); err != nil {
+		t.Fatalf("GenerateQuestions failed: %v", err)
+	}
+	prompt := calls()[1].prompt
+	if !strings.Contains(prompt, "- "+questions[1].Text) || strings.Contains(prompt, questions[0].Text) || strings.Contains(prompt, questions[2].Text) {
+		t.Errorf("new conversation should list only the accepted question %q:\n%s", questions[1].Text, prompt)
+	}
+}
+
+func TestQuestionMakerFreshOnRetry(t *testing.T) {
+	contextLength := &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Code: "context_length_exceeded"}
+	for _, fresh := range []bool{false, true} {
+		env := newTestEnv(t)
+		calls := recordMakerCalls(env, func(req ChatRequest) error {
+			if len(req.Messages) > 2 {
+				return contextLength
+			}
+			return nil
+		})
+		maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{FreshOnRetry: fresh})
+
+		if _, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil); err != nil {
+			t.Fatalf("GenerateQuestions failed: %v", err)
+		}
+		history := len(maker.messages)
+		_, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes"}, 3, nil, nil, nil)
+		if !fresh {
+			if err == nil {
+				t.Fatalf("second batch succeeded without a fresh retry")
+			}
+			// The failed batch isn't left in the conversation
+			if len(maker.messages) != history {
+				t.Errorf("conversation has %d messages after a failed batch, want the first batch's %d", len(maker.messages), history)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GenerateQuestions with fresh retry failed: %v", err)
+		}
+		if got := calls(); len(got) != 3 || got[2].messages != 2 || !strings.Contains(got[2].prompt, "already been written") {
+			t.Errorf("maker calls = %+v, want the batch retried in a new conversation listing earlier questions", got)
+		}
+	}
+}
+
+func TestQuestionMakerDoesNotCountSourceTowardsHistory(t *testing.T) {
+	env := newTestEnv(t)
+	calls := recordMakerCalls(env, nil)
+	maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{StemTokens: 12})
+	// Source material alone well over the history limit
+	req := GenerationRequest{Topic: "Volcanoes", SourceMaterial: strings.Repeat("Magma rises through vents in the crust.\n", 1000)}
+
+	var last *Question
+	for range 3 {
+		questions, err := maker.GenerateQuestions(context.Background(), req, 3, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("GenerateQuestions failed: %v", err)
+		}
+		last = questions[len(questions)-1]
+	}
+	for i, call := range calls()[1:] {
+		if call.messages <= 2 {
+			t.Errorf("call %d started a new conversation because of the source material", i+2)
+		}
+	}
+
+	// A new conversation lists only as many of the latest stems as fit in StemTokens
+	maker.messages = maker.messages[:1]
+	if _, err := maker.GenerateQuestions(context.Background(), req, 3, nil, nil, nil); err != nil {
+		t.Fatalf("GenerateQuestions failed: %v", err)
+	}
+	prompt := calls()[len(calls())-1].prompt
+	if listed := strings.Count(prompt, "- Fake question"); listed == 0 || listed > 3 || !strings.Contains(prompt, "- "+last.Text) {
+		t.Errorf("new conversation lists %d questions, want the latest few including %q:\n%s", listed, last.Text, prompt[len(prompt)-500:])
+	}
+}
+
+// questionProperty returns the schema the maker gave a property of its questions
+func questionProperty(t *testing.T, req ChatRequest, name string) map[string]interface{} {
+	t.Helper()
+	questions := req.Tool.Parameters["properties"].(map[string]interface{})["questions"].(map[string]interface{})
+	property, ok := questions["items"].(map[string]interface{})["properties"].(map[string]interface{})[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("maker schema has no %s property", name)
+	}
+	return property
+}
+
+func TestQuestionMakerDescribesRequestedTypes(t *testing.T) {
+	tests := []struct {
+		types   []QuestionType
+		options []string
+	}{
+		{nil, []string{"Array of 4 answer options"}},
+		{[]QuestionType{QuestionTrueFalse, QuestionOrdering}, []string{`true_false: ["True", "False"]`, "ordering: the items in the correct order"}},
+		{[]QuestionType{QuestionMultiSelect, QuestionMatching}, []string{"multi_select: 4 answer options", "matching: the terms to match"}},
+	}
+	for _, tt := range tests {
+		env := newTestEnv(t)
+		maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{})
+		if _, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes", QuestionTypes: tt.types}, 2, nil, nil, nil); err != nil {
+			t.Fatalf("GenerateQuestions failed: %v", err)
+		}
+		req := env.provider.Requests()[0]
+
+		description := questionProperty(t, req, "options")["description"].(string)
+		for _, want := range tt.options {
+			if !strings.Contains(description, want) {
+				t.Errorf("types %v: options description %q doesn't say %q", tt.types, description, want)
+			}
+		}
+		if len(tt.types) > 0 && strings.Contains(description, string(QuestionSingleChoice)) {
+			t.Errorf("types %v: options description %q describes a type that wasn't asked for", tt.types, description)
+		}
+		for _, message := range req.Messages {
+			if strings.Contains(message.Content, "multiple choice") {
+				t.Errorf("types %v: maker was asked for multiple choice questions:\n%s", tt.types, message.Content)
+			}
+		}
+	}
+}
+
+func TestQuestionMakerDescribesTypedAnswers(t *testing.T) {
+	env := newTestEnv(t)
+	maker := NewQuestionMaker(env.provider, env.cfg.ResolveStage(env.cfg.Maker, DefaultModel), HistoryConfig{})
+	if _, err := maker.GenerateQuestions(context.Background(), GenerationRequest{Topic: "Volcanoes", QuestionTypes: []QuestionType{QuestionShortAnswer, QuestionNumeric}}, 2, nil, nil, nil); err != nil {
+		t.Fatalf("GenerateQuestions failed: %v", err)
+	}
+	req := env.provider.Requests()[0]
+
+	// Typed answers have no options, and say where their answers go instead
+	for property, wants := range map[string][]string{
+		"options":          {"short_answer: empty, with the answers in accepted_answers", "numeric: empty, with the answer in numeric_answer"},
+		"correct_answer":   {"omit for short_answer", "omit for numeric"},
+		"accepted_answers": {"other spellings"},
+		"tolerance":        {"0 for exact answers"},
+	} {
+		description := questionProperty(t, req, property)["description"].(string)
+		for _, want := range wants {
+			if !strings.Contains(description, want) {
+				t.Errorf("%s description %q doesn't say %q", property, description, want)
+			}
+		}
+	}
+}
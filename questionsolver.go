@@ -0,0 +1,257 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+// What to do with a question whose blind solver picked a different answer
+const (
+	MismatchReject = "reject" // Reject the question
+	MismatchFlag   = "flag"   // Accept it but mark it for review
+)
+
+// VerificationConfig configures the blind solver stage run after the checker accepts a question
+type VerificationConfig struct {
+	Enabled    bool   `json:"enabled"`
+	OnMismatch string `json:"on_mismatch,omitempty"` // MismatchReject or MismatchFlag; empty means reject
+	// Mismatches the solver is less confident about than this are flagged instead of rejected
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+}
+
+// SolverResult is the blind solver's answer to a question
+type SolverResult struct {
+	Answer     string  `json:"answer"`     // Text of the options the solver chose, or its typed answer
+	Confidence float64 `json:"confidence"` // Solver's confidence in its answer, from 0 to 1
+	Agreed     bool    `json:"agreed"`     // Whether the solver chose the marked correct answer
+	Reasoning  string  `json:"reasoning,omitempty"`
+}
+
+// QuestionSolver answers questions without seeing the answer key to verify them
+type QuestionSolver struct {
+	provider LLMProvider
+	config   StageConfig
+}
+
+// NewQuestionSolver creates a new question solver using the given provider and stage config
+func NewQuestionSolver(provider LLMProvider, config StageConfig) *QuestionSolver {
+	return &QuestionSolver{
+		provider: provider,
+		config:   config,
+	}
+}
+
+// Solve shows the question with shuffled options and no answer key to the model and compares its answer
+func (qs *QuestionSolver) Solve(ctx context.Context, question *Question, logger *LLMLogger) (*SolverResult, error) {
+	// Shuffle so the solver can't lean on where the maker tends to put the right
+	// answer. Matching questions keep their options in place and shuffle their
+	// matches, so the solver's pairs line up with the answer key.
+	order := rand.Perm(len(question.Options))
+	if question.Kind() == QuestionMatching {
+		order = identityArrangement(len(question.Options))
+	}
+	matchOrder := rand.Perm(len(question.Matches))
+	blind := &Question{
+		ID:            question.ID,
+		Type:          question.Type,
+		Text:          question.Text,
+		Topic:         question.Topic,
+		Options:       make([]string, len(order)),
+		CorrectAnswer: -1,
+	}
+	for i, original := range order {
+		blind.Options[i] = question.Options[original]
+	}
+	for _, original := range matchOrder {
+		blind.Matches = append(blind.Matches, question.Matches[original])
+	}
+	// The solver is told what the image shows, as players would see it
+	if question.Image != nil {
+		blind.Image = &QuestionImage{Name: question.Image.Name, Alt: question.Image.Alt}
+	}
+	// A numeric answer's unit tells the solver what to answer in without giving the number away
+	if question.Numeric != nil {
+		blind.Numeric = &NumericAnswer{Unit: question.Numeric.Unit}
+	}
+
+	prompt, err := RenderPrompt(qs.config.Prompt, PromptData{
+		Topic:    question.Topic,
+		Question: blind,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.LogLLMRequest("QuestionSolver", prompt)
+	}
+
+	// Multi-select questions are answered with every option the solver thinks
+	// is correct, arranged ones with the whole arrangement, and free-response
+	// ones by typing
+	multi := question.Kind() == QuestionMultiSelect
+	required := []string{"answer", "confidence"}
+	if multi || question.Arranged() {
+		required = []string{"answers", "confidence"}
+	} else if question.FreeResponse() {
+		required = []string{"response", "confidence"}
+	}
+
+	resp, err := qs.provider.Chat(ctx, ChatRequest{
+		Stage:       "QuestionSolver",
+		Model:       qs.config.Model,
+		Temperature: qs.config.Temperature,
+		MaxTokens:   qs.config.MaxTokens,
+		Messages: []ChatMessage{
+			{
+				Role:    RoleSystem,
+				Content: qs.config.SystemPrompt,
+			},
+			{
+				Role:    RoleUser,
+				Content: prompt,
+			},
+		},
+		Tool: ToolDefinition{
+			Name:        "answer_question",
+			Description: "Answer a quiz question",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"reasoning": map[string]interface{}{
+						"type":        "string",
+						"description": "Brief reasoning behind the answer",
+					},
+					"answer": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of the chosen option, starting from 1",
+					},
+					"answers": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "integer",
+						},
+						"description": "Multi-select questions: numbers of every correct option; ordering questions: numbers of every option in the correct order; matching questions: number of the match for each option in turn. Numbers start from 1",
+					},
+					"response": map[string]interface{}{
+						"type":        "string",
+						"description": "Short-answer and numeric questions only: the typed answer",
+					},
+					"confidence": map[string]interface{}{
+						"type":        "number",
+						"description": "Confidence that the answer is correct, from 0 (guessing) to 1 (certain)",
+					},
+				},
+				"required": required,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to solve question: %w", err)
+	}
+
+	if logger != nil {
+		responseText := ""
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
+		}
+		logger.LogLLMResponse("QuestionSolver", responseText)
+	}
+
+	if len(resp.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no tool calls in response")
+	}
+
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "answer_question" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
+	}
+
+	var toolArgs struct {
+		Reasoning  string  `json:"reasoning"`
+		Answer     int     `json:"answer"`
+		Answers    []int   `json:"answers"`
+		Response   string  `json:"response"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
+		return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
+	}
+
+	// A typed answer agrees if it would be graded correct, without asking the LLM grader
+	if question.FreeResponse() {
+		return qs.result(question, &SolverResult{
+			Answer:     toolArgs.Response,
+			Confidence: min(max(toolArgs.Confidence, 0), 1),
+			Agreed:     question.GradeResponse(toolArgs.Response).Correct,
+			Reasoning:  toolArgs.Reasoning,
+		}, logger), nil
+	}
+	if question.Arranged() {
+		return qs.arrangedResult(question, order, matchOrder, toolArgs.Answers, toolArgs.Confidence, toolArgs.Reasoning, logger)
+	}
+	if !multi || len(toolArgs.Answers) == 0 {
+		toolArgs.Answers = []int{toolArgs.Answer}
+	}
+
+	// Map the solver's choices back to the original option order
+	chosen := &Question{Type: question.Type, Options: question.Options}
+	var texts []string
+	for _, answer := range toolArgs.Answers {
+		if answer < 1 || answer > len(order) {
+			return nil, fmt.Errorf("solver chose option %d of %d", answer, len(order))
+		}
+		chosen.CorrectAnswers = append(chosen.CorrectAnswers, order[answer-1])
+		texts = append(texts, blind.Options[answer-1])
+	}
+	chosen.CorrectAnswer = chosen.CorrectAnswers[0]
+	chosen.normalizeAnswers()
+
+	result := &SolverResult{
+		Answer:     strings.Join(texts, "; "),
+		Confidence: min(max(toolArgs.Confidence, 0), 1),
+		Agreed:     slices.Equal(chosen.CorrectOptions(), question.CorrectOptions()),
+		Reasoning:  toolArgs.Reasoning,
+	}
+	return qs.result(question, result, logger), nil
+}
+
+// arrangedResult maps the solver's arrangement of the blind question's
+// options or matches back to the original order and compares it with the
+// answer key; only the whole arrangement being right counts as agreeing
+func (qs *QuestionSolver) arrangedResult(question *Question, order, matchOrder, answers []int, confidence float64, reasoning string, logger *LLMLogger) (*SolverResult, error) {
+	// Ordering answers pick from the options and matching ones from the matches
+	shuffled := order
+	if question.Kind() == QuestionMatching {
+		shuffled = matchOrder
+	}
+	var arrangement []int
+	for _, answer := range answers {
+		if answer < 1 || answer > len(shuffled) {
+			return nil, fmt.Errorf("solver chose item %d of %d", answer, len(shuffled))
+		}
+		arrangement = append(arrangement, shuffled[answer-1])
+	}
+
+	result := &SolverResult{
+		Answer:     question.ArrangementText(arrangement),
+		Confidence: min(max(confidence, 0), 1),
+		Agreed:     slices.Equal(arrangement, question.CorrectAnswers),
+		Reasoning:  reasoning,
+	}
+	return qs.result(question, result, logger), nil
+}
+
+// result logs the solver's answer and returns it
+func (qs *QuestionSolver) result(question *Question, result *SolverResult, logger *LLMLogger) *SolverResult {
+	if logger != nil {
+		logger.Logf("Question %s: SOLVED %q (confidence %.2f, agreed %t) - %s\n",
+			question.ID, result.Answer, result.Confidence, result.Agreed, result.Reasoning)
+	}
+	VerboseLog("Question %s: solver chose %q (confidence %.2f, agreed %t)", question.ID, result.Answer, result.Confidence, result.Agreed)
+	return result
+}
@@ -0,0 +1,195 @@
+package quizgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSolverMapsAnswersBack(t *testing.T) {
+	singleChoice := &Question{Text: "Capital of France?", Options: []string{"Lyon", "Paris", "Nice", "Lille"}, CorrectAnswer: 1}
+	multiSelect := &Question{Type: QuestionMultiSelect, Text: "Which are prime?", Options: []string{"2", "4", "5", "9"}, CorrectAnswers: []int{0, 2}}
+	ordering := &Question{Type: QuestionOrdering, Text: "Order by size", Options: []string{"Moon", "Earth", "Sun", "Ceres"}, CorrectAnswers: []int{3, 0, 1, 2}}
+	matching := &Question{Type: QuestionMatching, Text: "Match symbols", Options: []string{"Fe", "Au", "Ag"}, Matches: []string{"silver", "iron", "gold"}, CorrectAnswers: []int{1, 2, 0}}
+
+	tests := []struct {
+		name     string
+		question *Question
+		texts    []string
+		answer   string
+		agreed   bool
+	}{
+		{"single choice right", singleChoice, []string{"Paris"}, "Paris", true},
+		{"single choice wrong", singleChoice, []string{"Nice"}, "Nice", false},
+		{"multi-select right", multiSelect, []string{"5", "2"}, "5; 2", true},
+		{"multi-select partly right", multiSelect, []string{"2"}, "2", false},
+		{"multi-select with a wrong option", multiSelect, []string{"2", "5", "9"}, "2; 5; 9", false},
+		{"ordering right", ordering, []string{"Ceres", "Moon", "Earth", "Sun"}, "Ceres → Moon → Earth → Sun", true},
+		{"ordering wrong", ordering, []string{"Moon", "Ceres", "Earth", "Sun"}, "Moon → Ceres → Earth → Sun", false},
+		{"matching right", matching, []string{"iron", "gold", "silver"}, "Fe: iron; Au: gold; Ag: silver", true},
+		{"matching wrong", matching, []string{"gold", "iron", "silver"}, "Fe: gold; Au: iron; Ag: silver", false},
+	}
+	for _, tt := range tests {
+		solver := newTestEnv(t, withSolverAnswers(tt.texts...)).solver()
+		// The options are shuffled differently every time, so solve each question a few times
+		for range 10 {
+			result, err := solver.Solve(context.Background(), tt.question, nil)
+			if err != nil {
+				t.Fatalf("%s: Solve failed: %v", tt.name, err)
+			}
+			if result.Answer != tt.answer || result.Agreed != tt.agreed {
+				t.Fatalf("%s: solver result %q (agreed %t), want %q (agreed %t)", tt.name, result.Answer, result.Agreed, tt.answer, tt.agreed)
+			}
+			if result.Confidence != 0.8 {
+				t.Fatalf("%s: confidence %v, want 0.8", tt.name, result.Confidence)
+			}
+		}
+	}
+}
+
+func TestSolverGradesTypedAnswers(t *testing.T) {
+	shortAnswer := &Question{Type: QuestionShortAnswer, Text: "Capital of France?", AcceptedAnswers: []string{"Paris"}}
+	numeric := &Question{Type: QuestionNumeric, Text: "How high is Everest?", Numeric: &NumericAnswer{Value: 8849, Tolerance: 10, Unit: "m"}}
+
+	tests := []struct {
+		name     string
+		question *Question
+		response string
+		agreed   bool
+	}{
+		{"short answer right", shortAnswer, "paris", true},
+		{"short answer wrong", shortAnswer, "Lyon", false},
+		{"numeric in another unit", numeric, "8.85 km", true},
+		{"numeric out of tolerance", numeric, "8000 m", false},
+	}
+	for _, tt := range tests {
+		env := newTestEnv(t)
+		env.provider.Enqueue("answer_question", mustMarshal(map[string]interface{}{"response": tt.response, "confidence": 0.7}))
+		result, err := env.solver().Solve(context.Background(), tt.question, nil)
+		if err != nil {
+			t.Fatalf("%s: Solve failed: %v", tt.name, err)
+		}
+		if result.Answer != tt.response || result.Agreed != tt.agreed {
+			t.Errorf("%s: solver result %q (agreed %t), want %q (agreed %t)", tt.name, result.Answer, result.Agreed, tt.response, tt.agreed)
+		}
+	}
+
+	// The solver is told the unit to answer in, but not the answer
+	env := newTestEnv(t)
+	if _, err := env.solver().Solve(context.Background(), numeric, nil); err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if prompt := env.provider.Requests()[0].Messages[1].Content; strings.Contains(prompt, "8849") || !strings.Contains(prompt, "as a number in m") {
+		t.Errorf("solver prompt should ask for meters without giving the answer away:\n%s", prompt)
+	}
+}
+
+func TestSolverClampsConfidence(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Enqueue("answer_question", mustMarshal(map[string]interface{}{"answer": 1, "confidence": 1.5}))
+	question := &Question{Text: "Pick one", Options: []string{"A", "B", "C", "D"}}
+	result, err := env.solver().Solve(context.Background(), question, nil)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+	if result.Confidence != 1 {
+		t.Errorf("confidence %v, want it clamped to 1", result.Confidence)
+	}
+}
+
+func TestSolverRejectsUnknownOption(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Enqueue("answer_question", mustMarshal(map[string]interface{}{"answer": 5, "confidence": 0.5}))
+	question := &Question{Text: "Pick one", Options: []string{"A", "B", "C", "D"}}
+	if _, err := env.solver().Solve(context.Background(), question, nil); err == nil {
+		t.Errorf("Solve accepted option 5 of 4")
+	}
+}
+
+func TestGenerateQuizVerifiesAnswers(t *testing.T) {
+	tests := map[string]struct {
+		verification VerificationConfig
+		rejected     int
+		flagged      int
+	}{
+		"reject":             {VerificationConfig{Enabled: true}, 1, 0},
+		"flag":               {VerificationConfig{Enabled: true, OnMismatch: MismatchFlag}, 0, 1},
+		"unsure of mismatch": {VerificationConfig{Enabled: true, MinConfidence: 0.95}, 0, 1},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			env := newTestEnv(t, withConfig(func(cfg *Config) {
+				cfg.Verification = tt.verification
+				cfg.Workers = 1
+			}))
+			// The solver gets the first question wrong and the rest right
+			solved := 0
+			env.provider.Handle("answer_question", func(req ChatRequest) (string, error) {
+				solved++
+				if solved == 1 {
+					options, _ := solverNumbers(req.Messages[len(req.Messages)-1].Content)
+					return mustMarshal(map[string]interface{}{"answer": options["Wrong A"], "confidence": 0.9}), nil
+				}
+				return fakeDefaultArguments(req, 0)
+			})
+			generator := env.generator(t)
+			var events []GenerationEvent
+			generator.SetEventHandler(func(event GenerationEvent) {
+				events = append(events, event)
+			})
+
+			quiz, err := generator.GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+			if err != nil {
+				t.Fatalf("GenerateQuiz failed: %v", err)
+			}
+			stats := generator.Result().Stats
+			if stats.Rejected != tt.rejected || stats.Flagged != tt.flagged {
+				t.Errorf("stats = %+v, want %d rejected and %d flagged", stats, tt.rejected, tt.flagged)
+			}
+
+			mismatches := 0
+			for _, question := range quiz.Questions {
+				if question.Verification == nil {
+					t.Fatalf("question %q wasn't verified", question.Text)
+				}
+				if !question.Verification.Agreed {
+					mismatches++
+				}
+			}
+			flaggedEvents := 0
+			for _, event := range events {
+				if event.Type == EventQuestionFlagged {
+					flaggedEvents++
+				}
+			}
+			if mismatches != tt.flagged || flaggedEvents != tt.flagged {
+				t.Errorf("quiz has %d questions the solver got wrong and %d flagged events, want %d", mismatches, flaggedEvents, tt.flagged)
+			}
+		})
+	}
+}
+
+func TestDBGenerateQuizStoresVerification(t *testing.T) {
+	env := newTestEnv(t,
+		withConfig(func(cfg *Config) {
+			cfg.Verification = VerificationConfig{Enabled: true, OnMismatch: MismatchFlag}
+		}),
+		withSolverAnswers("Wrong B"),
+		withQuiz("quiz1", "Volcanoes", 2),
+	)
+
+	env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 2})
+
+	contested, err := env.db.GetContestedQuestions(0)
+	if err != nil {
+		t.Fatalf("GetContestedQuestions failed: %v", err)
+	}
+	if len(contested) != 2 {
+		t.Fatalf("%d contested questions, want both questions the solver got wrong", len(contested))
+	}
+	for _, question := range contested {
+		if question.SolverAnswer != "Wrong B" || question.SolverConfidence != 0.8 || question.SolverAgreed {
+			t.Errorf("question %d stored solver answer %q (confidence %.2f, agreed %t)", question.QuestionNum, question.SolverAnswer, question.SolverConfidence, question.SolverAgreed)
+		}
+	}
+}
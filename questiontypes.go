@@ -0,0 +1,241 @@
+package quizgenerator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// QuestionType says how a question is answered
+type QuestionType string
+
+const (
+	QuestionSingleChoice QuestionType = "single_choice" // One correct option out of MinOptions to MaxOptions
+	QuestionTrueFalse    QuestionType = "true_false"    // A statement judged with the options "True" and "False"
+	QuestionMultiSelect  QuestionType = "multi_select"  // Every correct option must be picked, with partial credit
+	QuestionShortAnswer  QuestionType = "short_answer"  // A typed word or phrase matched against accepted answers
+	QuestionNumeric      QuestionType = "numeric"       // A typed number, accepted within a tolerance
+	QuestionOrdering     QuestionType = "ordering"      // Options put in order, with credit for each one in the right place
+	QuestionMatching     QuestionType = "matching"      // Each option paired with one of Matches, with credit for each right pair
+)
+
+// QuestionTypes lists every supported question type
+var QuestionTypes = []QuestionType{QuestionSingleChoice, QuestionTrueFalse, QuestionMultiSelect, QuestionShortAnswer, QuestionNumeric, QuestionOrdering, QuestionMatching}
+
+// Limits on the number of options of single-choice and multi-select
+// questions, which also bound the items of ordering and matching questions
+const (
+	MinOptions        = 2
+	MaxOptions        = 6
+	DefaultNumOptions = 4
+	MinOrderItems     = 3 // Fewer items make an ordering question a coin toss
+)
+
+// ParseQuestionTypes parses a comma-separated list of question types; an empty list means single choice only
+func ParseQuestionTypes(list string) ([]QuestionType, error) {
+	var types []QuestionType
+	for _, name := range strings.Split(list, ",") {
+		qt := QuestionType(strings.ToLower(strings.TrimSpace(name)))
+		if qt == "" || slices.Contains(types, qt) {
+			continue
+		}
+		if !slices.Contains(QuestionTypes, qt) {
+			return nil, fmt.Errorf("unknown question type %q", qt)
+		}
+		types = append(types, qt)
+	}
+	return types, nil
+}
+
+// Kind returns the question's type, treating an empty type as single choice
+func (q *Question) Kind() QuestionType {
+	if q.Type == "" {
+		return QuestionSingleChoice
+	}
+	return q.Type
+}
+
+// FreeResponse reports whether the question is answered by typing rather than choosing options
+func (q *Question) FreeResponse() bool {
+	return q.Kind() == QuestionShortAnswer || q.Kind() == QuestionNumeric
+}
+
+// Arranged reports whether the question is answered by arranging its options
+// into an order or into pairs rather than picking some of them
+func (q *Question) Arranged() bool {
+	return q.Kind() == QuestionOrdering || q.Kind() == QuestionMatching
+}
+
+// CorrectOptions returns the indexes of the options that are correct; free-response
+// and arranged questions have none, as their answer is CorrectAnswers as a whole
+func (q *Question) CorrectOptions() []int {
+	if q.FreeResponse() || q.Arranged() {
+		return nil
+	}
+	if q.Kind() == QuestionMultiSelect {
+		return q.CorrectAnswers
+	}
+	return []int{q.CorrectAnswer}
+}
+
+// IsCorrectOption reports whether the option at index is a correct one
+func (q *Question) IsCorrectOption(index int) bool {
+	return slices.Contains(q.CorrectOptions(), index)
+}
+
+// CorrectText returns the text of the correct options, separated by semicolons,
+// the typed answer a free-response question expects, or the correct order or
+// pairs of an arranged question
+func (q *Question) CorrectText() string {
+	switch q.Kind() {
+	case QuestionOrdering, QuestionMatching:
+		return q.ArrangementText(q.CorrectAnswers)
+	case QuestionShortAnswer:
+		if len(q.AcceptedAnswers) > 0 {
+			return q.AcceptedAnswers[0]
+		}
+		return ""
+	case QuestionNumeric:
+		if q.Numeric != nil {
+			return q.Numeric.String()
+		}
+		return ""
+	}
+	var texts []string
+	for _, index := range q.CorrectOptions() {
+		if index >= 0 && index < len(q.Options) {
+			texts = append(texts, q.Options[index])
+		}
+	}
+	return strings.Join(texts, "; ")
+}
+
+// ArrangementText describes an answer to an ordering question as its options
+// in the given order, e.g. "B → A → C", or to a matching question as the
+// match given for each option, e.g. "Fe: iron; Au: gold"
+func (q *Question) ArrangementText(arrangement []int) string {
+	separator := "; "
+	if q.Kind() == QuestionOrdering {
+		separator = " → "
+	}
+	return strings.Join(q.ArrangementParts(arrangement), separator)
+}
+
+// ArrangementParts lists the parts of ArrangementText one by one: the options
+// of an ordering question in the given order, or each option of a matching
+// question with its match. Indexes out of range are skipped, so a malformed
+// answer key can still be shown.
+func (q *Question) ArrangementParts(arrangement []int) []string {
+	var parts []string
+	switch q.Kind() {
+	case QuestionOrdering:
+		for _, index := range arrangement {
+			if index >= 0 && index < len(q.Options) {
+				parts = append(parts, q.Options[index])
+			}
+		}
+	case QuestionMatching:
+		for i, index := range arrangement {
+			if i < len(q.Options) && index >= 0 && index < len(q.Matches) {
+				parts = append(parts, q.Options[i]+": "+q.Matches[index])
+			}
+		}
+	}
+	return parts
+}
+
+// Score grades the selected options from 0 to 1. A single answer scores 1 when
+// it is correct. Multi-select earns an equal share for each correct option
+// picked and loses the same share for each wrong one, never going below 0.
+// Ordering questions take the options in the order given and matching ones
+// the index of the match given for each option in turn; both earn an equal
+// share for each option in its correct place or pair.
+// Free-response questions are graded with GradeResponse instead.
+func (q *Question) Score(selected []int) float64 {
+	if q.Arranged() {
+		if len(q.CorrectAnswers) == 0 {
+			return 0
+		}
+		hits := 0
+		for i, want := range q.CorrectAnswers {
+			if i < len(selected) && selected[i] == want {
+				hits++
+			}
+		}
+		return float64(hits) / float64(len(q.CorrectAnswers))
+	}
+
+	correct := q.CorrectOptions()
+	if len(correct) == 0 {
+		return 0
+	}
+	if q.Kind() != QuestionMultiSelect {
+		if len(selected) == 1 && selected[0] == q.CorrectAnswer {
+			return 1
+		}
+		return 0
+	}
+
+	hits := 0
+	seen := make(map[int]bool)
+	for _, index := range selected {
+		if seen[index] || index < 0 || index >= len(q.Options) {
+			continue
+		}
+		seen[index] = true
+		if slices.Contains(correct, index) {
+			hits++
+		} else {
+			hits--
+		}
+	}
+	return max(float64(hits), 0) / float64(len(correct))
+}
+
+// normalizeAnswers sorts and dedupes a multi-select question's correct
+// answers and points CorrectAnswer at the first, so code that only looks at
+// one correct option still sees a right one. Free-response questions lose any
+// options and keep only their distinct, non-blank accepted answers. Only
+// matching questions keep their matches, and arranged questions keep their
+// correct answers as they are, since their order is the answer.
+func (q *Question) normalizeAnswers() {
+	if q.Kind() != QuestionMatching {
+		q.Matches = nil
+	}
+	if q.FreeResponse() {
+		q.Options = nil
+		q.CorrectAnswer = 0
+		q.CorrectAnswers = nil
+		var accepted []string
+		for _, answer := range q.AcceptedAnswers {
+			answer = strings.TrimSpace(answer)
+			if answer != "" && !slices.ContainsFunc(accepted, func(a string) bool { return strings.EqualFold(a, answer) }) {
+				accepted = append(accepted, answer)
+			}
+		}
+		q.AcceptedAnswers = accepted
+		if q.Kind() != QuestionNumeric {
+			q.Numeric = nil
+		} else if q.Numeric != nil {
+			q.Numeric.Tolerance = math.Abs(q.Numeric.Tolerance)
+			q.Numeric.Unit = strings.TrimSpace(q.Numeric.Unit)
+		}
+		return
+	}
+	q.AcceptedAnswers = nil
+	q.Numeric = nil
+	if q.Arranged() {
+		q.CorrectAnswer = 0
+		return
+	}
+	if q.Kind() != QuestionMultiSelect {
+		q.CorrectAnswers = nil
+		return
+	}
+	slices.Sort(q.CorrectAnswers)
+	q.CorrectAnswers = slices.Compact(q.CorrectAnswers)
+	if len(q.CorrectAnswers) > 0 {
+		q.CorrectAnswer = q.CorrectAnswers[0]
+	}
+}
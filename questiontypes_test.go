@@ -0,0 +1,105 @@
+package quizgenerator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQuestionScore(t *testing.T) {
+	singleChoice := &Question{Options: []string{"A", "B", "C", "D"}, CorrectAnswer: 2}
+	trueFalse := &Question{Type: QuestionTrueFalse, Options: []string{"True", "False"}, CorrectAnswer: 1}
+	multiSelect := &Question{Type: QuestionMultiSelect, Options: []string{"A", "B", "C", "D"}, CorrectAnswers: []int{0, 2}}
+	ordering := &Question{Type: QuestionOrdering, Options: []string{"A", "B", "C", "D"}, CorrectAnswers: []int{2, 0, 3, 1}}
+	matching := &Question{Type: QuestionMatching, Options: []string{"A", "B", "C"}, Matches: []string{"x", "y", "z"}, CorrectAnswers: []int{1, 2, 0}}
+	shortAnswer := &Question{Type: QuestionShortAnswer, AcceptedAnswers: []string{"A"}}
+
+	tests := []struct {
+		name     string
+		question *Question
+		selected []int
+		want     float64
+	}{
+		{"single choice correct", singleChoice, []int{2}, 1},
+		{"single choice wrong", singleChoice, []int{1}, 0},
+		{"single choice with extra options", singleChoice, []int{2, 1}, 0},
+		{"single choice unanswered", singleChoice, nil, 0},
+		{"true or false", trueFalse, []int{1}, 1},
+		{"multi-select all correct", multiSelect, []int{2, 0}, 1},
+		{"multi-select half", multiSelect, []int{0}, 0.5},
+		{"multi-select with a wrong option", multiSelect, []int{0, 2, 1}, 0.5},
+		{"multi-select repeated option", multiSelect, []int{0, 0}, 0.5},
+		{"multi-select never negative", multiSelect, []int{1, 3}, 0},
+		{"multi-select out of range", multiSelect, []int{0, 9}, 0.5},
+		{"ordering correct", ordering, []int{2, 0, 3, 1}, 1},
+		{"ordering half in place", ordering, []int{2, 0, 1, 3}, 0.5},
+		{"ordering short", ordering, []int{2}, 0.25},
+		{"matching correct", matching, []int{1, 2, 0}, 1},
+		{"matching one pair", matching, []int{1, 0, 2}, 1.0 / 3},
+		{"short answer", shortAnswer, []int{0}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.question.Score(tt.selected); got != tt.want {
+			t.Errorf("%s: Score(%v) = %v, want %v", tt.name, tt.selected, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuestionTypes(t *testing.T) {
+	types, err := ParseQuestionTypes(" Multi_Select, numeric,,multi_select")
+	if err != nil {
+		t.Fatalf("ParseQuestionTypes failed: %v", err)
+	}
+	if want := []QuestionType{QuestionMultiSelect, QuestionNumeric}; !slices.Equal(types, want) {
+		t.Errorf("ParseQuestionTypes = %v, want %v", types, want)
+	}
+
+	if _, err := ParseQuestionTypes("essay"); err == nil {
+		t.Errorf("ParseQuestionTypes accepted an unknown type")
+	}
+}
+
+func TestCorrectText(t *testing.T) {
+	tests := []struct {
+		question *Question
+		want     string
+	}{
+		{&Question{Options: []string{"A", "B"}, CorrectAnswer: 1}, "B"},
+		{&Question{Type: QuestionMultiSelect, Options: []string{"A", "B", "C"}, CorrectAnswers: []int{0, 2}}, "A; C"},
+		{&Question{Type: QuestionMultiSelect, Options: []string{"A", "B"}, CorrectAnswers: []int{1, 5}}, "B"},
+		{&Question{Type: QuestionOrdering, Options: []string{"A", "B", "C"}, CorrectAnswers: []int{2, 0, 1}}, "C → A → B"},
+		{&Question{Type: QuestionMatching, Options: []string{"Fe", "Au"}, Matches: []string{"gold", "iron"}, CorrectAnswers: []int{1, 0}}, "Fe: iron; Au: gold"},
+		// Indexes out of range in a malformed answer key are skipped
+		{&Question{Type: QuestionOrdering, Options: []string{"A", "B"}, CorrectAnswers: []int{1, 4, 0}}, "B → A"},
+		{&Question{Type: QuestionMatching, Options: []string{"Fe", "Au"}, Matches: []string{"gold", "iron"}, CorrectAnswers: []int{1, 3, 0}}, "Fe: iron"},
+		{&Question{Type: QuestionShortAnswer, AcceptedAnswers: []string{"Paris", "City of Light"}}, "Paris"},
+		{&Question{Type: QuestionNumeric, Numeric: &NumericAnswer{Value: 8849, Tolerance: 10, Unit: "m"}}, "8849 m"},
+	}
+	for _, tt := range tests {
+		if got := tt.question.CorrectText(); got != tt.want {
+			t.Errorf("CorrectText of %s question = %q, want %q", tt.question.Kind(), got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeFreeResponseAnswers(t *testing.T) {
+	question := &Question{
+		Type:            QuestionShortAnswer,
+		Options:         []string{"Paris", "Lyon"},
+		CorrectAnswers:  []int{0},
+		AcceptedAnswers: []string{" Paris", "paris", "", "City of Light"},
+		Numeric:         &NumericAnswer{Value: 1},
+	}
+	question.normalizeAnswers()
+	if question.Options != nil || question.CorrectAnswers != nil || question.Numeric != nil {
+		t.Errorf("short-answer question kept options %q, correct answers %v and numeric answer %v", question.Options, question.CorrectAnswers, question.Numeric)
+	}
+	if want := []string{"Paris", "City of Light"}; !slices.Equal(question.AcceptedAnswers, want) {
+		t.Errorf("accepted answers = %q, want %q", question.AcceptedAnswers, want)
+	}
+
+	numeric := &Question{Type: QuestionNumeric, Numeric: &NumericAnswer{Value: 100, Tolerance: -5, Unit: " m "}}
+	numeric.normalizeAnswers()
+	if numeric.Numeric.Tolerance != 5 || numeric.Numeric.Unit != "m" {
+		t.Errorf("numeric answer normalized to %+v", numeric.Numeric)
+	}
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // DB represents a quiz database connection
 type DB struct {
-	db *sql.DB
+	db     *sql.DB
+	config *Config      // Generator configuration used by GenerateQuiz
+	daily  *DailyBudget // Optional daily budget shared by every GenerateQuiz call
 }
 
 // Quiz represents a quiz in the database
@@ -25,7 +30,37 @@ type DBQuiz struct {
 	SourceMaterial string    `json:"source_material"`
 	Difficulty     string    `json:"difficulty"`
 	CreatedAt      time.Time `json:"created_at"`
-	Status         string    `json:"status"` // "generating", "ready", "completed"
+	Status         string    `json:"status"` // "generating", "ready", "completed", "budget_exhausted", "failed"
+	Category       string    `json:"category,omitempty"`
+	// File the source material was extracted from, if it was uploaded as one
+	SourceFilename string `json:"source_filename,omitempty"`
+	SourceLength   int    `json:"source_length,omitempty"` // Characters of text extracted from SourceFilename
+	Coverage       string `json:"coverage"`                // JSON array of SubtopicCoverage, empty without planning
+	// LLM usage accounting, filled in when generation finishes
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	StageUsage       string  `json:"stage_usage"` // JSON object of StageUsage by stage name
+}
+
+// SubtopicCoverage returns the quiz's per-subtopic coverage, or nil if it wasn't planned
+func (quiz DBQuiz) SubtopicCoverage() []SubtopicCoverage {
+	var coverage []SubtopicCoverage
+	if quiz.Coverage == "" || json.Unmarshal([]byte(quiz.Coverage), &coverage) != nil {
+		return nil
+	}
+	return coverage
+}
+
+// Playable reports whether the quiz has finished generating and has questions to play
+func (quiz DBQuiz) Playable() bool {
+	switch quiz.Status {
+	case "completed":
+		return true
+	case "budget_exhausted":
+		return quiz.NumQuestions > 0
+	}
+	return false
 }
 
 // Question represents a question in the database
@@ -34,9 +69,44 @@ type DBQuestion struct {
 	QuizID        string `json:"quiz_id"`
 	QuestionNum   int    `json:"question_num"`
 	Text          string `json:"text"`
+	Type          string `json:"type"`    // QuestionType; empty means single choice
 	Options       string `json:"options"` // JSON array of strings
 	CorrectAnswer int    `json:"correct_answer"`
-	Explanation   string `json:"explanation"`
+	// JSON array of every correct option's index for multi-select questions, the
+	// correct order or each option's match for arranged ones, empty otherwise
+	CorrectAnswers string `json:"correct_answers"`
+	// JSON array of the items a matching question's options pair with, empty otherwise
+	Matches string `json:"matches"`
+	// JSON array of a short-answer question's accepted answers, empty otherwise
+	AcceptedAnswers string `json:"accepted_answers"`
+	// JSON NumericAnswer of a numeric question, empty otherwise
+	NumericAnswer string  `json:"numeric_answer"`
+	Explanation   string  `json:"explanation"`
+	Votes         string  `json:"votes"`        // JSON array of JudgeVote, empty without consensus
+	Disagreement  float64 `json:"disagreement"` // Fraction of judges that voted against accepting
+	// Blind solver verification; SolverAnswer is empty when the question wasn't verified
+	SolverAnswer     string  `json:"solver_answer"`
+	SolverConfidence float64 `json:"solver_confidence"`
+	SolverAgreed     bool    `json:"solver_agreed"`
+	// Excerpt of the quiz's source material supporting the answer; empty without source material
+	SourceQuote string `json:"source_quote"`
+	SourceStart int    `json:"source_start"`
+	SourceEnd   int    `json:"source_end"`
+	Subtopic    string `json:"subtopic"` // Planned subtopic, empty without planning
+	// Name in the image store of the picture shown with the question, empty without one
+	Image    string `json:"image"`
+	ImageAlt string `json:"image_alt"`
+	// Question this one was copied from into a quiz built to a target difficulty, empty otherwise
+	OriginID string `json:"origin_id"`
+}
+
+// StatsID returns the ID answers to the question are recorded under, which
+// copies share with the question they were copied from
+func (question *DBQuestion) StatsID() string {
+	if question.OriginID != "" {
+		return question.OriginID
+	}
+	return question.ID
 }
 
 // OpenDB opens a new database connection
@@ -52,7 +122,17 @@ func OpenDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db: db}, nil
+	return &DB{db: db, config: DefaultConfig()}, nil
+}
+
+// SetConfig sets the generator configuration used when generating quizzes
+func (db *DB) SetConfig(cfg *Config) {
+	db.config = cfg
+}
+
+// SetDailyBudget sets the daily budget shared by every quiz generated through this database
+func (db *DB) SetDailyBudget(daily *DailyBudget) {
+	db.daily = daily
 }
 
 // Close closes the database connection
@@ -82,6 +162,28 @@ func (db *DB) CreateTables() error {
 			explanation TEXT,
 			FOREIGN KEY (quiz_id) REFERENCES quizzes(id)
 		)`,
+		`CREATE TABLE IF NOT EXISTS generation_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			quiz_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			question_id TEXT,
+			batch_size INTEGER NOT NULL DEFAULT 0,
+			reason TEXT,
+			duplicate_id TEXT,
+			error TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (quiz_id) REFERENCES quizzes(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS answer_outcomes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			question_id TEXT NOT NULL,
+			quiz_id TEXT NOT NULL,
+			selected TEXT NOT NULL DEFAULT '',
+			score REAL NOT NULL,
+			game TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS answer_outcomes_question_id ON answer_outcomes (question_id)`,
 	}
 
 	for _, query := range queries {
@@ -89,14 +191,64 @@ func (db *DB) CreateTables() error {
 			return fmt.Errorf("failed to execute %s: %w", query, err)
 		}
 	}
+
+	// Columns added after the original schema; existing databases get them here
+	columns := []struct {
+		table      string
+		definition string
+	}{
+		{"quizzes", "prompt_tokens INTEGER NOT NULL DEFAULT 0"},
+		{"quizzes", "completion_tokens INTEGER NOT NULL DEFAULT 0"},
+		{"quizzes", "cost_usd REAL NOT NULL DEFAULT 0"},
+		{"quizzes", "stage_usage TEXT NOT NULL DEFAULT '{}'"},
+		{"quizzes", "category TEXT NOT NULL DEFAULT ''"},
+		{"quizzes", "source_filename TEXT NOT NULL DEFAULT ''"},
+		{"quizzes", "source_length INTEGER NOT NULL DEFAULT 0"},
+		{"quizzes", "coverage TEXT NOT NULL DEFAULT ''"},
+		{"generation_events", "duplicate_quiz_id TEXT NOT NULL DEFAULT ''"},
+		{"generation_events", "duplicate_question_num INTEGER NOT NULL DEFAULT 0"},
+		{"generation_events", "subtopic TEXT NOT NULL DEFAULT ''"},
+		{"questions", "votes TEXT NOT NULL DEFAULT ''"},
+		{"questions", "disagreement REAL NOT NULL DEFAULT 0"},
+		{"questions", "solver_answer TEXT NOT NULL DEFAULT ''"},
+		{"questions", "solver_confidence REAL NOT NULL DEFAULT 0"},
+		{"questions", "solver_agreed INTEGER NOT NULL DEFAULT 0"},
+		{"questions", "source_quote TEXT NOT NULL DEFAULT ''"},
+		{"questions", "source_start INTEGER NOT NULL DEFAULT -1"},
+		{"questions", "source_end INTEGER NOT NULL DEFAULT -1"},
+		{"questions", "subtopic TEXT NOT NULL DEFAULT ''"},
+		{"questions", "question_type TEXT NOT NULL DEFAULT ''"},
+		{"questions", "correct_answers TEXT NOT NULL DEFAULT ''"},
+		{"questions", "accepted_answers TEXT NOT NULL DEFAULT ''"},
+		{"questions", "numeric_answer TEXT NOT NULL DEFAULT ''"},
+		{"questions", "matches TEXT NOT NULL DEFAULT ''"},
+		{"questions", "image TEXT NOT NULL DEFAULT ''"},
+		{"questions", "image_alt TEXT NOT NULL DEFAULT ''"},
+		{"questions", "origin_id TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, column := range columns {
+		if err := db.addColumn(column.table, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to a table unless it already exists
+func (db *DB) addColumn(table, definition string) error {
+	_, err := db.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition))
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return fmt.Errorf("failed to add column %s to %s: %w", definition, table, err)
+	}
 	return nil
 }
 
 // CreateQuiz creates a new quiz in the database
 func (db *DB) CreateQuiz(quiz *DBQuiz) error {
 	_, err := db.db.Exec(
-		"INSERT INTO quizzes (id, topic, num_questions, source_material, difficulty, created_at, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		quiz.ID, quiz.Topic, quiz.NumQuestions, quiz.SourceMaterial, quiz.Difficulty, quiz.CreatedAt, quiz.Status,
+		"INSERT INTO quizzes (id, topic, num_questions, source_material, difficulty, created_at, status, category, source_filename, source_length) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		quiz.ID, quiz.Topic, quiz.NumQuestions, quiz.SourceMaterial, quiz.Difficulty, quiz.CreatedAt, quiz.Status, quiz.Category,
+		quiz.SourceFilename, quiz.SourceLength,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create quiz: %w", err)
@@ -104,13 +256,24 @@ func (db *DB) CreateQuiz(quiz *DBQuiz) error {
 	return nil
 }
 
+// quizColumns lists the quizzes columns in the order scanned by DBQuiz.scanFields
+const quizColumns = "id, topic, num_questions, source_material, difficulty, created_at, status, prompt_tokens, completion_tokens, cost_usd, stage_usage, category, source_filename, source_length, coverage"
+
+func (quiz *DBQuiz) scanFields() []interface{} {
+	return []interface{}{
+		&quiz.ID, &quiz.Topic, &quiz.NumQuestions, &quiz.SourceMaterial, &quiz.Difficulty, &quiz.CreatedAt, &quiz.Status,
+		&quiz.PromptTokens, &quiz.CompletionTokens, &quiz.CostUSD, &quiz.StageUsage, &quiz.Category,
+		&quiz.SourceFilename, &quiz.SourceLength, &quiz.Coverage,
+	}
+}
+
 // GetQuiz retrieves a quiz by ID
 func (db *DB) GetQuiz(id string) (*DBQuiz, error) {
 	var quiz DBQuiz
 	err := db.db.QueryRow(
-		"SELECT id, topic, num_questions, source_material, difficulty, created_at, status FROM quizzes WHERE id = ?",
+		"SELECT "+quizColumns+" FROM quizzes WHERE id = ?",
 		id,
-	).Scan(&quiz.ID, &quiz.Topic, &quiz.NumQuestions, &quiz.SourceMaterial, &quiz.Difficulty, &quiz.CreatedAt, &quiz.Status)
+	).Scan(quiz.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("quiz not found: %s", id)
@@ -122,7 +285,7 @@ func (db *DB) GetQuiz(id string) (*DBQuiz, error) {
 
 // GetQuizzes retrieves all quizzes, optionally limited by count
 func (db *DB) GetQuizzes(limit int) ([]DBQuiz, error) {
-	query := "SELECT id, topic, num_questions, source_material, difficulty, created_at, status FROM quizzes ORDER BY created_at DESC"
+	query := "SELECT " + quizColumns + " FROM quizzes ORDER BY created_at DESC"
 	if limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", limit)
 	}
@@ -136,7 +299,7 @@ func (db *DB) GetQuizzes(limit int) ([]DBQuiz, error) {
 	var quizzes []DBQuiz
 	for rows.Next() {
 		var quiz DBQuiz
-		err := rows.Scan(&quiz.ID, &quiz.Topic, &quiz.NumQuestions, &quiz.SourceMaterial, &quiz.Difficulty, &quiz.CreatedAt, &quiz.Status)
+		err := rows.Scan(quiz.scanFields()...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan quiz: %w", err)
 		}
@@ -159,11 +322,82 @@ func (db *DB) UpdateQuizStatus(id, status string) error {
 	return nil
 }
 
+// UpdateQuizUsage stores the LLM token usage and cost of a quiz
+func (db *DB) UpdateQuizUsage(id string, usage UsageSummary) error {
+	stages, err := json.Marshal(usage.Stages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stage usage: %w", err)
+	}
+	_, err = db.db.Exec(
+		"UPDATE quizzes SET prompt_tokens = ?, completion_tokens = ?, cost_usd = ?, stage_usage = ? WHERE id = ?",
+		usage.PromptTokens, usage.CompletionTokens, usage.CostUSD, string(stages), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update quiz usage: %w", err)
+	}
+	return nil
+}
+
+// UpdateQuizCoverage stores how many questions were planned and accepted for each subtopic of a quiz
+func (db *DB) UpdateQuizCoverage(id string, coverage []SubtopicCoverage) error {
+	data, err := json.Marshal(coverage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal coverage: %w", err)
+	}
+	_, err = db.db.Exec("UPDATE quizzes SET coverage = ? WHERE id = ?", string(data), id)
+	if err != nil {
+		return fmt.Errorf("failed to update quiz coverage: %w", err)
+	}
+	return nil
+}
+
+// UpdateQuestionImage sets the picture shown with a question, or removes it if image is nil
+func (db *DB) UpdateQuestionImage(quizID string, questionNum int, image *QuestionImage) error {
+	var name, alt string
+	if image != nil {
+		name, alt = image.Name, image.Alt
+	}
+	result, err := db.db.Exec(
+		"UPDATE questions SET image = ?, image_alt = ? WHERE quiz_id = ? AND question_num = ?",
+		name, alt, quizID, questionNum,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update question image: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("question not found: quiz_id=%s, question_num=%d", quizID, questionNum)
+	}
+	return nil
+}
+
+// CountImageUses returns how many questions show the named image
+func (db *DB) CountImageUses(name string) (int, error) {
+	var count int
+	err := db.db.QueryRow("SELECT COUNT(*) FROM questions WHERE image = ?", name).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count questions with image %s: %w", name, err)
+	}
+	return count, nil
+}
+
+// GetCostSince returns the total LLM cost of quizzes created since the given time
+func (db *DB) GetCostSince(since time.Time) (float64, error) {
+	var cost float64
+	err := db.db.QueryRow("SELECT COALESCE(SUM(cost_usd), 0) FROM quizzes WHERE created_at >= ?", since).Scan(&cost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cost since %s: %w", since.Format(time.RFC3339), err)
+	}
+	return cost, nil
+}
+
 // CreateQuestion creates a new question in the database
 func (db *DB) CreateQuestion(question *DBQuestion) error {
 	_, err := db.db.Exec(
-		"INSERT INTO questions (id, quiz_id, question_num, text, options, correct_answer, explanation) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		"INSERT INTO questions (id, quiz_id, question_num, text, options, correct_answer, explanation, votes, disagreement, solver_answer, solver_confidence, solver_agreed, source_quote, source_start, source_end, subtopic, question_type, correct_answers, accepted_answers, numeric_answer, matches, image, image_alt, origin_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		question.ID, question.QuizID, question.QuestionNum, question.Text, question.Options, question.CorrectAnswer, question.Explanation,
+		question.Votes, question.Disagreement, question.SolverAnswer, question.SolverConfidence, question.SolverAgreed,
+		question.SourceQuote, question.SourceStart, question.SourceEnd, question.Subtopic, question.Type, question.CorrectAnswers,
+		question.AcceptedAnswers, question.NumericAnswer, question.Matches, question.Image, question.ImageAlt, question.OriginID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create question: %w", err)
@@ -171,13 +405,72 @@ func (db *DB) CreateQuestion(question *DBQuestion) error {
 	return nil
 }
 
+// questionColumns lists the questions columns in the order scanned by DBQuestion.scanFields
+const questionColumns = "id, quiz_id, question_num, text, options, correct_answer, explanation, votes, disagreement, " +
+	"solver_answer, solver_confidence, solver_agreed, source_quote, source_start, source_end, subtopic, question_type, correct_answers, " +
+	"accepted_answers, numeric_answer, matches, image, image_alt, origin_id"
+
+func (question *DBQuestion) scanFields() []interface{} {
+	return []interface{}{
+		&question.ID, &question.QuizID, &question.QuestionNum, &question.Text, &question.Options, &question.CorrectAnswer, &question.Explanation,
+		&question.Votes, &question.Disagreement, &question.SolverAnswer, &question.SolverConfidence, &question.SolverAgreed,
+		&question.SourceQuote, &question.SourceStart, &question.SourceEnd, &question.Subtopic, &question.Type, &question.CorrectAnswers,
+		&question.AcceptedAnswers, &question.NumericAnswer, &question.Matches, &question.Image, &question.ImageAlt, &question.OriginID,
+	}
+}
+
+// ToQuestion converts a stored question back into a Question
+func (question *DBQuestion) ToQuestion() (*Question, error) {
+	options, err := JSONToOptions(question.Options)
+	if err != nil {
+		return nil, err
+	}
+	result := &Question{
+		ID:            question.ID,
+		Type:          QuestionType(question.Type),
+		Text:          question.Text,
+		Options:       options,
+		CorrectAnswer: question.CorrectAnswer,
+		Explanation:   question.Explanation,
+		Status:        StatusAccepted,
+		Subtopic:      question.Subtopic,
+	}
+	if question.CorrectAnswers != "" {
+		if err := json.Unmarshal([]byte(question.CorrectAnswers), &result.CorrectAnswers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal correct answers: %w", err)
+		}
+	}
+	if question.Matches != "" {
+		if err := json.Unmarshal([]byte(question.Matches), &result.Matches); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal matches: %w", err)
+		}
+	}
+	if question.AcceptedAnswers != "" {
+		if err := json.Unmarshal([]byte(question.AcceptedAnswers), &result.AcceptedAnswers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal accepted answers: %w", err)
+		}
+	}
+	if question.NumericAnswer != "" {
+		if err := json.Unmarshal([]byte(question.NumericAnswer), &result.Numeric); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal numeric answer: %w", err)
+		}
+	}
+	if question.SourceQuote != "" {
+		result.Source = &SourceSpan{Quote: question.SourceQuote, Start: question.SourceStart, End: question.SourceEnd}
+	}
+	if question.Image != "" {
+		result.Image = &QuestionImage{Name: question.Image, Alt: question.ImageAlt}
+	}
+	return result, nil
+}
+
 // GetQuestion retrieves a question by quiz ID and question number
 func (db *DB) GetQuestion(quizID string, questionNum int) (*DBQuestion, error) {
 	var question DBQuestion
 	err := db.db.QueryRow(
-		"SELECT id, quiz_id, question_num, text, options, correct_answer, explanation FROM questions WHERE quiz_id = ? AND question_num = ?",
+		"SELECT "+questionColumns+" FROM questions WHERE quiz_id = ? AND question_num = ?",
 		quizID, questionNum,
-	).Scan(&question.ID, &question.QuizID, &question.QuestionNum, &question.Text, &question.Options, &question.CorrectAnswer, &question.Explanation)
+	).Scan(question.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("question not found: quiz_id=%s, question_num=%d", quizID, questionNum)
@@ -190,25 +483,44 @@ func (db *DB) GetQuestion(quizID string, questionNum int) (*DBQuestion, error) {
 // GetQuestions retrieves all questions for a quiz
 func (db *DB) GetQuestions(quizID string) ([]DBQuestion, error) {
 	rows, err := db.db.Query(
-		"SELECT id, quiz_id, question_num, text, options, correct_answer, explanation FROM questions WHERE quiz_id = ? ORDER BY question_num",
+		"SELECT "+questionColumns+" FROM questions WHERE quiz_id = ? ORDER BY question_num",
 		quizID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get questions: %w", err)
 	}
+	return scanQuestions(rows)
+}
+
+// GetContestedQuestions returns the questions the consensus judges disagreed on or the blind solver
+// got wrong, most contested first; limit <= 0 returns all
+func (db *DB) GetContestedQuestions(limit int) ([]DBQuestion, error) {
+	query := "SELECT " + questionColumns + " FROM questions WHERE disagreement > 0 OR (solver_answer != '' AND NOT solver_agreed)" +
+		" ORDER BY disagreement DESC, solver_confidence DESC, quiz_id, question_num"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := db.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contested questions: %w", err)
+	}
+	return scanQuestions(rows)
+}
+
+// scanQuestions reads every row of a questions query and closes it
+func scanQuestions(rows *sql.Rows) ([]DBQuestion, error) {
 	defer rows.Close()
 
 	var questions []DBQuestion
 	for rows.Next() {
 		var question DBQuestion
-		err := rows.Scan(&question.ID, &question.QuizID, &question.QuestionNum, &question.Text, &question.Options, &question.CorrectAnswer, &question.Explanation)
-		if err != nil {
+		if err := rows.Scan(question.scanFields()...); err != nil {
 			return nil, fmt.Errorf("failed to scan question: %w", err)
 		}
 		questions = append(questions, question)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating questions: %w", err)
 	}
 
@@ -266,22 +578,34 @@ func (db *DB) UpdateQuizNumQuestions(id string, numQuestions int) error {
 	return nil
 }
 
-func (db *DB) GenerateQuiz(quizID, topic string, numQuestions int, sourceMaterial, difficulty string) {
+// GenerateQuiz generates the questions for an existing quiz record and stores them as they are accepted
+func (db *DB) GenerateQuiz(quizID string, req GenerationRequest) {
 	// Ensure at least 1 question is generated
-	if numQuestions < 1 {
-		numQuestions = 1
+	if req.NumQuestions < 1 {
+		req.NumQuestions = 1
 	}
+	numQuestions := req.NumQuestions
 
-	req := GenerationRequest{
-		Topic:          topic,
-		NumQuestions:   numQuestions,
-		SourceMaterial: sourceMaterial,
-		Difficulty:     difficulty,
+	// Create a new QuizGenerator instance for this quiz
+	generator, err := NewQuizGenerator(db.config)
+	if err != nil {
+		log.Printf("Failed to create generator for quiz %s: %v", quizID, err)
+		if updateErr := db.UpdateQuizNumQuestions(quizID, 0); updateErr != nil {
+			log.Printf("Failed to update quiz num questions %s: %v", quizID, updateErr)
+		}
+		if updateErr := db.UpdateQuizStatus(quizID, "failed"); updateErr != nil {
+			log.Printf("Failed to update quiz status to failed %s: %v", quizID, updateErr)
+		}
+		return
 	}
+	generator.SetDailyBudget(db.daily)
 
-	// Create a new QuizGenerator instance for this quiz
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	generator := NewQuizGenerator(apiKey)
+	// Store every generation event so the web UI can show progress and outcomes
+	generator.SetEventHandler(func(event GenerationEvent) {
+		if err := db.CreateGenerationEvent(quizID, event); err != nil {
+			log.Printf("Failed to store generation event for quiz %s: %v", quizID, err)
+		}
+	})
 
 	// Create logger with our specific quiz ID
 	logger, err := NewLLMLogger(quizID, req)
@@ -297,6 +621,16 @@ func (db *DB) GenerateQuiz(quizID, topic string, numQuestions int, sourceMateria
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
+	// Check new questions against earlier quizzes too; without the bank generation still works
+	if db.config.CrossQuiz.Enabled {
+		bank, err := db.GetQuestionBank(quizID, db.config.CrossQuiz.Scope, db.config.CrossQuiz.MaxQuestions)
+		if err != nil {
+			log.Printf("Failed to load question bank for quiz %s: %v", quizID, err)
+		} else if err := generator.AddQuestionBank(ctx, bank); err != nil {
+			log.Printf("Failed to add question bank for quiz %s: %v", quizID, err)
+		}
+	}
+
 	questionChan, err := generator.GenerateQuizStream(ctx, req)
 	if err != nil {
 		log.Printf("Failed to generate quiz %s: %v", quizID, err)
@@ -320,6 +654,38 @@ func (db *DB) GenerateQuiz(quizID, topic string, numQuestions int, sourceMateria
 			log.Printf("Failed to update quiz num questions %s: %v", quizID, err)
 		}
 
+		usage := generator.Usage()
+		if err := db.UpdateQuizUsage(quizID, usage); err != nil {
+			log.Printf("Failed to update quiz usage %s: %v", quizID, err)
+		}
+		VerboseLog("Quiz %s usage:\n%s", quizID, usage)
+		stats := generator.Result().Stats
+		if len(stats.Coverage) > 0 {
+			if err := db.UpdateQuizCoverage(quizID, stats.Coverage); err != nil {
+				log.Printf("Failed to update quiz coverage %s: %v", quizID, err)
+			}
+		}
+		VerboseLog("Quiz %s stats: requested %d, accepted %d, rejected %d, revised %d, duplicates %d, dropped %d, flagged %d",
+			quizID, stats.Requested, stats.Accepted, stats.Rejected, stats.Revised, stats.Duplicates, stats.Dropped, stats.Flagged)
+
+		// Running out of budget keeps whatever questions were accepted so far
+		if errors.Is(generator.Err(), ErrBudgetExhausted) {
+			log.Printf("Quiz %s stopped with %d questions (requested: %d): budget exhausted", quizID, actualQuestions, numQuestions)
+			if err := db.UpdateQuizStatus(quizID, "budget_exhausted"); err != nil {
+				log.Printf("Failed to update quiz status to budget_exhausted %s: %v", quizID, err)
+			}
+			return
+		}
+
+		// A generation error that left no questions at all means the quiz failed
+		if genErr := generator.Err(); genErr != nil && actualQuestions == 0 {
+			log.Printf("Quiz %s failed: %v", quizID, genErr)
+			if err := db.UpdateQuizStatus(quizID, "failed"); err != nil {
+				log.Printf("Failed to update quiz status to failed %s: %v", quizID, err)
+			}
+			return
+		}
+
 		// Mark quiz as completed when all questions are done
 		if err := db.UpdateQuizStatus(quizID, "completed"); err != nil {
 			log.Printf("Failed to update quiz status to completed %s: %v", quizID, err)
@@ -340,10 +706,69 @@ func (db *DB) GenerateQuiz(quizID, topic string, numQuestions int, sourceMateria
 			ID:            question.ID,
 			QuizID:        quizID,
 			QuestionNum:   questionNum,
+			Type:          string(question.Type),
 			Text:          question.Text,
 			Options:       optionsJSON,
 			CorrectAnswer: question.CorrectAnswer,
 			Explanation:   question.Explanation,
+			Disagreement:  question.Disagreement,
+			SourceStart:   -1,
+			SourceEnd:     -1,
+			Subtopic:      question.Subtopic,
+		}
+		if question.Source != nil {
+			dbQuestion.SourceQuote = question.Source.Quote
+			dbQuestion.SourceStart = question.Source.Start
+			dbQuestion.SourceEnd = question.Source.End
+		}
+		if question.Image != nil {
+			dbQuestion.Image = question.Image.Name
+			dbQuestion.ImageAlt = question.Image.Alt
+		}
+		if question.Verification != nil {
+			dbQuestion.SolverAnswer = question.Verification.Answer
+			dbQuestion.SolverConfidence = question.Verification.Confidence
+			dbQuestion.SolverAgreed = question.Verification.Agreed
+		}
+		if len(question.CorrectAnswers) > 0 {
+			correctAnswers, err := json.Marshal(question.CorrectAnswers)
+			if err != nil {
+				log.Printf("Failed to marshal correct answers for question %s: %v", question.ID, err)
+				continue
+			}
+			dbQuestion.CorrectAnswers = string(correctAnswers)
+		}
+		if len(question.Matches) > 0 {
+			matches, err := json.Marshal(question.Matches)
+			if err != nil {
+				log.Printf("Failed to marshal matches for question %s: %v", question.ID, err)
+				continue
+			}
+			dbQuestion.Matches = string(matches)
+		}
+		if len(question.AcceptedAnswers) > 0 {
+			acceptedAnswers, err := json.Marshal(question.AcceptedAnswers)
+			if err != nil {
+				log.Printf("Failed to marshal accepted answers for question %s: %v", question.ID, err)
+				continue
+			}
+			dbQuestion.AcceptedAnswers = string(acceptedAnswers)
+		}
+		if question.Numeric != nil {
+			numeric, err := json.Marshal(question.Numeric)
+			if err != nil {
+				log.Printf("Failed to marshal numeric answer for question %s: %v", question.ID, err)
+				continue
+			}
+			dbQuestion.NumericAnswer = string(numeric)
+		}
+		if len(question.Votes) > 0 {
+			votes, err := json.Marshal(question.Votes)
+			if err != nil {
+				log.Printf("Failed to marshal votes for question %s: %v", question.ID, err)
+			} else {
+				dbQuestion.Votes = string(votes)
+			}
 		}
 
 		if err := db.CreateQuestion(dbQuestion); err != nil {
@@ -368,6 +793,202 @@ func (db *DB) GenerateQuiz(quizID, topic string, numQuestions int, sourceMateria
 			break
 		}
 	}
+
+	// Wait for the generator to finish so its final events and usage are recorded
+	for range questionChan {
+	}
+}
+
+// CreateGenerationEvent stores a generation event for a quiz
+func (db *DB) CreateGenerationEvent(quizID string, event GenerationEvent) error {
+	_, err := db.db.Exec(
+		"INSERT INTO generation_events (quiz_id, type, question_id, batch_size, reason, duplicate_id, duplicate_quiz_id, duplicate_question_num, error, subtopic, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		quizID, string(event.Type), event.QuestionID, event.BatchSize, event.Reason, event.DuplicateID, event.DuplicateQuizID, event.DuplicateQuestionNum, event.Error, event.Subtopic, event.Time,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create generation event: %w", err)
+	}
+	return nil
+}
+
+// GetGenerationEvents retrieves the most recent generation events for a quiz, oldest first; limit <= 0 returns all
+func (db *DB) GetGenerationEvents(quizID string, limit int) ([]GenerationEvent, error) {
+	query := "SELECT type, question_id, batch_size, reason, duplicate_id, duplicate_quiz_id, duplicate_question_num, error, subtopic, created_at FROM generation_events WHERE quiz_id = ? ORDER BY id DESC"
+	args := []interface{}{quizID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generation events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []GenerationEvent
+	for rows.Next() {
+		var event GenerationEvent
+		var eventType string
+		if err := rows.Scan(&eventType, &event.QuestionID, &event.BatchSize, &event.Reason, &event.DuplicateID, &event.DuplicateQuizID, &event.DuplicateQuestionNum, &event.Error, &event.Subtopic, &event.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan generation event: %w", err)
+		}
+		event.Type = EventType(eventType)
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating generation events: %w", err)
+	}
+
+	// Reverse into chronological order
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// CrossQuizDedupConfig controls checking new questions against other quizzes in the database
+type CrossQuizDedupConfig struct {
+	Enabled bool `json:"enabled"`
+	// Which quizzes to compare against: "all" (the default), "category" for quizzes in the
+	// same category, or "topic" for quizzes whose topics share a significant word
+	Scope string `json:"scope,omitempty"`
+	// Most questions to compare against, from the newest quizzes in scope; 0 means DefaultBankQuestions
+	MaxQuestions int `json:"max_questions,omitempty"`
+}
+
+// DefaultBankQuestions bounds the questions of other quizzes a question bank holds
+const DefaultBankQuestions = 2000
+
+// Cross-quiz dedup scopes
+const (
+	ScopeAll      = "all"
+	ScopeCategory = "category"
+	ScopeTopic    = "topic"
+)
+
+// GetQuestionBank retrieves up to limit questions of the newest other quizzes
+// within scope of the given quiz; limit <= 0 means DefaultBankQuestions
+func (db *DB) GetQuestionBank(quizID, scope string, limit int) ([]BankQuestion, error) {
+	quiz, err := db.GetQuiz(quizID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope == ScopeCategory && quiz.Category == "" {
+		VerboseLog("Quiz %s has no category, checking against all quizzes", quizID)
+		scope = ScopeAll
+	}
+	return db.questionBank(quizID, quiz.Topic, quiz.Category, scope, limit)
+}
+
+// bankQuestionColumns are questionColumns qualified for a join with quizzes
+var bankQuestionColumns = "q." + strings.ReplaceAll(questionColumns, ", ", ", q.")
+
+// questionBank retrieves up to limit questions of the newest quizzes other
+// than excludeID whose topic or category is within scope of the given ones;
+// limit <= 0 means DefaultBankQuestions
+func (db *DB) questionBank(excludeID, topic, category, scope string, limit int) ([]BankQuestion, error) {
+	if limit <= 0 {
+		limit = DefaultBankQuestions
+	}
+
+	query := "SELECT " + bankQuestionColumns + ", z.topic FROM questions q JOIN quizzes z ON z.id = q.quiz_id WHERE q.quiz_id != ?"
+	args := []interface{}{excludeID}
+	switch scope {
+	case ScopeCategory:
+		query += " AND z.category = ? COLLATE NOCASE"
+		args = append(args, category)
+	case ScopeTopic:
+		words := topicWords(topic)
+		if len(words) == 0 {
+			return nil, nil
+		}
+		// Narrow the scan to topics containing one of the words. LIKE only
+		// ignores the case of ASCII letters, so other words can't be matched
+		// this way and every topic is left for topicsOverlap to check.
+		ascii := true
+		for _, word := range words {
+			ascii = ascii && isASCII(word)
+		}
+		if ascii {
+			query += " AND (z.topic LIKE ?" + strings.Repeat(" OR z.topic LIKE ?", len(words)-1) + ")"
+			for _, word := range words {
+				args = append(args, "%"+word+"%")
+			}
+		}
+	}
+	query += " ORDER BY z.created_at DESC, q.quiz_id, q.question_num"
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get question bank: %w", err)
+	}
+	defer rows.Close()
+
+	var bank []BankQuestion
+	// LIKE also matches words inside longer ones, so topics are compared word by word here
+	for len(bank) < limit && rows.Next() {
+		var dbQuestion DBQuestion
+		var quizTopic string
+		if err := rows.Scan(append(dbQuestion.scanFields(), &quizTopic)...); err != nil {
+			return nil, fmt.Errorf("failed to scan question: %w", err)
+		}
+		if scope == ScopeTopic && !topicsOverlap(quizTopic, topic) {
+			continue
+		}
+
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse question %s: %w", dbQuestion.ID, err)
+		}
+		question.Topic = quizTopic
+		bank = append(bank, BankQuestion{
+			Question:    question,
+			QuizID:      dbQuestion.QuizID,
+			QuestionNum: dbQuestion.QuestionNum,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating question bank: %w", err)
+	}
+	return bank, nil
+}
+
+// topicsOverlap reports whether two topics share a word of four or more letters
+func topicsOverlap(a, b string) bool {
+	words := make(map[string]bool)
+	for _, word := range topicWords(a) {
+		words[word] = true
+	}
+	for _, word := range tokenize(b) {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// topicWords returns the distinct lowercase words of four or more letters that
+// topicsOverlap compares topics by
+func topicWords(topic string) []string {
+	var words []string
+	for _, word := range tokenize(topic) {
+		if len(word) >= 4 && !slices.Contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// isASCII reports whether s has only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
 }
 
 // GetQuizActualQuestionCount gets the actual number of questions that exist for a quiz
@@ -379,3 +1000,148 @@ func (db *DB) GetQuizActualQuestionCount(quizID string) (int, error) {
 	}
 	return count, nil
 }
+
+// RecordAnswer stores a player's graded answer to a question for difficulty calibration
+func (db *DB) RecordAnswer(outcome AnswerOutcome) error {
+	selected := ""
+	if len(outcome.Selected) > 0 {
+		data, err := json.Marshal(outcome.Selected)
+		if err != nil {
+			return fmt.Errorf("failed to marshal selected options: %w", err)
+		}
+		selected = string(data)
+	}
+	if outcome.Time.IsZero() {
+		outcome.Time = time.Now()
+	}
+	_, err := db.db.Exec(
+		"INSERT INTO answer_outcomes (question_id, quiz_id, selected, score, game, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		outcome.QuestionID, outcome.QuizID, selected, outcome.Score, outcome.Game, outcome.Time,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record answer: %w", err)
+	}
+	return nil
+}
+
+// GetQuestionStats summarises the recorded answers to the questions of a quiz,
+// or of every quiz if quizID is empty, keyed by DBQuestion.StatsID. Answers to
+// a copied question count towards the question it was copied from.
+func (db *DB) GetQuestionStats(quizID string) (map[string]*QuestionStats, error) {
+	query := "SELECT question_id, selected, score FROM answer_outcomes"
+	var args []interface{}
+	if quizID != "" {
+		query += " WHERE question_id IN (SELECT CASE WHEN origin_id != '' THEN origin_id ELSE id END FROM questions WHERE quiz_id = ?)"
+		args = append(args, quizID)
+	}
+	stats := make(map[string]*QuestionStats)
+	return stats, db.addQuestionStats(stats, query, args...)
+}
+
+// statsBatchSize bounds how many question IDs one query passes to SQLite
+const statsBatchSize = 500
+
+// getQuestionStatsByID summarises the recorded answers to the given questions, keyed by their IDs
+func (db *DB) getQuestionStatsByID(questionIDs []string) (map[string]*QuestionStats, error) {
+	stats := make(map[string]*QuestionStats)
+	for batch := range slices.Chunk(questionIDs, statsBatchSize) {
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := "SELECT question_id, selected, score FROM answer_outcomes WHERE question_id IN (?" + strings.Repeat(", ?", len(batch)-1) + ")"
+		if err := db.addQuestionStats(stats, query, args...); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// addQuestionStats counts the answer outcomes a query selects into stats
+func (db *DB) addQuestionStats(stats map[string]*QuestionStats, query string, args ...interface{}) error {
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get answer outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID, selectedJSON string
+		var score float64
+		if err := rows.Scan(&questionID, &selectedJSON, &score); err != nil {
+			return fmt.Errorf("failed to scan answer outcome: %w", err)
+		}
+		var selected []int
+		if selectedJSON != "" {
+			if err := json.Unmarshal([]byte(selectedJSON), &selected); err != nil {
+				return fmt.Errorf("failed to unmarshal selected options: %w", err)
+			}
+		}
+		if stats[questionID] == nil {
+			stats[questionID] = &QuestionStats{QuestionID: questionID}
+		}
+		stats[questionID].add(selected, score)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating answer outcomes: %w", err)
+	}
+	return nil
+}
+
+// FindCalibratedQuestions picks up to n calibrated questions, from quizzes on
+// topics related to the given one, whose measured difficulty is closest to the
+// target, easiest first
+func (db *DB) FindCalibratedQuestions(topic string, n int, target float64) ([]CalibratedQuestion, error) {
+	bank, err := db.questionBank("", topic, "", ScopeTopic, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Copies have no answers of their own, so only the originals are candidates
+	ids := make([]string, len(bank))
+	for i, question := range bank {
+		ids[i] = question.Question.ID
+	}
+	stats, err := db.getQuestionStatsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []CalibratedQuestion
+	for _, question := range bank {
+		if questionStats := stats[question.Question.ID]; questionStats != nil && questionStats.Calibrated() {
+			candidates = append(candidates, CalibratedQuestion{
+				QuizID:      question.QuizID,
+				QuestionNum: question.QuestionNum,
+				Stats:       *questionStats,
+			})
+		}
+	}
+	selected := SelectByDifficulty(candidates, n, target)
+	VerboseLog("%d of %d calibrated questions related to %q picked for difficulty %.2f", len(selected), len(candidates), topic, target)
+	return selected, nil
+}
+
+// AssembleCalibratedQuiz fills an existing quiz record with copies of the
+// given questions, in order, and marks it completed
+func (db *DB) AssembleCalibratedQuiz(quizID string, selected []CalibratedQuestion) error {
+	for i, candidate := range selected {
+		original, err := db.GetQuestion(candidate.QuizID, candidate.QuestionNum)
+		if err != nil {
+			return err
+		}
+		question := *original
+		question.ID = generateQuestionID()
+		question.QuizID = quizID
+		question.QuestionNum = i + 1
+		question.OriginID = original.StatsID()
+		if err := db.CreateQuestion(&question); err != nil {
+			return err
+		}
+	}
+
+	if err := db.UpdateQuizNumQuestions(quizID, len(selected)); err != nil {
+		return err
+	}
+	return db.UpdateQuizStatus(quizID, "completed")
+}
@@ -0,0 +1,380 @@
+package quizgenerator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBGenerateQuiz(t *testing.T) {
+	env := newTestEnv(t, withCassette("volcanoes.json"), withQuiz("quiz1", "Volcanoes", 4))
+
+	req := GenerationRequest{Topic: "Volcanoes", NumQuestions: 4, QuestionTypes: []QuestionType{QuestionSingleChoice, QuestionMultiSelect}}
+	env.db.GenerateQuiz("quiz1", req)
+
+	quiz, err := env.db.GetQuiz("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuiz failed: %v", err)
+	}
+	if quiz.Status != "completed" || quiz.NumQuestions != 4 {
+		t.Errorf("quiz status %q with %d questions, want completed with 4", quiz.Status, quiz.NumQuestions)
+	}
+	if quiz.PromptTokens == 0 || quiz.StageUsage == "" {
+		t.Errorf("quiz usage wasn't recorded: %d prompt tokens, stage usage %q", quiz.PromptTokens, quiz.StageUsage)
+	}
+
+	questions, err := env.db.GetQuestions("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuestions failed: %v", err)
+	}
+	if len(questions) != 4 {
+		t.Fatalf("stored %d questions, want 4", len(questions))
+	}
+	perType := make(map[QuestionType]int)
+	for i, dbQuestion := range questions {
+		if dbQuestion.QuestionNum != i+1 {
+			t.Errorf("question %d is numbered %d", i+1, dbQuestion.QuestionNum)
+		}
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			t.Fatalf("ToQuestion failed: %v", err)
+		}
+		perType[question.Kind()]++
+		if question.Kind() == QuestionMultiSelect && len(question.CorrectAnswers) != 2 {
+			t.Errorf("multi-select question %q was stored with correct answers %v", question.Text, question.CorrectAnswers)
+		}
+		if question.Text == "" || len(question.Options) != 4 || question.CorrectAnswer < 0 || question.CorrectAnswer > 3 {
+			t.Errorf("question %d was stored as %+v", i+1, question)
+		}
+	}
+	if perType[QuestionSingleChoice] == 0 || perType[QuestionMultiSelect] == 0 {
+		t.Errorf("stored questions per type = %v, want both requested types", perType)
+	}
+
+	events, err := env.db.GetGenerationEvents("quiz1", 0)
+	if err != nil {
+		t.Fatalf("GetGenerationEvents failed: %v", err)
+	}
+	if len(events) == 0 || events[0].Type != EventBatchRequested {
+		t.Errorf("generation events %+v don't start with %s", events, EventBatchRequested)
+	}
+	if latest, err := env.db.GetGenerationEvents("quiz1", 2); err != nil || len(latest) != 2 || !reflect.DeepEqual(latest[1], events[len(events)-1]) {
+		t.Errorf("GetGenerationEvents with limit 2 = %+v (%v), want the last two events", latest, err)
+	}
+}
+
+func TestDBGenerateQuizStoresAnswerKeys(t *testing.T) {
+	env := newTestEnv(t,
+		withConfig(func(cfg *Config) {
+			cfg.Verification = VerificationConfig{Enabled: true, OnMismatch: MismatchReject}
+		}),
+		withQuiz("quiz1", "Mountains", 4),
+	)
+
+	req := GenerationRequest{Topic: "Mountains", NumQuestions: 4, QuestionTypes: []QuestionType{QuestionShortAnswer, QuestionNumeric}}
+	env.db.GenerateQuiz("quiz1", req)
+
+	questions, err := env.db.GetQuestions("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuestions failed: %v", err)
+	}
+	if len(questions) != 4 {
+		t.Fatalf("stored %d questions, want 4", len(questions))
+	}
+	for _, dbQuestion := range questions {
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			t.Fatalf("ToQuestion failed: %v", err)
+		}
+		if len(question.Options) != 0 {
+			t.Errorf("%s question %q was stored with options %q", question.Kind(), question.Text, question.Options)
+		}
+		switch question.Kind() {
+		case QuestionShortAnswer:
+			if len(question.AcceptedAnswers) != 2 {
+				t.Errorf("short-answer question %q was stored with accepted answers %q", question.Text, question.AcceptedAnswers)
+			}
+		case QuestionNumeric:
+			if question.Numeric == nil || question.Numeric.Tolerance != 1 || question.Numeric.Unit != "m" {
+				t.Errorf("numeric question %q was stored with answer %+v", question.Text, question.Numeric)
+			}
+		default:
+			t.Errorf("question %q has type %s", question.Text, question.Kind())
+		}
+		// The fake solver types the right answer, which the rules accept
+		if !dbQuestion.SolverAgreed || dbQuestion.SolverAnswer == "" {
+			t.Errorf("question %q stored solver answer %q (agreed %t)", question.Text, dbQuestion.SolverAnswer, dbQuestion.SolverAgreed)
+		}
+	}
+}
+
+func TestDBGenerateQuizStoresImages(t *testing.T) {
+	env := newTestEnv(t, withQuiz("quiz1", "Volcanoes", 3))
+	images := []QuestionImage{{Name: strings.Repeat("a", 64) + ".png", Alt: "A caldera"}, {Name: strings.Repeat("b", 64) + ".png"}}
+
+	env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 3, SourceImages: images})
+
+	questions, err := env.db.GetQuestions("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuestions failed: %v", err)
+	}
+	shown := 0
+	for _, dbQuestion := range questions {
+		question, err := dbQuestion.ToQuestion()
+		if err != nil {
+			t.Fatalf("ToQuestion failed: %v", err)
+		}
+		if question.Image == nil {
+			continue
+		}
+		shown++
+		// The fake maker shows the first image with the first question of the batch
+		if !reflect.DeepEqual(*question.Image, images[0]) {
+			t.Errorf("question %q shows image %+v, want %+v", question.Text, question.Image, images[0])
+		}
+	}
+	if shown != 1 {
+		t.Errorf("%d questions show an image, want 1", shown)
+	}
+	for i, want := range []int{1, 0} {
+		if uses, err := env.db.CountImageUses(images[i].Name); err != nil || uses != want {
+			t.Errorf("CountImageUses(%s) = %d (%v), want %d", images[i].Alt, uses, err, want)
+		}
+	}
+
+	// The LLM stages can't see images, so they are given the descriptions
+	described := 0
+	for _, req := range env.provider.Requests() {
+		prompt := req.Messages[len(req.Messages)-1].Content
+		switch req.Tool.Name {
+		case "submit_questions":
+			if !strings.Contains(prompt, "1. A caldera\n2. (no description)") {
+				t.Errorf("maker prompt doesn't describe the source images:\n%s", prompt)
+			}
+		case "evaluate_question":
+			if strings.Contains(prompt, "Image shown with the question: A caldera") {
+				described++
+			}
+		}
+	}
+	if described != 1 {
+		t.Errorf("checker was told about the image for %d questions, want 1", described)
+	}
+}
+
+func TestDBGenerateQuizFailure(t *testing.T) {
+	tests := map[string]func(cfg *Config){
+		"missing cassette": func(cfg *Config) {
+			cfg.Provider = ProviderOptions{Cassette: "missing.json", CassetteMode: CassetteReplay}
+		},
+		"permanent error": func(cfg *Config) {
+			fp := NewFakeProvider()
+			fp.Handle("submit_questions", func(req ChatRequest) (string, error) {
+				return "", &LLMError{Kind: ErrorPermanent, Err: errors.New("invalid API key")}
+			})
+			cfg.Provider.Provider = fp
+		},
+	}
+	for name, configure := range tests {
+		env := newTestEnv(t, withQuiz("quiz1", "Volcanoes", 3), withConfig(configure))
+
+		env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+
+		quiz, err := env.db.GetQuiz("quiz1")
+		if err != nil {
+			t.Fatalf("GetQuiz failed: %v", err)
+		}
+		if quiz.Status != "failed" || quiz.NumQuestions != 0 {
+			t.Errorf("%s: quiz status %q with %d questions, want failed with none", name, quiz.Status, quiz.NumQuestions)
+		}
+	}
+}
+
+func TestDBGenerateQuizBudgetExhausted(t *testing.T) {
+	env := newTestEnv(t, withQuiz("quiz1", "Volcanoes", 20))
+
+	env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 20, Budget: Budget{MaxTokens: 4000}})
+
+	quiz, err := env.db.GetQuiz("quiz1")
+	if err != nil {
+		t.Fatalf("GetQuiz failed: %v", err)
+	}
+	if quiz.Status != "budget_exhausted" || quiz.NumQuestions == 0 || quiz.NumQuestions >= 20 {
+		t.Errorf("quiz status %q with %d questions, want budget_exhausted with some but fewer than 20", quiz.Status, quiz.NumQuestions)
+	}
+	if !quiz.Playable() {
+		t.Errorf("quiz cut short by its budget isn't playable")
+	}
+	if count, err := env.db.GetQuizActualQuestionCount("quiz1"); err != nil || count != quiz.NumQuestions {
+		t.Errorf("quiz records %d questions but %d are stored (%v)", quiz.NumQuestions, count, err)
+	}
+}
+
+func TestDBGenerateQuizChecksOtherQuizzes(t *testing.T) {
+	env := newTestEnv(t,
+		withConfig(func(cfg *Config) {
+			cfg.CrossQuiz = CrossQuizDedupConfig{Enabled: true}
+			cfg.Workers = 1
+		}),
+		withQuestions("old", "Volcanoes", "", time.Now().Add(-time.Hour), 2),
+		withQuiz("quiz1", "Volcanoes", 3),
+	)
+	env.provider.EnqueueDedup(true, "old-q2", "Asked in an earlier quiz")
+
+	env.db.GenerateQuiz("quiz1", GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+
+	if quiz, err := env.db.GetQuiz("quiz1"); err != nil || quiz.Status != "completed" || quiz.NumQuestions != 3 {
+		t.Fatalf("quiz = %+v (%v), want completed with 3 questions", quiz, err)
+	}
+	events, err := env.db.GetGenerationEvents("quiz1", 0)
+	if err != nil {
+		t.Fatalf("GetGenerationEvents failed: %v", err)
+	}
+	var duplicates []GenerationEvent
+	for _, event := range events {
+		if event.Type == EventDuplicateFound {
+			duplicates = append(duplicates, event)
+		}
+	}
+	if len(duplicates) != 1 || duplicates[0].DuplicateQuizID != "old" || duplicates[0].DuplicateQuestionNum != 2 {
+		t.Errorf("duplicate events = %+v, want one pointing at question 2 of quiz old", duplicates)
+	}
+}
+
+func TestGetQuestionBank(t *testing.T) {
+	now := time.Now()
+	env := newTestEnv(t,
+		withQuestions("new", "Volcanoes of Iceland", "Geology", now, 2),
+		withQuestions("older", "Active volcanoes", "geology", now.Add(-time.Hour), 2),
+		withQuestions("oldest", "Roman history", "History", now.Add(-2*time.Hour), 2),
+	)
+
+	tests := []struct {
+		scope string
+		limit int
+		want  []string
+	}{
+		{ScopeAll, 0, []string{"older-q1", "older-q2", "oldest-q1", "oldest-q2"}},
+		{ScopeAll, 3, []string{"older-q1", "older-q2", "oldest-q1"}},
+		{ScopeCategory, 0, []string{"older-q1", "older-q2"}},
+		{ScopeTopic, 0, []string{"older-q1", "older-q2"}},
+	}
+	for _, tt := range tests {
+		bank, err := env.db.GetQuestionBank("new", tt.scope, tt.limit)
+		if err != nil {
+			t.Fatalf("GetQuestionBank failed: %v", err)
+		}
+		var ids []string
+		for _, question := range bank {
+			ids = append(ids, question.Question.ID)
+			if question.Question.Topic == "" || question.QuizID == "" || question.QuestionNum == 0 {
+				t.Errorf("bank question %s is missing its quiz: %+v", question.Question.ID, question)
+			}
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("GetQuestionBank(%q, %d) = %v, want %v", tt.scope, tt.limit, ids, tt.want)
+		}
+	}
+}
+
+func TestQuestionBankTopicScope(t *testing.T) {
+	now := time.Now()
+	env := newTestEnv(t,
+		withQuestions("sagas", "Icelandic sagas", "", now, 1),
+		withQuestions("volcanoes", "Volcanoes of ICELAND", "", now.Add(-time.Hour), 1),
+		withQuestions("eruptions", "Grandes Éruptions", "", now.Add(-2*time.Hour), 1),
+		withQuestions("history", "Roman history", "", now.Add(-3*time.Hour), 1),
+	)
+
+	tests := map[string][]string{
+		// "Icelandic" contains "iceland" but isn't the same word
+		"Iceland":               {"volcanoes-q1"},
+		"éruptions volcaniques": {"eruptions-q1"},
+		"The Alps":              nil,
+	}
+	for topic, want := range tests {
+		bank, err := env.db.questionBank("", topic, "", ScopeTopic, 0)
+		if err != nil {
+			t.Fatalf("questionBank failed: %v", err)
+		}
+		var ids []string
+		for _, question := range bank {
+			ids = append(ids, question.Question.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("questionBank on topic %q = %v, want %v", topic, ids, want)
+		}
+	}
+}
+
+func TestCalibratedQuiz(t *testing.T) {
+	env := newTestEnv(t,
+		withQuestions("volcanoes", "Volcanoes", "", time.Now(), 3),
+		withQuestions("history", "Roman history", "", time.Now(), 1),
+	)
+
+	// Question 1 is easy, question 2 hard and question 3 hasn't been answered often enough
+	record := func(questionID string, scores ...float64) {
+		for _, score := range scores {
+			if err := env.db.RecordAnswer(AnswerOutcome{QuestionID: questionID, QuizID: "volcanoes", Score: score, Game: GameSinglePlayer}); err != nil {
+				t.Fatalf("RecordAnswer failed: %v", err)
+			}
+		}
+	}
+	record("volcanoes-q1", 1, 1, 1, 1, 1)
+	record("volcanoes-q2", 0, 0, 0, 0, 1)
+	record("volcanoes-q3", 0, 0)
+	record("history-q1", 1, 1, 1, 1, 1)
+
+	selected, err := env.db.FindCalibratedQuestions("Active volcanoes", 5, 0.25)
+	if err != nil {
+		t.Fatalf("FindCalibratedQuestions failed: %v", err)
+	}
+	// Only the volcano quiz shares a word with the topic, and is picked easiest first
+	if len(selected) != 2 || selected[0].QuizID != "volcanoes" || selected[0].QuestionNum != 1 || selected[1].QuestionNum != 2 {
+		t.Fatalf("FindCalibratedQuestions picked %+v, want questions 1 and 2 of the volcano quiz", selected)
+	}
+	if selected, err := env.db.FindCalibratedQuestions("Glaciers", 5, 0.25); err != nil || len(selected) != 0 {
+		t.Errorf("FindCalibratedQuestions on an unplayed topic = %+v (%v), want none", selected, err)
+	}
+
+	if err := env.db.CreateQuiz(&DBQuiz{ID: "calibrated", Topic: "Active volcanoes", NumQuestions: len(selected), Status: "generating", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateQuiz failed: %v", err)
+	}
+	if err := env.db.AssembleCalibratedQuiz("calibrated", selected); err != nil {
+		t.Fatalf("AssembleCalibratedQuiz failed: %v", err)
+	}
+	questions, err := env.db.GetQuestions("calibrated")
+	if err != nil {
+		t.Fatalf("GetQuestions failed: %v", err)
+	}
+	if len(questions) != 2 || questions[0].OriginID != "volcanoes-q1" || questions[1].OriginID != "volcanoes-q2" {
+		t.Fatalf("calibrated quiz questions = %+v", questions)
+	}
+	if questions[0].ID == "volcanoes-q1" || questions[0].Text != "Question 1 about Volcanoes?" {
+		t.Errorf("copied question = %+v, want a copy of the original under a new ID", questions[0])
+	}
+
+	// Answers to the copies count towards the originals
+	if err := env.db.RecordAnswer(AnswerOutcome{QuestionID: questions[0].StatsID(), QuizID: "calibrated", Selected: []int{1}, Score: 0}); err != nil {
+		t.Fatalf("RecordAnswer failed: %v", err)
+	}
+	stats, err := env.db.GetQuestionStats("calibrated")
+	if err != nil {
+		t.Fatalf("GetQuestionStats failed: %v", err)
+	}
+	if len(stats) != 2 || stats["volcanoes-q1"] == nil || stats["volcanoes-q1"].Answers != 6 || stats["volcanoes-q1"].SelectionRate(1) != 1.0/6 {
+		t.Errorf("stats of the calibrated quiz = %+v, want the originals' answers", stats)
+	}
+
+	quiz, err := env.db.GetQuiz("calibrated")
+	if err != nil {
+		t.Fatalf("GetQuiz failed: %v", err)
+	}
+	if quiz.Status != "completed" || quiz.NumQuestions != 2 {
+		t.Errorf("calibrated quiz is %s with %d questions, want completed with 2", quiz.Status, quiz.NumQuestions)
+	}
+}
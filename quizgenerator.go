@@ -2,35 +2,198 @@ package quizgenerator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrInsufficientQuestions is returned by GenerateQuiz when fewer questions than requested were accepted
+var ErrInsufficientQuestions = errors.New("insufficient questions generated")
+
+// GenerationResult is the outcome of a GenerateQuizStream run
+type GenerationResult struct {
+	Stats GenerationStats
+	Err   error // Why generation stopped early; nil if it finished or ran out of questions to request
+}
+
 // QuizGenerator orchestrates the generation and validation of quiz questions
 type QuizGenerator struct {
+	planner *TopicPlanner // Nil unless subtopic planning is enabled
 	maker   *QuestionMaker
-	checker *QuestionChecker
+	checker Checker
+	linter  *QuestionLinter
+	solver  *QuestionSolver // Nil unless blind verification is enabled
 	dedup   *QuestionDedup
 	pool    *QuestionPool
 	logger  *LLMLogger
+	retry   RetryPolicy
+	usage   *UsageTracker
+	err     error           // Terminal error from the last GenerateQuizStream run
+	stats   GenerationStats // Final stats of the last GenerateQuizStream run
+
+	defaultBudget Budget       // Used when a request has no budget of its own
+	budget        Budget       // Budget of the current request
+	daily         *DailyBudget // Optional global daily budget
+	workers       int          // Number of questions checked in parallel
+	lowWaterMark  int          // Prefetch the next batch when this few questions are left to check
+	chunkChars    int          // Size of source material chunks; 0 disables chunking
+	onEvent       EventHandler // Optional receiver of generation events
+	verification  VerificationConfig
+	maxSubtopics  int // Largest subtopic outline to ask the planner for
+}
+
+// checkResult is the outcome of checking one question on a worker
+type checkResult struct {
+	question   *Question
+	validation *ValidationResult
+	err        error
+}
+
+// batchResult is the outcome of one background call to the question maker
+type batchResult struct {
+	questions []*Question
+	err       error
+}
+
+// NewQuizGenerator creates a new quiz generator from the given config; nil uses DefaultConfig
+func NewQuizGenerator(cfg *Config) (*QuizGenerator, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	provider, err := NewProvider(cfg.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	qg := &QuizGenerator{
+		pool:          NewQuestionPool(),
+		retry:         cfg.Retry,
+		usage:         NewUsageTracker(cfg.Prices),
+		defaultBudget: cfg.Budget,
+		workers:       cfg.Workers,
+		lowWaterMark:  cfg.LowWaterMark,
+		chunkChars:    cfg.ChunkChars,
+		verification:  cfg.Verification,
+		maxSubtopics:  cfg.Planning.MaxSubtopics,
+	}
+	if qg.workers < 1 {
+		qg.workers = 1
+	}
+
+	// Check the budget and record usage inside the retry loop so every attempt is covered
+	wrap := func(provider LLMProvider) LLMProvider {
+		provider = NewUsageProvider(provider, qg.usage)
+		provider = &budgetProvider{inner: provider, check: qg.checkBudget}
+		return NewRetryProvider(provider, cfg.Retry)
+	}
+	provider = wrap(provider)
+
+	if cfg.Planning.Enabled {
+		qg.planner = NewTopicPlanner(provider, cfg.ResolveStage(cfg.Planner, DefaultModel))
+	}
+	qg.maker = NewQuestionMaker(provider, cfg.ResolveStage(cfg.Maker, DefaultModel), cfg.MakerHistory)
+	checkerStage := cfg.ResolveStage(cfg.Checker, DefaultModel)
+	if cfg.Consensus.Enabled() {
+		consensus := NewConsensusChecker(cfg.Consensus.Voting)
+		for i, judge := range cfg.Consensus.Judges {
+			judgeProvider := provider
+			stage := checkerStage
+			if judge.Provider != nil {
+				base, err := NewProvider(*judge.Provider)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create provider for judge %d: %w", i+1, err)
+				}
+				judgeProvider = wrap(base)
+				stage.Model = judge.Provider.ModelOr(stage.Model)
+			}
+			if judge.Model != "" {
+				stage.Model = judge.Model
+			}
+			if judge.Temperature != 0 {
+				stage.Temperature = judge.Temperature
+			}
+			name := judge.Name
+			if name == "" {
+				name = fmt.Sprintf("%d-%s", i+1, stage.Model)
+			}
+			consensus.AddJudge(name, NewQuestionChecker(judgeProvider, stage))
+		}
+		qg.checker = consensus
+	} else {
+		qg.checker = NewQuestionChecker(provider, checkerStage)
+	}
+	qg.linter = NewQuestionLinter(cfg.Linter)
+	if cfg.Verification.Enabled {
+		qg.solver = NewQuestionSolver(provider, cfg.ResolveStage(cfg.Solver, DefaultModel))
+	}
+	qg.dedup = NewQuestionDedup(provider, cfg.ResolveStage(cfg.Dedup, DefaultFastModel), cfg.DedupFilter)
+	return qg, nil
+}
+
+// AddQuestionBank makes dedup also reject questions that duplicate ones from other quizzes
+func (qg *QuizGenerator) AddQuestionBank(ctx context.Context, questions []BankQuestion) error {
+	return qg.dedup.AddBank(ctx, questions)
 }
 
-// NewQuizGenerator creates a new quiz generator
-func NewQuizGenerator(apiKey string) *QuizGenerator {
-	return &QuizGenerator{
-		maker:   NewQuestionMaker(apiKey),
-		checker: NewQuestionChecker(apiKey),
-		dedup:   NewQuestionDedup(apiKey),
-		pool:    NewQuestionPool(),
+// SetDailyBudget makes the generator stop when the shared daily budget is spent
+func (qg *QuizGenerator) SetDailyBudget(daily *DailyBudget) {
+	qg.daily = daily
+}
+
+// checkBudget returns ErrBudgetExhausted once the request or daily budget is spent
+func (qg *QuizGenerator) checkBudget() error {
+	if qg.budget.Exceeded(qg.usage.Summary()) {
+		return ErrBudgetExhausted
+	}
+	if qg.daily != nil && qg.daily.Exceeded() {
+		return ErrBudgetExhausted
+	}
+	return nil
+}
+
+// SetEventHandler sets a function that receives every generation event; nil disables events
+func (qg *QuizGenerator) SetEventHandler(handler EventHandler) {
+	qg.onEvent = handler
+}
+
+// emit stamps an event and passes it to the event handler, if any
+func (qg *QuizGenerator) emit(event GenerationEvent) {
+	if qg.onEvent == nil {
+		return
 	}
+	event.Time = time.Now()
+	qg.onEvent(event)
+}
+
+// Usage returns the token usage and cost of every LLM call made by this generator
+func (qg *QuizGenerator) Usage() UsageSummary {
+	return qg.usage.Summary()
+}
+
+// Err returns the error that ended the last GenerateQuizStream run, if any.
+// It is only meaningful once the question channel has been closed.
+func (qg *QuizGenerator) Err() error {
+	return qg.err
+}
+
+// Result returns the error and stats of the last GenerateQuizStream run.
+// It is only meaningful once the question channel has been closed.
+func (qg *QuizGenerator) Result() GenerationResult {
+	return GenerationResult{Stats: qg.stats, Err: qg.err}
 }
 
 // SetLogger sets the logger for this quiz generator
 func (qg *QuizGenerator) SetLogger(logger *LLMLogger) {
 	qg.logger = logger
+	logger.SetUsageTracker(qg.usage)
 }
 
-// GenerateQuiz generates a complete quiz with the specified number of questions
+// GenerateQuiz generates a complete quiz with the specified number of questions.
+// If fewer questions are accepted it returns the partial quiz along with an
+// error wrapping ErrInsufficientQuestions and the cause, if any; the quiz is
+// nil only when no questions were accepted at all.
 func (qg *QuizGenerator) GenerateQuiz(ctx context.Context, req GenerationRequest) (*Quiz, error) {
 	VerboseLog("Starting quiz generation for topic: %s, target questions: %d", req.Topic, req.NumQuestions)
 
@@ -41,7 +204,7 @@ func (qg *QuizGenerator) GenerateQuiz(ctx context.Context, req GenerationRequest
 		VerboseLog("Failed to create logger: %v", err)
 		// Continue without logging rather than failing
 	} else {
-		qg.logger = logger
+		qg.SetLogger(logger)
 		defer logger.Close()
 	}
 
@@ -57,9 +220,24 @@ func (qg *QuizGenerator) GenerateQuiz(ctx context.Context, req GenerationRequest
 		acceptedQuestions = append(acceptedQuestions, question)
 	}
 
+	result := qg.Result()
+	VerboseLog("Generation stats: requested %d, generated %d, accepted %d, rejected %d, revised %d, duplicates %d, dropped %d, flagged %d",
+		result.Stats.Requested, result.Stats.Generated, result.Stats.Accepted, result.Stats.Rejected,
+		result.Stats.Revised, result.Stats.Duplicates, result.Stats.Dropped, result.Stats.Flagged)
+
+	if len(acceptedQuestions) < req.NumQuestions {
+		err = fmt.Errorf("%w: got %d of %d", ErrInsufficientQuestions, len(acceptedQuestions), req.NumQuestions)
+		if result.Err != nil {
+			err = fmt.Errorf("%w: %w", err, result.Err)
+		}
+		if len(acceptedQuestions) == 0 {
+			return nil, err
+		}
+	}
+
 	// Create the final quiz
-	questions := make([]Question, req.NumQuestions)
-	for i, q := range acceptedQuestions[:req.NumQuestions] {
+	questions := make([]Question, len(acceptedQuestions))
+	for i, q := range acceptedQuestions {
 		questions[i] = *q
 	}
 
@@ -68,11 +246,12 @@ func (qg *QuizGenerator) GenerateQuiz(ctx context.Context, req GenerationRequest
 		Topic:          req.Topic,
 		Questions:      questions,
 		CreatedAt:      time.Now(),
-		TotalQuestions: req.NumQuestions,
+		TotalQuestions: len(questions),
+		Coverage:       result.Stats.Coverage,
 	}
 
 	VerboseLog("Quiz generation complete: %d questions for topic '%s'", len(quiz.Questions), quiz.Topic)
-	return quiz, nil
+	return quiz, err
 }
 
 // GenerateQuizStream generates questions and yields them as they become available
@@ -87,109 +266,393 @@ func (qg *QuizGenerator) GenerateQuizStream(ctx context.Context, req GenerationR
 			VerboseLog("Failed to create logger: %v", err)
 			// Continue without logging rather than failing
 		} else {
-			qg.logger = logger
+			qg.SetLogger(logger)
 		}
 	}
 
+	qg.err = nil
+	qg.stats = GenerationStats{}
+	qg.budget = req.Budget
+	if qg.budget.IsZero() {
+		qg.budget = qg.defaultBudget
+	}
+	if qg.daily != nil {
+		qg.daily.Attach(qg.usage)
+	}
+
 	go func() {
 		defer close(questionChan)
+
+		stats := GenerationStats{}
+		start := time.Now()
+		defer func() {
+			stats.Usage = qg.usage.Summary()
+			stats.Duration = time.Since(start)
+			qg.stats = stats
+			event := GenerationEvent{Type: EventFinished, Stats: &stats}
+			if qg.err != nil {
+				event.Error = qg.err.Error()
+			}
+			qg.emit(event)
+		}()
 		if qg.logger != nil {
 			defer qg.logger.Close()
 		}
+		if qg.daily != nil {
+			defer qg.daily.Detach(qg.usage)
+		}
+
+		// Check questions in parallel; results are handled here so dedup stays serialized
+		workCtx, cancel := context.WithCancel(ctx)
+		jobs := make(chan *Question)
+		results := make(chan checkResult, qg.workers)
+		var background sync.WaitGroup
+		for i := 0; i < qg.workers; i++ {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				for question := range jobs {
+					validation, err := qg.checkQuestion(workCtx, question)
+					results <- checkResult{question: question, validation: validation, err: err}
+				}
+			}()
+		}
+		// Abandon checks and batches still in flight once generation ends
+		defer func() {
+			close(jobs)
+			cancel()
+			background.Wait()
+		}()
+
+		// fail ends generation with err
+		fail := func(err error) {
+			qg.err = err
+			if errors.Is(err, ErrBudgetExhausted) {
+				qg.emit(GenerationEvent{Type: EventBudgetExhausted, Error: err.Error()})
+			}
+		}
 
-		acceptedCount := 0
-		totalQuestionsRequested := 0
+		inFlight := 0
 		maxQuestionsToRequest := req.NumQuestions * 3
 
-		for acceptedCount < req.NumQuestions {
-			// Check if we've already requested 3x the target number of questions
-			if totalQuestionsRequested >= maxQuestionsToRequest {
-				VerboseLog("Already requested %d questions (max: %d), stopping generation. Generated %d accepted questions.",
-					totalQuestionsRequested, maxQuestionsToRequest, acceptedCount)
+		// The maker keeps a conversation, so at most one batch is generated at a time
+		batches := make(chan batchResult, 1)
+		generating := false
+		// Spread questions across long source material instead of sending it whole
+		chunks := ChunkSource(req.SourceMaterial, qg.chunkChars)
+		var chunkPlan *coveragePlan
+		if len(chunks) > 1 {
+			chunkPlan = newCoveragePlan(len(chunks), req.NumQuestions)
+			VerboseLog("Split %d bytes of source material into %d chunks", len(req.SourceMaterial), len(chunks))
+		}
+
+		// Spread questions across an outline of subtopics. Chunked source
+		// material already spreads them across the document, and the planner
+		// can't see all of it, so the two aren't combined.
+		var subtopics []Subtopic
+		var topicPlan *coveragePlan
+		if qg.planner != nil && chunkPlan == nil && req.NumQuestions > 1 {
+			var err error
+			subtopics, err = qg.planner.Plan(workCtx, req, qg.maxSubtopics, qg.logger)
+			if err != nil {
+				if IsPermanentLLMError(err) || errors.Is(err, ErrBudgetExhausted) || ctx.Err() != nil {
+					fail(err)
+					return
+				}
+				// Questions can still be generated without a plan
+				VerboseLog("Failed to plan subtopics, generating without a plan: %v", err)
+			} else {
+				topicPlan = newCoveragePlan(len(subtopics), req.NumQuestions)
+				names := make([]string, len(subtopics))
+				for i, subtopic := range subtopics {
+					names[i] = subtopic.Name
+				}
+				qg.emit(GenerationEvent{Type: EventPlanCreated, Subtopics: subtopics, Reason: strings.Join(names, "; ")})
+			}
+		} else if qg.planner != nil && chunkPlan != nil {
+			VerboseLog("Source material is split into chunks, generating without a subtopic plan")
+		}
+		subtopicIndex := make(map[string]int, len(subtopics))
+		for i, subtopic := range subtopics {
+			subtopicIndex[subtopic.Name] = i
+		}
+		defer func() {
+			if topicPlan == nil {
 				return
 			}
-
-			// Generate new questions if pool is empty
-			if qg.pool.IsEmpty() {
-				questionsRequired := req.NumQuestions - acceptedCount
-				if questionsRequired < 3 {
-					questionsRequired = 3
+			stats.Coverage = make([]SubtopicCoverage, len(subtopics))
+			for i, subtopic := range subtopics {
+				stats.Coverage[i] = SubtopicCoverage{Subtopic: subtopic.Name, Quota: topicPlan.quota[i], Accepted: topicPlan.accepted[i]}
+				if topicPlan.accepted[i] < topicPlan.quota[i] {
+					VerboseLog("Subtopic %q is short of questions: %d of %d accepted", subtopic.Name, topicPlan.accepted[i], topicPlan.quota[i])
 				}
-				totalQuestionsRequested += questionsRequired
+			}
+		}()
 
-				VerboseLog("Pool is empty, generating new batch of %d questions (requested so far: %d/%d)",
-					questionsRequired, totalQuestionsRequested, maxQuestionsToRequest)
-				questions, err := qg.maker.GenerateQuestions(ctx, req, questionsRequired, qg.logger)
-				if err != nil {
-					VerboseLog("Failed to generate questions: %v", err)
-					return
+		// track applies a coverage plan update to each plan following the question
+		track := func(question *Question, update func(plan *coveragePlan, part int)) {
+			if chunkPlan != nil {
+				update(chunkPlan, question.chunk)
+			}
+			if topicPlan != nil {
+				if i, ok := subtopicIndex[question.Subtopic]; ok {
+					update(topicPlan, i)
 				}
+			}
+		}
 
-				// Add to pool
-				for _, question := range questions {
-					qg.pool.Add(question)
+		// full reports whether the question's part has met its quota in a plan whose other parts are still short
+		full := func(question *Question) bool {
+			full := false
+			track(question, func(plan *coveragePlan, part int) {
+				full = full || plan.full(part)
+			})
+			return full
+		}
+
+		// deliver streams an accepted question and reports whether the caller is still listening
+		deliver := func(question *Question) bool {
+			select {
+			case questionChan <- question:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return false
+			}
+			stats.Accepted++
+			qg.emit(GenerationEvent{Type: EventQuestionAccepted, QuestionID: question.ID})
+			qg.maker.RecordAccepted(question)
+			track(question, (*coveragePlan).accept)
+			if question.Verification != nil && !question.Verification.Agreed {
+				stats.Flagged++
+				qg.emit(GenerationEvent{Type: EventQuestionFlagged, QuestionID: question.ID, Reason: solverMismatch(question)})
+			}
+			return true
+		}
+
+		// Accepted questions held back because their part met its quota while
+		// others are still short; they fill in once the short parts can't be
+		// filled any more, or whatever is missing when generation ends
+		var reserve []*Question
+		release := func(all bool) bool {
+			kept := reserve[:0]
+			for _, question := range reserve {
+				if stats.Accepted >= req.NumQuestions || (!all && full(question)) {
+					kept = append(kept, question)
+					continue
 				}
+				if !deliver(question) {
+					return false
+				}
+			}
+			reserve = kept
+			return true
+		}
+		defer func() {
+			if qg.err == nil || errors.Is(qg.err, ErrBudgetExhausted) {
+				release(true)
+			}
+		}()
+
+		// Number of times each question has been put back after a failed check
+		checkFailures := make(map[string]int)
+		requeue := func(question *Question, err error) {
+			qg.emit(GenerationEvent{Type: EventCheckFailed, QuestionID: question.ID, Error: err.Error()})
+			checkFailures[question.ID]++
+			if checkFailures[question.ID] > qg.retry.MaxQuestionRetries {
+				VerboseLog("Dropping question %s after %d failed checks: %v", question.ID, checkFailures[question.ID], err)
+				stats.Dropped++
+				track(question, (*coveragePlan).settle)
+				return
+			}
+			// Put it back in pool for retry
+			qg.pool.Add(question)
+		}
 
-				VerboseLog("Added %d questions to pool (total requested: %d/%d)",
-					len(questions), totalQuestionsRequested, maxQuestionsToRequest)
+		// requestBatch asks the maker for up to size questions and returns how many it asked for
+		requestBatch := func(size int) int {
+			var chunk *SourceChunk
+			if chunkPlan != nil {
+				var i int
+				i, size = chunkPlan.next(size)
+				chunk = &chunks[i]
+			}
+			var subtopic *Subtopic
+			event := GenerationEvent{Type: EventBatchRequested}
+			if topicPlan != nil {
+				var i int
+				i, size = topicPlan.next(size)
+				subtopic = &subtopics[i]
+				event.Subtopic = subtopic.Name
+			}
+			stats.Requested += size
+			event.BatchSize = size
+			qg.emit(event)
+			generating = true
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				questions, err := qg.maker.GenerateQuestions(workCtx, req, size, chunk, subtopic, qg.logger)
+				batches <- batchResult{questions: questions, err: err}
+			}()
+			return size
+		}
+
+		for stats.Accepted < req.NumQuestions {
+			// Stop cleanly before making more calls once the budget is spent
+			if err := qg.checkBudget(); err != nil {
+				VerboseLog("Budget exhausted after %d accepted questions (usage: %d tokens, $%.4f)",
+					stats.Accepted, qg.usage.Summary().TotalTokens(), qg.usage.Summary().CostUSD)
+				fail(err)
+				return
+			}
+
+			if len(reserve) > 0 {
+				if !release(false) {
+					return
+				}
+				if stats.Accepted >= req.NumQuestions {
+					break
+				}
 			}
 
-			// Process one question at a time and yield accepted ones
-			if !qg.pool.IsEmpty() {
+			// Keep every worker busy while the pool has questions
+			for inFlight < qg.workers && !qg.pool.IsEmpty() {
 				question := qg.pool.Get()
 				if question == nil {
-					continue
+					break
 				}
+				jobs <- question
+				inFlight++
+			}
 
-				// Step 1: Validate the question
-				validation, err := qg.checker.CheckQuestion(ctx, question, qg.logger)
-				if err != nil {
-					VerboseLog("Error checking question %s: %v", question.ID, err)
-					// Put it back in pool for retry
-					qg.pool.Add(question)
-					continue
+			// Project how many of the questions still waiting will be accepted,
+			// assuming the acceptance rate seen so far (all of them before any decisions)
+			pending := qg.pool.Size() + inFlight
+			acceptanceRate := 1.0
+			if decided := stats.Accepted + stats.Rejected + stats.Duplicates; decided > 0 {
+				acceptanceRate = float64(stats.Accepted) / float64(decided)
+			}
+			shortfall := req.NumQuestions - stats.Accepted - int(float64(pending)*acceptanceRate)
+			batchSize := min(max(shortfall, 3), maxQuestionsToRequest-stats.Requested)
+
+			if !generating {
+				if pending == 0 {
+					// Check if we've already requested 3x the target number of questions
+					if batchSize <= 0 {
+						VerboseLog("Already requested %d questions (max: %d), stopping generation. Generated %d accepted questions.",
+							stats.Requested, maxQuestionsToRequest, stats.Accepted)
+						return
+					}
+					size := requestBatch(batchSize)
+					VerboseLog("Pool is empty, generating new batch of %d questions (requested so far: %d/%d)",
+						size, stats.Requested, maxQuestionsToRequest)
+				} else if pending <= qg.lowWaterMark && shortfall > 0 && batchSize > 0 {
+					size := requestBatch(batchSize)
+					VerboseLog("Pool is low (%d pending, projected shortfall %d), prefetching batch of %d questions (requested so far: %d/%d)",
+						pending, shortfall, size, stats.Requested, maxQuestionsToRequest)
 				}
+			}
 
-				// If validation failed, skip to next question
-				if validation.Action != ActionAccept {
-					if validation.Action == ActionRevise && validation.RevisedQuestion != nil {
-						// Add revised question back to pool
-						qg.pool.Add(validation.RevisedQuestion)
-						VerboseLog("Question %s revised (attempt %d), added back to pool", question.ID, validation.RevisedQuestion.RevisionCount)
-					} else if validation.Action == ActionReject {
-						VerboseLog("Question %s rejected: %s", question.ID, validation.Reason)
-					}
-					continue
+			// Step 1: Wait for the next validation result or generated batch
+			var result checkResult
+			select {
+			case batch := <-batches:
+				generating = false
+				if batch.err != nil {
+					VerboseLog("Failed to generate questions: %v", batch.err)
+					fail(batch.err)
+					return
 				}
+				stats.Generated += len(batch.questions)
 
-				// Step 2: Check for duplicates
-				dedupResult, err := qg.dedup.CheckDuplicate(ctx, question, qg.logger)
-				if err != nil {
-					VerboseLog("Error checking duplicate for question %s: %v", question.ID, err)
-					// Put it back in pool for retry
+				// Add to pool
+				for _, question := range batch.questions {
 					qg.pool.Add(question)
-					continue
+					track(question, (*coveragePlan).generated)
 				}
 
-				// If it's a duplicate, skip this question
-				if dedupResult.IsDuplicate {
-					VerboseLog("Question %s rejected as duplicate of %s: %s",
-						question.ID, dedupResult.DuplicateID, dedupResult.Reason)
-					continue
+				VerboseLog("Added %d questions to pool (total requested: %d/%d)",
+					len(batch.questions), stats.Requested, maxQuestionsToRequest)
+				continue
+			case result = <-results:
+				inFlight--
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+			question, validation, err := result.question, result.validation, result.err
+			if err != nil {
+				VerboseLog("Error checking question %s: %v", question.ID, err)
+				if IsPermanentLLMError(err) {
+					fail(err)
+					return
 				}
+				requeue(question, err)
+				continue
+			}
 
-				// Question passed both validation and deduplication
-				question.Status = StatusAccepted
-
-				// Randomize answer order to avoid position bias
-				qg.randomizeAnswerOrder(question)
+			// If validation failed, skip to next question
+			if validation.Action != ActionAccept {
+				if validation.Action == ActionRevise && validation.RevisedQuestion != nil {
+					// Add revised question back to pool
+					qg.pool.Add(validation.RevisedQuestion)
+					stats.Revised++
+					qg.emit(GenerationEvent{Type: EventQuestionRevised, QuestionID: question.ID, Reason: validation.Reason})
+					VerboseLog("Question %s revised (attempt %d), added back to pool", question.ID, validation.RevisedQuestion.RevisionCount)
+				} else {
+					track(question, (*coveragePlan).settle)
+					if validation.Action == ActionReject {
+						stats.Rejected++
+						qg.emit(GenerationEvent{Type: EventQuestionRejected, QuestionID: question.ID, Reason: validation.Reason})
+						VerboseLog("Question %s rejected: %s", question.ID, validation.Reason)
+					}
+				}
+				continue
+			}
 
-				select {
-				case questionChan <- question:
-					acceptedCount++
-				case <-ctx.Done():
+			// Step 2: Check for duplicates
+			dedupResult, err := qg.dedup.CheckDuplicate(ctx, question, qg.logger)
+			if err != nil {
+				VerboseLog("Error checking duplicate for question %s: %v", question.ID, err)
+				if IsPermanentLLMError(err) {
+					fail(err)
 					return
 				}
+				requeue(question, err)
+				continue
+			}
+
+			// If it's a duplicate, skip this question
+			if dedupResult.IsDuplicate {
+				stats.Duplicates++
+				track(question, (*coveragePlan).settle)
+				qg.emit(GenerationEvent{
+					Type:                 EventDuplicateFound,
+					QuestionID:           question.ID,
+					DuplicateID:          dedupResult.DuplicateID,
+					DuplicateQuizID:      dedupResult.DuplicateQuizID,
+					DuplicateQuestionNum: dedupResult.DuplicateQuestionNum,
+					Reason:               dedupResult.Reason,
+				})
+				VerboseLog("Question %s rejected as duplicate of %s: %s",
+					question.ID, dedupResult.DuplicateID, dedupResult.Reason)
+				continue
+			}
+
+			// Question passed both validation and deduplication
+			question.Status = StatusAccepted
+			track(question, (*coveragePlan).settle)
+
+			// Randomize answer order to avoid position bias
+			qg.randomizeAnswerOrder(question)
+
+			if full(question) {
+				reserve = append(reserve, question)
+				VerboseLog("Question %s held in reserve, its part of the quiz already has enough questions", question.ID)
+				continue
+			}
+			if !deliver(question) {
+				return
 			}
 		}
 	}()
@@ -197,6 +660,45 @@ func (qg *QuizGenerator) GenerateQuizStream(ctx context.Context, req GenerationR
 	return questionChan, nil
 }
 
+// checkQuestion runs a question through the linter, the checker and, if enabled, the blind solver
+func (qg *QuizGenerator) checkQuestion(ctx context.Context, question *Question) (*ValidationResult, error) {
+	// Only ask the checker about questions that pass the free rule-based checks
+	validation := qg.linter.Lint(question, qg.logger)
+	if validation.Action != ActionAccept {
+		return validation, nil
+	}
+
+	validation, err := qg.checker.CheckQuestion(ctx, question, qg.logger)
+	if err != nil || validation.Action != ActionAccept || qg.solver == nil {
+		return validation, err
+	}
+
+	solved, err := qg.solver.Solve(ctx, question, qg.logger)
+	if err != nil {
+		return nil, err
+	}
+	question.Verification = solved
+	if solved.Agreed || qg.verification.OnMismatch == MismatchFlag || solved.Confidence < qg.verification.MinConfidence {
+		return validation, nil
+	}
+
+	result := &ValidationResult{
+		QuestionID: question.ID,
+		Action:     ActionReject,
+		Reason:     solverMismatch(question),
+	}
+	if qg.logger != nil {
+		qg.logger.LogQuestionResult(question.ID, string(result.Action), result.Reason)
+	}
+	return result, nil
+}
+
+// solverMismatch describes how the blind solver's answer differs from the answer key
+func solverMismatch(question *Question) string {
+	return fmt.Sprintf("Blind solver chose %q instead of %q (confidence %.2f)",
+		question.Verification.Answer, question.CorrectText(), question.Verification.Confidence)
+}
+
 func generateQuizID() string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
 	b := make([]byte, 12)
@@ -206,32 +708,43 @@ func generateQuizID() string {
 	return string(b)
 }
 
-// randomizeAnswerOrder randomizes the order of answer options while preserving the correct answer
+// randomizeAnswerOrder randomizes the order of answer options while preserving
+// the correct answers. Matching questions shuffle their matches instead, as
+// it's the pairing that has to be worked out.
 func (qg *QuizGenerator) randomizeAnswerOrder(question *Question) {
-	if len(question.Options) != 4 {
-		// Don't randomize if we don't have exactly 4 options
+	if question.Kind() == QuestionMatching {
+		indices := rand.Perm(len(question.Matches))
+		newMatches := make([]string, len(indices))
+		newIndex := make(map[int]int, len(indices))
+		for i, oldIndex := range indices {
+			newMatches[i] = question.Matches[oldIndex]
+			newIndex[oldIndex] = i
+		}
+		question.Matches = newMatches
+		for i, oldIndex := range question.CorrectAnswers {
+			question.CorrectAnswers[i] = newIndex[oldIndex]
+		}
 		return
 	}
-
-	// Create a permutation of indices [0,1,2,3]
-	indices := []int{0, 1, 2, 3}
-	for i := len(indices) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		indices[i], indices[j] = indices[j], indices[i]
+	if question.Kind() == QuestionTrueFalse || len(question.Options) < 2 {
+		// True/false options always read "True", "False"
+		return
 	}
 
-	// Create new options and find new correct answer index
-	newOptions := make([]string, 4)
-	var newCorrectAnswer int
-
+	// Create new options and find the new index of each old one
+	indices := rand.Perm(len(question.Options))
+	newOptions := make([]string, len(indices))
+	newIndex := make(map[int]int, len(indices))
 	for i, oldIndex := range indices {
 		newOptions[i] = question.Options[oldIndex]
-		if oldIndex == question.CorrectAnswer {
-			newCorrectAnswer = i
-		}
+		newIndex[oldIndex] = i
 	}
 
 	// Update the question
 	question.Options = newOptions
-	question.CorrectAnswer = newCorrectAnswer
+	question.CorrectAnswer = newIndex[question.CorrectAnswer]
+	for i, oldIndex := range question.CorrectAnswers {
+		question.CorrectAnswers[i] = newIndex[oldIndex]
+	}
+	question.normalizeAnswers()
 }
@@ -0,0 +1,421 @@
+package quizgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenerateQuiz(t *testing.T) {
+	env := newTestEnv(t)
+	generator := env.generator(t)
+
+	quiz, err := generator.GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 5})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if quiz.TotalQuestions != 5 || len(quiz.Questions) != 5 {
+		t.Fatalf("quiz has %d questions, want 5", len(quiz.Questions))
+	}
+	ids := make(map[string]bool)
+	for _, question := range quiz.Questions {
+		if ids[question.ID] {
+			t.Errorf("question ID %s is used twice", question.ID)
+		}
+		ids[question.ID] = true
+		if len(question.Options) != 4 || question.Status != StatusAccepted {
+			t.Errorf("question %q has %d options and status %s", question.Text, len(question.Options), question.Status)
+		}
+		// The fake provider always puts the answer first, so the shuffle must have kept track of it
+		if answer := question.Options[question.CorrectAnswer]; !strings.HasPrefix(answer, "Answer") {
+			t.Errorf("question %q has correct answer %q", question.Text, answer)
+		}
+	}
+
+	usage := generator.Usage()
+	if usage.TotalTokens() == 0 || usage.Stages["QuestionMaker"].Calls == 0 || usage.Stages["QuestionChecker"].Calls != 5 {
+		t.Errorf("usage = %+v, want the maker's calls and one check per question", usage)
+	}
+}
+
+func TestGenerateQuizChecksConcurrently(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Workers = 3
+	}))
+	// concurrency answers a tool slowly and records how many of its calls overlapped
+	var mu sync.Mutex
+	concurrency := func(arguments string) (handler func(ChatRequest) (string, error), peak *int) {
+		active := 0
+		peak = new(int)
+		return func(req ChatRequest) (string, error) {
+			mu.Lock()
+			active++
+			*peak = max(*peak, active)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return arguments, nil
+		}, peak
+	}
+	checker, checks := concurrency(`{"action":"accept","reason":"Fine"}`)
+	dedup, dedups := concurrency(`{"is_duplicate":false,"reason":"Unique"}`)
+	env.provider.Handle("evaluate_question", checker)
+	env.provider.Handle("check_duplicate", dedup)
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 6})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if len(quiz.Questions) != 6 {
+		t.Errorf("quiz has %d questions, want 6", len(quiz.Questions))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if *checks < 2 || *checks > 3 {
+		t.Errorf("%d checks ran at once, want up to the 3 workers", *checks)
+	}
+	if *dedups != 1 {
+		t.Errorf("%d dedup calls ran at once, want them serialized", *dedups)
+	}
+}
+
+func TestGenerateQuizPrefetchesBatches(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Workers = 2
+		cfg.LowWaterMark = 4
+	}))
+	var mu sync.Mutex
+	checks, batches, generated, prefetched := 0, 0, 0, 0
+	env.provider.Handle("evaluate_question", func(req ChatRequest) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		checks++
+		// Reject half the questions so the first batch falls short
+		action := []string{"accept", "reject"}[checks%2]
+		return mustMarshal(map[string]interface{}{"action": action, "reason": "Checked"}), nil
+	})
+	env.provider.Handle("submit_questions", func(req ChatRequest) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Without prefetching, a batch is only requested once every question has been checked
+		if checks < generated {
+			prefetched++
+		}
+		batches++
+		arguments, err := fakeDefaultArguments(req, batches)
+		generated += strings.Count(arguments, `"text"`)
+		return arguments, err
+	})
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 6})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if len(quiz.Questions) != 6 {
+		t.Errorf("quiz has %d questions, want 6", len(quiz.Questions))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if prefetched == 0 {
+		t.Errorf("no batch was requested while questions were still waiting to be checked")
+	}
+}
+
+func TestGenerateQuizReplacesRejectedQuestions(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Workers = 1
+	}))
+	env.provider.EnqueueEvaluation(ActionReject, "The answer is ambiguous", nil)
+	env.provider.EnqueueEvaluation(ActionReject, "The answer is ambiguous", nil)
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 4})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if len(quiz.Questions) != 4 {
+		t.Errorf("quiz has %d questions, want 4", len(quiz.Questions))
+	}
+	evaluations := 0
+	for _, req := range env.provider.Requests() {
+		if req.Tool.Name == "evaluate_question" {
+			evaluations++
+		}
+	}
+	if evaluations != 6 {
+		t.Errorf("checker was called %d times, want 6 for 4 accepted and 2 rejected questions", evaluations)
+	}
+}
+
+func TestGenerateQuizRevisesQuestions(t *testing.T) {
+	env := newTestEnv(t)
+	revised := Question{Text: "Which volcano erupted in 79 AD?", Options: []string{"Vesuvius", "Etna", "Hekla", "Fuji"}, CorrectAnswer: 0, Explanation: "Vesuvius buried Pompeii."}
+	env.provider.EnqueueEvaluation(ActionRevise, "Too vague", &revised)
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	found := false
+	for _, question := range quiz.Questions {
+		if question.Text == revised.Text {
+			found = true
+			if answer := question.Options[question.CorrectAnswer]; answer != "Vesuvius" {
+				t.Errorf("revised question's answer is %q, want Vesuvius", answer)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("quiz doesn't include the revised question")
+	}
+}
+
+func TestGenerateQuizDropsDuplicates(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Workers = 1
+	}))
+	env.provider.EnqueueDedup(true, "", "Asks the same thing")
+	env.provider.EnqueueDedup(true, "", "Asks the same thing")
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 4})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if len(quiz.Questions) != 4 {
+		t.Errorf("quiz has %d questions, want 4", len(quiz.Questions))
+	}
+	checks := 0
+	for _, req := range env.provider.Requests() {
+		if req.Tool.Name == "check_duplicate" {
+			checks++
+		}
+	}
+	// The first question has nothing to duplicate, so it isn't sent to the judge
+	if checks != 5 {
+		t.Errorf("dedup judge was called %d times, want 5 for 3 unique questions and 2 duplicates", checks)
+	}
+}
+
+func TestGenerateQuizEvents(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Workers = 1
+	}))
+	env.provider.EnqueueEvaluation(ActionReject, "The answer is ambiguous", nil)
+	env.provider.EnqueueDedup(true, "", "Asks the same thing")
+	generator := env.generator(t)
+	var events []GenerationEvent
+	generator.SetEventHandler(func(event GenerationEvent) {
+		events = append(events, event)
+	})
+
+	if _, err := generator.GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 4}); err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+
+	counts := make(map[EventType]int)
+	for _, event := range events {
+		counts[event.Type]++
+		if event.Time.IsZero() {
+			t.Errorf("%s event has no time", event.Type)
+		}
+	}
+	if counts[EventQuestionAccepted] != 4 || counts[EventQuestionRejected] != 1 || counts[EventDuplicateFound] != 1 || counts[EventBatchRequested] == 0 {
+		t.Errorf("event counts = %v", counts)
+	}
+	if len(events) == 0 || events[0].Type != EventBatchRequested {
+		t.Fatalf("events don't start with %s: %+v", EventBatchRequested, events)
+	}
+	last := events[len(events)-1]
+	if last.Type != EventFinished || last.Stats == nil {
+		t.Fatalf("events don't end with %s: %+v", EventFinished, last)
+	}
+	if stats := last.Stats; stats.Accepted != 4 || stats.Rejected != 1 || stats.Duplicates != 1 || stats.Generated < 6 || stats.Usage.TotalTokens() == 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestGenerateQuizStopsOnPermanentError(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Handle("submit_questions", func(req ChatRequest) (string, error) {
+		return "", &LLMError{Kind: ErrorPermanent, Err: errors.New("invalid API key")}
+	})
+
+	_, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+	if !IsPermanentLLMError(err) {
+		t.Errorf("GenerateQuiz error = %v, want a permanent error", err)
+	}
+	if calls := len(env.provider.Requests()); calls != 1 {
+		t.Errorf("maker was called %d times, want once without retries", calls)
+	}
+}
+
+func TestGenerateQuizStopsAtBudget(t *testing.T) {
+	env := newTestEnv(t)
+	generator := env.generator(t)
+
+	quiz, err := generator.GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 20, Budget: Budget{MaxTokens: 4000}})
+	if !errors.Is(err, ErrInsufficientQuestions) || !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("GenerateQuiz error = %v, want ErrInsufficientQuestions caused by ErrBudgetExhausted", err)
+	}
+	if quiz == nil || len(quiz.Questions) == 0 || len(quiz.Questions) >= 20 || quiz.TotalQuestions != len(quiz.Questions) {
+		t.Errorf("quiz has %d of %d questions, want the ones accepted before the budget ran out", quiz.TotalQuestions, len(quiz.Questions))
+	}
+	// Only the call that crossed the limit may overshoot it
+	if tokens := generator.Usage().TotalTokens(); tokens > 8000 {
+		t.Errorf("generator used %d tokens of a 4000 token budget", tokens)
+	}
+}
+
+func TestGenerateQuizReturnsPartialQuiz(t *testing.T) {
+	for _, accepted := range []int{2, 0} {
+		t.Run(fmt.Sprintf("%d accepted", accepted), func(t *testing.T) {
+			env := newTestEnv(t)
+			var mu sync.Mutex
+			checks := 0
+			env.provider.Handle("evaluate_question", func(req ChatRequest) (string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				checks++
+				if checks <= accepted {
+					return `{"action":"accept","reason":"Fine"}`, nil
+				}
+				return `{"action":"reject","reason":"Off topic"}`, nil
+			})
+			generator := env.generator(t)
+
+			quiz, err := generator.GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 4})
+			if !errors.Is(err, ErrInsufficientQuestions) {
+				t.Errorf("GenerateQuiz error = %v, want ErrInsufficientQuestions", err)
+			}
+			if accepted == 0 && quiz != nil {
+				t.Errorf("GenerateQuiz returned a quiz without questions")
+			}
+			if accepted > 0 && (quiz == nil || len(quiz.Questions) != accepted || quiz.TotalQuestions != accepted) {
+				t.Errorf("GenerateQuiz returned %+v, want the %d accepted questions", quiz, accepted)
+			}
+			// Running out of questions to request isn't a failure of its own
+			if result := generator.Result(); result.Err != nil || result.Stats.Accepted != accepted || result.Stats.Rejected == 0 || result.Stats.Requested > 12 {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestGenerateQuizLintsBeforeChecking(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.EnqueueQuestions(
+		Question{Text: "Which volcano buried Pompeii?", Options: []string{"Vesuvius", "Etna", "Hekla", "Fuji"}, Explanation: "It erupted in 79 AD."},
+		Question{Text: "Which volcano is the tallest?", Options: []string{"Mauna Kea", "Etna", "Etna", "Fuji"}, Explanation: "It repeats an option."},
+		Question{Text: "Where is Hekla?", Options: []string{"Iceland", "Italy", "Japan", "Chile"}, Explanation: "Hekla is in southern Iceland."},
+	)
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 3})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	for _, question := range quiz.Questions {
+		if strings.Contains(question.Text, "tallest") {
+			t.Errorf("quiz includes %q with options %q", question.Text, question.Options)
+		}
+	}
+	for _, req := range env.provider.Requests() {
+		if req.Tool.Name == "evaluate_question" && strings.Contains(req.Messages[len(req.Messages)-1].Content, "tallest") {
+			t.Errorf("question failing the linter was sent to the checker")
+		}
+	}
+}
+
+func TestGenerateQuizMixesQuestionTypes(t *testing.T) {
+	env := newTestEnv(t)
+	types := []QuestionType{QuestionSingleChoice, QuestionTrueFalse, QuestionMultiSelect}
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 6, QuestionTypes: types, NumOptions: 5})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	perType := make(map[QuestionType]int)
+	for _, question := range quiz.Questions {
+		perType[question.Kind()]++
+		switch question.Kind() {
+		case QuestionTrueFalse:
+			// True/false options aren't shuffled
+			if len(question.Options) != 2 || question.Options[0] != "True" || question.CorrectAnswer != 0 {
+				t.Errorf("true/false question %q has options %q and answer %d", question.Text, question.Options, question.CorrectAnswer)
+			}
+		case QuestionMultiSelect:
+			// The shuffle must have kept track of both correct options
+			if len(question.CorrectAnswers) != 2 || question.CorrectAnswer != question.CorrectAnswers[0] {
+				t.Fatalf("multi-select question %q has correct answers %v", question.Text, question.CorrectAnswers)
+			}
+			for _, index := range question.CorrectAnswers {
+				if !strings.HasPrefix(question.Options[index], "Answer") {
+					t.Errorf("multi-select question %q marks %q correct", question.Text, question.Options[index])
+				}
+			}
+		default:
+			if answer := question.Options[question.CorrectAnswer]; !strings.HasPrefix(answer, "Answer") {
+				t.Errorf("question %q has correct answer %q", question.Text, answer)
+			}
+		}
+	}
+	if len(perType) != 3 {
+		t.Errorf("questions per type = %v, want every requested type", perType)
+	}
+
+	// The maker is told which types to write and how many options to give
+	prompt := env.provider.Requests()[0].Messages[1].Content
+	if !strings.Contains(prompt, "single_choice, true_false, multi_select") || !strings.Contains(prompt, "exactly 5 options") {
+		t.Errorf("maker prompt doesn't ask for the types and option count:\n%s", prompt)
+	}
+}
+
+func TestGenerateQuizArrangesQuestions(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Verification = VerificationConfig{Enabled: true}
+		// A prefetched batch only asks for the default type
+		cfg.LowWaterMark = 0
+	}))
+	types := []QuestionType{QuestionOrdering, QuestionMatching}
+
+	// Placeholder questions of the same type are duplicates, so ask for one of each
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 2, QuestionTypes: types})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	if len(quiz.Questions) != 2 || quiz.Questions[0].Kind() == quiz.Questions[1].Kind() {
+		t.Fatalf("questions = %+v, want an ordering and a matching question", quiz.Questions)
+	}
+	want := map[QuestionType]string{
+		QuestionOrdering: "Step 1 of 4 → Step 2 of 4 → Step 3 of 4 → Step 4 of 4",
+		QuestionMatching: "Term 1: Meaning 1; Term 2: Meaning 2; Term 3: Meaning 3; Term 4: Meaning 4",
+	}
+	for _, question := range quiz.Questions {
+		// The shuffle must have kept track of the order and pairs
+		if got := question.CorrectText(); got != want[question.Kind()] {
+			t.Errorf("%s question %q has correct answer %q, want %q", question.Kind(), question.Text, got, want[question.Kind()])
+		}
+		if question.Verification == nil || !question.Verification.Agreed {
+			t.Errorf("%s question %q verification = %+v, want the solver to agree", question.Kind(), question.Text, question.Verification)
+		}
+	}
+}
+
+func TestGenerateQuizQuotesSource(t *testing.T) {
+	source := "Shield volcanoes are built almost entirely of fluid lava flows.\nThey have gentle slopes."
+	env := newTestEnv(t)
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 2, SourceMaterial: source})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	for _, question := range quiz.Questions {
+		if question.Source == nil || !question.Source.Found() || source[question.Source.Start:question.Source.End] != question.Source.Quote {
+			t.Errorf("question %q doesn't point at its quote in the source: %+v", question.Text, question.Source)
+		}
+	}
+}
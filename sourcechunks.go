@@ -0,0 +1,66 @@
+package quizgenerator
+
+import (
+	"strings"
+)
+
+// SourceChunk is a contiguous piece of the source material
+type SourceChunk struct {
+	Index int    // Position among the chunks, from 0
+	Start int    // Byte offset of Text in the full source material
+	Text  string // The chunk itself
+}
+
+// ChunkSource splits source material into chunks of at most size bytes,
+// breaking between paragraphs where possible, then between sentences, then
+// between words. size <= 0 or a short source returns a single chunk.
+func ChunkSource(source string, size int) []SourceChunk {
+	if size <= 0 || len(source) <= size {
+		return []SourceChunk{{Index: 0, Start: 0, Text: source}}
+	}
+
+	var chunks []SourceChunk
+	start := 0
+	for start < len(source) {
+		// Skip whitespace left over from the previous break
+		for start < len(source) && strings.ContainsRune(" \t\r\n", rune(source[start])) {
+			start++
+		}
+		if start >= len(source) {
+			break
+		}
+
+		end := len(source)
+		if end-start > size {
+			end = chunkBreak(source[start:start+size]) + start
+		}
+		chunks = append(chunks, SourceChunk{
+			Index: len(chunks),
+			Start: start,
+			Text:  strings.TrimRight(source[start:end], " \t\r\n"),
+		})
+		start = end
+	}
+	return chunks
+}
+
+// chunkBreak returns where to end a chunk within window: after the last
+// paragraph break, sentence end or space in its second half, or at the end of
+// window if there is none
+func chunkBreak(window string) int {
+	half := len(window) / 2
+	for _, sep := range []string{"\n\n", "\n", ". ", "? ", "! ", " "} {
+		if i := strings.LastIndex(window, sep); i >= half {
+			return i + len(sep)
+		}
+	}
+	// Don't split a UTF-8 sequence
+	end := len(window)
+	for end > 0 && window[end-1]&0xC0 == 0x80 {
+		end--
+	}
+	if end > 0 && window[end-1] >= 0xC0 {
+		end--
+	}
+	return end
+}
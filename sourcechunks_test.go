@@ -0,0 +1,115 @@
+package quizgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkSourceShort(t *testing.T) {
+	for _, size := range []int{0, -1, 100} {
+		chunks := ChunkSource("A short source.", size)
+		if len(chunks) != 1 || chunks[0].Text != "A short source." || chunks[0].Start != 0 {
+			t.Errorf("ChunkSource with size %d = %+v, want the whole source as one chunk", size, chunks)
+		}
+	}
+}
+
+func TestChunkSourceBreaks(t *testing.T) {
+	paragraph := strings.Repeat("Sentence about something. ", 8)
+	source := strings.Join([]string{paragraph, paragraph, paragraph, paragraph}, "\n\n")
+	size := len(paragraph) + 50
+
+	chunks := ChunkSource(source, size)
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks, want one per paragraph", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if len(chunk.Text) > size {
+			t.Errorf("chunk %d is %d bytes, more than %d", i, len(chunk.Text), size)
+		}
+		if source[chunk.Start:chunk.Start+len(chunk.Text)] != chunk.Text {
+			t.Errorf("chunk %d text doesn't match the source at its start offset", i)
+		}
+		if chunk.Text != strings.TrimSpace(paragraph) {
+			t.Errorf("chunk %d = %q, want a whole paragraph", i, chunk.Text)
+		}
+	}
+}
+
+func TestChunkSourceCoversSource(t *testing.T) {
+	source := strings.Repeat("naïve café résumé ", 200)
+	chunks := ChunkSource(source, 97)
+
+	var words []string
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk.Text) {
+			t.Fatalf("chunk %d splits a UTF-8 sequence", chunk.Index)
+		}
+		if len(chunk.Text) > 97 {
+			t.Errorf("chunk %d is %d bytes", chunk.Index, len(chunk.Text))
+		}
+		words = append(words, strings.Fields(chunk.Text)...)
+	}
+	if got, want := strings.Join(words, " "), strings.Join(strings.Fields(source), " "); got != want {
+		t.Errorf("chunks don't cover the source word for word")
+	}
+}
+
+func TestChunkSourceWithoutSpaces(t *testing.T) {
+	source := strings.Repeat("é", 100)
+	chunks := ChunkSource(source, 15)
+
+	var sb strings.Builder
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk.Text) {
+			t.Fatalf("chunk %d splits a UTF-8 sequence", chunk.Index)
+		}
+		sb.WriteString(chunk.Text)
+	}
+	if sb.String() != source {
+		t.Errorf("chunks don't reassemble into the source")
+	}
+}
+
+func TestGenerateQuizSpreadsAcrossChunks(t *testing.T) {
+	paragraphs := []string{
+		"Shield volcanoes are built almost entirely of fluid lava flows.",
+		"Stratovolcanoes are built up of layers of hardened lava and ash.",
+		"Cinder cones are the simplest type of volcano.",
+	}
+	for i, paragraph := range paragraphs {
+		paragraphs[i] = paragraph + "\n" + strings.Repeat("More detail follows. ", 5)
+	}
+	source := strings.Join(paragraphs, "\n\n")
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.ChunkChars = len(paragraphs[0]) + 10
+		cfg.Workers = 1
+	}))
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 3, SourceMaterial: source})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	paragraph := make(map[int]bool)
+	for _, question := range quiz.Questions {
+		if question.Source == nil || !question.Source.Found() || source[question.Source.Start:question.Source.End] != question.Source.Quote {
+			t.Fatalf("question %q doesn't point at its quote in the source: %+v", question.Text, question.Source)
+		}
+		paragraph[strings.Count(source[:question.Source.Start], "\n\n")] = true
+	}
+	if len(paragraph) != 3 {
+		t.Errorf("questions quote paragraphs %v, want one question from each", paragraph)
+	}
+
+	// Each chunk gets a conversation of its own
+	for _, req := range env.provider.Requests() {
+		if req.Tool.Name == "submit_questions" && len(req.Messages) != 2 {
+			t.Errorf("maker request has %d messages, want a fresh conversation per chunk", len(req.Messages))
+		}
+	}
+}
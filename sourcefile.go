@@ -0,0 +1,383 @@
+package quizgenerator
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedSourceFormat is returned for source files whose extension isn't recognized
+var ErrUnsupportedSourceFormat = errors.New("unsupported source file format")
+
+// ErrSourceTooLarge is returned for compressed source files that expand to more than MaxExtractedBytes
+var ErrSourceTooLarge = errors.New("source file decompresses to too much data")
+
+// MaxExtractedBytes caps how much a source file may decompress to in total,
+// across every chapter of an EPUB or stream of a PDF, so a small crafted file
+// can't exhaust memory
+const MaxExtractedBytes = 64 << 20
+
+// SourceFileExtensions lists the file extensions ExtractText understands
+var SourceFileExtensions = []string{".txt", ".text", ".md", ".markdown", ".html", ".htm", ".xhtml", ".epub", ".pdf"}
+
+// ReadSourceFile reads a file from disk and extracts its text
+func ReadSourceFile(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read source file: %w", err)
+	}
+	return ExtractText(filepath.Base(filename), data)
+}
+
+// ExtractText returns the plain text of a source file, choosing the format by
+// the file name's extension
+func ExtractText(filename string, data []byte) (string, error) {
+	var text string
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".text":
+		text = string(data)
+	case ".md", ".markdown":
+		text = markdownText(string(data))
+	case ".html", ".htm", ".xhtml":
+		text, err = htmlText(data)
+	case ".epub":
+		text, err = epubText(data)
+	case ".pdf":
+		text, err = pdfText(data)
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedSourceFormat, filename)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from %s: %w", filename, err)
+	}
+
+	text = cleanExtractedText(text)
+	if text == "" {
+		return "", fmt.Errorf("no text found in %s", filename)
+	}
+	return text, nil
+}
+
+var (
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+	lineSpaceRegexp  = regexp.MustCompile(`[ \t\f\v]+`)
+)
+
+// cleanExtractedText makes text valid UTF-8, collapses runs of spaces and blank lines and trims it
+func cleanExtractedText(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(lineSpaceRegexp.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinesRegexp.ReplaceAllString(text, "\n\n"))
+}
+
+var (
+	mdFenceRegexp    = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$")
+	mdImageRegexp    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRegexp     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdRefLinkRegexp  = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	mdRefDefRegexp   = regexp.MustCompile(`(?m)^[ \t]*\[[^\]]+\]:[ \t]+\S+.*$`)
+	mdHeadingRegexp  = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+(.*?)[ \t]*#*[ \t]*$`)
+	mdSetextRegexp   = regexp.MustCompile(`(?m)^[ \t]*(=+|-+)[ \t]*$`)
+	mdQuoteRegexp    = regexp.MustCompile(`(?m)^[ \t]*>[ \t]?`)
+	mdListRegexp     = regexp.MustCompile(`(?m)^([ \t]*)([-*+]|\d+[.)])[ \t]+`)
+	mdEmphasisRegexp = regexp.MustCompile(`(\*\*|__|\*|_|~~)([^*_~\n]+)(\*\*|__|\*|_|~~)`)
+	mdCodeRegexp     = regexp.MustCompile("`([^`]*)`")
+	htmlTagRegexp    = regexp.MustCompile(`<[^>]+>`)
+)
+
+// markdownText strips Markdown syntax, keeping the text of headings, links, lists and code
+func markdownText(md string) string {
+	md = mdFenceRegexp.ReplaceAllString(md, "")
+	md = mdRefDefRegexp.ReplaceAllString(md, "")
+	md = mdImageRegexp.ReplaceAllString(md, "$1")
+	md = mdLinkRegexp.ReplaceAllString(md, "$1")
+	md = mdRefLinkRegexp.ReplaceAllString(md, "$1")
+	md = mdHeadingRegexp.ReplaceAllString(md, "$1")
+	md = mdSetextRegexp.ReplaceAllString(md, "")
+	md = mdQuoteRegexp.ReplaceAllString(md, "")
+	md = mdListRegexp.ReplaceAllString(md, "$1")
+	md = mdEmphasisRegexp.ReplaceAllString(md, "$2")
+	md = mdCodeRegexp.ReplaceAllString(md, "$1")
+	return htmlTagRegexp.ReplaceAllString(md, "")
+}
+
+// Elements whose content is never source text. Script and style bodies are
+// removed before parsing since they may contain unescaped markup characters.
+var (
+	htmlRawRegexp     = regexp.MustCompile(`(?is)<(script|style|noscript|template)\b.*?</(script|style|noscript|template)\s*>|<!--.*?-->`)
+	htmlSkipElements  = map[string]bool{"head": true, "nav": true, "header": true, "footer": true, "aside": true, "form": true, "button": true, "svg": true, "iframe": true, "select": true, "menu": true}
+	htmlBlockElements = map[string]bool{
+		"p": true, "div": true, "br": true, "li": true, "tr": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"section": true, "article": true, "main": true, "blockquote": true, "pre": true, "table": true, "ul": true, "ol": true, "dl": true,
+		"dt": true, "dd": true, "figure": true, "figcaption": true, "hr": true, "title": true,
+	}
+	htmlBoilerplateRegexp = regexp.MustCompile(`(?i)\b(nav|navbar|menu|sidebar|footer|header|cookie|banner|breadcrumb|advert|ads|social|share|comments?|related|subscribe|popup|modal)\b`)
+)
+
+// htmlText extracts the readable text of an HTML document. Navigation,
+// headers, footers and elements whose class or id looks like boilerplate are
+// dropped, and if the page has a main or article element only its text is used.
+func htmlText(data []byte) (string, error) {
+	source := htmlRawRegexp.ReplaceAllString(string(data), " ")
+
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var all, main strings.Builder
+	var stack []string // Open elements, to match end tags to start tags
+	skipDepth := 0     // Depth in stack where skipping started, 0 when not skipping
+	mainDepth := 0     // Depth in stack of the main or article element, 0 outside it
+
+	write := func(s string) {
+		if skipDepth > 0 {
+			return
+		}
+		all.WriteString(s)
+		if mainDepth > 0 {
+			main.WriteString(s)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Keep what was extracted before the markup became unreadable
+			if all.Len() == 0 {
+				return "", err
+			}
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			stack = append(stack, name)
+			if skipDepth == 0 && (htmlSkipElements[name] || htmlIsBoilerplate(t.Attr)) {
+				skipDepth = len(stack)
+			}
+			if mainDepth == 0 && (name == "main" || name == "article") {
+				mainDepth = len(stack)
+			}
+			if htmlBlockElements[name] {
+				write("\n")
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			// Pop to the matching start tag, tolerating unclosed elements
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] != name {
+					continue
+				}
+				if htmlBlockElements[name] {
+					write("\n")
+				}
+				if skipDepth > i {
+					skipDepth = 0
+				}
+				if mainDepth > i {
+					mainDepth = 0
+				}
+				stack = stack[:i]
+				break
+			}
+		case xml.CharData:
+			write(string(t))
+		}
+	}
+
+	if strings.TrimSpace(main.String()) != "" {
+		return main.String(), nil
+	}
+	return all.String(), nil
+}
+
+// htmlIsBoilerplate reports whether an element's class, id or role marks it as page furniture
+func htmlIsBoilerplate(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		switch strings.ToLower(attr.Name.Local) {
+		case "class", "id", "role":
+			if htmlBoilerplateRegexp.MatchString(attr.Value) {
+				return true
+			}
+		case "hidden", "aria-hidden":
+			if attr.Value != "false" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// epubText extracts the text of an EPUB's chapters in reading order
+func epubText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open EPUB archive: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	limit := newExtractLimit()
+	chapters, err := epubSpine(files, limit)
+	if errors.Is(err, ErrSourceTooLarge) {
+		return "", err
+	}
+	if err != nil {
+		// Without a readable package document, fall back to every HTML file in name order
+		VerboseLog("Failed to read EPUB spine, using all HTML files: %v", err)
+		chapters = nil
+		for name := range files {
+			switch strings.ToLower(path.Ext(name)) {
+			case ".html", ".htm", ".xhtml":
+				chapters = append(chapters, name)
+			}
+		}
+		sort.Strings(chapters)
+	}
+
+	var sb strings.Builder
+	for _, name := range chapters {
+		file, ok := files[name]
+		if !ok {
+			continue
+		}
+		content, err := readZipFile(file, limit)
+		if err != nil {
+			return "", err
+		}
+		text, err := htmlText(content)
+		if err != nil {
+			VerboseLog("Skipping unreadable EPUB chapter %s: %v", name, err)
+			continue
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n\n")
+	}
+	return sb.String(), nil
+}
+
+// epubSpine returns the archive paths of an EPUB's content documents in reading order
+func epubSpine(files map[string]*zip.File, limit *extractLimit) ([]string, error) {
+	containerFile, ok := files["META-INF/container.xml"]
+	if !ok {
+		return nil, fmt.Errorf("missing META-INF/container.xml")
+	}
+	containerData, err := readZipFile(containerFile, limit)
+	if err != nil {
+		return nil, err
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(containerData, &container); err != nil {
+		return nil, fmt.Errorf("failed to parse container.xml: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("container.xml lists no package document")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	opfFile, ok := files[opfPath]
+	if !ok {
+		return nil, fmt.Errorf("missing package document %s", opfPath)
+	}
+	opfData, err := readZipFile(opfFile, limit)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Items []struct {
+			ID   string `xml:"id,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"manifest>item"`
+		Refs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(opfData, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package document: %w", err)
+	}
+
+	hrefs := make(map[string]string)
+	for _, item := range pkg.Items {
+		hrefs[item.ID] = item.Href
+	}
+	base := path.Dir(opfPath)
+	var chapters []string
+	for _, ref := range pkg.Refs {
+		if href, ok := hrefs[ref.IDRef]; ok {
+			chapters = append(chapters, path.Join(base, href))
+		}
+	}
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("package document has an empty spine")
+	}
+	return chapters, nil
+}
+
+// readZipFile returns the uncompressed contents of a file in a zip archive,
+// counting them against limit
+func readZipFile(file *zip.File, limit *extractLimit) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+	data, err := limit.readAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	return data, nil
+}
+
+// extractLimit tracks how much more a source file may decompress to
+type extractLimit struct {
+	remaining int64
+}
+
+func newExtractLimit() *extractLimit {
+	return &extractLimit{remaining: MaxExtractedBytes}
+}
+
+// readAll reads r to the end, failing with ErrSourceTooLarge once the limit
+// is used up. Like io.ReadAll it returns what was read before any other error.
+func (l *extractLimit) readAll(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, l.remaining+1))
+	if int64(len(data)) > l.remaining {
+		l.remaining = 0
+		return nil, fmt.Errorf("%w: more than %d MB", ErrSourceTooLarge, MaxExtractedBytes>>20)
+	}
+	l.remaining -= int64(len(data))
+	return data, err
+}
@@ -0,0 +1,146 @@
+package quizgenerator
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHTMLText(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title>Page title</title><style>p { color: red; }</style></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<div class="cookie-banner">We use cookies</div>
+<main>
+<h1>Volcanoes</h1>
+<p>Magma &amp; ash erupt from vents.<br>Lava cools into rock.</p>
+<script>if (a < b) { document.write("<p>hidden</p>"); }</script>
+<ul><li>Shield</li><li>Stratovolcano</li></ul>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+	text, err := htmlText([]byte(page))
+	if err != nil {
+		t.Fatalf("htmlText failed: %v", err)
+	}
+	text = cleanExtractedText(text)
+	for _, want := range []string{"Volcanoes", "Magma & ash erupt from vents.\n\nLava cools into rock.", "Shield\n\nStratovolcano"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q doesn't contain %q", text, want)
+		}
+	}
+	for _, unwanted := range []string{"Home", "cookies", "hidden", "color", "Copyright", "Page title"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("text %q contains %q", text, unwanted)
+		}
+	}
+}
+
+func TestHTMLTextWithoutMain(t *testing.T) {
+	text, err := htmlText([]byte(`<body><header>Site</header><p>First</p><p>Second<p>Unclosed</body>`))
+	if err != nil {
+		t.Fatalf("htmlText failed: %v", err)
+	}
+	if got := cleanExtractedText(text); got != "First\n\nSecond\nUnclosed" {
+		t.Errorf("text = %q", got)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	text, err := ExtractText("notes.md", []byte("# Heading\n\nSome **bold** text and a [link](http://example.com).\n\n- item"))
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+	if text != "Heading\n\nSome bold text and a link.\n\nitem" {
+		t.Errorf("markdown text = %q", text)
+	}
+
+	if _, err := ExtractText("slides.pptx", []byte("data")); !errors.Is(err, ErrUnsupportedSourceFormat) {
+		t.Errorf("ExtractText error = %v, want ErrUnsupportedSourceFormat", err)
+	}
+	if _, err := ExtractText("empty.txt", []byte(" \n\t")); err == nil {
+		t.Errorf("ExtractText accepted a file with no text")
+	}
+}
+
+// testEPUB builds an EPUB whose spine lists the chapters in order
+func testEPUB(t *testing.T, chapters ...string) []byte {
+	t.Helper()
+	return testEPUBWithFiles(t, nil, chapters...)
+}
+
+// testEPUBWithFiles builds an EPUB like testEPUB that also holds the given
+// files, such as images; chapters are stored in OEBPS/text
+func testEPUBWithFiles(t *testing.T, files map[string][]byte, chapters ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	add := func(name, content string) {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	add("mimetype", "application/epub+zip")
+	add("META-INF/container.xml", `<?xml version="1.0"?>
+<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`)
+	var manifest, spine strings.Builder
+	for i := range chapters {
+		id := string(rune('a' + i))
+		manifest.WriteString(`<item id="` + id + `" href="text/` + id + `.xhtml"/>`)
+		spine.WriteString(`<itemref idref="` + id + `"/>`)
+	}
+	add("OEBPS/content.opf", `<package><manifest>`+manifest.String()+`</manifest><spine>`+spine.String()+`</spine></package>`)
+	// Store the chapters in reverse so only the spine gives their order
+	for i := len(chapters) - 1; i >= 0; i-- {
+		add("OEBPS/text/"+string(rune('a'+i))+".xhtml", "<html><body><p>"+chapters[i]+"</p></body></html>")
+	}
+	for name, content := range files {
+		add(name, string(content))
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close EPUB: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEPUBText(t *testing.T) {
+	text, err := ExtractText("book.epub", testEPUB(t, "Chapter one.", "Chapter two.", "Chapter three."))
+	if err != nil {
+		t.Fatalf("ExtractText failed: %v", err)
+	}
+	if text != "Chapter one.\n\nChapter two.\n\nChapter three." {
+		t.Errorf("text = %q, want the chapters in spine order", text)
+	}
+}
+
+func TestEPUBTextDecompressionLimit(t *testing.T) {
+	// Chapters that are each under the limit but exceed it together
+	chapter := strings.Repeat("a", MaxExtractedBytes/2)
+	data := testEPUB(t, chapter, chapter, chapter)
+	if _, err := ExtractText("book.epub", data); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("ExtractText error = %v, want ErrSourceTooLarge", err)
+	}
+}
+
+func TestExtractLimit(t *testing.T) {
+	limit := &extractLimit{remaining: 10}
+	if data, err := limit.readAll(strings.NewReader("123456")); err != nil || string(data) != "123456" {
+		t.Fatalf("first read = %q, %v", data, err)
+	}
+	if data, err := limit.readAll(strings.NewReader("1234")); err != nil || string(data) != "1234" {
+		t.Fatalf("read up to the limit = %q, %v", data, err)
+	}
+	if _, err := limit.readAll(strings.NewReader("1")); !errors.Is(err, ErrSourceTooLarge) {
+		t.Errorf("read past the limit error = %v, want ErrSourceTooLarge", err)
+	}
+}
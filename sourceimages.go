@@ -0,0 +1,202 @@
+package quizgenerator
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxSourceImages bounds how many of a source file's images are offered to the maker
+const MaxSourceImages = 20
+
+// SourceImage is an image shown in a source file
+type SourceImage struct {
+	Alt  string
+	Data []byte
+}
+
+var (
+	mdImageRefRegexp = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	htmlImgRegexp    = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	htmlAttrRegexp   = regexp.MustCompile(`(?is)\b(src|alt)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// imageRef is an image reference found in a document: the path as written and its alt text
+type imageRef struct {
+	src string
+	alt string
+}
+
+// ReadSourceImages reads a source file from disk and returns the images it
+// shows, reading images it links to relative to its directory
+func ReadSourceImages(filename string) ([]SourceImage, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+	dir := filepath.Dir(filename)
+	return ExtractImages(filepath.Base(filename), data, func(ref string) ([]byte, error) {
+		// Only follow links to files beside or below the source file
+		if !filepath.IsLocal(filepath.FromSlash(ref)) {
+			return nil, fmt.Errorf("image %s is outside the source file's directory", ref)
+		}
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+	}), nil
+}
+
+// ExtractImages returns the images an HTML, Markdown or EPUB source file
+// shows, in order, with their alt text. An EPUB's images are read from inside
+// it; other files' images are read with open, given the path as written in the
+// file, and skipped when open is nil. Remote images, images that can't be
+// read or aren't PNG, JPEG, GIF or WebP, and other file formats are skipped.
+func ExtractImages(filename string, data []byte, open func(ref string) ([]byte, error)) []SourceImage {
+	var refs []imageRef
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		for _, match := range mdImageRefRegexp.FindAllStringSubmatch(string(data), -1) {
+			refs = append(refs, imageRef{src: match[2], alt: match[1]})
+		}
+	case ".html", ".htm", ".xhtml":
+		refs = htmlImageRefs(string(data))
+	case ".epub":
+		return epubImages(data)
+	default:
+		return nil
+	}
+	if open == nil {
+		return nil
+	}
+
+	var images []SourceImage
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		src, ok := localImagePath(ref.src)
+		if !ok || seen[src] {
+			continue
+		}
+		seen[src] = true
+		content, err := open(src)
+		if err != nil {
+			VerboseLog("Skipping source image %s: %v", src, err)
+			continue
+		}
+		if image, ok := newSourceImage(ref.alt, content); ok {
+			images = append(images, image)
+		}
+		if len(images) == MaxSourceImages {
+			break
+		}
+	}
+	return images
+}
+
+// htmlImageRefs finds the img elements of an HTML document
+func htmlImageRefs(document string) []imageRef {
+	var refs []imageRef
+	for _, tag := range htmlImgRegexp.FindAllString(document, -1) {
+		var ref imageRef
+		for _, attr := range htmlAttrRegexp.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(attr[2] + attr[3] + attr[4])
+			if strings.EqualFold(attr[1], "src") {
+				ref.src = value
+			} else {
+				ref.alt = value
+			}
+		}
+		if ref.src != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// epubImages returns the images an EPUB's chapters show, in reading order
+func epubImages(data []byte) []SourceImage {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	limit := newExtractLimit()
+	chapters, err := epubSpine(files, limit)
+	if err != nil {
+		VerboseLog("Failed to read EPUB spine, skipping its images: %v", err)
+		return nil
+	}
+
+	var images []SourceImage
+	seen := make(map[string]bool)
+	for _, chapter := range chapters {
+		file, ok := files[chapter]
+		if !ok {
+			continue
+		}
+		content, err := readZipFile(file, limit)
+		if errors.Is(err, ErrSourceTooLarge) {
+			return images
+		}
+		if err != nil {
+			continue
+		}
+		for _, ref := range htmlImageRefs(string(content)) {
+			src, ok := localImagePath(ref.src)
+			if !ok {
+				continue
+			}
+			// Chapters link images relative to themselves
+			src = path.Join(path.Dir(chapter), src)
+			imageFile, ok := files[src]
+			if !ok || seen[src] {
+				continue
+			}
+			seen[src] = true
+			imageData, err := readZipFile(imageFile, limit)
+			if errors.Is(err, ErrSourceTooLarge) {
+				return images
+			}
+			if err != nil {
+				continue
+			}
+			if image, ok := newSourceImage(ref.alt, imageData); ok {
+				images = append(images, image)
+			}
+			if len(images) == MaxSourceImages {
+				return images
+			}
+		}
+	}
+	return images
+}
+
+// localImagePath returns the path of an image link that points at a local
+// file, without any query or fragment
+func localImagePath(src string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(src))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// newSourceImage checks an image is small enough and of a format questions can show
+func newSourceImage(alt string, data []byte) (SourceImage, bool) {
+	if len(data) > MaxImageBytes {
+		return SourceImage{}, false
+	}
+	if _, ok := imageExtensions[http.DetectContentType(data)]; !ok {
+		return SourceImage{}, false
+	}
+	return SourceImage{Alt: strings.Join(strings.Fields(alt), " "), Data: data}, true
+}
@@ -0,0 +1,117 @@
+package quizgenerator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openTestFiles opens images from the given files by the path written in the document
+func openTestFiles(files map[string][]byte) func(ref string) ([]byte, error) {
+	return func(ref string) ([]byte, error) {
+		if data, ok := files[ref]; ok {
+			return data, nil
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+// altTexts returns the alt text of each image
+func altTexts(images []SourceImage) []string {
+	alts := make([]string, len(images))
+	for i, image := range images {
+		alts[i] = image.Alt
+	}
+	return alts
+}
+
+func TestExtractImagesMarkdown(t *testing.T) {
+	document := `# Volcanoes
+
+![A  shield
+volcano](images/shield.png "Mauna Loa")
+![Remote](https://example.com/cone.png)
+![Not an image](notes.txt)
+![Missing](images/missing.png)
+![Shield again](images/shield.png)
+![Stratovolcano](<images/strato.png?v=2>)
+`
+	files := map[string][]byte{"images/shield.png": testPNG, "images/strato.png": testPNG, "notes.txt": []byte("just text")}
+
+	images := ExtractImages("notes.md", []byte(document), openTestFiles(files))
+	if alts := altTexts(images); len(alts) != 2 || alts[0] != "A shield volcano" || alts[1] != "Stratovolcano" {
+		t.Fatalf("images have alt text %q, want the shield and stratovolcano once each", alts)
+	}
+	if !bytes.Equal(images[0].Data, testPNG) {
+		t.Errorf("image data wasn't read")
+	}
+
+	// Without a way to read linked images there are none
+	if images := ExtractImages("notes.md", []byte(document), nil); images != nil {
+		t.Errorf("ExtractImages without open = %d images", len(images))
+	}
+}
+
+func TestExtractImagesHTML(t *testing.T) {
+	document := `<p>Eruptions</p>
+<IMG alt='Lava &amp; ash' SRC="img/lava.png">
+<img src=img/plume.png>
+<img alt="No source">
+<img src="data:image/png;base64,AAAA" alt="Inline">`
+	files := map[string][]byte{"img/lava.png": testPNG, "img/plume.png": testPNG}
+
+	images := ExtractImages("page.html", []byte(document), openTestFiles(files))
+	if alts := altTexts(images); len(alts) != 2 || alts[0] != "Lava & ash" || alts[1] != "" {
+		t.Errorf("images have alt text %q, want the lava and plume images", alts)
+	}
+
+	if images := ExtractImages("slides.pptx", []byte(document), openTestFiles(files)); images != nil {
+		t.Errorf("ExtractImages read %d images from an unsupported format", len(images))
+	}
+}
+
+func TestExtractImagesEPUB(t *testing.T) {
+	epub := testEPUBWithFiles(t, map[string][]byte{
+		"OEBPS/images/crater.png": testPNG,
+		"OEBPS/images/cover.png":  testPNG,
+	},
+		`Calderas form when a volcano collapses. <img src="../images/crater.png" alt="A caldera"/>`,
+		`Nothing to see here. <img src="../images/missing.png" alt="Missing"/>`,
+	)
+
+	images := ExtractImages("book.epub", epub, nil)
+	if alts := altTexts(images); len(alts) != 1 || alts[0] != "A caldera" {
+		t.Errorf("images have alt text %q, want only the image a chapter shows", alts)
+	}
+}
+
+func TestReadSourceImagesStaysInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "inside.png"), testPNG, 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	source := filepath.Join(dir, "notes", "volcanoes.md")
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes", "beside.png"), testPNG, 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+	if err := os.WriteFile(source, []byte("![Beside](beside.png)\n![Outside](../inside.png)\n"), 0644); err != nil {
+		t.Fatalf("failed to write source file: %v", err)
+	}
+
+	images, err := ReadSourceImages(source)
+	if err != nil {
+		t.Fatalf("ReadSourceImages failed: %v", err)
+	}
+	if alts := altTexts(images); len(alts) != 1 || alts[0] != "Beside" {
+		t.Errorf("images have alt text %q, want only the image beside the source file", alts)
+	}
+
+	if _, err := ReadSourceImages(filepath.Join(dir, "missing.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadSourceImages of a missing file error = %v", err)
+	}
+}
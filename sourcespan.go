@@ -0,0 +1,78 @@
+package quizgenerator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SourceSpan is the excerpt of the source material that supports a question's correct answer
+type SourceSpan struct {
+	Quote string `json:"quote"`
+	Start int    `json:"start"` // Byte offset of the quote in the source material, -1 if it wasn't found
+	End   int    `json:"end"`   // Byte offset just past the quote, -1 if it wasn't found
+}
+
+// Found reports whether the quote was located in the source material
+func (ss *SourceSpan) Found() bool {
+	return ss.Start >= 0 && ss.End > ss.Start
+}
+
+// newSourceSpan locates quote in source. When found the quote is replaced by
+// the exact source text so citations are always verbatim.
+func newSourceSpan(source, quote string) *SourceSpan {
+	span := &SourceSpan{Quote: quote, Start: -1, End: -1}
+	if start, end, ok := locateQuote(source, quote); ok {
+		span.Quote = source[start:end]
+		span.Start = start
+		span.End = end
+	}
+	return span
+}
+
+// locateQuote finds quote in source ignoring case, surrounding quotation marks
+// and differences in whitespace, and returns its byte offsets in source
+func locateQuote(source, quote string) (int, int, bool) {
+	quote = strings.Trim(strings.TrimSpace(quote), `"'“”‘’`)
+	quote = strings.TrimSuffix(strings.TrimPrefix(quote, "..."), "...")
+	needle, _ := normalizeQuote(quote)
+	if strings.TrimSpace(needle) == "" {
+		return 0, 0, false
+	}
+	needle = strings.TrimSpace(needle)
+
+	haystack, offsets := normalizeQuote(source)
+	i := strings.Index(haystack, needle)
+	if i < 0 {
+		return 0, 0, false
+	}
+	last := i + len(needle) - 1
+	_, size := utf8.DecodeRuneInString(source[offsets[last]:])
+	return offsets[i], offsets[last] + size, true
+}
+
+// normalizeQuote lowercases text and collapses whitespace runs to single
+// spaces. offsets maps each byte of the result to the offset of the rune in
+// text it came from.
+func normalizeQuote(text string) (string, []int) {
+	var sb strings.Builder
+	var offsets []int
+	space := false
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if !space {
+				sb.WriteByte(' ')
+				offsets = append(offsets, i)
+			}
+			space = true
+			continue
+		}
+		space = false
+		lower := string(unicode.ToLower(r))
+		sb.WriteString(lower)
+		for range len(lower) {
+			offsets = append(offsets, i)
+		}
+	}
+	return sb.String(), offsets
+}
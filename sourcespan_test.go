@@ -0,0 +1,59 @@
+package quizgenerator
+
+import "testing"
+
+func TestLocateQuote(t *testing.T) {
+	const source = "The quick  brown fox\njumps over the lazy dog. Café au lait."
+	tests := []struct {
+		name  string
+		quote string
+		want  string // Source text the quote should be found at; empty if it shouldn't be found
+	}{
+		{"exact", "brown fox", "brown fox"},
+		{"case", "THE QUICK", "The quick"},
+		{"whitespace", "quick brown fox jumps", "quick  brown fox\njumps"},
+		{"quotation marks", `"lazy dog."`, "lazy dog."},
+		{"curly quotation marks", "“lazy dog”", "lazy dog"},
+		{"ellipsis", "...over the lazy...", "over the lazy"},
+		{"multibyte", "CAFÉ AU", "Café au"},
+		{"missing", "red fox", ""},
+		{"empty", `""`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := locateQuote(source, tt.quote)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("locateQuote(%q) found %q, want not found", tt.quote, source[start:end])
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("locateQuote(%q) not found, want %q", tt.quote, tt.want)
+			}
+			if got := source[start:end]; got != tt.want {
+				t.Errorf("locateQuote(%q) = %q, want %q", tt.quote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSourceSpan(t *testing.T) {
+	const source = "Water boils at 100 degrees Celsius at sea level."
+
+	span := newSourceSpan(source, "BOILS AT 100 degrees")
+	if !span.Found() {
+		t.Fatalf("span not found")
+	}
+	if span.Quote != "boils at 100 degrees" {
+		t.Errorf("Quote = %q, want the verbatim source text", span.Quote)
+	}
+
+	span = newSourceSpan(source, "freezes at 0 degrees")
+	if span.Found() || span.Start != -1 || span.End != -1 {
+		t.Errorf("span = %+v, want not found", span)
+	}
+	if span.Quote != "freezes at 0 degrees" {
+		t.Errorf("Quote = %q, want the quote kept as given", span.Quote)
+	}
+}
@@ -0,0 +1,191 @@
+package quizgenerator
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateCassettes = flag.Bool("update", false, "re-record the cassettes in testdata from the fake provider")
+
+// testEnv is what a test runs against: a config whose LLM calls go to a fake
+// provider, a temporary working directory that collects the LLM logs, and a
+// database in it if the test asks for one
+type testEnv struct {
+	dir      string
+	cfg      *Config
+	provider *FakeProvider
+	db       *DB
+}
+
+// testOption sets up part of a test environment
+type testOption func(t *testing.T, env *testEnv)
+
+// newTestEnv sets up a test environment and makes its directory the working directory
+func newTestEnv(t *testing.T, options ...testOption) *testEnv {
+	t.Helper()
+	env := &testEnv{dir: t.TempDir(), provider: NewFakeProvider()}
+	env.cfg = DefaultConfig()
+	env.cfg.Provider = ProviderOptions{Provider: env.provider}
+	env.cfg.Retry.BaseDelayMs = 1
+	for _, option := range options {
+		option(t, env)
+	}
+	if env.db != nil {
+		env.db.SetConfig(env.cfg)
+	}
+	t.Chdir(env.dir)
+	return env
+}
+
+// withConfig adjusts the config
+func withConfig(configure func(cfg *Config)) testOption {
+	return func(t *testing.T, env *testEnv) {
+		configure(env.cfg)
+	}
+}
+
+// withCassette answers from the named cassette in testdata, or records it
+// afresh from the fake provider when the tests run with -update
+func withCassette(name string) testOption {
+	return func(t *testing.T, env *testEnv) {
+		path, err := filepath.Abs(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("failed to resolve cassette path: %v", err)
+		}
+		if *updateCassettes {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("failed to remove old cassette: %v", err)
+			}
+			recorder, err := NewRecordingProvider(env.provider, path)
+			if err != nil {
+				t.Fatalf("NewRecordingProvider failed: %v", err)
+			}
+			env.cfg.Provider.Provider = recorder
+			return
+		}
+		provider, err := NewReplayProvider(path, false)
+		if err != nil {
+			t.Fatalf("NewReplayProvider failed: %v", err)
+		}
+		env.cfg.Provider.Provider = provider
+	}
+}
+
+// withDB creates an empty database in the environment's directory
+func withDB() testOption {
+	return func(t *testing.T, env *testEnv) {
+		env.openDB(t)
+	}
+}
+
+// withQuiz stores a quiz record for DB.GenerateQuiz to fill in
+func withQuiz(id, topic string, numQuestions int) testOption {
+	return func(t *testing.T, env *testEnv) {
+		quiz := &DBQuiz{ID: id, Topic: topic, NumQuestions: numQuestions, Status: "generating", CreatedAt: time.Now()}
+		if err := env.openDB(t).CreateQuiz(quiz); err != nil {
+			t.Fatalf("CreateQuiz failed: %v", err)
+		}
+	}
+}
+
+// withQuestions stores a completed quiz with n placeholder questions whose first option is the answer
+func withQuestions(quizID, topic, category string, created time.Time, n int) testOption {
+	return func(t *testing.T, env *testEnv) {
+		db := env.openDB(t)
+		quiz := &DBQuiz{ID: quizID, Topic: topic, Category: category, NumQuestions: n, Status: "completed", CreatedAt: created}
+		if err := db.CreateQuiz(quiz); err != nil {
+			t.Fatalf("CreateQuiz failed: %v", err)
+		}
+		for i := 1; i <= n; i++ {
+			question := &DBQuestion{
+				ID:          fmt.Sprintf("%s-q%d", quizID, i),
+				QuizID:      quizID,
+				QuestionNum: i,
+				Text:        fmt.Sprintf("Question %d about %s?", i, topic),
+				Options:     `["Right","Wrong A","Wrong B","Wrong C"]`,
+			}
+			if err := db.CreateQuestion(question); err != nil {
+				t.Fatalf("CreateQuestion failed: %v", err)
+			}
+		}
+	}
+}
+
+// withSolverAnswers makes the blind solver choose the options or matches
+// with the given texts, wherever the shuffle put them
+func withSolverAnswers(texts ...string) testOption {
+	return func(t *testing.T, env *testEnv) {
+		env.provider.Handle("answer_question", func(req ChatRequest) (string, error) {
+			options, matches := solverNumbers(req.Messages[len(req.Messages)-1].Content)
+			answers := make([]int, len(texts))
+			for i, text := range texts {
+				if number, ok := matches[text]; ok {
+					answers[i] = number
+				} else {
+					answers[i] = options[text]
+				}
+			}
+			return mustMarshal(map[string]interface{}{"answer": answers[0], "answers": answers, "confidence": 0.8}), nil
+		})
+	}
+}
+
+var solverItemRegexp = regexp.MustCompile(`^[ *](\d+)\. (.*)$`)
+
+// solverNumbers reads the numbers the solver prompt gives the options and, after "Matches:", the matches
+func solverNumbers(prompt string) (options, matches map[string]int) {
+	options, matches = make(map[string]int), make(map[string]int)
+	items := options
+	for _, line := range strings.Split(prompt, "\n") {
+		if line == "Matches:" {
+			items = matches
+			continue
+		}
+		if match := solverItemRegexp.FindStringSubmatch(line); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			items[match[2]] = number
+		}
+	}
+	return options, matches
+}
+
+// openDB returns the environment's database, creating it first if needed
+func (env *testEnv) openDB(t *testing.T) *DB {
+	t.Helper()
+	if env.db != nil {
+		return env.db
+	}
+	db, err := OpenDB(filepath.Join(env.dir, "quiz.db"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	if err := db.CreateTables(); err != nil {
+		t.Fatalf("CreateTables failed: %v", err)
+	}
+	env.db = db
+	return db
+}
+
+// generator returns a quiz generator talking to the environment's provider
+func (env *testEnv) generator(t *testing.T) *QuizGenerator {
+	t.Helper()
+	generator, err := NewQuizGenerator(env.cfg)
+	if err != nil {
+		t.Fatalf("NewQuizGenerator failed: %v", err)
+	}
+	return generator
+}
+
+// solver returns a blind solver talking to the environment's provider
+func (env *testEnv) solver() *QuestionSolver {
+	return NewQuestionSolver(env.provider, env.cfg.ResolveStage(env.cfg.Solver, DefaultModel))
+}
@@ -0,0 +1,167 @@
+package quizgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxSubtopics is the largest outline the planner asks for when the config doesn't say
+const DefaultMaxSubtopics = 8
+
+// PlanningConfig configures the subtopic outline drawn up before any questions are generated
+type PlanningConfig struct {
+	Enabled      bool `json:"enabled"`
+	MaxSubtopics int  `json:"max_subtopics,omitempty"` // 0 means DefaultMaxSubtopics
+}
+
+// Subtopic is one part of a quiz topic that questions are planned against
+type Subtopic struct {
+	Name      string `json:"name"`
+	Objective string `json:"objective"` // What answering its questions should show the player knows
+}
+
+// SubtopicCoverage reports how many questions a subtopic was allotted and how many were accepted
+type SubtopicCoverage struct {
+	Subtopic string `json:"subtopic"`
+	Quota    int    `json:"quota"`
+	Accepted int    `json:"accepted"`
+}
+
+// TopicPlanner outlines a quiz topic as subtopics so questions can be spread across them
+type TopicPlanner struct {
+	provider LLMProvider
+	config   StageConfig
+}
+
+// NewTopicPlanner creates a new topic planner using the given provider and stage config
+func NewTopicPlanner(provider LLMProvider, config StageConfig) *TopicPlanner {
+	return &TopicPlanner{
+		provider: provider,
+		config:   config,
+	}
+}
+
+// Plan asks for an outline of at most maxSubtopics subtopics of the request's topic.
+// Subtopics without a name and repeated names are dropped.
+func (tp *TopicPlanner) Plan(ctx context.Context, req GenerationRequest, maxSubtopics int, logger *LLMLogger) ([]Subtopic, error) {
+	if maxSubtopics <= 0 {
+		maxSubtopics = DefaultMaxSubtopics
+	}
+	// There is no point planning more subtopics than questions
+	maxSubtopics = min(maxSubtopics, max(req.NumQuestions, 1))
+
+	prompt, err := RenderPrompt(tp.config.Prompt, PromptData{
+		Topic:          req.Topic,
+		Difficulty:     req.Difficulty,
+		SourceMaterial: req.SourceMaterial,
+		BatchSize:      req.NumQuestions,
+		MaxSubtopics:   maxSubtopics,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		logger.LogLLMRequest("TopicPlanner", prompt)
+	}
+
+	resp, err := tp.provider.Chat(ctx, ChatRequest{
+		Stage:       "TopicPlanner",
+		Model:       tp.config.Model,
+		Temperature: tp.config.Temperature,
+		MaxTokens:   tp.config.MaxTokens,
+		Messages: []ChatMessage{
+			{
+				Role:    RoleSystem,
+				Content: tp.config.SystemPrompt,
+			},
+			{
+				Role:    RoleUser,
+				Content: prompt,
+			},
+		},
+		Tool: ToolDefinition{
+			Name:        "submit_outline",
+			Description: "Submit an outline of the subtopics a quiz should cover",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"subtopics": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"name": map[string]interface{}{
+									"type":        "string",
+									"description": "Short name of the subtopic",
+								},
+								"objective": map[string]interface{}{
+									"type":        "string",
+									"description": "What a player who answers this subtopic's questions correctly knows or understands",
+								},
+							},
+							"required": []string{"name", "objective"},
+						},
+					},
+				},
+				"required": []string{"subtopics"},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan subtopics: %w", err)
+	}
+
+	if logger != nil {
+		responseText := ""
+		if len(resp.ToolCalls) > 0 {
+			responseText = resp.ToolCalls[0].Arguments
+		}
+		logger.LogLLMResponse("TopicPlanner", responseText)
+	}
+
+	if len(resp.ToolCalls) == 0 {
+		return nil, fmt.Errorf("no tool calls in response")
+	}
+
+	toolCall := resp.ToolCalls[0]
+	if toolCall.Name != "submit_outline" {
+		return nil, fmt.Errorf("unexpected tool call: %s", toolCall.Name)
+	}
+
+	var toolArgs struct {
+		Subtopics []Subtopic `json:"subtopics"`
+	}
+	if err := json.Unmarshal([]byte(toolCall.Arguments), &toolArgs); err != nil {
+		return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	subtopics := make([]Subtopic, 0, len(toolArgs.Subtopics))
+	for _, subtopic := range toolArgs.Subtopics {
+		subtopic.Name = strings.TrimSpace(subtopic.Name)
+		subtopic.Objective = strings.TrimSpace(subtopic.Objective)
+		key := strings.ToLower(subtopic.Name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		subtopics = append(subtopics, subtopic)
+		if len(subtopics) == maxSubtopics {
+			break
+		}
+	}
+	if len(subtopics) == 0 {
+		return nil, fmt.Errorf("planner returned no subtopics")
+	}
+
+	if logger != nil {
+		for i, subtopic := range subtopics {
+			logger.Logf("Subtopic %d: %s - %s\n", i+1, subtopic.Name, subtopic.Objective)
+		}
+	}
+	VerboseLog("Planned %d subtopics for topic: %s", len(subtopics), req.Topic)
+	return subtopics, nil
+}
@@ -0,0 +1,85 @@
+package quizgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTopicPlannerPlan(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Handle("submit_outline", func(req ChatRequest) (string, error) {
+		return mustMarshal(map[string]interface{}{"subtopics": []Subtopic{
+			{Name: " Eruptions ", Objective: "Knows what drives an eruption"},
+			{Name: "", Objective: "Nameless"},
+			{Name: "eruptions", Objective: "Repeated"},
+			{Name: "Plate tectonics", Objective: "Knows where volcanoes form"},
+			{Name: "Famous volcanoes", Objective: "Knows notable eruptions"},
+		}}), nil
+	})
+	planner := NewTopicPlanner(env.provider, env.cfg.ResolveStage(env.cfg.Planner, DefaultModel))
+
+	subtopics, err := planner.Plan(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 10}, 2, nil)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(subtopics) != 2 || subtopics[0].Name != "Eruptions" || subtopics[1].Name != "Plate tectonics" {
+		t.Errorf("subtopics = %+v, want the first two distinct named subtopics", subtopics)
+	}
+	if prompt := env.provider.Requests()[0].Messages[1].Content; !strings.Contains(prompt, "between 2 and 2 subtopics") {
+		t.Errorf("planner prompt doesn't ask for at most 2 subtopics:\n%s", prompt)
+	}
+
+	// There is no point planning more subtopics than questions
+	if _, err := planner.Plan(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 1}, 0, nil); err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if prompt := env.provider.Requests()[1].Messages[1].Content; !strings.Contains(prompt, "between 2 and 1 subtopics") {
+		t.Errorf("planner prompt for one question doesn't cap the outline:\n%s", prompt)
+	}
+}
+
+func TestTopicPlannerRejectsEmptyOutline(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.Handle("submit_outline", func(req ChatRequest) (string, error) {
+		return `{"subtopics":[{"name":" ","objective":"Blank"}]}`, nil
+	})
+	planner := NewTopicPlanner(env.provider, env.cfg.ResolveStage(env.cfg.Planner, DefaultModel))
+
+	if _, err := planner.Plan(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 4}, 0, nil); err == nil {
+		t.Errorf("Plan accepted an outline without named subtopics")
+	}
+}
+
+func TestGenerateQuizSpreadsAcrossSubtopics(t *testing.T) {
+	env := newTestEnv(t, withConfig(func(cfg *Config) {
+		cfg.Planning.Enabled = true
+	}))
+
+	quiz, err := env.generator(t).GenerateQuiz(context.Background(), GenerationRequest{Topic: "Volcanoes", NumQuestions: 8})
+	if err != nil {
+		t.Fatalf("GenerateQuiz failed: %v", err)
+	}
+	perSubtopic := make(map[string]int)
+	for _, question := range quiz.Questions {
+		perSubtopic[question.Subtopic]++
+	}
+	if len(perSubtopic) != 4 || perSubtopic[""] != 0 {
+		t.Errorf("questions per subtopic = %v, want all four fake subtopics", perSubtopic)
+	}
+	if len(quiz.Coverage) != 4 {
+		t.Fatalf("coverage = %+v, want an entry per subtopic", quiz.Coverage)
+	}
+	for _, coverage := range quiz.Coverage {
+		if coverage.Quota != 2 || coverage.Accepted != 2 || perSubtopic[coverage.Subtopic] != 2 {
+			t.Errorf("coverage %+v with %d questions, want 2 of 2", coverage, perSubtopic[coverage.Subtopic])
+		}
+	}
+
+	// Each maker batch names the subtopic it is for
+	for _, req := range env.provider.Requests() {
+		if req.Tool.Name == "submit_questions" && !strings.Contains(req.Messages[len(req.Messages)-1].Content, "Fake subtopic") {
+			t.Errorf("maker prompt doesn't name a subtopic:\n%s", req.Messages[len(req.Messages)-1].Content)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package quizgenerator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Usage is the token count reported for a single LLM call
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// ModelPrice is the price of a model in US dollars per million tokens
+type ModelPrice struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+}
+
+// DefaultPrices returns the built-in price table
+func DefaultPrices() map[string]ModelPrice {
+	return map[string]ModelPrice{
+		DefaultModel:          {PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+		DefaultFastModel:      {PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+		DefaultEmbeddingModel: {PromptPerMillion: 0.02},
+	}
+}
+
+// Cost returns the dollar cost of the given usage at this price
+func (mp ModelPrice) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*mp.PromptPerMillion + float64(usage.CompletionTokens)*mp.CompletionPerMillion) / 1e6
+}
+
+// StageUsage is the accumulated usage of one pipeline stage
+type StageUsage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// UsageSummary is the accumulated usage of a quiz, broken down by stage
+type UsageSummary struct {
+	Stages           map[string]StageUsage `json:"stages"`
+	PromptTokens     int                   `json:"prompt_tokens"`
+	CompletionTokens int                   `json:"completion_tokens"`
+	CostUSD          float64               `json:"cost_usd"`
+}
+
+// TotalTokens returns prompt plus completion tokens
+func (us UsageSummary) TotalTokens() int {
+	return us.PromptTokens + us.CompletionTokens
+}
+
+// String formats the summary as one line per stage followed by the total
+func (us UsageSummary) String() string {
+	names := make([]string, 0, len(us.Stages))
+	for name := range us.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		stage := us.Stages[name]
+		sb.WriteString(fmt.Sprintf("%s: %d calls, %d prompt + %d completion tokens, $%.4f\n",
+			name, stage.Calls, stage.PromptTokens, stage.CompletionTokens, stage.CostUSD))
+	}
+	sb.WriteString(fmt.Sprintf("Total: %d prompt + %d completion tokens, $%.4f",
+		us.PromptTokens, us.CompletionTokens, us.CostUSD))
+	return sb.String()
+}
+
+// UsageTracker accumulates token usage and cost per stage
+type UsageTracker struct {
+	mu     sync.Mutex
+	prices map[string]ModelPrice
+	stages map[string]StageUsage
+}
+
+// NewUsageTracker creates a tracker that prices calls using the given table
+func NewUsageTracker(prices map[string]ModelPrice) *UsageTracker {
+	return &UsageTracker{
+		prices: prices,
+		stages: make(map[string]StageUsage),
+	}
+}
+
+// Record adds the usage of one call made by the given stage with the given model
+func (ut *UsageTracker) Record(stage, model string, usage Usage) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	su := ut.stages[stage]
+	su.Calls++
+	su.PromptTokens += usage.PromptTokens
+	su.CompletionTokens += usage.CompletionTokens
+	if price, ok := ut.prices[model]; ok {
+		su.CostUSD += price.Cost(usage)
+	} else if usage.PromptTokens+usage.CompletionTokens > 0 {
+		VerboseLog("No price configured for model %s, counting its cost as $0", model)
+	}
+	ut.stages[stage] = su
+}
+
+// Summary returns the usage recorded so far
+func (ut *UsageTracker) Summary() UsageSummary {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	summary := UsageSummary{Stages: make(map[string]StageUsage, len(ut.stages))}
+	for name, stage := range ut.stages {
+		summary.Stages[name] = stage
+		summary.PromptTokens += stage.PromptTokens
+		summary.CompletionTokens += stage.CompletionTokens
+		summary.CostUSD += stage.CostUSD
+	}
+	return summary
+}
+
+// UsageProvider records the usage of every call made through it
+type UsageProvider struct {
+	inner   LLMProvider
+	tracker *UsageTracker
+}
+
+// NewUsageProvider wraps inner so its calls are recorded in tracker
+func NewUsageProvider(inner LLMProvider, tracker *UsageTracker) *UsageProvider {
+	return &UsageProvider{
+		inner:   inner,
+		tracker: tracker,
+	}
+}
+
+// Chat forwards the call and records its usage under the request's stage
+func (up *UsageProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := up.inner.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	stage := req.Stage
+	if stage == "" {
+		stage = req.Tool.Name
+	}
+	up.tracker.Record(stage, req.Model, resp.Usage)
+	return resp, nil
+}
+
+// Embed forwards the call and records its usage under the request's stage
+func (up *UsageProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	resp, err := embed(ctx, up.inner, req)
+	if err != nil {
+		return nil, err
+	}
+	up.tracker.Record(req.Stage, req.Model, resp.Usage)
+	return resp, nil
+}
@@ -0,0 +1,53 @@
+package quizgenerator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestUsageTracker(t *testing.T) {
+	tracker := NewUsageTracker(map[string]ModelPrice{"gpt": {PromptPerMillion: 2, CompletionPerMillion: 8}})
+	tracker.Record("QuestionMaker", "gpt", Usage{PromptTokens: 1000, CompletionTokens: 500})
+	tracker.Record("QuestionMaker", "gpt", Usage{PromptTokens: 1000, CompletionTokens: 0})
+	// Models without a price are counted as free
+	tracker.Record("QuestionChecker", "local", Usage{PromptTokens: 300, CompletionTokens: 100})
+
+	summary := tracker.Summary()
+	maker := summary.Stages["QuestionMaker"]
+	if maker.Calls != 2 || maker.PromptTokens != 2000 || maker.CompletionTokens != 500 {
+		t.Errorf("maker usage = %+v", maker)
+	}
+	if math.Abs(maker.CostUSD-0.008) > 1e-9 {
+		t.Errorf("maker cost = %v, want 0.008", maker.CostUSD)
+	}
+	if checker := summary.Stages["QuestionChecker"]; checker.Calls != 1 || checker.CostUSD != 0 {
+		t.Errorf("checker usage = %+v", checker)
+	}
+	if summary.TotalTokens() != 2900 || math.Abs(summary.CostUSD-0.008) > 1e-9 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestUsageProvider(t *testing.T) {
+	tracker := NewUsageTracker(DefaultPrices())
+	provider := NewUsageProvider(NewFakeProvider(), tracker)
+
+	requests := []ChatRequest{
+		{Stage: "QuestionChecker", Model: DefaultModel, Tool: ToolDefinition{Name: "evaluate_question"}, Messages: []ChatMessage{{Role: RoleUser, Content: "Check this question"}}},
+		// Calls without a stage are counted under their tool
+		{Model: DefaultFastModel, Tool: ToolDefinition{Name: "check_duplicate"}, Messages: []ChatMessage{{Role: RoleUser, Content: "Is this a duplicate?"}}},
+	}
+	for _, req := range requests {
+		if _, err := provider.Chat(context.Background(), req); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+	}
+
+	summary := tracker.Summary()
+	for _, stage := range []string{"QuestionChecker", "check_duplicate"} {
+		if usage := summary.Stages[stage]; usage.Calls != 1 || usage.PromptTokens == 0 || usage.CostUSD == 0 {
+			t.Errorf("%s usage = %+v", stage, usage)
+		}
+	}
+}